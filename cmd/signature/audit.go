@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	flags2 "github.com/qiaopengjun5162/web3-wallet-sign/flags"
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+)
+
+func runAuditVerify(ctx *cli.Context) error {
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+	report, err := db.VerifyAudit(ctx.String(flags2.AuditKeyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("verify audit log: %w", err)
+	}
+	fmt.Printf("audit log verified: %d entries, head %d %s\n", report.Entries, report.Head.Seq, report.Head.Hash)
+	for _, anchor := range report.Anchors {
+		fmt.Printf("anchor %d %s signed by %s at %s\n", anchor.Seq, anchor.Hash, anchor.Pubkey, time.Unix(anchor.Time, 0).UTC().Format(time.RFC3339))
+	}
+	if len(report.Anchors) == 0 {
+		fmt.Println("the audit log is not anchored")
+	} else if last := report.Anchors[len(report.Anchors)-1]; last.Seq < report.Head.Seq {
+		fmt.Printf("entries %d to %d are not anchored yet\n", last.Seq+1, report.Head.Seq)
+	}
+	return nil
+}
+
+// runAuditExport writes the audit entries from --from to --to whose time is within [--since, --until) as JSON lines.
+func runAuditExport(ctx *cli.Context) error {
+	from, to := ctx.Uint64(flags2.AuditFromFlag.Name), ctx.Uint64(flags2.AuditToFlag.Name)
+	if to != 0 && to < from {
+		return fmt.Errorf("--to %d is before --from %d", to, from)
+	}
+	since, err := parseAuditTime(ctx.String(flags2.AuditSinceFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	until, err := parseAuditTime(ctx.String(flags2.AuditUntilFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+
+	var out io.Writer = os.Stdout
+	if file := ctx.String(flags2.AuditOutputFlag.Name); file != "" {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	encoder := json.NewEncoder(w)
+	var count int
+	var writeErr error
+	err = db.IterateAudit(from, func(entry *leveldb.AuditEntry) bool {
+		if to != 0 && entry.Seq > to {
+			return false
+		}
+		if (since != 0 && entry.Time < since) || (until != 0 && entry.Time >= until) {
+			return true
+		}
+		if writeErr = encoder.Encode(entry); writeErr != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("export audit log: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d audit entries\n", count)
+	return nil
+}
+
+// parseAuditTime parses an RFC3339 time to the nanoseconds of AuditEntry.Time, 0 when value is empty.
+func parseAuditTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixNano(), nil
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/version"
@@ -12,7 +17,10 @@ import (
 	"github.com/qiaopengjun5162/web3-wallet-sign/config"
 	flags2 "github.com/qiaopengjun5162/web3-wallet-sign/flags"
 	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
 	"github.com/qiaopengjun5162/web3-wallet-sign/services/rpc"
+	"github.com/qiaopengjun5162/web3-wallet-sign/tracing"
 )
 
 // Semantic holds the textual version string for major.minor.patch.
@@ -42,17 +50,183 @@ func runRpc(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycl
 	fmt.Println("running grpc services...")
 	cfg := config.NewConfig(ctx)
 	grpcServerCfg := &rpc.RpcServerConfig{
-		GrpcHostname: cfg.RPCServer.Host,
-		GrpcPort:     cfg.RPCServer.Port,
-		KeyName:      cfg.KeyName,
-		KeyPath:      cfg.CredentialsFile,
-		HsmEnable:    cfg.HsmEnable,
+		GrpcHostname:                 cfg.RPCServer.Host,
+		GrpcPort:                     cfg.RPCServer.Port,
+		KeyName:                      cfg.KeyName,
+		KeyPath:                      cfg.CredentialsFile,
+		HsmEnable:                    cfg.HsmEnable,
+		MetricsEnabled:               cfg.MetricsEnabled,
+		MetricsHostname:              cfg.MetricsServer.Host,
+		MetricsPort:                  cfg.MetricsServer.Port,
+		HealthEnabled:                cfg.HealthEnabled,
+		HealthHostname:               cfg.HealthServer.Host,
+		HealthPort:                   cfg.HealthServer.Port,
+		RestEnabled:                  cfg.RestEnabled,
+		RestHostname:                 cfg.RestServer.Host,
+		RestPort:                     cfg.RestServer.Port,
+		EthRpcEnabled:                cfg.EthRpcEnabled,
+		EthRpcHostname:               cfg.EthRpcServer.Host,
+		EthRpcPort:                   cfg.EthRpcServer.Port,
+		Web3SignerEnabled:            cfg.Web3SignerEnabled,
+		Web3SignerHostname:           cfg.Web3SignerServer.Host,
+		Web3SignerPort:               cfg.Web3SignerServer.Port,
+		Web3SignerConsumerToken:      cfg.Web3SignerConsumerToken,
+		Web3SignerAuthToken:          cfg.Web3SignerAuthToken,
+		Web3SignerGenesisForkVersion: cfg.Web3SignerGenesisForkVersion,
+		Tracing: tracing.Config{
+			Endpoint:    cfg.TracingEndpoint,
+			Insecure:    cfg.TracingInsecure,
+			SampleRatio: cfg.TracingSampleRatio,
+		},
+		BatchSignWorkers:      cfg.BatchSignWorkers,
+		StreamSignConcurrency: cfg.StreamSignConcurrency,
+		AdminToken:            cfg.AdminToken,
+		KeyDestructionDelay:   cfg.KeyDestructionDelay,
+		RequestIDRetention:    cfg.RequestIDRetention,
+		AuditKey:              cfg.AuditKey,
+		AuditAnchorInterval:   cfg.AuditAnchorInterval,
+	}
+	if cfg.ConsumersFile != "" {
+		consumers, err := rpc.LoadConsumers(cfg.ConsumersFile)
+		if err != nil {
+			log.Error("load consumers file", "file", cfg.ConsumersFile, "err", err)
+			return nil, err
+		}
+		grpcServerCfg.Consumers = consumers.Consumers
+		grpcServerCfg.DefaultLimits = consumers.DefaultLimits
+	}
+	if cfg.PolicyFile != "" {
+		txPolicy, err := policy.Load(cfg.PolicyFile)
+		if err != nil {
+			log.Error("load policy file", "file", cfg.PolicyFile, "err", err)
+			return nil, err
+		}
+		grpcServerCfg.Policy = txPolicy
+	}
+	return rpc.NewRpcServer(func() (*leveldb.Keys, error) {
+		db, err := leveldb.OpenKeyStore(cfg.DbBackend, cfg.LevelDbPath)
+		if err != nil {
+			log.Error("new key store db", "backend", cfg.DbBackend, "err", err)
+		}
+		return db, err
+	}, grpcServerCfg)
+}
+
+func runImportKey(ctx *cli.Context) error {
+	keyFile := ctx.String(flags2.ImportKeyFileFlag.Name)
+	var data []byte
+	var err error
+	if keyFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(keyFile)
+	}
+	if err != nil {
+		return fmt.Errorf("read key file: %w", err)
+	}
+
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+	if db.Sealed() {
+		return errors.New("key store is sealed, import the key through the importKey rpc of the unsealed service")
+	}
+	publicKey, err := rpc.ImportKey(db, ctx.String(flags2.ImportConsumerFlag.Name), &wallet.ImportKeyRequest{
+		Type:           ctx.String(flags2.ImportTypeFlag.Name),
+		Format:         ctx.String(flags2.ImportFormatFlag.Name),
+		Key:            strings.TrimSpace(string(data)),
+		Passphrase:     ctx.String(flags2.ImportPassphraseFlag.Name),
+		DerivationPath: ctx.String(flags2.ImportDerivationPathFlag.Name),
+		Labels:         ctx.StringSlice(flags2.ImportLabelsFlag.Name),
+	})
+	if err != nil {
+		return fmt.Errorf("import key: %w", err)
+	}
+	fmt.Println("pubkey:", publicKey.Pubkey)
+	fmt.Println("compress pubkey:", publicKey.CompressPubkey)
+	return nil
+}
+
+func runAssignKeyOwner(ctx *cli.Context) error {
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+	meta, err := db.AssignKeyOwner(strings.TrimPrefix(ctx.String(flags2.AssignPublicKeyFlag.Name), "0x"), ctx.String(flags2.AssignConsumerFlag.Name))
+	if err != nil {
+		return fmt.Errorf("assign key owner: %w", err)
+	}
+	fmt.Println("pubkey:", meta.Pubkey)
+	fmt.Println("consumer:", meta.Consumer)
+	return nil
+}
+
+func runBackup(ctx *cli.Context) error {
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, use the backupKeystore rpc while the service is running: %w", err)
+	}
+	defer db.Close()
+	file := ctx.String(flags2.BackupFileFlag.Name)
+	tmpFile := file + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	count, err := db.Backup(f, ctx.String(flags2.BackupPassphraseFlag.Name))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile)
+		return fmt.Errorf("backup key store: %w", err)
+	}
+	if err := os.Rename(tmpFile, file); err != nil {
+		return err
+	}
+	fmt.Printf("backup %d entries to %s\n", count, file)
+	return nil
+}
+
+func runRestore(ctx *cli.Context) error {
+	f, err := os.Open(ctx.String(flags2.BackupFileFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	backup, err := leveldb.ReadBackup(f, ctx.String(flags2.BackupPassphraseFlag.Name))
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	fmt.Printf("backup created at %s with %d entries\n", time.Unix(backup.CreatedAt, 0).UTC().Format(time.RFC3339), len(backup.Entries))
+
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+	dryRun := ctx.Bool(flags2.RestoreDryRunFlag.Name)
+	report, err := db.Restore(backup, ctx.Bool(flags2.RestoreForceFlag.Name), dryRun)
+	if report != nil {
+		for _, pubkey := range report.Added {
+			fmt.Println("add:", pubkey)
+		}
+		for _, pubkey := range report.Overwritten {
+			fmt.Println("overwrite:", pubkey)
+		}
+		for _, pubkey := range report.Conflicts {
+			fmt.Println("conflict:", pubkey)
+		}
+		fmt.Printf("added = %d, overwritten = %d, conflicts = %d, unchanged = %d, entries = %d, dry run = %t\n",
+			len(report.Added), len(report.Overwritten), len(report.Conflicts), report.Unchanged, report.Entries, dryRun)
 	}
-	db, err := leveldb.NewKeyStore(cfg.LevelDbPath)
 	if err != nil {
-		log.Error("new key store level db", "err", err)
+		return fmt.Errorf("restore key store: %w", err)
 	}
-	return rpc.NewRpcServer(db, grpcServerCfg)
+	return nil
 }
 
 func NewCli(GitCommit string, gitDate string) *cli.App {
@@ -70,6 +244,83 @@ func NewCli(GitCommit string, gitDate string) *cli.App {
 				Description: "Run rpc services",
 				Action:      cliapp.LifecycleCmd(runRpc),
 			},
+			{
+				Name:        "import-key",
+				Flags:       flags2.ImportKeyFlags,
+				Usage:       "Import a private key, keystore V3 file or mnemonic",
+				Description: "Import an existing private key (hex or WIF), Ethereum keystore V3 file or BIP39 mnemonic into the key store, the rpc service must be stopped",
+				Action:      runImportKey,
+			},
+			{
+				Name:        "assign-key-owner",
+				Flags:       flags2.AssignKeyOwnerFlags,
+				Usage:       "Assign a key without owner to a consumer",
+				Description: "Move a key without owner, such as a key migrated from before the consumer namespaces, into the namespace of a consumer, only the admin can use it until then, the rpc service must be stopped",
+				Action:      runAssignKeyOwner,
+			},
+			{
+				Name:        "backup",
+				Flags:       flags2.BackupFlags,
+				Usage:       "Export the key store to an encrypted backup file",
+				Description: "Export a consistent snapshot of the key store to an encrypted, checksummed backup file, the rpc service must be stopped",
+				Action:      runBackup,
+			},
+			{
+				Name:        "restore",
+				Flags:       flags2.RestoreFlags,
+				Usage:       "Restore the key store from an encrypted backup file",
+				Description: "Verify an encrypted backup file and restore it into the key store, existing keys are only overwritten with --force",
+				Action:      runRestore,
+			},
+			{
+				Name:        "shamir-split",
+				Flags:       flags2.ShamirSplitFlags,
+				Usage:       "Split a master secret into N shares with threshold K",
+				Description: "Split a hex encoded master secret (HD seed or key store encryption key) into N Shamir shares, any K of which recover it",
+				Action:      runShamirSplit,
+			},
+			{
+				Name:        "shamir-recover",
+				Flags:       flags2.ShamirRecoverFlags,
+				Usage:       "Recover a master secret from K shares entered one at a time",
+				Description: "Recover a master secret split by shamir-split, shares are read from stdin one per line and verified as they are entered",
+				Action:      runShamirRecover,
+			},
+			{
+				Name:        "init-seal",
+				Flags:       flags2.InitSealFlags,
+				Usage:       "Encrypt the key store with a master key split into N unseal shares",
+				Description: "Generate a master key, encrypt every private key of the key store with it and print its N Shamir shares, the rpc service then starts sealed until K shares are submitted, the rpc service must be stopped",
+				Action:      runInitSeal,
+			},
+			{
+				Name:        "unseal",
+				Flags:       flags2.UnsealFlags,
+				Usage:       "Submit unseal shares to the running rpc service",
+				Description: "Read unseal shares from stdin one per line and submit them to the running rpc service until the key store is unsealed",
+				Action:      runUnseal,
+			},
+			{
+				Name:        "seal",
+				Flags:       flags2.SealFlags,
+				Usage:       "Seal the running rpc service",
+				Description: "Seal the key store of the running rpc service, wiping the master key from its memory",
+				Action:      runSeal,
+			},
+			{
+				Name:        "audit-verify",
+				Flags:       flags2.AuditVerifyFlags,
+				Usage:       "Verify the hash chain and the anchors of the audit log",
+				Description: "Verify that no audit entry was modified or deleted and that every anchor is signed by the audit key, the rpc service must be stopped",
+				Action:      runAuditVerify,
+			},
+			{
+				Name:        "audit-export",
+				Flags:       flags2.AuditExportFlags,
+				Usage:       "Export a range of the audit log as JSON lines",
+				Description: "Export the audit entries within a range of sequence numbers and times as JSON lines, the rpc service must be stopped",
+				Action:      runAuditExport,
+			},
 			{
 				Name:        "version",
 				Usage:       "Show project version",
@@ -20,7 +20,7 @@ func main() {
 	app := NewCli(GitCommit, gitDate)
 	ctx := opio.WithInterruptBlocker(context.Background())
 	if err := app.RunContext(ctx, os.Args); err != nil {
-		log.Error("Application failed")
+		log.Error("Application failed", "err", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	flags2 "github.com/qiaopengjun5162/web3-wallet-sign/flags"
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// sealRequestTimeout bounds each request made to the rpc service by the unseal and seal commands.
+const sealRequestTimeout = 10 * time.Second
+
+func runInitSeal(ctx *cli.Context) error {
+	db, err := leveldb.OpenKeyStore(ctx.String(flags2.DbBackendFlag.Name), ctx.String(flags2.LevelDbPathFlag.Name))
+	if err != nil {
+		return fmt.Errorf("open key store, is the rpc service still running? %w", err)
+	}
+	defer db.Close()
+	shares, err := db.InitSeal(ctx.Int(flags2.ShamirSharesFlag.Name), ctx.Int(flags2.ShamirThresholdFlag.Name))
+	if err != nil {
+		return fmt.Errorf("init seal: %w", err)
+	}
+	fmt.Println("the key store is sealed, hand one share to each operator, they are not stored anywhere else")
+	fmt.Printf("group %08x: %d shares, any %d of them unseal the key store\n", shares[0].GroupID, shares[0].Total, shares[0].Threshold)
+	for _, share := range shares {
+		fmt.Printf("share %d/%d: %s\n", share.Index, share.Total, share)
+	}
+	return nil
+}
+
+func dialWalletService(ctx *cli.Context) (wallet.WalletServiceClient, func() error, error) {
+	conn, err := grpc.NewClient(ctx.String(flags2.RpcAddrFlag.Name), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return wallet.NewWalletServiceClient(conn), conn.Close, nil
+}
+
+// runUnseal submits the shares read from stdin one at a time, so that each operator can enter
+// their own share, until the rpc service reports that the key store is unsealed.
+func runUnseal(ctx *cli.Context) error {
+	client, closeConn, err := dialWalletService(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	reqCtx, cancel := context.WithTimeout(ctx.Context, sealRequestTimeout)
+	status, err := client.SealStatus(reqCtx, &wallet.SealStatusRequest{})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("get seal status: %w", err)
+	}
+	if !status.Initialized {
+		return errors.New(status.Msg)
+	}
+	if !status.Sealed {
+		fmt.Println("key store is already unsealed")
+		return nil
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	progress, threshold := status.Progress, status.Threshold
+	for {
+		fmt.Fprintf(os.Stderr, "enter unseal share (%d of %d submitted): ", progress, threshold)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr)
+			fmt.Printf("key store is still sealed, %d of %d shares submitted\n", progress, threshold)
+			return nil
+		}
+		share := strings.TrimSpace(scanner.Text())
+		if share == "" {
+			continue
+		}
+		reqCtx, cancel := context.WithTimeout(ctx.Context, sealRequestTimeout)
+		resp, err := client.Unseal(reqCtx, &wallet.UnsealRequest{Share: share})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("unseal: %w", err)
+		}
+		progress = resp.Progress
+		if resp.Code != wallet.ReturnCode_SUCCESS {
+			fmt.Fprintln(os.Stderr, "rejected:", resp.Msg)
+			continue
+		}
+		if !resp.Sealed {
+			fmt.Println(resp.Msg)
+			return nil
+		}
+		fmt.Fprintln(os.Stderr, resp.Msg)
+	}
+}
+
+func runSeal(ctx *cli.Context) error {
+	client, closeConn, err := dialWalletService(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	reqCtx, cancel := context.WithTimeout(ctx.Context, sealRequestTimeout)
+	defer cancel()
+	resp, err := client.Seal(reqCtx, &wallet.SealRequest{ConsumerToken: ctx.String(flags2.AdminTokenFlag.Name)})
+	if err != nil {
+		return fmt.Errorf("seal: %w", err)
+	}
+	if resp.Code != wallet.ReturnCode_SUCCESS {
+		return errors.New(resp.Msg)
+	}
+	fmt.Println(resp.Msg)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	flags2 "github.com/qiaopengjun5162/web3-wallet-sign/flags"
+	"github.com/qiaopengjun5162/web3-wallet-sign/shamir"
+)
+
+func runShamirSplit(ctx *cli.Context) error {
+	secretFile := ctx.String(flags2.ShamirSecretFileFlag.Name)
+	var data []byte
+	var err error
+	if secretFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(secretFile)
+	}
+	if err != nil {
+		return fmt.Errorf("read secret file: %w", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil {
+		return fmt.Errorf("decode secret: %w", err)
+	}
+
+	shares, err := shamir.SplitSecret(secret, ctx.Int(flags2.ShamirSharesFlag.Name), ctx.Int(flags2.ShamirThresholdFlag.Name))
+	if err != nil {
+		return fmt.Errorf("split secret: %w", err)
+	}
+	fmt.Printf("group %08x: %d shares, any %d of them recover the secret\n", shares[0].GroupID, shares[0].Total, shares[0].Threshold)
+	for _, share := range shares {
+		fmt.Printf("share %d/%d: %s\n", share.Index, share.Total, share)
+	}
+	return nil
+}
+
+// runShamirRecover runs the recovery ceremony: shares are entered one at a time and each of them
+// is checked against the first one, so a mistyped or foreign share is rejected immediately.
+func runShamirRecover(ctx *cli.Context) error {
+	scanner := bufio.NewScanner(os.Stdin)
+	var shares []*shamir.Share
+	seen := make(map[uint8]bool)
+	for len(shares) == 0 || len(shares) < int(shares[0].Threshold) {
+		if len(shares) == 0 {
+			fmt.Fprint(os.Stderr, "enter share 1: ")
+		} else {
+			fmt.Fprintf(os.Stderr, "enter share %d of %d: ", len(shares)+1, shares[0].Threshold)
+		}
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return errors.New("not enough shares entered")
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		share, err := shamir.ParseShare(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rejected:", err)
+			continue
+		}
+		if len(shares) > 0 {
+			if err := shares[0].CheckSameGroup(share); err != nil {
+				fmt.Fprintln(os.Stderr, "rejected:", err)
+				continue
+			}
+		}
+		if seen[share.Index] {
+			fmt.Fprintf(os.Stderr, "rejected: share %d was already entered\n", share.Index)
+			continue
+		}
+		seen[share.Index] = true
+		shares = append(shares, share)
+		fmt.Fprintf(os.Stderr, "accepted share %d/%d of group %08x\n", share.Index, share.Total, share.GroupID)
+	}
+
+	secret, err := shamir.CombineShares(shares)
+	if err != nil {
+		return fmt.Errorf("recover secret: %w", err)
+	}
+	encoded := hex.EncodeToString(secret)
+	output := ctx.String(flags2.ShamirOutputFlag.Name)
+	if output == "" {
+		fmt.Println(encoded)
+		return nil
+	}
+	if err := os.WriteFile(output, []byte(encoded+"\n"), 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "secret written to", output)
+	return nil
+}
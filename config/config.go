@@ -2,6 +2,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
 
 	"github.com/qiaopengjun5162/web3-wallet-sign/flags"
@@ -17,14 +19,66 @@ type ServerConfig struct {
 type Config struct {
 	// LevelDB数据库的路径
 	LevelDbPath string
+	// 密钥库的存储后端
+	DbBackend string
 	// RPC服务器的配置信息
 	RPCServer ServerConfig
+	// 是否启用 Prometheus 指标服务
+	MetricsEnabled bool
+	// 指标服务的配置信息
+	MetricsServer ServerConfig
+	// 是否启用 HTTP 健康检查服务
+	HealthEnabled bool
+	// 健康检查服务的配置信息
+	HealthServer ServerConfig
+	// 是否启用 HTTP/JSON 接口服务
+	RestEnabled bool
+	// HTTP/JSON 接口服务的配置信息
+	RestServer ServerConfig
+	// 是否启用以太坊 JSON-RPC 远程签名服务
+	EthRpcEnabled bool
+	// 以太坊 JSON-RPC 远程签名服务的配置信息
+	EthRpcServer ServerConfig
+	// 是否启用 Web3Signer 兼容的共识层签名服务
+	Web3SignerEnabled bool
+	// Web3Signer 签名服务的配置信息
+	Web3SignerServer ServerConfig
+	// Web3Signer 签名服务使用的调用方令牌，服务该调用方的 BLS 密钥
+	Web3SignerConsumerToken string
+	// Web3Signer 签名服务请求的 Bearer 令牌，只有监听回环地址时可以为空
+	Web3SignerAuthToken string
+	// 网络的创世分叉版本，用于签名验证者在区块构建者处的注册
+	Web3SignerGenesisForkVersion string
+	// OTLP 链路追踪收集器的地址，为空时不导出链路追踪数据
+	TracingEndpoint string
+	// 是否不使用 TLS 连接链路追踪收集器
+	TracingInsecure bool
+	// 服务发起的链路追踪的采样率
+	TracingSampleRatio float64
 	// 凭证文件的路径
 	CredentialsFile string
 	// 密钥的名称
 	KeyName string
 	// 是否启用HSM
 	HsmEnable bool
+	// 批量签名的并发数
+	BatchSignWorkers int
+	// 单个签名流的并发数
+	StreamSignConcurrency int
+	// 管理员的凭证，可以管理所有密钥
+	AdminToken string
+	// 密钥等待销毁的时长
+	KeyDestructionDelay time.Duration
+	// 密钥生成请求 id 的保留时长
+	RequestIDRetention time.Duration
+	// 调用方配置文件的路径，将调用方凭证映射为拥有密钥的调用方 id，并配置调用方的配额
+	ConsumersFile string
+	// 交易策略文件的路径，每次签名前检查交易策略
+	PolicyFile string
+	// 签名审计日志锚点的审计密钥公钥，为空时不锚定审计日志
+	AuditKey string
+	// 审计日志的锚定间隔
+	AuditAnchorInterval time.Duration
 }
 
 // NewConfig 根据 CLI 上下文创建并返回一个新的配置实例。
@@ -35,12 +89,32 @@ func NewConfig(ctx *cli.Context) Config {
 	return Config{
 		// 从上下文中获取 LevelDb 路径
 		LevelDbPath: ctx.String(flags.LevelDbPathFlag.Name),
+		// 从上下文中获取密钥库的存储后端
+		DbBackend: ctx.String(flags.DbBackendFlag.Name),
 		// 从上下文中获取凭证文件路径
 		CredentialsFile: ctx.String(flags.CredentialsFileFlag.Name),
 		// 从上下文中获取密钥名称
 		KeyName: ctx.String(flags.KeyNameFlag.Name),
 		// 从上下文中获取硬件安全模块启用状态
 		HsmEnable: ctx.Bool(flags.HsmEnable.Name),
+		// 从上下文中获取批量签名的并发数
+		BatchSignWorkers: ctx.Int(flags.BatchSignWorkersFlag.Name),
+		// 从上下文中获取单个签名流的并发数
+		StreamSignConcurrency: ctx.Int(flags.StreamSignConcurrencyFlag.Name),
+		// 从上下文中获取管理员的凭证
+		AdminToken: ctx.String(flags.AdminTokenFlag.Name),
+		// 从上下文中获取密钥等待销毁的时长
+		KeyDestructionDelay: ctx.Duration(flags.KeyDestructionDelayFlag.Name),
+		// 从上下文中获取密钥生成请求 id 的保留时长
+		RequestIDRetention: ctx.Duration(flags.RequestIDRetentionFlag.Name),
+		// 从上下文中获取调用方配置文件的路径
+		ConsumersFile: ctx.String(flags.ConsumersFileFlag.Name),
+		// 从上下文中获取交易策略文件的路径
+		PolicyFile: ctx.String(flags.PolicyFileFlag.Name),
+		// 从上下文中获取审计密钥的公钥
+		AuditKey: ctx.String(flags.AuditKeyFlag.Name),
+		// 从上下文中获取审计日志的锚定间隔
+		AuditAnchorInterval: ctx.Duration(flags.AuditAnchorIntervalFlag.Name),
 		// 初始化 RpcServer 配置
 		RPCServer: ServerConfig{
 			// 从上下文中获取 RPC 服务器主机名
@@ -48,5 +122,62 @@ func NewConfig(ctx *cli.Context) Config {
 			// 从上下文中获取 RPC 服务器端口号
 			Port: ctx.Int(flags.RpcPortFlag.Name),
 		},
+		// 从上下文中获取是否启用指标服务
+		MetricsEnabled: ctx.Bool(flags.MetricsEnabledFlag.Name),
+		// 从上下文中获取是否启用健康检查服务
+		HealthEnabled: ctx.Bool(flags.HealthEnabledFlag.Name),
+		// 初始化健康检查服务配置
+		HealthServer: ServerConfig{
+			// 从上下文中获取健康检查服务主机名
+			Host: ctx.String(flags.HealthHostFlag.Name),
+			// 从上下文中获取健康检查服务端口号
+			Port: ctx.Int(flags.HealthPortFlag.Name),
+		},
+		// 从上下文中获取是否启用 HTTP/JSON 接口服务
+		RestEnabled: ctx.Bool(flags.RestEnabledFlag.Name),
+		// 初始化 HTTP/JSON 接口服务配置
+		RestServer: ServerConfig{
+			// 从上下文中获取 HTTP/JSON 接口服务主机名
+			Host: ctx.String(flags.RestHostFlag.Name),
+			// 从上下文中获取 HTTP/JSON 接口服务端口号
+			Port: ctx.Int(flags.RestPortFlag.Name),
+		},
+		// 从上下文中获取是否启用以太坊 JSON-RPC 远程签名服务
+		EthRpcEnabled: ctx.Bool(flags.EthRpcEnabledFlag.Name),
+		// 初始化以太坊 JSON-RPC 远程签名服务配置
+		EthRpcServer: ServerConfig{
+			// 从上下文中获取以太坊 JSON-RPC 远程签名服务主机名
+			Host: ctx.String(flags.EthRpcHostFlag.Name),
+			// 从上下文中获取以太坊 JSON-RPC 远程签名服务端口号
+			Port: ctx.Int(flags.EthRpcPortFlag.Name),
+		},
+		// 从上下文中获取是否启用 Web3Signer 签名服务
+		Web3SignerEnabled: ctx.Bool(flags.Web3SignerEnabledFlag.Name),
+		// 初始化 Web3Signer 签名服务配置
+		Web3SignerServer: ServerConfig{
+			// 从上下文中获取 Web3Signer 签名服务主机名
+			Host: ctx.String(flags.Web3SignerHostFlag.Name),
+			// 从上下文中获取 Web3Signer 签名服务端口号
+			Port: ctx.Int(flags.Web3SignerPortFlag.Name),
+		},
+		// 从上下文中获取 Web3Signer 签名服务使用的调用方令牌
+		Web3SignerConsumerToken: ctx.String(flags.Web3SignerConsumerTokenFlag.Name),
+		// 从上下文中获取 Web3Signer 签名服务请求的 Bearer 令牌
+		Web3SignerAuthToken: ctx.String(flags.Web3SignerAuthTokenFlag.Name),
+		// 从上下文中获取网络的创世分叉版本
+		Web3SignerGenesisForkVersion: ctx.String(flags.Web3SignerGenesisForkVersionFlag.Name),
+		// 从上下文中获取链路追踪收集器的地址
+		TracingEndpoint: ctx.String(flags.TracingEndpointFlag.Name),
+		// 从上下文中获取是否不使用 TLS 连接链路追踪收集器
+		TracingInsecure: ctx.Bool(flags.TracingInsecureFlag.Name),
+		// 从上下文中获取链路追踪的采样率
+		TracingSampleRatio: ctx.Float64(flags.TracingSampleRatioFlag.Name),
+		// 初始化指标服务配置
+		MetricsServer: ServerConfig{
+			// 从上下文中获取指标服务主机名
+			Host: ctx.String(flags.MetricsHostFlag.Name),
+			// 从上下文中获取指标服务端口号
+			Port: ctx.Int(flags.MetricsPortFlag.Name),
+		},
 	}
 }
@@ -1,6 +1,10 @@
 package flags
 
-import "github.com/urfave/cli/v2"
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
 
 const envVarPrefix = "SIGNATURE"
 
@@ -23,6 +27,123 @@ var (
 		Value:    8980,
 		Required: true,
 	}
+	MetricsEnabledFlag = &cli.BoolFlag{
+		Name:    "metrics-enabled",
+		Usage:   "Serve the Prometheus metrics over HTTP",
+		EnvVars: prefixEnvVars("METRICS_ENABLED"),
+	}
+	MetricsHostFlag = &cli.StringFlag{
+		Name:    "metrics-host",
+		Usage:   "The host of the metrics listener",
+		EnvVars: prefixEnvVars("METRICS_HOST"),
+		Value:   "0.0.0.0",
+	}
+	MetricsPortFlag = &cli.IntFlag{
+		Name:    "metrics-port",
+		Usage:   "The port of the metrics listener",
+		EnvVars: prefixEnvVars("METRICS_PORT"),
+		Value:   7300,
+	}
+	HealthEnabledFlag = &cli.BoolFlag{
+		Name:    "health-enabled",
+		Usage:   "Serve /healthz and /readyz over HTTP for the probes that do not speak gRPC",
+		EnvVars: prefixEnvVars("HEALTH_ENABLED"),
+	}
+	HealthHostFlag = &cli.StringFlag{
+		Name:    "health-host",
+		Usage:   "The host of the health listener",
+		EnvVars: prefixEnvVars("HEALTH_HOST"),
+		Value:   "0.0.0.0",
+	}
+	HealthPortFlag = &cli.IntFlag{
+		Name:    "health-port",
+		Usage:   "The port of the health listener",
+		EnvVars: prefixEnvVars("HEALTH_PORT"),
+		Value:   8990,
+	}
+	RestEnabledFlag = &cli.BoolFlag{
+		Name:    "rest-enabled",
+		Usage:   "Serve the rpc methods over HTTP/JSON with an OpenAPI document at /openapi.json",
+		EnvVars: prefixEnvVars("REST_ENABLED"),
+	}
+	RestHostFlag = &cli.StringFlag{
+		Name:    "rest-host",
+		Usage:   "The host of the rest listener",
+		EnvVars: prefixEnvVars("REST_HOST"),
+		Value:   "0.0.0.0",
+	}
+	RestPortFlag = &cli.IntFlag{
+		Name:    "rest-port",
+		Usage:   "The port of the rest listener",
+		EnvVars: prefixEnvVars("REST_PORT"),
+		Value:   8970,
+	}
+	EthRpcEnabledFlag = &cli.BoolFlag{
+		Name:    "eth-rpc-enabled",
+		Usage:   "Serve the ecdsa keys as an Ethereum JSON-RPC remote signer, the consumer token is read from the Authorization: Bearer header",
+		EnvVars: prefixEnvVars("ETH_RPC_ENABLED"),
+	}
+	EthRpcHostFlag = &cli.StringFlag{
+		Name:    "eth-rpc-host",
+		Usage:   "The host of the Ethereum JSON-RPC signer listener",
+		EnvVars: prefixEnvVars("ETH_RPC_HOST"),
+		Value:   "0.0.0.0",
+	}
+	EthRpcPortFlag = &cli.IntFlag{
+		Name:    "eth-rpc-port",
+		Usage:   "The port of the Ethereum JSON-RPC signer listener",
+		EnvVars: prefixEnvVars("ETH_RPC_PORT"),
+		Value:   8550,
+	}
+	Web3SignerEnabledFlag = &cli.BoolFlag{
+		Name:    "web3signer-enabled",
+		Usage:   "Serve the bls keys with the eth2 API of Web3Signer for consensus validator clients",
+		EnvVars: prefixEnvVars("WEB3SIGNER_ENABLED"),
+	}
+	Web3SignerHostFlag = &cli.StringFlag{
+		Name:    "web3signer-host",
+		Usage:   "The host of the Web3Signer listener, other hosts than the loopback addresses need an auth token",
+		EnvVars: prefixEnvVars("WEB3SIGNER_HOST"),
+		Value:   "127.0.0.1",
+	}
+	Web3SignerPortFlag = &cli.IntFlag{
+		Name:    "web3signer-port",
+		Usage:   "The port of the Web3Signer listener",
+		EnvVars: prefixEnvVars("WEB3SIGNER_PORT"),
+		Value:   9000,
+	}
+	Web3SignerConsumerTokenFlag = &cli.StringFlag{
+		Name:    "web3signer-consumer-token",
+		Usage:   "The consumer token whose bls keys are served by the Web3Signer listener",
+		EnvVars: prefixEnvVars("WEB3SIGNER_CONSUMER_TOKEN"),
+	}
+	Web3SignerAuthTokenFlag = &cli.StringFlag{
+		Name:    "web3signer-auth-token",
+		Usage:   "The token the requests of the Web3Signer listener must carry in the Authorization: Bearer header",
+		EnvVars: prefixEnvVars("WEB3SIGNER_AUTH_TOKEN"),
+	}
+	Web3SignerGenesisForkVersionFlag = &cli.StringFlag{
+		Name:    "web3signer-genesis-fork-version",
+		Usage:   "The genesis fork version of the network the validator registrations are signed for, 0x00000000 on mainnet",
+		EnvVars: prefixEnvVars("WEB3SIGNER_GENESIS_FORK_VERSION"),
+		Value:   "0x00000000",
+	}
+	TracingEndpointFlag = &cli.StringFlag{
+		Name:    "tracing-endpoint",
+		Usage:   "The host:port of the OTLP gRPC collector the traces are exported to, empty to disable the export",
+		EnvVars: prefixEnvVars("TRACING_ENDPOINT"),
+	}
+	TracingInsecureFlag = &cli.BoolFlag{
+		Name:    "tracing-insecure",
+		Usage:   "Connect to the OTLP collector without TLS",
+		EnvVars: prefixEnvVars("TRACING_INSECURE"),
+	}
+	TracingSampleRatioFlag = &cli.Float64Flag{
+		Name:    "tracing-sample-ratio",
+		Usage:   "The ratio of the traces started by the service that are sampled, traces of the callers follow their sampling decision",
+		EnvVars: prefixEnvVars("TRACING_SAMPLE_RATIO"),
+		Value:   1,
+	}
 	// LevelDbPathFlag Database
 	LevelDbPathFlag = &cli.StringFlag{
 		Name:    "master-db-host",
@@ -30,6 +151,12 @@ var (
 		EnvVars: prefixEnvVars("LEVEL_DB_PATH"),
 		Value:   "./",
 	}
+	DbBackendFlag = &cli.StringFlag{
+		Name:    "db-backend",
+		Usage:   "The storage backend of the key store: leveldb, bolt or memory",
+		EnvVars: prefixEnvVars("DB_BACKEND"),
+		Value:   "leveldb",
+	}
 	CredentialsFileFlag = &cli.StringFlag{
 		Name:    "credentials-file",
 		Usage:   "the credentials file of cloud hsm",
@@ -46,8 +173,266 @@ var (
 		EnvVars: prefixEnvVars("HSM_ENABLE"),
 		Value:   false,
 	}
+	BatchSignWorkersFlag = &cli.IntFlag{
+		Name:    "batch-sign-workers",
+		Usage:   "The number of concurrent signers used by one batch sign request",
+		EnvVars: prefixEnvVars("BATCH_SIGN_WORKERS"),
+		Value:   16,
+	}
+	StreamSignConcurrencyFlag = &cli.IntFlag{
+		Name:    "stream-sign-concurrency",
+		Usage:   "The number of in-flight sign requests allowed on one sign stream",
+		EnvVars: prefixEnvVars("STREAM_SIGN_CONCURRENCY"),
+		Value:   32,
+	}
+	AdminTokenFlag = &cli.StringFlag{
+		Name:    "admin-token",
+		Usage:   "The consumer token allowed to manage every key",
+		EnvVars: prefixEnvVars("ADMIN_TOKEN"),
+	}
+	KeyDestructionDelayFlag = &cli.DurationFlag{
+		Name:    "key-destruction-delay",
+		Usage:   "The waiting period before a key pending destruction is destroyed",
+		EnvVars: prefixEnvVars("KEY_DESTRUCTION_DELAY"),
+		Value:   7 * 24 * time.Hour,
+	}
+	RequestIDRetentionFlag = &cli.DurationFlag{
+		Name:    "request-id-retention",
+		Usage:   "How long the request id of a key generation request is remembered for retries",
+		EnvVars: prefixEnvVars("REQUEST_ID_RETENTION"),
+		Value:   24 * time.Hour,
+	}
+	PolicyFileFlag = &cli.StringFlag{
+		Name:    "policy-file",
+		Usage:   "The JSON file holding the transaction policy checked before every signature",
+		EnvVars: prefixEnvVars("POLICY_FILE"),
+	}
+	ConsumersFileFlag = &cli.StringFlag{
+		Name:    "consumers-file",
+		Usage:   "The JSON file mapping consumer tokens to the consumer ids owning the keys and holding their limits",
+		EnvVars: prefixEnvVars("CONSUMERS_FILE"),
+	}
+	AuditKeyFlag = &cli.StringFlag{
+		Name:    "audit-key",
+		Usage:   "The public key of the ecdsa key signing the audit log anchors, empty to disable anchoring",
+		EnvVars: prefixEnvVars("AUDIT_KEY"),
+	}
+	AuditAnchorIntervalFlag = &cli.DurationFlag{
+		Name:    "audit-anchor-interval",
+		Usage:   "The interval at which the head of the audit log is signed with the audit key",
+		EnvVars: prefixEnvVars("AUDIT_ANCHOR_INTERVAL"),
+		Value:   time.Hour,
+	}
+)
+
+// import-key command
+var (
+	ImportTypeFlag = &cli.StringFlag{
+		Name:     "type",
+		Usage:    "The crypto type of the imported key, ecdsa, eddsa or bls",
+		Required: true,
+	}
+	ImportFormatFlag = &cli.StringFlag{
+		Name:     "format",
+		Usage:    "The format of the imported key, hex, wif, keystore or mnemonic",
+		Required: true,
+	}
+	ImportKeyFileFlag = &cli.StringFlag{
+		Name:     "key-file",
+		Usage:    "The file holding the private key, keystore JSON or mnemonic, - for stdin",
+		Required: true,
+	}
+	ImportPassphraseFlag = &cli.StringFlag{
+		Name:    "passphrase",
+		Usage:   "The keystore passphrase or the BIP39 passphrase",
+		EnvVars: prefixEnvVars("IMPORT_PASSPHRASE"),
+	}
+	ImportDerivationPathFlag = &cli.StringFlag{
+		Name:  "derivation-path",
+		Usage: "The BIP32 derivation path of a mnemonic",
+	}
+	ImportLabelsFlag = &cli.StringSliceFlag{
+		Name:  "label",
+		Usage: "The labels of the imported key",
+	}
+	ImportConsumerFlag = &cli.StringFlag{
+		Name:  "consumer-token",
+		Usage: "The consumer id or the consumer token owning the imported key, a token is replaced by its consumer id when the server starts; without it only the admin can use the key",
+	}
+)
+
+var ImportKeyFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	ImportTypeFlag,
+	ImportFormatFlag,
+	ImportKeyFileFlag,
+	ImportPassphraseFlag,
+	ImportDerivationPathFlag,
+	ImportLabelsFlag,
+	ImportConsumerFlag,
+}
+
+// assign-key-owner command
+var (
+	AssignPublicKeyFlag = &cli.StringFlag{
+		Name:     "public-key",
+		Usage:    "The public key of the key without owner",
+		Required: true,
+	}
+	AssignConsumerFlag = &cli.StringFlag{
+		Name:     "consumer-token",
+		Usage:    "The consumer id or the consumer token the key is assigned to, a token is replaced by its consumer id when the server starts",
+		Required: true,
+	}
 )
 
+var AssignKeyOwnerFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	AssignPublicKeyFlag,
+	AssignConsumerFlag,
+}
+
+// backup and restore commands
+var (
+	BackupFileFlag = &cli.StringFlag{
+		Name:     "file",
+		Usage:    "The backup file to write or to restore from",
+		Required: true,
+	}
+	BackupPassphraseFlag = &cli.StringFlag{
+		Name:     "passphrase",
+		Usage:    "The passphrase encrypting the backup file",
+		EnvVars:  prefixEnvVars("BACKUP_PASSPHRASE"),
+		Required: true,
+	}
+	RestoreForceFlag = &cli.BoolFlag{
+		Name:  "force",
+		Usage: "Overwrite existing keys that differ from the backup",
+	}
+	RestoreDryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "List what would be restored without writing the key store",
+	}
+)
+
+var BackupFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	BackupFileFlag,
+	BackupPassphraseFlag,
+}
+
+var RestoreFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	BackupFileFlag,
+	BackupPassphraseFlag,
+	RestoreForceFlag,
+	RestoreDryRunFlag,
+}
+
+// shamir-split and shamir-recover commands
+var (
+	ShamirSharesFlag = &cli.IntFlag{
+		Name:     "shares",
+		Usage:    "The number of shares N the secret is split into",
+		Required: true,
+	}
+	ShamirThresholdFlag = &cli.IntFlag{
+		Name:     "threshold",
+		Usage:    "The number of shares K required to recover the secret",
+		Required: true,
+	}
+	ShamirSecretFileFlag = &cli.StringFlag{
+		Name:     "secret-file",
+		Usage:    "The file holding the hex encoded secret, - for stdin",
+		Required: true,
+	}
+	ShamirOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "The file the recovered hex encoded secret is written to, stdout if empty",
+	}
+)
+
+var ShamirSplitFlags = []cli.Flag{
+	ShamirSharesFlag,
+	ShamirThresholdFlag,
+	ShamirSecretFileFlag,
+}
+
+var ShamirRecoverFlags = []cli.Flag{
+	ShamirOutputFlag,
+}
+
+// init-seal, unseal and seal commands
+var (
+	RpcAddrFlag = &cli.StringFlag{
+		Name:    "rpc-addr",
+		Usage:   "The address of the running rpc service",
+		EnvVars: prefixEnvVars("RPC_ADDR"),
+		Value:   "127.0.0.1:8980",
+	}
+)
+
+var InitSealFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	ShamirSharesFlag,
+	ShamirThresholdFlag,
+}
+
+var UnsealFlags = []cli.Flag{
+	RpcAddrFlag,
+}
+
+var SealFlags = []cli.Flag{
+	RpcAddrFlag,
+	AdminTokenFlag,
+}
+
+// audit-verify and audit-export commands
+var (
+	AuditFromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "The sequence number of the first exported audit entry",
+		Value: 1,
+	}
+	AuditToFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "The sequence number of the last exported audit entry, 0 for the end of the log",
+	}
+	AuditSinceFlag = &cli.StringFlag{
+		Name:  "since",
+		Usage: "Only export the audit entries at or after this RFC3339 time",
+	}
+	AuditUntilFlag = &cli.StringFlag{
+		Name:  "until",
+		Usage: "Only export the audit entries before this RFC3339 time",
+	}
+	AuditOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "The file the audit entries are written to as JSON lines, stdout if empty",
+	}
+)
+
+var AuditVerifyFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	AuditKeyFlag,
+}
+
+var AuditExportFlags = []cli.Flag{
+	LevelDbPathFlag,
+	DbBackendFlag,
+	AuditFromFlag,
+	AuditToFlag,
+	AuditSinceFlag,
+	AuditUntilFlag,
+	AuditOutputFlag,
+}
+
 var requireFlags = []cli.Flag{
 	RpcHostFlag,
 	RpcPortFlag,
@@ -55,9 +440,40 @@ var requireFlags = []cli.Flag{
 }
 
 var optionalFlags = []cli.Flag{
+	MetricsEnabledFlag,
+	MetricsHostFlag,
+	MetricsPortFlag,
+	HealthEnabledFlag,
+	HealthHostFlag,
+	HealthPortFlag,
+	RestEnabledFlag,
+	RestHostFlag,
+	RestPortFlag,
+	EthRpcEnabledFlag,
+	EthRpcHostFlag,
+	EthRpcPortFlag,
+	Web3SignerEnabledFlag,
+	Web3SignerHostFlag,
+	Web3SignerPortFlag,
+	Web3SignerConsumerTokenFlag,
+	Web3SignerAuthTokenFlag,
+	Web3SignerGenesisForkVersionFlag,
+	TracingEndpointFlag,
+	TracingInsecureFlag,
+	TracingSampleRatioFlag,
+	DbBackendFlag,
 	CredentialsFileFlag,
 	KeyNameFlag,
 	HsmEnable,
+	BatchSignWorkersFlag,
+	StreamSignConcurrencyFlag,
+	AdminTokenFlag,
+	KeyDestructionDelayFlag,
+	RequestIDRetentionFlag,
+	ConsumersFileFlag,
+	PolicyFileFlag,
+	AuditKeyFlag,
+	AuditAnchorIntervalFlag,
 }
 
 var Flags []cli.Flag
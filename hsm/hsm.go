@@ -4,20 +4,47 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
 	"google.golang.org/api/option"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/tracing"
 )
 
+var tracer = otel.Tracer("github.com/qiaopengjun5162/web3-wallet-sign/hsm")
+
 // HSMClient represents a client for interacting with a Hardware Security Module (HSM).
 type HSMClient struct {
 	Ctx       context.Context
 	KeyName   string
 	KmsClient *kms.KeyManagementClient
+	// Metrics records the latency and the errors of the KMS calls, nil to record nothing
+	Metrics Metricer
+}
+
+// Metricer records the calls made to the cloud HSM.
+type Metricer interface {
+	RecordHSMCall(method string, start time.Time, err error)
+}
+
+// call starts the span of a KMS call, the returned function ends it and records the call in Metrics.
+func (hsm *HSMClient) call(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "kms."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("kms.key_name", hsm.KeyName)))
+	return ctx, func(err error) {
+		if hsm.Metrics != nil {
+			hsm.Metrics.RecordHSMCall(method, start, err)
+		}
+		tracing.End(span, err)
+	}
 }
 
 func NewHSMClient(ctx context.Context, keyPath string, keyName string) (*HSMClient, error) {
@@ -33,6 +60,10 @@ func NewHSMClient(ctx context.Context, keyPath string, keyName string) (*HSMClie
 }
 
 func (hsm *HSMClient) SignTransaction(hash string) (string, error) {
+	return hsm.SignTransactionContext(hsm.Ctx, hash)
+}
+
+func (hsm *HSMClient) SignTransactionContext(ctx context.Context, hash string) (string, error) {
 	hashByte, _ := hex.DecodeString(hash)
 	req := kmspb.AsymmetricSignRequest{
 		Name: hsm.KeyName,
@@ -42,7 +73,9 @@ func (hsm *HSMClient) SignTransaction(hash string) (string, error) {
 			},
 		},
 	}
-	resp, err := hsm.KmsClient.AsymmetricSign(hsm.Ctx, &req)
+	ctx, done := hsm.call(ctx, "AsymmetricSign")
+	resp, err := hsm.KmsClient.AsymmetricSign(ctx, &req)
+	done(err)
 	if err != nil {
 		return common.Hash{}.String(), err
 	}
@@ -50,11 +83,17 @@ func (hsm *HSMClient) SignTransaction(hash string) (string, error) {
 }
 
 func (hsm *HSMClient) CreateKeyRing(projectID, locationID, keyRingID string) (string, error) {
+	return hsm.CreateKeyRingContext(hsm.Ctx, projectID, locationID, keyRingID)
+}
+
+func (hsm *HSMClient) CreateKeyRingContext(ctx context.Context, projectID, locationID, keyRingID string) (string, error) {
 	parent := fmt.Sprintf("projects/%s/locations/%s", projectID, locationID)
-	_, err := hsm.KmsClient.CreateKeyRing(hsm.Ctx, &kmspb.CreateKeyRingRequest{
+	ctx, done := hsm.call(ctx, "CreateKeyRing")
+	_, err := hsm.KmsClient.CreateKeyRing(ctx, &kmspb.CreateKeyRingRequest{
 		Parent:    parent,
 		KeyRingId: keyRingID,
 	})
+	done(err)
 	if err != nil {
 		log.Error("create key ring fail", "err", err)
 		return "", err
@@ -63,6 +102,10 @@ func (hsm *HSMClient) CreateKeyRing(projectID, locationID, keyRingID string) (st
 }
 
 func (hsm *HSMClient) CreateKeyPair(projectID, locationID, keyRingID, keyID, method string) (string, error) {
+	return hsm.CreateKeyPairContext(hsm.Ctx, projectID, locationID, keyRingID, keyID, method)
+}
+
+func (hsm *HSMClient) CreateKeyPairContext(ctx context.Context, projectID, locationID, keyRingID, keyID, method string) (string, error) {
 	parent := fmt.Sprintf("projects/%s/locations/%s/keyRings/%s", projectID, locationID, keyRingID)
 	var key *kmspb.CryptoKey
 	if method == "ecdsa" {
@@ -82,14 +125,24 @@ func (hsm *HSMClient) CreateKeyPair(projectID, locationID, keyRingID, keyID, met
 			},
 		}
 	}
-	createdKey, err := hsm.KmsClient.CreateCryptoKey(hsm.Ctx, &kmspb.CreateCryptoKeyRequest{
+	ctx, done := hsm.call(ctx, "CreateCryptoKey")
+	createdKey, err := hsm.KmsClient.CreateCryptoKey(ctx, &kmspb.CreateCryptoKeyRequest{
 		Parent:      parent,
 		CryptoKeyId: keyID,
 		CryptoKey:   key,
 	})
+	done(err)
 	if err != nil {
 		log.Error("Failed to create ECDSA key: %v", err)
 		return "", err
 	}
 	return createdKey.Name, nil
 }
+
+// Ping checks that KMS is reachable and the key of KeyName can be read.
+func (hsm *HSMClient) Ping(ctx context.Context) error {
+	ctx, done := hsm.call(ctx, "GetPublicKey")
+	_, err := hsm.KmsClient.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: hsm.KeyName})
+	done(err)
+	return err
+}
@@ -0,0 +1,152 @@
+package leveldb
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// signRequestPrefix 是等待审批的签名请求的键前缀，键为 signRequestPrefix + 请求 id。
+const signRequestPrefix = "signreq/"
+
+// 签名请求的状态。
+const (
+	// SignRequestPending 表示请求正在等待审批
+	SignRequestPending = "pending"
+	// SignRequestSigned 表示请求已经审批通过并完成签名
+	SignRequestSigned = "signed"
+	// SignRequestRejected 表示请求被审批人拒绝
+	SignRequestRejected = "rejected"
+	// SignRequestExpired 表示请求在过期前没有得到足够的审批
+	SignRequestExpired = "expired"
+	// SignRequestFailed 表示请求审批通过后签名失败，例如超出了策略的限额
+	SignRequestFailed = "failed"
+)
+
+// ErrSignRequestNotFound 表示签名请求不存在。
+var ErrSignRequestNotFound = errors.New("sign request not found")
+
+// SignRequest 是一个需要多个审批人审批后才能签名的请求，签名所需的输入全部保存在请求中，
+// 服务重启后审批可以继续。
+type SignRequest struct {
+	ID string `json:"id"`
+	// Consumer 是发起请求的调用方，Admin 表示调用方使用的是管理员凭证
+	Consumer string `json:"consumer"`
+	Admin    bool   `json:"admin,omitempty"`
+	Pubkey   string `json:"pubkey"`
+	// Type 是密钥的算法，取值为 protobuf.CryptoType
+	Type         string `json:"type"`
+	MessageHash  string `json:"messageHash"`
+	UnsignedTx   string `json:"unsignedTx,omitempty"`
+	ChainID      uint64 `json:"chainId,omitempty"`
+	UnsignedPSBT string `json:"unsignedPsbt,omitempty"`
+	InputIndex   uint32 `json:"inputIndex,omitempty"`
+	Network      string `json:"network,omitempty"`
+	// Chain、Token 和 Amount 是交易的概要，供审批人查看，签名裸哈希时为空
+	Chain  string `json:"chain,omitempty"`
+	Token  string `json:"token,omitempty"`
+	Amount string `json:"amount,omitempty"`
+	// Requirements 是请求需要的审批，每一项都满足后才能签名
+	Requirements []*ApprovalRequirement `json:"requirements"`
+	Approvals    []*SignApproval        `json:"approvals,omitempty"`
+	// Rejection 是拒绝请求的审批人的签名
+	Rejection *SignApproval `json:"rejection,omitempty"`
+	Status    string        `json:"status"`
+	// Signature 和 SignedTx 是审批通过后的签名结果，Error 是签名失败的原因
+	Signature string `json:"signature,omitempty"`
+	SignedTx  string `json:"signedTx,omitempty"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+	// UpdatedAt 是请求最后一次修改的时间，结束的请求在保留期过后按它删除
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+// ApprovalRequirement 要求请求得到 Approvers 中 Required 个审批人的审批。
+type ApprovalRequirement struct {
+	Rule      string   `json:"rule"`
+	Required  int      `json:"required"`
+	Approvers []string `json:"approvers"`
+}
+
+// SignApproval 是审批人对请求摘要的签名。
+type SignApproval struct {
+	Approver  string `json:"approver"`
+	Signature string `json:"signature"`
+	At        int64  `json:"at"`
+}
+
+// Approved 判断请求是否已经满足所有审批要求。
+func (r *SignRequest) Approved() bool {
+	for _, requirement := range r.Requirements {
+		approved := 0
+		for _, approval := range r.Approvals {
+			for _, approver := range requirement.Approvers {
+				if approval.Approver == approver {
+					approved++
+				}
+			}
+		}
+		if approved < requirement.Required {
+			return false
+		}
+	}
+	return true
+}
+
+// PutSignRequest 保存签名请求，已存在时覆盖。
+func (k *Keys) PutSignRequest(request *SignRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return k.db.Put([]byte(signRequestPrefix+request.ID), data)
+}
+
+// GetSignRequest 读取签名请求，不存在时返回 ErrSignRequestNotFound。
+func (k *Keys) GetSignRequest(id string) (*SignRequest, error) {
+	data, err := k.db.Get([]byte(signRequestPrefix + id))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrSignRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var request SignRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ListSignRequests 按 id 的顺序返回满足 match 的签名请求。
+func (k *Keys) ListSignRequests(match func(*SignRequest) bool) ([]*SignRequest, error) {
+	var requests []*SignRequest
+	var decodeErr error
+	err := k.db.Iterate([]byte(signRequestPrefix), nil, func(_, value []byte) bool {
+		var request SignRequest
+		if err := json.Unmarshal(value, &request); err != nil {
+			decodeErr = err
+			return false
+		}
+		if match(&request) {
+			requests = append(requests, &request)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return requests, decodeErr
+}
+
+// DeleteSignRequests 删除签名请求，返回删除的数量。
+func (k *Keys) DeleteSignRequests(requests []*SignRequest) (int, error) {
+	if len(requests) == 0 {
+		return 0, nil
+	}
+	batch := new(Batch)
+	for _, request := range requests {
+		batch.Delete([]byte(signRequestPrefix + request.ID))
+	}
+	return batch.Len(), k.db.Write(batch)
+}
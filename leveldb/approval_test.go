@@ -0,0 +1,50 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignRequests(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+
+	request := &SignRequest{
+		ID:       "r1",
+		Consumer: "c1",
+		Pubkey:   "aa01",
+		Type:     "ecdsa",
+		Requirements: []*ApprovalRequirement{
+			{Rule: "withdrawals", Required: 2, Approvers: []string{"alice", "bob", "carol"}},
+			{Rule: "treasury", Required: 1, Approvers: []string{"dave"}},
+		},
+		Status: SignRequestPending,
+	}
+	assert.NoError(t, keys.PutSignRequest(request))
+	assert.NoError(t, keys.PutSignRequest(&SignRequest{ID: "r2", Consumer: "c2", Status: SignRequestSigned}))
+
+	_, err = keys.GetSignRequest("r3")
+	assert.ErrorIs(t, err, ErrSignRequestNotFound)
+	stored, err := keys.GetSignRequest("r1")
+	assert.NoError(t, err)
+	assert.Equal(t, request, stored)
+
+	// 每一项审批要求都满足后请求才审批通过
+	stored.Approvals = []*SignApproval{{Approver: "alice"}, {Approver: "dave"}}
+	assert.False(t, stored.Approved())
+	stored.Approvals = append(stored.Approvals, &SignApproval{Approver: "carol"})
+	assert.True(t, stored.Approved())
+
+	pending, err := keys.ListSignRequests(func(r *SignRequest) bool { return r.Status == SignRequestPending })
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "r1", pending[0].ID)
+
+	deleted, err := keys.DeleteSignRequests(pending)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	all, err := keys.ListSignRequests(func(*SignRequest) bool { return true })
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+}
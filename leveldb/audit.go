@@ -0,0 +1,279 @@
+package leveldb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// auditPrefix 是审计日志的键前缀，键为 auditPrefix + 十六进制的序号，日志按序号排列
+	auditPrefix = "audit/"
+	// auditHeadKey 保存审计日志最后一条记录的序号和哈希
+	auditHeadKey = "audithead"
+	// auditAnchorPrefix 是审计日志锚点的键前缀，键为 auditAnchorPrefix + 十六进制的序号
+	auditAnchorPrefix = "auditanchor/"
+	// auditAnchorDomain 区分锚点签名的摘要与审计密钥签名的其他消息
+	auditAnchorDomain = "web3-wallet-sign audit anchor\x00"
+)
+
+// AuditGenesisHash 是第一条审计记录的 PrevHash。
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// ErrAuditChainBroken 表示审计日志的哈希链不连续，日志被修改或删除过。
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// AuditEntry 是审计日志的一条记录。每条记录包含上一条记录的哈希，修改或删除任何一条记录都会使之后的哈希链断开。
+type AuditEntry struct {
+	Seq uint64 `json:"seq"`
+	// Time 是操作的时间，单位为纳秒
+	Time int64 `json:"time"`
+	// Consumer 是调用方标识，不保存调用方凭证
+	Consumer    string `json:"consumer"`
+	RPC         string `json:"rpc"`
+	Pubkey      string `json:"pubkey,omitempty"`
+	MessageHash string `json:"messageHash,omitempty"`
+	Result      string `json:"result"`
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+// AuditHead 是审计日志最后一条记录的序号和哈希，日志为空时序号为 0。
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditAnchor 是审计密钥对审计日志某一时刻最后一条记录的签名。
+type AuditAnchor struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+	// Time 是锚定的时间，单位为秒
+	Time      int64  `json:"time"`
+	Pubkey    string `json:"pubkey"`
+	Signature string `json:"signature"`
+}
+
+// ComputeHash 计算记录的哈希：sha256(序号 | 时间 | 各字符串字段 | 上一条记录的哈希)，
+// 整数按 8 字节大端编码，字符串带 4 字节大端长度前缀。
+func (e *AuditEntry) ComputeHash() string {
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint64(nil, e.Seq))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(e.Time)))
+	for _, field := range []string{e.Consumer, e.RPC, e.Pubkey, e.MessageHash, e.Result, e.PrevHash} {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Digest 返回审计密钥签名的锚点摘要：sha256(域分隔符 | 序号 | 哈希 | 时间)。
+func (a *AuditAnchor) Digest() []byte {
+	h := sha256.New()
+	h.Write([]byte(auditAnchorDomain))
+	h.Write(binary.BigEndian.AppendUint64(nil, a.Seq))
+	h.Write([]byte(a.Hash))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(a.Time)))
+	return h.Sum(nil)
+}
+
+// Verify 验证锚点的签名，签名为 ECDSA 的 [R || S || V]。
+func (a *AuditAnchor) Verify() bool {
+	pubkey, err := hex.DecodeString(strings.TrimPrefix(a.Pubkey, "0x"))
+	if err != nil {
+		return false
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(a.Signature, "0x"))
+	if err != nil || len(signature) < 64 {
+		return false
+	}
+	return crypto.VerifySignature(pubkey, a.Digest(), signature[:64])
+}
+
+// AppendAudit 将 entries 依次追加到审计日志，填写它们的序号、PrevHash 和 Hash，所有记录在同一个 Batch 中写入。
+func (k *Keys) AppendAudit(entries []*AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	k.auditMu.Lock()
+	defer k.auditMu.Unlock()
+	head, err := k.auditHead()
+	if err != nil {
+		return err
+	}
+	batch := new(Batch)
+	for _, entry := range entries {
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		entry.Hash = entry.ComputeHash()
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		batch.Put(auditKey(entry.Seq), data)
+		head = &AuditHead{Seq: entry.Seq, Hash: entry.Hash}
+	}
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	batch.Put([]byte(auditHeadKey), data)
+	return k.db.Write(batch)
+}
+
+// GetAuditHead 返回审计日志最后一条记录的序号和哈希。
+func (k *Keys) GetAuditHead() (*AuditHead, error) {
+	k.auditMu.Lock()
+	defer k.auditMu.Unlock()
+	return k.auditHead()
+}
+
+func (k *Keys) auditHead() (*AuditHead, error) {
+	data, err := k.db.Get([]byte(auditHeadKey))
+	if errors.Is(err, ErrNotFound) {
+		return &AuditHead{Hash: AuditGenesisHash}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var head AuditHead
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// IterateAudit 按序号遍历从 from 开始的审计记录，fn 返回 false 时停止遍历。
+func (k *Keys) IterateAudit(from uint64, fn func(*AuditEntry) bool) error {
+	var decodeErr error
+	err := k.db.Iterate([]byte(auditPrefix), auditKey(from), func(_, value []byte) bool {
+		var entry AuditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			decodeErr = err
+			return false
+		}
+		return fn(&entry)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// PutAuditAnchor 保存审计日志的锚点。
+func (k *Keys) PutAuditAnchor(anchor *AuditAnchor) error {
+	data, err := json.Marshal(anchor)
+	if err != nil {
+		return err
+	}
+	return k.db.Put(auditAnchorKey(anchor.Seq), data)
+}
+
+// ListAuditAnchors 按序号返回所有锚点。
+func (k *Keys) ListAuditAnchors() ([]*AuditAnchor, error) {
+	var anchors []*AuditAnchor
+	var decodeErr error
+	err := k.db.Iterate([]byte(auditAnchorPrefix), nil, func(_, value []byte) bool {
+		var anchor AuditAnchor
+		if err := json.Unmarshal(value, &anchor); err != nil {
+			decodeErr = err
+			return false
+		}
+		anchors = append(anchors, &anchor)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return anchors, decodeErr
+}
+
+// AuditReport 是审计日志的验证结果。
+type AuditReport struct {
+	Entries uint64
+	Head    *AuditHead
+	Anchors []*AuditAnchor
+}
+
+// VerifyAudit 从第一条记录开始验证审计日志的哈希链，以及每个锚点的签名和它锚定的记录。
+// anchorPubkey 不为空时要求所有锚点由该公钥签名。日志被修改时返回 ErrAuditChainBroken。
+func (k *Keys) VerifyAudit(anchorPubkey string) (*AuditReport, error) {
+	k.auditMu.Lock()
+	defer k.auditMu.Unlock()
+	head, err := k.auditHead()
+	if err != nil {
+		return nil, err
+	}
+	anchors, err := k.ListAuditAnchors()
+	if err != nil {
+		return nil, err
+	}
+	anchored := make(map[uint64]*AuditAnchor, len(anchors))
+	for _, anchor := range anchors {
+		if anchorPubkey != "" && !strings.EqualFold(strings.TrimPrefix(anchor.Pubkey, "0x"), strings.TrimPrefix(anchorPubkey, "0x")) {
+			return nil, fmt.Errorf("%w: anchor %d is not signed by the audit key", ErrAuditChainBroken, anchor.Seq)
+		}
+		if !anchor.Verify() {
+			return nil, fmt.Errorf("%w: invalid signature of anchor %d", ErrAuditChainBroken, anchor.Seq)
+		}
+		anchored[anchor.Seq] = anchor
+	}
+
+	report := &AuditReport{Head: head, Anchors: anchors}
+	prev := &AuditHead{Hash: AuditGenesisHash}
+	var chainErr error
+	err = k.IterateAudit(0, func(entry *AuditEntry) bool {
+		switch {
+		case entry.Seq != prev.Seq+1:
+			chainErr = fmt.Errorf("%w: entry %d follows entry %d", ErrAuditChainBroken, entry.Seq, prev.Seq)
+		case entry.PrevHash != prev.Hash:
+			chainErr = fmt.Errorf("%w: previous hash of entry %d does not match", ErrAuditChainBroken, entry.Seq)
+		case entry.ComputeHash() != entry.Hash:
+			chainErr = fmt.Errorf("%w: hash of entry %d does not match its content", ErrAuditChainBroken, entry.Seq)
+		}
+		if anchor, isOk := anchored[entry.Seq]; chainErr == nil && isOk && anchor.Hash != entry.Hash {
+			chainErr = fmt.Errorf("%w: entry %d does not match its anchor", ErrAuditChainBroken, entry.Seq)
+		}
+		if chainErr != nil {
+			return false
+		}
+		prev = &AuditHead{Seq: entry.Seq, Hash: entry.Hash}
+		report.Entries++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chainErr != nil {
+		return nil, chainErr
+	}
+	if prev.Seq != head.Seq || prev.Hash != head.Hash {
+		return nil, fmt.Errorf("%w: the log ends at entry %d, the head is entry %d", ErrAuditChainBroken, prev.Seq, head.Seq)
+	}
+	for _, anchor := range anchors {
+		if anchor.Seq > head.Seq {
+			return nil, fmt.Errorf("%w: anchor %d is past the end of the log", ErrAuditChainBroken, anchor.Seq)
+		}
+	}
+	return report, nil
+}
+
+// isAuditKey 判断数据库中的键是否属于审计日志，审计日志只追加，恢复备份时不会覆盖。
+func isAuditKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(auditPrefix)) || bytes.HasPrefix(key, []byte(auditAnchorPrefix)) || string(key) == auditHeadKey
+}
+
+func auditKey(seq uint64) []byte {
+	return fmt.Appendf(nil, "%s%016x", auditPrefix, seq)
+}
+
+func auditAnchorKey(seq uint64) []byte {
+	return fmt.Appendf(nil, "%s%016x", auditAnchorPrefix, seq)
+}
@@ -0,0 +1,75 @@
+package leveldb
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	store := NewMemoryStore()
+	keys, err := NewKeys(store)
+	assert.NoError(t, err)
+
+	report, err := keys.VerifyAudit("")
+	assert.NoError(t, err)
+	assert.Zero(t, report.Entries)
+
+	assert.NoError(t, keys.AppendAudit([]*AuditEntry{
+		{Time: 1, Consumer: "c1", RPC: "exportPublicKeyList", Pubkey: "aa01", Result: "SUCCESS"},
+		{Time: 1, Consumer: "c1", RPC: "exportPublicKeyList", Pubkey: "aa02", Result: "SUCCESS"},
+	}))
+	assert.NoError(t, keys.AppendAudit([]*AuditEntry{{Time: 2, Consumer: "c1", RPC: "signTxMessage", Pubkey: "aa01", MessageHash: "0x01", Result: "SUCCESS"}}))
+	head, err := keys.GetAuditHead()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), head.Seq)
+
+	var entries []*AuditEntry
+	assert.NoError(t, keys.IterateAudit(2, func(entry *AuditEntry) bool {
+		entries = append(entries, entry)
+		return true
+	}))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, head.Hash, entries[1].Hash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+
+	// 锚点由审计密钥签名
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	anchor := &AuditAnchor{Seq: head.Seq, Hash: head.Hash, Time: 3, Pubkey: hex.EncodeToString(crypto.FromECDSAPub(&privateKey.PublicKey))}
+	signature, err := crypto.Sign(anchor.Digest(), privateKey)
+	assert.NoError(t, err)
+	anchor.Signature = hex.EncodeToString(signature)
+	assert.NoError(t, keys.PutAuditAnchor(anchor))
+	report, err = keys.VerifyAudit(anchor.Pubkey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), report.Entries)
+	assert.Len(t, report.Anchors, 1)
+	_, err = keys.VerifyAudit("04aa")
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+
+	// 修改任何一条记录都会被发现
+	entries[0].Result = "ERROR"
+	data, err := json.Marshal(entries[0])
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put(auditKey(2), data))
+	_, err = keys.VerifyAudit("")
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+
+	// 重新计算哈希后哈希链和锚点不再匹配
+	entries[0].Hash = entries[0].ComputeHash()
+	data, err = json.Marshal(entries[0])
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put(auditKey(2), data))
+	_, err = keys.VerifyAudit("")
+	assert.ErrorContains(t, err, "previous hash of entry 3")
+
+	// 删除最后一条记录
+	assert.NoError(t, store.Delete(auditKey(2)))
+	assert.NoError(t, store.Delete(auditKey(3)))
+	_, err = keys.VerifyAudit("")
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+}
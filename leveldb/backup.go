@@ -0,0 +1,319 @@
+package leveldb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/crypto/scrypt"
+)
+
+// 备份文件的格式：
+//
+//	magic(4) | version(1) | scryptLogN(1) | scryptR(1) | scryptP(1) | salt(16) | nonce(12) | ciphertext
+//
+// ciphertext 是使用 scrypt(passphrase, salt) 派生的密钥、以文件头为附加数据进行 AES-256-GCM 加密的明文：
+//
+//	createdAt(8) | count(8) | count * (uvarint(len(key)) | key | uvarint(len(value)) | value) | sha256(32)
+//
+// 其中 sha256 是之前所有明文的校验和。
+const (
+	backupVersion    = 1
+	backupScryptLogN = 18
+	backupScryptR    = 8
+	backupScryptP    = 1
+	// 读取备份时接受的 scrypt 参数上限
+	backupMaxScryptLogN = 20
+	backupMaxScryptR    = 8
+	backupMaxScryptP    = 4
+	backupSaltSize      = 16
+	backupNonceSize     = 12
+	backupHeaderSize    = 4 + 4 + backupSaltSize + backupNonceSize
+)
+
+var backupMagic = []byte("WSBK")
+
+var (
+	// ErrBackupCorrupted 表示备份文件已损坏或口令错误
+	ErrBackupCorrupted = errors.New("backup corrupted or wrong passphrase")
+	// ErrRestoreConflict 表示备份中的数据会覆盖已有的密钥
+	ErrRestoreConflict = errors.New("restore would overwrite existing keys")
+	// ErrRestoreSealMismatch 表示备份与数据库使用了不同的封存主密钥
+	ErrRestoreSealMismatch = errors.New("backup is sealed with a different master key")
+)
+
+// BackupEntry 是备份中的一个键值对。
+type BackupEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// Backup 是解密后的备份内容。
+type Backup struct {
+	CreatedAt int64
+	Entries   []BackupEntry
+}
+
+// RestoreReport 是恢复备份的结果，列出新增、覆盖和冲突的公钥。
+type RestoreReport struct {
+	// Added 是数据库中原本不存在的公钥
+	Added []string
+	// Overwritten 是数据库中已存在且内容不同、使用 force 覆盖的公钥
+	Overwritten []string
+	// Conflicts 是数据库中已存在且内容不同、未使用 force 时拒绝覆盖的公钥
+	Conflicts []string
+	// Unchanged 是数据库中已存在且内容相同的公钥数量
+	Unchanged int
+	// Entries 是写入数据库的键值对数量（包括索引等非密钥数据）
+	Entries int
+}
+
+// Backup 基于数据库快照将整个数据库加密导出到 w，返回导出的键值对数量。
+func (k *Keys) Backup(w io.Writer, passphrase string) (int, error) {
+	if passphrase == "" {
+		return 0, errors.New("backup passphrase is empty")
+	}
+	snapshot, err := k.db.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer snapshot.Release()
+
+	var plaintext bytes.Buffer
+	var count uint64
+	err = snapshot.Iterate(nil, nil, func(key, value []byte) bool {
+		plaintext.Write(binary.AppendUvarint(nil, uint64(len(key))))
+		plaintext.Write(key)
+		plaintext.Write(binary.AppendUvarint(nil, uint64(len(value))))
+		plaintext.Write(value)
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	payload := binary.BigEndian.AppendUint64(nil, uint64(time.Now().Unix()))
+	payload = binary.BigEndian.AppendUint64(payload, count)
+	payload = append(payload, plaintext.Bytes()...)
+	checksum := sha256.Sum256(payload)
+	payload = append(payload, checksum[:]...)
+
+	header := make([]byte, backupHeaderSize)
+	copy(header, backupMagic)
+	header[4], header[5], header[6], header[7] = backupVersion, backupScryptLogN, backupScryptR, backupScryptP
+	if _, err := io.ReadFull(rand.Reader, header[8:]); err != nil {
+		return 0, err
+	}
+	aead, err := newBackupCipher(passphrase, header)
+	if err != nil {
+		return 0, err
+	}
+	nonce := header[8+backupSaltSize:]
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(aead.Seal(nil, nonce, payload, header)); err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// ReadBackup 读取并解密备份文件，校验版本和校验和。
+func ReadBackup(r io.Reader, passphrase string) (*Backup, error) {
+	data, err := io.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < backupHeaderSize || !bytes.Equal(data[:4], backupMagic) {
+		return nil, errors.New("not a key store backup")
+	}
+	header, ciphertext := data[:backupHeaderSize], data[backupHeaderSize:]
+	if header[4] != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", header[4])
+	}
+	// 文件头未经认证，scrypt 参数只接受 WriteBackup 可能写入的范围，
+	// 避免构造的文件头在校验之前消耗过多的内存和时间
+	if header[5] < 10 || header[5] > backupMaxScryptLogN || header[6] == 0 || header[6] > backupMaxScryptR ||
+		header[7] == 0 || header[7] > backupMaxScryptP {
+		return nil, ErrBackupCorrupted
+	}
+	aead, err := newBackupCipher(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := aead.Open(nil, header[8+backupSaltSize:], ciphertext, header)
+	if err != nil {
+		return nil, ErrBackupCorrupted
+	}
+	if len(payload) < 16+sha256.Size {
+		return nil, ErrBackupCorrupted
+	}
+	body, checksum := payload[:len(payload)-sha256.Size], payload[len(payload)-sha256.Size:]
+	if sum := sha256.Sum256(body); !bytes.Equal(sum[:], checksum) {
+		return nil, ErrBackupCorrupted
+	}
+
+	backup := &Backup{CreatedAt: int64(binary.BigEndian.Uint64(body))}
+	count := binary.BigEndian.Uint64(body[8:])
+	reader := bytes.NewReader(body[16:])
+	for i := uint64(0); i < count; i++ {
+		key, err := readBackupBytes(reader)
+		if err != nil {
+			return nil, ErrBackupCorrupted
+		}
+		value, err := readBackupBytes(reader)
+		if err != nil {
+			return nil, ErrBackupCorrupted
+		}
+		backup.Entries = append(backup.Entries, BackupEntry{Key: key, Value: value})
+	}
+	if reader.Len() != 0 {
+		return nil, ErrBackupCorrupted
+	}
+	return backup, nil
+}
+
+// Restore 将备份写入数据库。
+// 备份中与数据库已有内容不同的密钥视为冲突，未设置 force 时不写入任何数据并返回 ErrRestoreConflict；
+// dryRun 时只计算 RestoreReport 而不写入数据库。写入在一个原子的 Batch 中完成，
+// 写入后按备份的结构版本重新执行数据迁移。备份的封存配置与数据库不同时返回 ErrRestoreSealMismatch。
+// 防罚没记录与数据库中的记录合并，取两者中较高的区块和投票，恢复旧备份不会降低已签名的高度。
+func (k *Keys) Restore(backup *Backup, force, dryRun bool) (*RestoreReport, error) {
+	// 合并防罚没记录到写入完成期间不能签名新的区块或投票
+	k.slashingMu.Lock()
+	defer k.slashingMu.Unlock()
+	report := &RestoreReport{}
+	batch := new(Batch)
+	backupVersion := -1
+	for _, entry := range backup.Entries {
+		if string(entry.Key) == schemaVersionKey {
+			version, err := strconv.Atoi(string(entry.Value))
+			if err != nil {
+				return nil, ErrBackupCorrupted
+			}
+			backupVersion = version
+			continue
+		}
+		// 审计日志只追加，恢复备份不能改写本地的审计日志
+		if isAuditKey(entry.Key) {
+			continue
+		}
+		if isSlashingKey(entry.Key) {
+			value, changed, err := k.mergeSlashingValue(entry.Key, entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			if changed {
+				batch.Put(entry.Key, value)
+			}
+			continue
+		}
+		// 密钥记录按公钥比较，备份中的记录可能来自命名空间之前的布局或其他调用方的命名空间
+		currentKey := entry.Key
+		pubkey, isRecord := recordPubkey(entry.Key)
+		var current []byte
+		var err error
+		if isRecord {
+			currentKey, current, err = k.getRecordValue(pubkey)
+		} else {
+			current, err = k.db.Get(entry.Key)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		exist := err == nil
+		if string(entry.Key) == sealConfigKey && exist && !bytes.Equal(current, entry.Value) {
+			// 覆盖封存配置会使数据库中已加密的私钥无法解密，即使设置了 force 也不允许
+			return nil, ErrRestoreSealMismatch
+		}
+		if exist && bytes.Equal(current, entry.Value) {
+			if isRecord {
+				report.Unchanged++
+			}
+			continue
+		}
+		if isRecord {
+			switch {
+			case !exist:
+				report.Added = append(report.Added, pubkey)
+			case force:
+				report.Overwritten = append(report.Overwritten, pubkey)
+			default:
+				report.Conflicts = append(report.Conflicts, pubkey)
+			}
+			if exist && !bytes.Equal(currentKey, entry.Key) {
+				batch.Delete(currentKey)
+			}
+		}
+		batch.Put(entry.Key, entry.Value)
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Overwritten)
+	sort.Strings(report.Conflicts)
+	report.Entries = batch.Len()
+
+	if len(report.Conflicts) > 0 {
+		return report, ErrRestoreConflict
+	}
+	if dryRun || batch.Len() == 0 {
+		return report, nil
+	}
+
+	// 备份的结构版本比当前数据库旧时，需要对恢复的数据重新执行之后的迁移
+	currentVersion, err := k.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if backupVersion >= 0 && backupVersion < currentVersion {
+		batch.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(backupVersion)))
+	}
+	if err := k.db.Write(batch); err != nil {
+		return nil, err
+	}
+	log.Info("restore key store", "added", len(report.Added), "overwritten", len(report.Overwritten), "entries", report.Entries)
+	if err := k.migrate(); err != nil {
+		return nil, err
+	}
+	if err := k.loadSealConfig(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func newBackupCipher(passphrase string, header []byte) (cipher.AEAD, error) {
+	salt := header[8 : 8+backupSaltSize]
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<header[5], int(header[6]), int(header[7]), 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func readBackupBytes(r *bytes.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	return data, err
+}
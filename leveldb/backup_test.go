@@ -0,0 +1,126 @@
+package leveldb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	src, err := NewKeyStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, src.StoreKeys([]Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa"},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa"},
+	}))
+
+	var buf bytes.Buffer
+	count, err := src.Backup(&buf, "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	_, err = ReadBackup(bytes.NewReader(buf.Bytes()), "wrong")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+	corrupted := bytes.Clone(buf.Bytes())
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, err = ReadBackup(bytes.NewReader(corrupted), "passphrase")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	backup, err := ReadBackup(bytes.NewReader(buf.Bytes()), "passphrase")
+	assert.NoError(t, err)
+	assert.Len(t, backup.Entries, 5)
+
+	dst, err := NewKeyStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, dst.StoreKeys([]Key{{PrivateKey: "03", Pubkey: "aa02", Type: "ecdsa"}}))
+
+	report, err := dst.Restore(backup, false, true)
+	assert.ErrorIs(t, err, ErrRestoreConflict)
+	assert.Equal(t, []string{"aa01"}, report.Added)
+	assert.Equal(t, []string{"aa02"}, report.Conflicts)
+	_, isOk := dst.GetKeyRecord("aa01")
+	assert.False(t, isOk)
+
+	report, err = dst.Restore(backup, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aa02"}, report.Overwritten)
+	_, isOk = dst.GetKeyRecord("aa01")
+	assert.False(t, isOk)
+
+	_, err = dst.Restore(backup, true, false)
+	assert.NoError(t, err)
+	privateKey, isOk := dst.GetPrivateKey("aa02")
+	assert.True(t, isOk)
+	assert.Equal(t, "02", privateKey)
+
+	report, err = dst.Restore(backup, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Zero(t, report.Entries)
+}
+
+func TestReadBackupScryptBounds(t *testing.T) {
+	src, err := NewKeyStore(t.TempDir())
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = src.Backup(&buf, "passphrase")
+	assert.NoError(t, err)
+
+	for _, params := range [][3]byte{{40, 8, 1}, {21, 8, 1}, {18, 16, 1}, {18, 8, 16}, {9, 8, 1}, {18, 0, 1}, {18, 8, 0}} {
+		crafted := bytes.Clone(buf.Bytes())
+		crafted[5], crafted[6], crafted[7] = params[0], params[1], params[2]
+		// 构造的参数在派生密钥之前被拒绝，不会分配 scrypt 的内存
+		start := time.Now()
+		_, err = ReadBackup(bytes.NewReader(crafted), "passphrase")
+		assert.ErrorIs(t, err, ErrBackupCorrupted, "scrypt parameters %v", params)
+		assert.Less(t, time.Since(start), 100*time.Millisecond, "scrypt parameters %v", params)
+	}
+}
+
+func TestRestoreSlashingRecords(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	gvr := "4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 10, "r10"))
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 4, 5, "a5"))
+
+	var buf bytes.Buffer
+	_, err = keys.Backup(&buf, "passphrase")
+	assert.NoError(t, err)
+	backup, err := ReadBackup(bytes.NewReader(buf.Bytes()), "passphrase")
+	assert.NoError(t, err)
+
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 11, "r11"))
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 5, 6, "a6"))
+
+	// 恢复旧的备份不能降低已签名的区块和投票
+	_, err = keys.Restore(backup, false, false)
+	assert.NoError(t, err)
+	err = keys.CheckAndRecordBlock(gvr, "aa01", 11, "other")
+	assert.ErrorIs(t, err, ErrSlashable)
+	err = keys.CheckAndRecordAttestation(gvr, "aa01", 5, 6, "other")
+	assert.ErrorIs(t, err, ErrSlashable)
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 11, "r11"))
+
+	// 数据库中没有的记录从备份中恢复
+	dst, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	_, err = dst.Restore(backup, false, false)
+	assert.NoError(t, err)
+	err = dst.CheckAndRecordBlock(gvr, "aa01", 10, "other")
+	assert.ErrorIs(t, err, ErrSlashable)
+	assert.NoError(t, dst.CheckAndRecordBlock(gvr, "aa01", 10, "r10"))
+}
+
+func TestMergeAttestations(t *testing.T) {
+	a := &SignedAttestation{SourceEpoch: 4, TargetEpoch: 5, SigningRoot: "a5"}
+	b := &SignedAttestation{SourceEpoch: 5, TargetEpoch: 6, SigningRoot: "a6"}
+	assert.Equal(t, a, mergeAttestations(a, nil))
+	assert.Equal(t, b, mergeAttestations(nil, b))
+	assert.Equal(t, b, mergeAttestations(a, b))
+	// 合并出的投票不是签名过的投票，没有签名根
+	c := &SignedAttestation{SourceEpoch: 6, TargetEpoch: 2, SigningRoot: "c"}
+	assert.Equal(t, &SignedAttestation{SourceEpoch: 6, TargetEpoch: 6}, mergeAttestations(b, c))
+}
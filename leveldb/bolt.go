@@ -0,0 +1,133 @@
+package leveldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltFileName 是 bbolt 数据库在数据目录中的文件名。
+const BoltFileName = "keys.bolt"
+
+// boltBucket 是保存所有键值对的 bucket。
+var boltBucket = []byte("keys")
+
+// BoltStore 是保存在 bbolt 单文件数据库中的 KeyStore，每次写入都在一个同步提交的事务中完成。
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开数据目录 path 下的 bbolt 数据库，目录或数据库不存在时会被创建。
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, err
+	}
+	handle, err := bolt.Open(filepath.Join(path, BoltFileName), 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Error("open bolt db file fail", "err", err)
+		return nil, err
+	}
+	err = handle.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = handle.Close()
+		return nil, err
+	}
+	return &BoltStore{db: handle}, nil
+}
+
+func (db *BoltStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get(key)
+		if data == nil {
+			return ErrNotFound
+		}
+		// bbolt 返回的值只在事务中有效
+		value = append([]byte{}, data...)
+		return nil
+	})
+	return value, err
+}
+
+func (db *BoltStore) Has(key []byte) (bool, error) {
+	var exist bool
+	err := db.db.View(func(tx *bolt.Tx) error {
+		exist = tx.Bucket(boltBucket).Get(key) != nil
+		return nil
+	})
+	return exist, err
+}
+
+func (db *BoltStore) Put(key, value []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, value)
+	})
+}
+
+func (db *BoltStore) Delete(key []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+func (db *BoltStore) Iterate(prefix, start []byte, fn func(key, value []byte) bool) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		seek := prefix
+		if bytes.Compare(start, seek) > 0 {
+			seek = start
+		}
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for key, value := cursor.Seek(seek); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			if !fn(key, value) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Write 在一个事务中写入 batch 的所有修改。
+func (db *BoltStore) Write(batch *Batch) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, op := range batch.ops {
+			var err error
+			if op.value == nil {
+				err = bucket.Delete(op.key)
+			} else {
+				err = bucket.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Snapshot 在一个只读事务中拷贝全部数据作为一致性视图。
+// 没有直接持有只读事务，是因为只读事务未释放时 bbolt 扩容数据文件的写入会被阻塞。
+func (db *BoltStore) Snapshot() (Snapshot, error) {
+	data := make(map[string][]byte)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(key, value []byte) error {
+			data[string(key)] = append([]byte{}, value...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &memorySnapshot{data: data}, nil
+}
+
+func (db *BoltStore) Close() error {
+	return db.db.Close()
+}
@@ -2,10 +2,14 @@
 package leveldb
 
 import (
+	"bytes"
 	"encoding/hex"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // LevelStore 是一个封装了LevelDB数据库的结构体。
@@ -58,6 +62,68 @@ func (db *LevelStore) Delete(key []byte) error {
 	return db.DB.Delete(key, nil)
 }
 
+// Has 判断 LevelStore 数据库中是否存在指定的键。
+func (db *LevelStore) Has(key []byte) (bool, error) {
+	return db.DB.Has(key, nil)
+}
+
+// Iterate 按键的顺序遍历所有以 prefix 开头、且不小于 start 的键值对。
+// start 为空时从 prefix 的第一个键开始遍历；fn 返回 false 时停止遍历。
+// 传给 fn 的 key 和 value 只在本次回调中有效，需要保留时应自行拷贝。
+func (db *LevelStore) Iterate(prefix, start []byte, fn func(key, value []byte) bool) error {
+	return iterateLevelDB(db.DB, prefix, start, fn)
+}
+
+// Write 在一个同步的 leveldb Batch 中写入 batch 的所有修改。
+func (db *LevelStore) Write(batch *Batch) error {
+	levelBatch := new(leveldb.Batch)
+	for _, op := range batch.ops {
+		if op.value == nil {
+			levelBatch.Delete(op.key)
+		} else {
+			levelBatch.Put(op.key, op.value)
+		}
+	}
+	return db.DB.Write(levelBatch, &opt.WriteOptions{Sync: true})
+}
+
+// Snapshot 返回基于 leveldb 快照的一致性视图。
+func (db *LevelStore) Snapshot() (Snapshot, error) {
+	snapshot, err := db.DB.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelSnapshot{snapshot}, nil
+}
+
+type levelSnapshot struct {
+	*leveldb.Snapshot
+}
+
+func (s *levelSnapshot) Iterate(prefix, start []byte, fn func(key, value []byte) bool) error {
+	return iterateLevelDB(s.Snapshot, prefix, start, fn)
+}
+
+// levelIteratee 是可以创建迭代器的 leveldb.DB 或 leveldb.Snapshot。
+type levelIteratee interface {
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+func iterateLevelDB(db levelIteratee, prefix, start []byte, fn func(key, value []byte) bool) error {
+	slice := util.BytesPrefix(prefix)
+	if len(start) > 0 && bytes.Compare(start, slice.Start) > 0 {
+		slice.Start = start
+	}
+	iter := db.NewIterator(slice, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
 func toBytes(dataStr string) []byte {
 	dataBytes, _ := hex.DecodeString(dataStr)
 	return dataBytes
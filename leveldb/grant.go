@@ -0,0 +1,106 @@
+package leveldb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// grantPrefix 是密钥授权的键前缀，键为 grantPrefix + 公钥 + "/" + ownerNamespace(被授权的调用方)
+	grantPrefix = "grant/"
+	// grantedPrefix 是按被授权的调用方索引授权的键前缀，键为 grantedPrefix + ownerNamespace(被授权的调用方) + "/" + 公钥
+	grantedPrefix = "granted/"
+)
+
+// KeyGrant 表示密钥的所有者允许另一个调用方使用该密钥签名。
+type KeyGrant struct {
+	Pubkey    string `json:"pubkey"`
+	Grantee   string `json:"grantee"`
+	GrantedAt int64  `json:"grantedAt"`
+}
+
+// GrantKey 允许调用方 grantee 使用公钥对应的密钥，重复授权不会报错。
+func (k *Keys) GrantKey(publicKey, grantee string) error {
+	exist, err := k.HasKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return ErrKeyNotFound
+	}
+	batch := new(Batch)
+	if err := batchGrant(batch, &KeyGrant{Pubkey: publicKey, Grantee: grantee, GrantedAt: time.Now().Unix()}); err != nil {
+		return err
+	}
+	return k.db.Write(batch)
+}
+
+// RevokeKey 撤销调用方 grantee 使用公钥对应密钥的授权，授权不存在时返回 false。
+func (k *Keys) RevokeKey(publicKey, grantee string) (bool, error) {
+	exist, err := k.HasKeyGrant(publicKey, grantee)
+	if err != nil || !exist {
+		return false, err
+	}
+	batch := new(Batch)
+	batchDeleteGrant(batch, publicKey, grantee)
+	return true, k.db.Write(batch)
+}
+
+// HasKeyGrant 判断调用方 grantee 是否被授权使用公钥对应的密钥。
+func (k *Keys) HasKeyGrant(publicKey, grantee string) (bool, error) {
+	return k.db.Has(grantKey(publicKey, grantee))
+}
+
+// ListKeyGrants 返回公钥对应密钥的所有授权。
+func (k *Keys) ListKeyGrants(publicKey string) ([]*KeyGrant, error) {
+	var grants []*KeyGrant
+	var decodeErr error
+	err := k.db.Iterate([]byte(grantPrefix+publicKey+"/"), nil, func(_, value []byte) bool {
+		var grant KeyGrant
+		if err := json.Unmarshal(value, &grant); err != nil {
+			decodeErr = err
+			return false
+		}
+		grants = append(grants, &grant)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grants, decodeErr
+}
+
+// listGrantedPubkeys 按顺序返回授权给调用方 grantee、且不小于 start 的公钥。
+func (k *Keys) listGrantedPubkeys(grantee, start string) ([]string, error) {
+	prefix := []byte(grantedPrefix + ownerNamespace(grantee) + "/")
+	var pubkeys []string
+	err := k.db.Iterate(prefix, append(bytes.Clone(prefix), start...), func(key, _ []byte) bool {
+		pubkeys = append(pubkeys, string(key[len(prefix):]))
+		return true
+	})
+	return pubkeys, err
+}
+
+func batchGrant(batch *Batch, grant *KeyGrant) error {
+	if grant.Grantee == "" {
+		return errors.New("grantee is empty")
+	}
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	batch.Put(grantKey(grant.Pubkey, grant.Grantee), data)
+	batch.Put([]byte(grantedPrefix+ownerNamespace(grant.Grantee)+"/"+grant.Pubkey), []byte{1})
+	return nil
+}
+
+func batchDeleteGrant(batch *Batch, publicKey, grantee string) {
+	batch.Delete(grantKey(publicKey, grantee))
+	batch.Delete([]byte(grantedPrefix + ownerNamespace(grantee) + "/" + publicKey))
+}
+
+func grantKey(publicKey, grantee string) []byte {
+	return []byte(grantPrefix + publicKey + "/" + ownerNamespace(grantee))
+}
@@ -1,33 +1,132 @@
 package leveldb
 
-import "github.com/ethereum/go-ethereum/log"
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
-// Keys 包含一个指向LevelStore的指针，用于管理密钥。
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+)
+
+// metaPrefix 是密钥二级索引的键前缀，索引的键为 metaPrefix + 公钥，值为 JSON 编码的 KeyMeta。
+const metaPrefix = "meta/"
+
+// Keys 基于 KeyStore 管理密钥。
 type Keys struct {
-	db *LevelStore
+	db KeyStore
+
+	// sealMu 保护封存配置和主密钥，启用封存后私钥使用主密钥加密保存
+	sealMu    sync.RWMutex
+	seal      *SealConfig
+	masterKey []byte
+
+	// auditMu 保证审计日志按顺序追加
+	auditMu sync.Mutex
+
+	// slashingMu 保证防罚没记录的检查和写入是原子的
+	slashingMu sync.Mutex
+
+	// recordMu 保证修改已有密钥记录的读取和写入不会交错，例如状态转换、到期销毁和解封时的加密
+	recordMu sync.Mutex
+}
+
+// LevelStore 返回存储后端的 goleveldb 数据库，用于读取数据库的统计信息；存储后端不是 goleveldb 时返回 false。
+func (k *Keys) LevelStore() (*LevelStore, bool) {
+	store, isOk := k.db.(*LevelStore)
+	return store, isOk
 }
 
+// NewKeyStore 打开 path 下的 goleveldb 数据库管理密钥。
 func NewKeyStore(path string) (*Keys, error) {
-	db, err := NewLevelStore(path)
+	return OpenKeyStore(DbBackendLevelDB, path)
+}
+
+// OpenKeyStore 按 backend 打开 path 下的数据库管理密钥，backend 的取值见 OpenStore。
+func OpenKeyStore(backend, path string) (*Keys, error) {
+	db, err := OpenStore(backend, path)
 	if err != nil {
-		log.Error("Could not create leveldb database.")
+		log.Error("Could not create key store database.", "backend", backend, "err", err)
 		return nil, err
 	}
-	return &Keys{
+	keys, err := NewKeys(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return keys, nil
+}
+
+// NewKeys 使用 db 管理密钥，db 中尚未执行的数据迁移会先被执行。
+func NewKeys(db KeyStore) (*Keys, error) {
+	keys := &Keys{
 		db: db,
-	}, nil
+	}
+	if err := keys.migrate(); err != nil {
+		log.Error("migrate key store fail", "err", err)
+		return nil, err
+	}
+	if err := keys.loadSealConfig(); err != nil {
+		log.Error("load key store seal config fail", "err", err)
+		return nil, err
+	}
+	return keys, nil
 }
 
 func (k *Keys) GetPrivateKey(publicKey string) (string, bool) {
-	key := []byte(publicKey)
-	data, err := k.db.Get(key)
+	record, isOk := k.GetKeyRecord(publicKey)
+	if !isOk || record.Status == KeyStatusDestroyed {
+		return "0x00", false
+	}
+	privateKey, err := k.DecryptPrivateKey(record)
 	if err != nil {
+		log.Error("decrypt private key fail", "err", err, "key", publicKey)
 		return "0x00", false
 	}
-	privateKey := toString(data)
 	return privateKey, true
 }
 
+// GetKeyRecord 读取公钥对应的密钥记录，早期的原始私钥会被转换为密钥记录返回。
+// 启用封存后记录中的私钥是密文，需要通过 DecryptPrivateKey 读取。
+func (k *Keys) GetKeyRecord(publicKey string) (*KeyRecord, bool) {
+	_, data, err := k.getRecordValue(publicKey)
+	if err != nil {
+		return nil, false
+	}
+	record, isRecord, err := decodeKeyRecord(data)
+	if err != nil {
+		log.Error("decode key record fail", "err", err, "key", publicKey)
+		return nil, false
+	}
+	if !isRecord {
+		record = &KeyRecord{
+			Version:    KeyRecordVersion,
+			PrivateKey: toString(data),
+			KeyMeta:    *inferKeyMeta(publicKey),
+		}
+	}
+	return record, true
+}
+
+// getRecordValue 返回公钥对应的密钥记录在数据库中的键和值。密钥记录保存在所属调用方的命名空间下，
+// 位置由二级索引中的 Consumer 决定；尚未迁移的早期数据直接保存在公钥下。
+func (k *Keys) getRecordValue(publicKey string) ([]byte, []byte, error) {
+	if meta, isOk := k.GetKeyMeta(publicKey); isOk {
+		key := recordKey(meta.Consumer, publicKey)
+		data, err := k.db.Get(key)
+		if !errors.Is(err, ErrNotFound) {
+			return key, data, err
+		}
+	}
+	data, err := k.db.Get([]byte(publicKey))
+	return []byte(publicKey), data, err
+}
+
 // StoreKeys 存储密钥列表到数据库中。
 // 参数:
 //
@@ -35,22 +134,180 @@ func (k *Keys) GetPrivateKey(publicKey string) (string, bool) {
 //
 // 返回值:
 //
-//	成功存储所有密钥返回true，否则返回false。
-func (k *Keys) StoreKeys(keyList []Key) bool {
-	// 遍历密钥列表，将每个密钥对存储到数据库中。
+//	所有密钥在一个同步写入的 Batch 中保存，要么全部持久化，要么都不保存；失败时返回错误。
+func (k *Keys) StoreKeys(keyList []Key) error {
+	batch := new(Batch)
+	if err := k.batchKeys(batch, keyList); err != nil {
+		return err
+	}
+	return k.db.Write(batch)
+}
+
+// batchKeys 将密钥列表中每个密钥的记录及其二级索引写入 batch。
+func (k *Keys) batchKeys(batch *Batch, keyList []Key) error {
+	createdAt := time.Now().Unix()
 	for _, item := range keyList {
-		// 将公钥转换为字节切片，用作数据库中的键。
-		key := []byte(item.Pubkey)
-		// 将私钥转换为字节切片，用作数据库中的值。
-		value := toBytes(item.PrivateKey)
-		// 将键值对存储到数据库中。
-		err := k.db.Put(key, value)
-		// 如果存储过程中发生错误，记录错误日志并返回false。
-		if err != nil {
-			log.Error("store key value fail", "err", err, "key", key, "value", value)
+		backend := item.Backend
+		if backend == "" {
+			backend = BackendLocal
+		}
+		record := &KeyRecord{
+			Version:    KeyRecordVersion,
+			PrivateKey: item.PrivateKey,
+			KeyMeta: KeyMeta{
+				Pubkey:         item.Pubkey,
+				CompressPubkey: item.CompressPubkey,
+				Type:           item.Type,
+				CreatedAt:      createdAt,
+				Consumer:       item.Consumer,
+				Labels:         item.Labels,
+				Backend:        backend,
+				DerivationPath: item.DerivationPath,
+				Origin:         item.Origin,
+				Status:         KeyStatusActive,
+			},
+		}
+		if err := k.batchKeyRecord(batch, record); err != nil {
+			return fmt.Errorf("store key %s: %w", item.Pubkey, err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭密钥库使用的数据库。
+func (k *Keys) Close() error {
+	return k.db.Close()
+}
+
+// HasKey 判断数据库中是否保存了公钥对应的密钥，每个密钥都有二级索引。
+func (k *Keys) HasKey(publicKey string) (bool, error) {
+	return k.db.Has([]byte(metaPrefix + publicKey))
+}
+
+// GetKeyMeta 从二级索引中读取公钥对应的密钥元数据，密钥不存在时返回 false。
+func (k *Keys) GetKeyMeta(publicKey string) (*KeyMeta, bool) {
+	data, err := k.db.Get([]byte(metaPrefix + publicKey))
+	if err != nil {
+		return nil, false
+	}
+	var meta KeyMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		log.Error("decode key meta fail", "err", err, "key", publicKey)
+		return nil, false
+	}
+	return &meta, true
+}
+
+// ListKeys 按公钥顺序分页查询满足过滤条件的密钥元数据。
+// pageToken 为上一页返回的 nextPageToken，为空时从第一页开始；
+// 返回的 nextPageToken 为空表示没有更多数据。
+func (k *Keys) ListKeys(filter KeyFilter, pageToken string, pageSize int) ([]*KeyMeta, string, error) {
+	var start []byte
+	if pageToken != "" {
+		// 从上一页最后一个公钥之后开始遍历
+		start = []byte(metaPrefix + pageToken + "\x00")
+	}
+	var metas []*KeyMeta
+	var nextPageToken string
+	var decodeErr error
+	err := k.db.Iterate([]byte(metaPrefix), start, func(_, value []byte) bool {
+		var meta KeyMeta
+		if err := json.Unmarshal(value, &meta); err != nil {
+			decodeErr = err
+			return false
+		}
+		if !filter.Match(&meta) {
+			return true
+		}
+		if len(metas) == pageSize {
+			nextPageToken = metas[len(metas)-1].Pubkey
+			return false
+		}
+		metas = append(metas, &meta)
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if decodeErr != nil {
+		return nil, "", decodeErr
+	}
+	return metas, nextPageToken, nil
+}
+
+// putKeyRecord 保存密钥记录，并在同一个 Batch 中更新二级索引。
+func (k *Keys) putKeyRecord(record *KeyRecord) error {
+	batch := new(Batch)
+	if err := k.batchKeyRecord(batch, record); err != nil {
+		return err
+	}
+	return k.db.Write(batch)
+}
+
+// batchKeyRecord 将密钥记录及其二级索引写入 batch，启用封存时私钥会先加密。
+func (k *Keys) batchKeyRecord(batch *Batch, record *KeyRecord) error {
+	if err := k.encryptRecord(record); err != nil {
+		return err
+	}
+	return batchRecord(batch, record)
+}
+
+// batchRecord 将密钥记录写入所属调用方的命名空间，同时更新二级索引，并删除早期直接保存在公钥下的数据。
+func batchRecord(batch *Batch, record *KeyRecord) error {
+	value, err := encodeKeyRecord(record)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(&record.KeyMeta)
+	if err != nil {
+		return err
+	}
+	batch.Put(recordKey(record.Consumer, record.Pubkey), value)
+	batch.Put([]byte(metaPrefix+record.Pubkey), meta)
+	batch.Delete([]byte(record.Pubkey))
+	return nil
+}
+
+func (k *Keys) putKeyMeta(meta *KeyMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return k.db.Put([]byte(metaPrefix+meta.Pubkey), data)
+}
+
+// inferKeyMeta 根据公钥长度推断历史密钥的元数据：
+// 65 字节的未压缩公钥为 ECDSA 密钥，32 字节的公钥为 EdDSA 密钥。
+func inferKeyMeta(pubkey string) *KeyMeta {
+	meta := &KeyMeta{
+		Pubkey:         pubkey,
+		CompressPubkey: pubkey,
+		Backend:        BackendLocal,
+		Status:         KeyStatusActive,
+		Origin:         OriginLegacy,
+	}
+	pubkeyBytes := toBytes(pubkey)
+	switch len(pubkeyBytes) {
+	case 65:
+		meta.Type = string(protobuf.ECDSA)
+		if publicKey, err := crypto.UnmarshalPubkey(pubkeyBytes); err == nil {
+			meta.CompressPubkey = hex.EncodeToString(crypto.CompressPubkey(publicKey))
+		}
+	case 32:
+		meta.Type = string(protobuf.EDDSA)
+	}
+	return meta
+}
+
+// isPubkeyKey 判断数据库中的键是否为公钥，即不带任何前缀的十六进制字符串。
+func isPubkeyKey(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
 			return false
 		}
 	}
-	// 所有密钥对成功存储，返回true。
 	return true
 }
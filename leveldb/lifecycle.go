@@ -0,0 +1,100 @@
+package leveldb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// ErrKeyNotFound 表示公钥对应的密钥不存在
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrInvalidKeyStatus 表示不支持的密钥状态转换
+	ErrInvalidKeyStatus = errors.New("invalid key status transition")
+)
+
+// keyStatusTransitions 定义了允许的密钥状态转换。
+// 等待销毁的密钥只能取消销毁（转为禁用）；销毁由 DestroyDueKeys 在等待期满后执行，
+// 已销毁的密钥不能再转换为其他状态。
+var keyStatusTransitions = map[string][]string{
+	KeyStatusActive:             {KeyStatusDisabled, KeyStatusArchived, KeyStatusPendingDestruction},
+	KeyStatusDisabled:           {KeyStatusActive, KeyStatusArchived, KeyStatusPendingDestruction},
+	KeyStatusArchived:           {KeyStatusActive, KeyStatusDisabled, KeyStatusPendingDestruction},
+	KeyStatusPendingDestruction: {KeyStatusDisabled},
+}
+
+// CanTransitKeyStatus 判断密钥能否从 from 状态转换为 to 状态。
+func CanTransitKeyStatus(from, to string) bool {
+	for _, status := range keyStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetKeyStatus 修改密钥的状态，转换为等待销毁时 destroyAt 为销毁时间，其他状态忽略 destroyAt。
+func (k *Keys) SetKeyStatus(publicKey, status string, destroyAt int64) (*KeyMeta, error) {
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	record, isOk := k.GetKeyRecord(publicKey)
+	if !isOk {
+		return nil, ErrKeyNotFound
+	}
+	if !CanTransitKeyStatus(record.Status, status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidKeyStatus, record.Status, status)
+	}
+	record.Status = status
+	record.DestroyAt = 0
+	if status == KeyStatusPendingDestruction {
+		record.DestroyAt = destroyAt
+	}
+	if err := k.putKeyRecord(record); err != nil {
+		return nil, err
+	}
+	return &record.KeyMeta, nil
+}
+
+// DestroyDueKeys 删除所有等待期已满（DestroyAt 不晚于 now）的密钥的私钥，并返回销毁的密钥数量。
+// 密钥的元数据会被保留，状态改为已销毁。
+func (k *Keys) DestroyDueKeys(now int64) (int, error) {
+	filter := KeyFilter{Status: KeyStatusPendingDestruction}
+	var due []string
+	pageToken := ""
+	for {
+		metas, nextPageToken, err := k.ListKeys(filter, pageToken, 1000)
+		if err != nil {
+			return 0, err
+		}
+		for _, meta := range metas {
+			if meta.DestroyAt <= now {
+				due = append(due, meta.Pubkey)
+			}
+		}
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	destroyed := 0
+	for _, pubkey := range due {
+		// 重新读取密钥记录，跳过期间已被取消销毁的密钥
+		record, isOk := k.GetKeyRecord(pubkey)
+		if !isOk || record.Status != KeyStatusPendingDestruction || record.DestroyAt > now {
+			continue
+		}
+		record.PrivateKey = ""
+		record.Encrypted = false
+		record.Status = KeyStatusDestroyed
+		if err := k.putKeyRecord(record); err != nil {
+			return destroyed, err
+		}
+		destroyed++
+		log.Info("destroy key", "key", pubkey)
+	}
+	return destroyed, nil
+}
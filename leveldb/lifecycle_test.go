@@ -0,0 +1,135 @@
+package leveldb
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyStatusTransitions(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	assert.NoError(t, keys.StoreKeys([]Key{{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: "alice"}}))
+
+	_, err = keys.SetKeyStatus("ff00", KeyStatusDisabled, 0)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	for _, test := range []struct {
+		status string
+		err    bool
+	}{
+		{KeyStatusActive, true},
+		{KeyStatusDisabled, false},
+		{KeyStatusArchived, false},
+		{KeyStatusActive, false},
+		{KeyStatusDestroyed, true},
+		{KeyStatusPendingDestruction, false},
+		// 等待销毁的密钥只能取消销毁
+		{KeyStatusActive, true},
+		{KeyStatusArchived, true},
+		{KeyStatusDisabled, false},
+		{KeyStatusActive, false},
+	} {
+		meta, err := keys.SetKeyStatus("aa01", test.status, 100)
+		if test.err {
+			assert.ErrorIs(t, err, ErrInvalidKeyStatus, test.status)
+			continue
+		}
+		assert.NoError(t, err, test.status)
+		assert.Equal(t, test.status, meta.Status)
+		stored, isOk := keys.GetKeyMeta("aa01")
+		assert.True(t, isOk)
+		assert.Equal(t, test.status, stored.Status)
+		if test.status == KeyStatusPendingDestruction {
+			assert.Equal(t, int64(100), stored.DestroyAt)
+		} else {
+			assert.Zero(t, stored.DestroyAt)
+		}
+	}
+}
+
+func TestDestroyDueKeys(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	assert.NoError(t, keys.StoreKeys([]Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: "alice"},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa", Consumer: "alice"},
+		{PrivateKey: "03", Pubkey: "aa03", Type: "ecdsa", Consumer: "bob"},
+	}))
+	_, err = keys.SetKeyStatus("aa01", KeyStatusPendingDestruction, 100)
+	assert.NoError(t, err)
+	_, err = keys.SetKeyStatus("aa02", KeyStatusPendingDestruction, 200)
+	assert.NoError(t, err)
+
+	// 等待期内不销毁
+	destroyed, err := keys.DestroyDueKeys(99)
+	assert.NoError(t, err)
+	assert.Zero(t, destroyed)
+	privateKey, isOk := keys.GetPrivateKey("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, "01", privateKey)
+
+	destroyed, err = keys.DestroyDueKeys(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, destroyed)
+	_, isOk = keys.GetPrivateKey("aa01")
+	assert.False(t, isOk)
+	record, isOk := keys.GetKeyRecord("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, KeyStatusDestroyed, record.Status)
+	assert.Empty(t, record.PrivateKey)
+	// 已销毁的密钥不能再转换状态，也不计入调用方的密钥数量
+	_, err = keys.SetKeyStatus("aa01", KeyStatusDisabled, 0)
+	assert.ErrorIs(t, err, ErrInvalidKeyStatus)
+	count, err := keys.CountConsumerKeys("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// 取消销毁的密钥不再销毁
+	_, err = keys.SetKeyStatus("aa02", KeyStatusDisabled, 0)
+	assert.NoError(t, err)
+	destroyed, err = keys.DestroyDueKeys(1000)
+	assert.NoError(t, err)
+	assert.Zero(t, destroyed)
+	privateKey, isOk = keys.GetPrivateKey("aa02")
+	assert.True(t, isOk)
+	assert.Equal(t, "02", privateKey)
+}
+
+func TestDestroyDueKeysConcurrently(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	assert.NoError(t, keys.StoreKeys([]Key{{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: "alice"}}))
+
+	// 并发的状态转换不会写回读取后已被销毁的记录，销毁的密钥不会恢复
+	var wg sync.WaitGroup
+	var destroyed atomic.Int64
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_, _ = keys.SetKeyStatus("aa01", KeyStatusPendingDestruction, 0)
+				_, _ = keys.SetKeyStatus("aa01", KeyStatusDisabled, 0)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				n, err := keys.DestroyDueKeys(1)
+				assert.NoError(t, err)
+				destroyed.Add(int64(n))
+			}
+		}()
+	}
+	wg.Wait()
+	record, isOk := keys.GetKeyRecord("aa01")
+	assert.True(t, isOk)
+	if destroyed.Load() > 0 {
+		assert.Equal(t, KeyStatusDestroyed, record.Status)
+		assert.Empty(t, record.PrivateKey)
+	} else {
+		assert.Equal(t, "01", record.PrivateKey)
+	}
+}
@@ -0,0 +1,120 @@
+package leveldb
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// MemoryStore 是保存在内存中的 KeyStore，进程退出后数据丢失，用于测试。
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (db *MemoryStore) Get(key []byte) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	value, isOk := db.data[string(key)]
+	if !isOk {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (db *MemoryStore) Has(key []byte) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	_, isOk := db.data[string(key)]
+	return isOk, nil
+}
+
+func (db *MemoryStore) Put(key, value []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.data[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (db *MemoryStore) Delete(key []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.data, string(key))
+	return nil
+}
+
+// Iterate 遍历调用时数据的副本，fn 中可以修改 MemoryStore。
+func (db *MemoryStore) Iterate(prefix, start []byte, fn func(key, value []byte) bool) error {
+	db.mu.RLock()
+	entries := collectEntries(db.data, prefix, start)
+	db.mu.RUnlock()
+	iterateEntries(entries, fn)
+	return nil
+}
+
+func (db *MemoryStore) Write(batch *Batch) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, op := range batch.ops {
+		if op.value == nil {
+			delete(db.data, string(op.key))
+		} else {
+			db.data[string(op.key)] = op.value
+		}
+	}
+	return nil
+}
+
+func (db *MemoryStore) Snapshot() (Snapshot, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	data := make(map[string][]byte, len(db.data))
+	for key, value := range db.data {
+		data[key] = value
+	}
+	return &memorySnapshot{data: data}, nil
+}
+
+func (db *MemoryStore) Close() error {
+	return nil
+}
+
+type memorySnapshot struct {
+	data map[string][]byte
+}
+
+func (s *memorySnapshot) Iterate(prefix, start []byte, fn func(key, value []byte) bool) error {
+	iterateEntries(collectEntries(s.data, prefix, start), fn)
+	return nil
+}
+
+func (s *memorySnapshot) Release() {
+	s.data = nil
+}
+
+// collectEntries 按键的顺序返回 data 中以 prefix 开头、且不小于 start 的键值对。
+func collectEntries(data map[string][]byte, prefix, start []byte) []batchOp {
+	var entries []batchOp
+	for key, value := range data {
+		if !bytes.HasPrefix([]byte(key), prefix) || bytes.Compare([]byte(key), start) < 0 {
+			continue
+		}
+		entries = append(entries, batchOp{key: []byte(key), value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	return entries
+}
+
+func iterateEntries(entries []batchOp, fn func(key, value []byte) bool) {
+	for _, entry := range entries {
+		if !fn(entry.key, append([]byte{}, entry.value...)) {
+			return
+		}
+	}
+}
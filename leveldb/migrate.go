@@ -0,0 +1,213 @@
+package leveldb
+
+import (
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// schemaVersionKey 保存数据库当前的结构版本号。
+const schemaVersionKey = "schema/version"
+
+// migrations 按顺序保存所有的数据迁移，数据库结构版本号为已执行的迁移数量。
+// 每个迁移都必须是可重入的：迁移中途失败后，重启时会重新执行整个迁移。
+var migrations = []func(k *Keys) error{
+	// 1: 为历史密钥补写二级索引
+	(*Keys).rebuildKeyIndex,
+	// 2: 将早期直接保存的原始私钥升级为带版本号的密钥记录
+	(*Keys).upgradeKeyRecords,
+	// 3: 将直接保存在公钥下的密钥记录移动到所属调用方的命名空间
+	(*Keys).namespaceKeyRecords,
+	// 4: 将来源为空的早期密钥标记为迁移的早期密钥
+	(*Keys).markLegacyKeys,
+}
+
+// migrate 执行数据库尚未执行的数据迁移。
+func (k *Keys) migrate() error {
+	version, err := k.schemaVersion()
+	if err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		log.Info("migrate key store", "from", version, "to", version+1)
+		if err := migrations[version](k); err != nil {
+			return err
+		}
+		if err := k.db.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version+1))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Keys) schemaVersion() (int, error) {
+	exist, err := k.db.Has([]byte(schemaVersionKey))
+	if err != nil || !exist {
+		return 0, err
+	}
+	data, err := k.db.Get([]byte(schemaVersionKey))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// listPubkeys 返回数据库中保存的所有公钥。
+func (k *Keys) listPubkeys() ([]string, error) {
+	var pubkeys []string
+	err := k.db.Iterate(nil, nil, func(key, _ []byte) bool {
+		if isPubkeyKey(key) {
+			pubkeys = append(pubkeys, string(key))
+		}
+		return true
+	})
+	return pubkeys, err
+}
+
+// rebuildKeyIndex 为没有二级索引的历史密钥补写索引，密钥类型根据公钥长度推断。
+func (k *Keys) rebuildKeyIndex() error {
+	pubkeys, err := k.listPubkeys()
+	if err != nil {
+		return err
+	}
+	rebuilt := 0
+	for _, pubkey := range pubkeys {
+		exist, err := k.db.Has([]byte(metaPrefix + pubkey))
+		if err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+		if err := k.putKeyMeta(inferKeyMeta(pubkey)); err != nil {
+			return err
+		}
+		rebuilt++
+	}
+	if rebuilt > 0 {
+		log.Info("rebuild key index", "keys", rebuilt)
+	}
+	return nil
+}
+
+// upgradeKeyRecords 将原始私钥原地升级为密钥记录，元数据取自二级索引。
+func (k *Keys) upgradeKeyRecords() error {
+	pubkeys, err := k.listPubkeys()
+	if err != nil {
+		return err
+	}
+	upgraded := 0
+	for _, pubkey := range pubkeys {
+		value, err := k.db.Get([]byte(pubkey))
+		if err != nil {
+			return err
+		}
+		if isKeyRecord(value) {
+			continue
+		}
+		meta, isOk := k.GetKeyMeta(pubkey)
+		if !isOk {
+			meta = inferKeyMeta(pubkey)
+		}
+		if meta.Backend == "" {
+			meta.Backend = BackendLocal
+		}
+		if meta.Status == "" {
+			meta.Status = KeyStatusActive
+		}
+		if meta.Origin == "" {
+			meta.Origin = OriginLegacy
+		}
+		record := &KeyRecord{
+			Version:    KeyRecordVersion,
+			PrivateKey: toString(value),
+			KeyMeta:    *meta,
+		}
+		// 迁移不加密私钥，封存的密钥库会在解封时加密明文私钥
+		batch := new(Batch)
+		if err := batchRecord(batch, record); err != nil {
+			return err
+		}
+		if err := k.db.Write(batch); err != nil {
+			return err
+		}
+		upgraded++
+	}
+	if upgraded > 0 {
+		log.Info("upgrade key records", "keys", upgraded)
+	}
+	return nil
+}
+
+// namespaceKeyRecords 将直接保存在公钥下的密钥记录移动到所属调用方的命名空间。
+func (k *Keys) namespaceKeyRecords() error {
+	pubkeys, err := k.listPubkeys()
+	if err != nil {
+		return err
+	}
+	batch := new(Batch)
+	moved := 0
+	for _, pubkey := range pubkeys {
+		value, err := k.db.Get([]byte(pubkey))
+		if err != nil {
+			return err
+		}
+		record, isRecord, err := decodeKeyRecord(value)
+		if err != nil {
+			return err
+		}
+		if !isRecord {
+			continue
+		}
+		if err := batchRecord(batch, record); err != nil {
+			return err
+		}
+		moved++
+	}
+	if moved == 0 {
+		return nil
+	}
+	if err := k.db.Write(batch); err != nil {
+		return err
+	}
+	log.Info("move key records to consumer namespaces", "keys", moved)
+	return nil
+}
+
+// markLegacyKeys 将记录来源之前创建的密钥标记为 OriginLegacy。
+func (k *Keys) markLegacyKeys() error {
+	var records []*KeyRecord
+	var decodeErr error
+	err := k.db.Iterate([]byte(namespacePrefix), nil, func(_, value []byte) bool {
+		record, _, err := decodeKeyRecord(value)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if record != nil && record.Origin == "" {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	batch := new(Batch)
+	for _, record := range records {
+		record.Origin = OriginLegacy
+		if err := batchRecord(batch, record); err != nil {
+			return err
+		}
+	}
+	if err := k.db.Write(batch); err != nil {
+		return err
+	}
+	log.Info("mark legacy keys", "keys", len(records))
+	return nil
+}
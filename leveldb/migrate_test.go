@@ -0,0 +1,109 @@
+package leveldb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshotStore 返回数据库中所有的键值对。
+func snapshotStore(t *testing.T, db KeyStore) map[string]string {
+	snapshot := make(map[string]string)
+	err := db.Iterate(nil, nil, func(key, value []byte) bool {
+		snapshot[string(key)] = string(bytes.Clone(value))
+		return true
+	})
+	assert.NoError(t, err)
+	return snapshot
+}
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	db := NewMemoryStore()
+	ecdsaKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	ecdsaPubkey := hex.EncodeToString(crypto.FromECDSAPub(&ecdsaKey.PublicKey))
+	ecdsaPrivateKey := hex.EncodeToString(crypto.FromECDSA(ecdsaKey))
+	eddsaPubkey := "aa006da3938f3d4a8d8430a64e3b14747b057cdd16c852f3b7e5e8a51ff56e61"
+	eddsaPrivateKey := "8810a07a2298bfa4d7ef16e49ed7f224b6eaa4ab9b0f3fd8c7141e4e9d528088" + eddsaPubkey
+	// 最早的数据库只在公钥下保存原始私钥
+	assert.NoError(t, db.Put([]byte(ecdsaPubkey), toBytes(ecdsaPrivateKey)))
+	assert.NoError(t, db.Put([]byte(eddsaPubkey), toBytes(eddsaPrivateKey)))
+	// 之后的数据库为原始私钥写了二级索引，但没有后端和状态
+	assert.NoError(t, db.Put([]byte("bb01"), toBytes("01")))
+	assert.NoError(t, db.Put([]byte(metaPrefix+"bb01"), []byte(`{"pubkey":"bb01","compressPubkey":"bb01","type":"ecdsa","createdAt":1700000000,"consumer":"alice","labels":["hot"]}`)))
+
+	keys, err := NewKeys(db)
+	assert.NoError(t, err)
+	version, err := keys.schemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+
+	for _, test := range []struct {
+		pubkey, privateKey string
+		meta               KeyMeta
+	}{
+		{ecdsaPubkey, ecdsaPrivateKey, KeyMeta{
+			Pubkey:         ecdsaPubkey,
+			CompressPubkey: hex.EncodeToString(crypto.CompressPubkey(&ecdsaKey.PublicKey)),
+			Type:           "ecdsa",
+			Backend:        BackendLocal,
+			Status:         KeyStatusActive,
+			Origin:         OriginLegacy,
+		}},
+		{eddsaPubkey, eddsaPrivateKey, KeyMeta{
+			Pubkey:         eddsaPubkey,
+			CompressPubkey: eddsaPubkey,
+			Type:           "eddsa",
+			Backend:        BackendLocal,
+			Status:         KeyStatusActive,
+			Origin:         OriginLegacy,
+		}},
+		{"bb01", "01", KeyMeta{
+			Pubkey:         "bb01",
+			CompressPubkey: "bb01",
+			Type:           "ecdsa",
+			CreatedAt:      1700000000,
+			Consumer:       "alice",
+			Labels:         []string{"hot"},
+			Backend:        BackendLocal,
+			Status:         KeyStatusActive,
+			Origin:         OriginLegacy,
+		}},
+	} {
+		// 原始私钥升级为命名空间下的密钥记录
+		exist, err := db.Has([]byte(test.pubkey))
+		assert.NoError(t, err)
+		assert.False(t, exist)
+		value, err := db.Get(recordKey(test.meta.Consumer, test.pubkey))
+		assert.NoError(t, err)
+		record, isRecord, err := decodeKeyRecord(value)
+		assert.NoError(t, err)
+		assert.True(t, isRecord)
+		assert.Equal(t, KeyRecordVersion, record.Version)
+		assert.Equal(t, test.privateKey, record.PrivateKey)
+		assert.Equal(t, test.meta, record.KeyMeta)
+
+		// 二级索引与密钥记录的元数据一致
+		meta, isOk := keys.GetKeyMeta(test.pubkey)
+		assert.True(t, isOk)
+		assert.Equal(t, test.meta, *meta)
+		privateKey, isOk := keys.GetPrivateKey(test.pubkey)
+		assert.True(t, isOk)
+		assert.Equal(t, test.privateKey, privateKey)
+	}
+	metas, _, err := keys.ListKeys(KeyFilter{Type: "ecdsa"}, "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, metas, 2)
+	// 迁移的没有调用方的早期密钥不属于任何调用方
+	assert.Empty(t, listConsumerPubkeys(t, keys, "bob", 10))
+
+	// 重新执行所有迁移不修改任何数据
+	snapshot := snapshotStore(t, db)
+	assert.NoError(t, db.Put([]byte(schemaVersionKey), []byte("0")))
+	_, err = NewKeys(db)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, snapshotStore(t, db))
+}
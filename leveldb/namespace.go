@@ -0,0 +1,250 @@
+package leveldb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// namespacePrefix 是密钥记录的键前缀。每个调用方的密钥保存在自己的命名空间下，
+// 键为 namespacePrefix + ownerNamespace(consumer) + "/" + 公钥。
+const namespacePrefix = "ns/"
+
+// ErrKeyHasOwner 表示密钥已经属于某个调用方
+var ErrKeyHasOwner = errors.New("key already has an owner")
+
+// ownerNamespace 返回调用方的命名空间，键中只保存调用方标识的哈希。
+// 调用方为空的密钥属于同一个命名空间，只有管理员可以使用，需要通过 AssignKeyOwner 移动到调用方的命名空间。
+func ownerNamespace(consumer string) string {
+	hash := sha256.Sum256([]byte(consumer))
+	return hex.EncodeToString(hash[:16])
+}
+
+func namespaceKeyPrefix(consumer string) []byte {
+	return []byte(namespacePrefix + ownerNamespace(consumer) + "/")
+}
+
+// recordKey 返回调用方 consumer 的密钥记录在数据库中的键。
+func recordKey(consumer, pubkey string) []byte {
+	return append(namespaceKeyPrefix(consumer), pubkey...)
+}
+
+// recordPubkey 判断数据库中的键是否为密钥记录，并返回其公钥。
+// 密钥记录保存在命名空间下，早期的密钥记录直接保存在公钥下。
+func recordPubkey(key []byte) (string, bool) {
+	if isPubkeyKey(key) {
+		return string(key), true
+	}
+	if !bytes.HasPrefix(key, []byte(namespacePrefix)) {
+		return "", false
+	}
+	parts := strings.Split(string(key[len(namespacePrefix):]), "/")
+	if len(parts) != 2 || !isPubkeyKey([]byte(parts[1])) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// ListConsumerKeys 与 ListKeys 相同，但只返回调用方 consumer 可以使用的密钥：
+// 自己创建的密钥以及其他调用方授权给自己的密钥。
+func (k *Keys) ListConsumerKeys(consumer string, filter KeyFilter, pageToken string, pageSize int) ([]*KeyMeta, string, error) {
+	var start string
+	if pageToken != "" {
+		// 从上一页最后一个公钥之后开始遍历
+		start = pageToken + "\x00"
+	}
+	prefix := namespaceKeyPrefix(consumer)
+
+	// 每个来源按公钥顺序最多取 pageSize+1 个密钥，合并后的前 pageSize+1 个一定在其中
+	metas := make(map[string]*KeyMeta)
+	var decodeErr error
+	found := 0
+	err := k.db.Iterate(prefix, append(bytes.Clone(prefix), start...), func(_, value []byte) bool {
+		record, _, err := decodeKeyRecord(value)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if record == nil || !filter.Match(&record.KeyMeta) {
+			return true
+		}
+		metas[record.Pubkey] = &record.KeyMeta
+		found++
+		return found <= pageSize
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if decodeErr != nil {
+		return nil, "", decodeErr
+	}
+	granted, err := k.listGrantedPubkeys(consumer, start)
+	if err != nil {
+		return nil, "", err
+	}
+	found = 0
+	for _, pubkey := range granted {
+		if found > pageSize {
+			break
+		}
+		meta, isOk := k.GetKeyMeta(pubkey)
+		if !isOk || !filter.Match(meta) {
+			continue
+		}
+		metas[pubkey] = meta
+		found++
+	}
+
+	pubkeys := make([]string, 0, len(metas))
+	for pubkey := range metas {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	sort.Strings(pubkeys)
+	var nextPageToken string
+	if len(pubkeys) > pageSize {
+		pubkeys = pubkeys[:pageSize]
+		nextPageToken = pubkeys[pageSize-1]
+	}
+	result := make([]*KeyMeta, 0, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		result = append(result, metas[pubkey])
+	}
+	return result, nextPageToken, nil
+}
+
+// CountConsumerKeys 返回调用方 consumer 拥有的未销毁的密钥数量，不包括授权给它的密钥。
+func (k *Keys) CountConsumerKeys(consumer string) (int, error) {
+	count := 0
+	var decodeErr error
+	err := k.db.Iterate(namespaceKeyPrefix(consumer), nil, func(_, value []byte) bool {
+		record, _, err := decodeKeyRecord(value)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if record != nil && record.Status != KeyStatusDestroyed {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, decodeErr
+}
+
+// AssignKeyOwner 将没有调用方的密钥移动到调用方 consumer 的命名空间，之后 consumer 可以使用并授权该密钥。
+// 密钥已经属于某个调用方时返回 ErrKeyHasOwner。
+func (k *Keys) AssignKeyOwner(publicKey, consumer string) (*KeyMeta, error) {
+	if consumer == "" {
+		return nil, errors.New("consumer must not be empty")
+	}
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	record, isOk := k.GetKeyRecord(publicKey)
+	if !isOk {
+		return nil, ErrKeyNotFound
+	}
+	if record.Consumer != "" {
+		return nil, fmt.Errorf("%w: %s", ErrKeyHasOwner, record.Consumer)
+	}
+	batch := new(Batch)
+	batch.Delete(recordKey("", record.Pubkey))
+	record.Consumer = consumer
+	if err := batchRecord(batch, record); err != nil {
+		return nil, err
+	}
+	if err := k.db.Write(batch); err != nil {
+		return nil, err
+	}
+	return &record.KeyMeta, nil
+}
+
+// ReassignConsumers 将密钥及授权中的调用方标识按 consumers 替换，并把密钥记录移动到新调用方的命名空间。
+// 用于启用调用方配置后，把早期以凭证作为标识的密钥转移到配置的调用方 id 下，可以重复执行。
+func (k *Keys) ReassignConsumers(consumers map[string]string) (int, error) {
+	return k.RenameConsumers(func(consumer string) (string, bool) {
+		renamed, isOk := consumers[consumer]
+		return renamed, isOk
+	})
+}
+
+// RenameConsumers 将密钥及授权中 rename 返回 true 的调用方标识替换为 rename 返回的标识，
+// 并把密钥记录移动到新调用方的命名空间。所有修改在一个 Batch 中写入，返回移动的密钥数量，可以重复执行。
+func (k *Keys) RenameConsumers(rename func(consumer string) (string, bool)) (int, error) {
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	var records [][]byte
+	err := k.db.Iterate([]byte(namespacePrefix), nil, func(key, value []byte) bool {
+		records = append(records, bytes.Clone(key))
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	batch := new(Batch)
+	reassigned := 0
+	for _, key := range records {
+		value, err := k.db.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		record, _, err := decodeKeyRecord(value)
+		if err != nil {
+			return 0, err
+		}
+		if record == nil {
+			continue
+		}
+		consumer, isOk := rename(record.Consumer)
+		if !isOk || consumer == record.Consumer {
+			continue
+		}
+		batch.Delete(key)
+		record.Consumer = consumer
+		if err := batchRecord(batch, record); err != nil {
+			return 0, err
+		}
+		reassigned++
+	}
+
+	var grants []*KeyGrant
+	var decodeErr error
+	err = k.db.Iterate([]byte(grantPrefix), nil, func(_, value []byte) bool {
+		var grant KeyGrant
+		if err := json.Unmarshal(value, &grant); err != nil {
+			decodeErr = err
+			return false
+		}
+		grants = append(grants, &grant)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	for _, grant := range grants {
+		grantee, isOk := rename(grant.Grantee)
+		if !isOk || grantee == grant.Grantee {
+			continue
+		}
+		batchDeleteGrant(batch, grant.Pubkey, grant.Grantee)
+		grant.Grantee = grantee
+		if err := batchGrant(batch, grant); err != nil {
+			return 0, err
+		}
+	}
+
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+	return reassigned, k.db.Write(batch)
+}
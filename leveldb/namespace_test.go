@@ -0,0 +1,130 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listConsumerPubkeys(t *testing.T, keys *Keys, consumer string, pageSize int) []string {
+	var pubkeys []string
+	pageToken := ""
+	for {
+		metas, nextPageToken, err := keys.ListConsumerKeys(consumer, KeyFilter{}, pageToken, pageSize)
+		assert.NoError(t, err)
+		for _, meta := range metas {
+			pubkeys = append(pubkeys, meta.Pubkey)
+		}
+		if nextPageToken == "" {
+			return pubkeys
+		}
+		pageToken = nextPageToken
+	}
+}
+
+func TestConsumerNamespaces(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	assert.NoError(t, keys.StoreKeys([]Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: "alice"},
+		{PrivateKey: "03", Pubkey: "aa03", Type: "ecdsa", Consumer: "alice"},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa", Consumer: "bob"},
+		{PrivateKey: "04", Pubkey: "aa04", Type: "ecdsa", Consumer: "bob"},
+		{PrivateKey: "05", Pubkey: "aa05", Type: "ecdsa", Origin: OriginLegacy},
+		{PrivateKey: "06", Pubkey: "aa06", Type: "ecdsa", Origin: OriginGenerated},
+	}))
+
+	// 密钥记录按调用方分开保存
+	exist, err := keys.db.Has(recordKey("alice", "aa01"))
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = keys.db.Has([]byte("aa01"))
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	record, isOk := keys.GetKeyRecord("aa02")
+	assert.True(t, isOk)
+	assert.Equal(t, "bob", record.Consumer)
+
+	assert.Equal(t, []string{"aa01", "aa03"}, listConsumerPubkeys(t, keys, "alice", 1))
+	assert.Equal(t, []string{"aa02", "aa04"}, listConsumerPubkeys(t, keys, "bob", 2))
+	count, err := keys.CountConsumerKeys("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.ErrorIs(t, keys.GrantKey("ff00", "alice"), ErrKeyNotFound)
+	assert.NoError(t, keys.GrantKey("aa04", "alice"))
+	exist, err = keys.HasKeyGrant("aa04", "alice")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, []string{"aa01", "aa03", "aa04"}, listConsumerPubkeys(t, keys, "alice", 1))
+	assert.Equal(t, []string{"aa01", "aa03", "aa04"}, listConsumerPubkeys(t, keys, "alice", 10))
+	grants, err := keys.ListKeyGrants("aa04")
+	assert.NoError(t, err)
+	assert.Len(t, grants, 1)
+	assert.Equal(t, "alice", grants[0].Grantee)
+
+	reassigned, err := keys.ReassignConsumers(map[string]string{"alice": "alice-id"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reassigned)
+	assert.Equal(t, []string{"aa01", "aa03", "aa04"}, listConsumerPubkeys(t, keys, "alice-id", 10))
+	assert.Empty(t, listConsumerPubkeys(t, keys, "alice", 10))
+	assert.Equal(t, []string{"aa02", "aa04"}, listConsumerPubkeys(t, keys, "bob", 10))
+	privateKey, isOk := keys.GetPrivateKey("aa03")
+	assert.True(t, isOk)
+	assert.Equal(t, "03", privateKey)
+	reassigned, err = keys.ReassignConsumers(map[string]string{"alice": "alice-id"})
+	assert.NoError(t, err)
+	assert.Zero(t, reassigned)
+
+	revoked, err := keys.RevokeKey("aa04", "alice-id")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = keys.RevokeKey("aa04", "alice-id")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Equal(t, []string{"aa01", "aa03"}, listConsumerPubkeys(t, keys, "alice-id", 10))
+
+	// 没有调用方的密钥不属于任何调用方，指定调用方后移动到其命名空间
+	meta, err := keys.AssignKeyOwner("aa05", "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", meta.Consumer)
+	assert.Equal(t, OriginLegacy, meta.Origin)
+	assert.Equal(t, []string{"aa02", "aa04", "aa05"}, listConsumerPubkeys(t, keys, "bob", 10))
+	exist, err = keys.db.Has(recordKey("", "aa05"))
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	privateKey, isOk = keys.GetPrivateKey("aa05")
+	assert.True(t, isOk)
+	assert.Equal(t, "05", privateKey)
+	_, err = keys.AssignKeyOwner("aa05", "alice-id")
+	assert.ErrorIs(t, err, ErrKeyHasOwner)
+	_, err = keys.AssignKeyOwner("ff00", "bob")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestNamespaceMigration(t *testing.T) {
+	db := NewMemoryStore()
+	// 结构版本 2 的数据库：密钥记录直接保存在公钥下
+	value, err := encodeKeyRecord(&KeyRecord{
+		Version:    KeyRecordVersion,
+		PrivateKey: "01",
+		KeyMeta:    KeyMeta{Pubkey: "aa01", Type: "ecdsa", Consumer: "alice", Status: KeyStatusActive},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Put([]byte("aa01"), value))
+	assert.NoError(t, db.Put([]byte(metaPrefix+"aa01"), []byte(`{"pubkey":"aa01","type":"ecdsa","consumer":"alice","status":"active"}`)))
+	assert.NoError(t, db.Put([]byte(schemaVersionKey), []byte("2")))
+
+	keys, err := NewKeys(db)
+	assert.NoError(t, err)
+	exist, err := db.Has([]byte("aa01"))
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	privateKey, isOk := keys.GetPrivateKey("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, "01", privateKey)
+	assert.Equal(t, []string{"aa01"}, listConsumerPubkeys(t, keys, "alice", 10))
+	meta, isOk := keys.GetKeyMeta("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, OriginLegacy, meta.Origin)
+}
@@ -0,0 +1,40 @@
+package leveldb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// recordMagic 是密钥记录的前缀，用于区分密钥记录和早期直接保存的原始私钥。
+// 原始私钥为 32 字节（ECDSA）或 64 字节（EdDSA），而密钥记录总是比 64 字节长。
+var recordMagic = []byte("wkr:")
+
+// encodeKeyRecord 将密钥记录编码为 recordMagic + JSON。
+func encodeKeyRecord(record *KeyRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, recordMagic...), data...), nil
+}
+
+// isKeyRecord 判断保存在公钥下的值是否为密钥记录。
+func isKeyRecord(value []byte) bool {
+	return len(value) > 64 && bytes.HasPrefix(value, recordMagic)
+}
+
+// decodeKeyRecord 解码保存在公钥下的值，早期的原始私钥返回 false。
+func decodeKeyRecord(value []byte) (*KeyRecord, bool, error) {
+	if !isKeyRecord(value) {
+		return nil, false, nil
+	}
+	var record KeyRecord
+	if err := json.Unmarshal(value[len(recordMagic):], &record); err != nil {
+		return nil, true, err
+	}
+	if record.Version > KeyRecordVersion {
+		return nil, true, fmt.Errorf("unsupported key record version %d", record.Version)
+	}
+	return &record, true, nil
+}
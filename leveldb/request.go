@@ -0,0 +1,98 @@
+package leveldb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// requestPrefix 是密钥生成请求的键前缀，键为 requestPrefix + hex(sha256(consumer | 0 | requestId))，
+// 不同调用方使用相同的请求 id 互不影响。
+const requestPrefix = "req/"
+
+// KeyRequest 记录一次带请求 id 的密钥生成请求及其生成的公钥，用于重试时返回相同的密钥。
+type KeyRequest struct {
+	RequestID string   `json:"requestId"`
+	Consumer  string   `json:"consumer"`
+	Type      string   `json:"type"`
+	Number    int      `json:"number"`
+	Labels    []string `json:"labels,omitempty"`
+	// Keys 是本次请求生成的公钥，顺序与返回给调用方的顺序相同
+	Keys      []RequestKey `json:"keys"`
+	CreatedAt int64        `json:"createdAt"`
+}
+
+type RequestKey struct {
+	Pubkey         string `json:"pubkey"`
+	CompressPubkey string `json:"compressPubkey"`
+}
+
+// SameParams 判断重试的请求参数是否与记录的请求一致。
+func (r *KeyRequest) SameParams(other *KeyRequest) bool {
+	return r.Type == other.Type && r.Number == other.Number && slices.Equal(r.Labels, other.Labels)
+}
+
+// GetKeyRequest 读取调用方 consumer 的请求 requestID 的记录，不存在时返回 false。
+func (k *Keys) GetKeyRequest(consumer, requestID string) (*KeyRequest, bool, error) {
+	data, err := k.db.Get(keyRequestKey(consumer, requestID))
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var request KeyRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, false, err
+	}
+	return &request, true, nil
+}
+
+// StoreRequestKeys 与 StoreKeys 相同，同时在同一个 Batch 中保存请求记录，
+// 保证密钥与请求记录要么都持久化，要么都不保存。
+func (k *Keys) StoreRequestKeys(request *KeyRequest, keyList []Key) error {
+	batch := new(Batch)
+	if err := k.batchKeys(batch, keyList); err != nil {
+		return err
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	batch.Put(keyRequestKey(request.Consumer, request.RequestID), data)
+	return k.db.Write(batch)
+}
+
+// DeleteExpiredRequests 删除创建时间早于 before 的请求记录，返回删除的数量。
+func (k *Keys) DeleteExpiredRequests(before int64) (int, error) {
+	batch := new(Batch)
+	var decodeErr error
+	err := k.db.Iterate([]byte(requestPrefix), nil, func(key, value []byte) bool {
+		var request KeyRequest
+		if err := json.Unmarshal(value, &request); err != nil {
+			decodeErr = err
+			return false
+		}
+		if request.CreatedAt < before {
+			batch.Delete(key)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+	return batch.Len(), k.db.Write(batch)
+}
+
+func keyRequestKey(consumer, requestID string) []byte {
+	hash := sha256.Sum256([]byte(consumer + "\x00" + requestID))
+	return []byte(requestPrefix + hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,50 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRequests(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+
+	request := &KeyRequest{
+		RequestID: "r1",
+		Consumer:  "c1",
+		Type:      "ecdsa",
+		Number:    1,
+		Keys:      []RequestKey{{Pubkey: "aa01", CompressPubkey: "bb01"}},
+		CreatedAt: 100,
+	}
+	assert.NoError(t, keys.StoreRequestKeys(request, []Key{{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa"}}))
+	exist, err := keys.HasKey("aa01")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	stored, isOk, err := keys.GetKeyRequest("c1", "r1")
+	assert.NoError(t, err)
+	assert.True(t, isOk)
+	assert.Equal(t, request, stored)
+	assert.True(t, stored.SameParams(&KeyRequest{Type: "ecdsa", Number: 1, Labels: []string{}}))
+	assert.False(t, stored.SameParams(&KeyRequest{Type: "ecdsa", Number: 2}))
+
+	// 请求 id 按调用方隔离
+	_, isOk, err = keys.GetKeyRequest("c2", "r1")
+	assert.NoError(t, err)
+	assert.False(t, isOk)
+
+	expired, err := keys.DeleteExpiredRequests(100)
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+	expired, err = keys.DeleteExpiredRequests(101)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	_, isOk, err = keys.GetKeyRequest("c1", "r1")
+	assert.NoError(t, err)
+	assert.False(t, isOk)
+	exist, err = keys.HasKey("aa01")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
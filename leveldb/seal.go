@@ -0,0 +1,312 @@
+package leveldb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/shamir"
+)
+
+// sealConfigKey 保存封存配置，存在时所有私钥都使用主密钥加密保存。
+const sealConfigKey = "seal/config"
+
+// MasterKeySize 是加密私钥的主密钥长度，主密钥用于 AES-256-GCM 加密。
+const MasterKeySize = 32
+
+// sealCheckPlaintext 是用主密钥加密后保存在封存配置中的校验值，用于在解封时验证主密钥。
+var sealCheckPlaintext = []byte("web3-wallet-sign seal check")
+
+var (
+	// ErrSealed 表示密钥库处于封存状态，无法读写私钥
+	ErrSealed = errors.New("key store is sealed")
+	// ErrSealNotInitialized 表示密钥库没有启用封存
+	ErrSealNotInitialized = errors.New("key store seal is not initialized")
+	// ErrSealInitialized 表示密钥库已经启用了封存
+	ErrSealInitialized = errors.New("key store seal is already initialized")
+	// ErrInvalidMasterKey 表示主密钥与封存配置不匹配
+	ErrInvalidMasterKey = errors.New("invalid master key")
+)
+
+// SealConfig 是密钥库的封存配置。主密钥被拆分为 Total 份 Shamir 分片，任意 Threshold 份可以恢复。
+type SealConfig struct {
+	Threshold int `json:"threshold"`
+	Total     int `json:"total"`
+	// GroupID 是主密钥分片的分组标识，用于拒绝其他分组的分片
+	GroupID uint32 `json:"groupId"`
+	// Check 是用主密钥加密的 sealCheckPlaintext
+	Check string `json:"check"`
+}
+
+// SealConfig 返回密钥库的封存配置，没有启用封存时返回 false。
+func (k *Keys) SealConfig() (*SealConfig, bool) {
+	k.sealMu.RLock()
+	defer k.sealMu.RUnlock()
+	if k.seal == nil {
+		return nil, false
+	}
+	config := *k.seal
+	return &config, true
+}
+
+// Sealed 判断密钥库是否处于封存状态，没有启用封存的密钥库总是未封存的。
+func (k *Keys) Sealed() bool {
+	k.sealMu.RLock()
+	defer k.sealMu.RUnlock()
+	return k.seal != nil && k.masterKey == nil
+}
+
+// InitSeal 为密钥库启用封存：生成随机的主密钥并拆分为 total 份分片，
+// 用主密钥加密所有已保存的私钥。加密后的密钥记录与封存配置在一个同步的 Batch 中写入，
+// 返回的分片是恢复主密钥的唯一途径。启用后密钥库处于封存状态。
+func (k *Keys) InitSeal(total, threshold int) ([]*shamir.Share, error) {
+	if _, isOk := k.SealConfig(); isOk {
+		return nil, ErrSealInitialized
+	}
+	masterKey := make([]byte, MasterKeySize)
+	if _, err := io.ReadFull(rand.Reader, masterKey); err != nil {
+		return nil, err
+	}
+	defer wipe(masterKey)
+	shares, err := shamir.SplitSecret(masterKey, total, threshold)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newSealCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	check, err := sealValue(aead, sealCheckPlaintext, []byte(sealConfigKey))
+	if err != nil {
+		return nil, err
+	}
+	config := &SealConfig{
+		Threshold: threshold,
+		Total:     total,
+		GroupID:   shares[0].GroupID,
+		Check:     check,
+	}
+
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	batch := new(Batch)
+	encrypted, err := k.encryptPlainRecords(aead, batch)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put([]byte(sealConfigKey), data)
+	if err := k.db.Write(batch); err != nil {
+		return nil, err
+	}
+	k.sealMu.Lock()
+	k.seal = config
+	k.sealMu.Unlock()
+	log.Info("init key store seal", "threshold", threshold, "total", total, "encrypted", encrypted)
+	return shares, nil
+}
+
+// Unseal 使用恢复的主密钥解封密钥库，主密钥与封存配置不匹配时返回 ErrInvalidMasterKey。
+// 解封后会加密封存期间之外写入的明文私钥，例如从旧备份恢复的私钥。
+func (k *Keys) Unseal(masterKey []byte) error {
+	config, isOk := k.SealConfig()
+	if !isOk {
+		return ErrSealNotInitialized
+	}
+	if len(masterKey) != MasterKeySize {
+		return ErrInvalidMasterKey
+	}
+	aead, err := newSealCipher(masterKey)
+	if err != nil {
+		return err
+	}
+	check, err := openValue(aead, config.Check, []byte(sealConfigKey))
+	if err != nil || !bytes.Equal(check, sealCheckPlaintext) {
+		return ErrInvalidMasterKey
+	}
+
+	k.recordMu.Lock()
+	defer k.recordMu.Unlock()
+	batch := new(Batch)
+	encrypted, err := k.encryptPlainRecords(aead, batch)
+	if err != nil {
+		return err
+	}
+	if batch.Len() > 0 {
+		if err := k.db.Write(batch); err != nil {
+			return err
+		}
+		log.Info("encrypt plain key records", "keys", encrypted)
+	}
+	k.sealMu.Lock()
+	wipe(k.masterKey)
+	k.masterKey = append([]byte{}, masterKey...)
+	k.sealMu.Unlock()
+	return nil
+}
+
+// Seal 封存密钥库，清零并丢弃内存中的主密钥，之后只有重新解封才能读写私钥。
+func (k *Keys) Seal() error {
+	k.sealMu.Lock()
+	defer k.sealMu.Unlock()
+	if k.seal == nil {
+		return ErrSealNotInitialized
+	}
+	wipe(k.masterKey)
+	k.masterKey = nil
+	return nil
+}
+
+// sealCipher 使用内存中的主密钥创建加密私钥的 AEAD，密钥库封存时返回 ErrSealed。
+// 主密钥只以 Keys.masterKey 一份保存，AEAD 用完即弃，封存时清零主密钥即可清除密钥材料。
+func (k *Keys) sealCipher() (cipher.AEAD, error) {
+	k.sealMu.RLock()
+	defer k.sealMu.RUnlock()
+	if k.masterKey == nil {
+		return nil, ErrSealed
+	}
+	return newSealCipher(k.masterKey)
+}
+
+// DecryptPrivateKey 返回密钥记录的私钥，加密保存的私钥需要密钥库处于解封状态。
+func (k *Keys) DecryptPrivateKey(record *KeyRecord) (string, error) {
+	if !record.Encrypted {
+		return record.PrivateKey, nil
+	}
+	aead, err := k.sealCipher()
+	if err != nil {
+		return "", err
+	}
+	privateKey, err := openValue(aead, record.PrivateKey, []byte(record.Pubkey))
+	if err != nil {
+		return "", err
+	}
+	return toString(privateKey), nil
+}
+
+// encryptRecord 在启用封存时加密密钥记录中的明文私钥，已加密或已销毁的私钥保持不变。
+func (k *Keys) encryptRecord(record *KeyRecord) error {
+	if record.Encrypted || record.PrivateKey == "" {
+		return nil
+	}
+	if _, isOk := k.SealConfig(); !isOk {
+		return nil
+	}
+	aead, err := k.sealCipher()
+	if err != nil {
+		return err
+	}
+	return encryptKeyRecord(aead, record)
+}
+
+// encryptPlainRecords 将所有明文私钥的加密记录写入 batch，返回加密的密钥数量。
+func (k *Keys) encryptPlainRecords(aead cipher.AEAD, batch *Batch) (int, error) {
+	var records []*KeyRecord
+	var decodeErr error
+	err := k.db.Iterate([]byte(namespacePrefix), nil, func(_, value []byte) bool {
+		record, _, err := decodeKeyRecord(value)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if record != nil && !record.Encrypted && record.PrivateKey != "" {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	for _, record := range records {
+		if err := encryptKeyRecord(aead, record); err != nil {
+			return 0, err
+		}
+		if err := batchRecord(batch, record); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
+
+// loadSealConfig 从数据库读取封存配置，配置变化时密钥库回到封存状态。
+func (k *Keys) loadSealConfig() error {
+	data, err := k.db.Get([]byte(sealConfigKey))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	var config *SealConfig
+	if err == nil {
+		config = new(SealConfig)
+		if err := json.Unmarshal(data, config); err != nil {
+			return err
+		}
+	}
+	k.sealMu.Lock()
+	defer k.sealMu.Unlock()
+	if config == nil || k.seal == nil || *config != *k.seal {
+		wipe(k.masterKey)
+		k.masterKey = nil
+	}
+	k.seal = config
+	return nil
+}
+
+// encryptKeyRecord 使用主密钥加密私钥，以公钥作为附加数据，防止密文被挪用到其他公钥下。
+func encryptKeyRecord(aead cipher.AEAD, record *KeyRecord) error {
+	privateKey := toBytes(record.PrivateKey)
+	defer wipe(privateKey)
+	ciphertext, err := sealValue(aead, privateKey, []byte(record.Pubkey))
+	if err != nil {
+		return err
+	}
+	record.PrivateKey = ciphertext
+	record.Encrypted = true
+	return nil
+}
+
+func newSealCipher(masterKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealValue 加密 plaintext，返回十六进制编码的 nonce | ciphertext。
+func sealValue(aead cipher.AEAD, plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(aead.Seal(nonce, nonce, plaintext, additionalData)), nil
+}
+
+func openValue(aead cipher.AEAD, value string, additionalData []byte) ([]byte, error) {
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+}
+
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
@@ -0,0 +1,84 @@
+package leveldb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/shamir"
+)
+
+func TestSealUnseal(t *testing.T) {
+	path := t.TempDir()
+	keys, err := NewKeyStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, keys.StoreKeys([]Key{{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa"}}))
+	assert.False(t, keys.Sealed())
+	assert.ErrorIs(t, keys.Seal(), ErrSealNotInitialized)
+
+	shares, err := keys.InitSeal(5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+	_, err = keys.InitSeal(5, 3)
+	assert.ErrorIs(t, err, ErrSealInitialized)
+	assert.True(t, keys.Sealed())
+
+	// 私钥以密文保存，封存时无法读取也无法写入新的私钥
+	value, err := keys.db.Get(recordKey("", "aa01"))
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(value, []byte(`"privateKey":"01"`)))
+	_, isOk := keys.GetPrivateKey("aa01")
+	assert.False(t, isOk)
+	assert.ErrorIs(t, keys.StoreKeys([]Key{{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa"}}), ErrSealed)
+	exist, err := keys.HasKey("aa02")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	masterKey, err := shamir.CombineShares(shares[1:4])
+	assert.NoError(t, err)
+	wrongKey := bytes.Clone(masterKey)
+	wrongKey[0] ^= 1
+	assert.ErrorIs(t, keys.Unseal(wrongKey), ErrInvalidMasterKey)
+	assert.True(t, keys.Sealed())
+
+	// 重新打开数据库后仍处于封存状态
+	assert.NoError(t, keys.db.Close())
+	keys, err = NewKeyStore(path)
+	assert.NoError(t, err)
+	assert.True(t, keys.Sealed())
+	assert.NoError(t, keys.Unseal(masterKey))
+	assert.False(t, keys.Sealed())
+	privateKey, isOk := keys.GetPrivateKey("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, "01", privateKey)
+	assert.NoError(t, keys.StoreKeys([]Key{{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa"}}))
+	record, isOk := keys.GetKeyRecord("aa02")
+	assert.True(t, isOk)
+	assert.True(t, record.Encrypted)
+
+	assert.NoError(t, keys.Seal())
+	assert.True(t, keys.Sealed())
+	assert.Nil(t, keys.masterKey)
+	_, err = keys.DecryptPrivateKey(record)
+	assert.ErrorIs(t, err, ErrSealed)
+}
+
+func TestRestoreSealMismatch(t *testing.T) {
+	src, err := NewKeyStore(t.TempDir())
+	assert.NoError(t, err)
+	_, err = src.InitSeal(2, 2)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = src.Backup(&buf, "passphrase")
+	assert.NoError(t, err)
+	backup, err := ReadBackup(bytes.NewReader(buf.Bytes()), "passphrase")
+	assert.NoError(t, err)
+
+	dst, err := NewKeyStore(t.TempDir())
+	assert.NoError(t, err)
+	_, err = dst.InitSeal(2, 2)
+	assert.NoError(t, err)
+	_, err = dst.Restore(backup, true, false)
+	assert.ErrorIs(t, err, ErrRestoreSealMismatch)
+}
@@ -0,0 +1,171 @@
+package leveldb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// slashingPrefix 是防罚没记录的键前缀，键为 slashingPrefix + 创世验证者根 + "/" + 公钥，
+// 同一个验证者密钥在不同的链上分别记录。
+const slashingPrefix = "slashing/"
+
+// ErrSlashable 表示签名的区块或投票可能使验证者被罚没，拒绝签名。
+var ErrSlashable = errors.New("slashable")
+
+// SlashingRecord 记录一个验证者密钥在一条链上签名过的最高的区块和投票。
+// 新的区块的 slot 必须大于签名过的区块；新的投票的 source 不能小于、target 必须大于签名过的投票，
+// 这样既不会重复出块或重复投票，也不会产生包围投票。重新签名完全相同的区块或投票是允许的。
+type SlashingRecord struct {
+	GenesisValidatorsRoot string             `json:"genesisValidatorsRoot"`
+	Pubkey                string             `json:"pubkey"`
+	Block                 *SignedBlock       `json:"block,omitempty"`
+	Attestation           *SignedAttestation `json:"attestation,omitempty"`
+}
+
+type SignedBlock struct {
+	Slot        uint64 `json:"slot"`
+	SigningRoot string `json:"signingRoot"`
+}
+
+type SignedAttestation struct {
+	SourceEpoch uint64 `json:"sourceEpoch"`
+	TargetEpoch uint64 `json:"targetEpoch"`
+	SigningRoot string `json:"signingRoot"`
+}
+
+func slashingKey(genesisValidatorsRoot, pubkey string) []byte {
+	return []byte(slashingPrefix + strings.ToLower(genesisValidatorsRoot) + "/" + strings.ToLower(pubkey))
+}
+
+// CheckAndRecordBlock 检查在 slot 签名签名根为 signingRoot 的区块是否安全，安全时记录该区块，
+// 不安全时返回 ErrSlashable。调用方必须在签名前调用。
+func (k *Keys) CheckAndRecordBlock(genesisValidatorsRoot, pubkey string, slot uint64, signingRoot string) error {
+	k.slashingMu.Lock()
+	defer k.slashingMu.Unlock()
+	record, err := k.getSlashingRecord(genesisValidatorsRoot, pubkey)
+	if err != nil {
+		return err
+	}
+	if last := record.Block; last != nil {
+		if slot == last.Slot && strings.EqualFold(signingRoot, last.SigningRoot) {
+			return nil
+		}
+		if slot <= last.Slot {
+			return fmt.Errorf("%w: a block was already signed at slot %d", ErrSlashable, last.Slot)
+		}
+	}
+	record.Block = &SignedBlock{Slot: slot, SigningRoot: signingRoot}
+	return k.putSlashingRecord(record)
+}
+
+// CheckAndRecordAttestation 检查签名 source 到 target、签名根为 signingRoot 的投票是否安全，
+// 安全时记录该投票，不安全时返回 ErrSlashable。调用方必须在签名前调用。
+func (k *Keys) CheckAndRecordAttestation(genesisValidatorsRoot, pubkey string, sourceEpoch, targetEpoch uint64, signingRoot string) error {
+	if sourceEpoch > targetEpoch {
+		return fmt.Errorf("%w: source epoch %d is after target epoch %d", ErrSlashable, sourceEpoch, targetEpoch)
+	}
+	k.slashingMu.Lock()
+	defer k.slashingMu.Unlock()
+	record, err := k.getSlashingRecord(genesisValidatorsRoot, pubkey)
+	if err != nil {
+		return err
+	}
+	if last := record.Attestation; last != nil {
+		if sourceEpoch == last.SourceEpoch && targetEpoch == last.TargetEpoch && strings.EqualFold(signingRoot, last.SigningRoot) {
+			return nil
+		}
+		if sourceEpoch < last.SourceEpoch {
+			return fmt.Errorf("%w: an attestation with source epoch %d was already signed", ErrSlashable, last.SourceEpoch)
+		}
+		if targetEpoch <= last.TargetEpoch {
+			return fmt.Errorf("%w: an attestation with target epoch %d was already signed", ErrSlashable, last.TargetEpoch)
+		}
+	}
+	record.Attestation = &SignedAttestation{SourceEpoch: sourceEpoch, TargetEpoch: targetEpoch, SigningRoot: signingRoot}
+	return k.putSlashingRecord(record)
+}
+
+func isSlashingKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(slashingPrefix))
+}
+
+// mergeSlashingValue 返回备份中的防罚没记录 value 与数据库中键 key 的记录合并后的记录，
+// 合并后的记录与数据库中的相同时 changed 为 false。调用方必须持有 slashingMu。
+func (k *Keys) mergeSlashingValue(key, value []byte) (merged []byte, changed bool, err error) {
+	var restored SlashingRecord
+	if err := json.Unmarshal(value, &restored); err != nil {
+		return nil, false, err
+	}
+	current, err := k.db.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return value, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var record SlashingRecord
+	if err := json.Unmarshal(current, &record); err != nil {
+		return nil, false, err
+	}
+	if restored.Block != nil && (record.Block == nil || restored.Block.Slot > record.Block.Slot) {
+		record.Block = restored.Block
+	}
+	record.Attestation = mergeAttestations(record.Attestation, restored.Attestation)
+	merged, err = json.Marshal(&record)
+	if err != nil {
+		return nil, false, err
+	}
+	return merged, !bytes.Equal(merged, current), nil
+}
+
+// mergeAttestations 返回不低于 a 和 b 的投票记录：source 和 target 分别取较大值。
+// 合并出的投票与 a、b 都不同时没有签名根，不能被重新签名。
+func mergeAttestations(a, b *SignedAttestation) *SignedAttestation {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	merged := &SignedAttestation{
+		SourceEpoch: max(a.SourceEpoch, b.SourceEpoch),
+		TargetEpoch: max(a.TargetEpoch, b.TargetEpoch),
+	}
+	for _, attestation := range []*SignedAttestation{a, b} {
+		if attestation.SourceEpoch == merged.SourceEpoch && attestation.TargetEpoch == merged.TargetEpoch {
+			merged.SigningRoot = attestation.SigningRoot
+			break
+		}
+	}
+	return merged
+}
+
+func (k *Keys) getSlashingRecord(genesisValidatorsRoot, pubkey string) (*SlashingRecord, error) {
+	data, err := k.db.Get(slashingKey(genesisValidatorsRoot, pubkey))
+	if errors.Is(err, ErrNotFound) {
+		return &SlashingRecord{GenesisValidatorsRoot: strings.ToLower(genesisValidatorsRoot), Pubkey: strings.ToLower(pubkey)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record SlashingRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// putSlashingRecord 通过同步的 Batch 写入防罚没记录，签名返回前记录必须已经持久化，
+// 否则断电后可能再次签名相同高度的区块或投票。
+func (k *Keys) putSlashingRecord(record *SlashingRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	batch := new(Batch)
+	batch.Put(slashingKey(record.GenesisValidatorsRoot, record.Pubkey), data)
+	return k.db.Write(batch)
+}
@@ -0,0 +1,72 @@
+package leveldb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlashingProtection(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+	gvr := "4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"
+
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 10, "r10"))
+	// 重新签名相同的区块是允许的
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 10, "r10"))
+	err = keys.CheckAndRecordBlock(gvr, "aa01", 10, "other")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	err = keys.CheckAndRecordBlock(gvr, "aa01", 9, "r9")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 11, "r11"))
+	// 记录按链和密钥分开
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa02", 1, "r1"))
+	assert.NoError(t, keys.CheckAndRecordBlock("00", "aa01", 1, "r1"))
+
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 2, 3, "a3"))
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 2, 3, "A3"))
+	// 重复投票
+	err = keys.CheckAndRecordAttestation(gvr, "aa01", 2, 3, "other")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	// 被已签名的投票包围
+	err = keys.CheckAndRecordAttestation(gvr, "aa01", 1, 4, "a4")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	err = keys.CheckAndRecordAttestation(gvr, "aa01", 3, 2, "a2")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 3, 5, "a5"))
+	// 包围已签名的投票
+	err = keys.CheckAndRecordAttestation(gvr, "aa01", 3, 4, "a4")
+	assert.True(t, errors.Is(err, ErrSlashable))
+	// 区块的记录不受投票影响
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 12, "r12"))
+}
+
+// putRecordingStore 记录通过 Put 写入的键，Put 不保证写入已经持久化。
+type putRecordingStore struct {
+	KeyStore
+	keys []string
+}
+
+func (s *putRecordingStore) Put(key, value []byte) error {
+	s.keys = append(s.keys, string(key))
+	return s.KeyStore.Put(key, value)
+}
+
+func TestSlashingRecordsSynced(t *testing.T) {
+	db := &putRecordingStore{KeyStore: NewMemoryStore()}
+	keys, err := NewKeys(db)
+	assert.NoError(t, err)
+	gvr := "4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"
+	assert.NoError(t, keys.CheckAndRecordBlock(gvr, "aa01", 10, "r10"))
+	assert.NoError(t, keys.CheckAndRecordAttestation(gvr, "aa01", 2, 3, "a3"))
+
+	// 防罚没记录只通过同步的 Write 写入
+	for _, key := range db.keys {
+		assert.False(t, isSlashingKey([]byte(key)), key)
+	}
+	record, err := keys.getSlashingRecord(gvr, "aa01")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), record.Block.Slot)
+	assert.Equal(t, uint64(3), record.Attestation.TargetEpoch)
+}
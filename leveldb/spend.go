@@ -0,0 +1,138 @@
+package leveldb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// spendPrefix 是签名金额记录的键前缀，键为 spendPrefix + spendScopeKey(范围, 链, 代币) + "/" +
+	// 十六进制的签名时间 + "/" + 交易 id 的哈希，同一范围内的记录按签名时间排列
+	spendPrefix = "spend/"
+	// spendIDPrefix 是按交易 id 索引签名金额记录的键前缀，键为 spendIDPrefix + 哈希(范围, 交易 id)，值为记录的键
+	spendIDPrefix = "spendid/"
+)
+
+// SpendRecord 记录一笔交易在一个范围内签名的金额，用于滚动窗口限额。
+// 范围是一个密钥或一个调用方，由策略引擎定义。
+type SpendRecord struct {
+	Scope string `json:"scope"`
+	// ID 是交易 id，同一笔交易在同一范围内只记录一次
+	ID     string `json:"id"`
+	Chain  string `json:"chain"`
+	Token  string `json:"token"`
+	Amount string `json:"amount"`
+	// At 是签名时间，单位为纳秒
+	At int64 `json:"at"`
+}
+
+// SpentSince 返回范围 scope 在链 chain 上从 since 开始签名的代币 token 的总金额。
+func (k *Keys) SpentSince(scope, chain, token string, since time.Time) (*big.Int, error) {
+	prefix := spendEntryPrefix(scope, chain, token)
+	start := fmt.Appendf(prefix, "%016x", since.UnixNano())
+	total := new(big.Int)
+	var decodeErr error
+	err := k.db.Iterate(prefix, start, func(_, value []byte) bool {
+		var record SpendRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			decodeErr = err
+			return false
+		}
+		amount, isOk := new(big.Int).SetString(record.Amount, 10)
+		if !isOk {
+			decodeErr = fmt.Errorf("invalid spend amount %q", record.Amount)
+			return false
+		}
+		total.Add(total, amount)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return total, decodeErr
+}
+
+// HasSpend 判断交易 id 是否已经记录在范围 scope 中。
+func (k *Keys) HasSpend(scope, id string) (bool, error) {
+	return k.db.Has(spendIDKey(scope, id))
+}
+
+// RecordSpends 在同一个 Batch 中保存所有签名金额记录。
+func (k *Keys) RecordSpends(records []*SpendRecord) error {
+	batch := new(Batch)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		key := spendEntryKey(record)
+		batch.Put(key, data)
+		if record.ID != "" {
+			batch.Put(spendIDKey(record.Scope, record.ID), key)
+		}
+	}
+	return k.db.Write(batch)
+}
+
+// DeleteSpends 删除签名金额记录，用于签名失败时撤销 RecordSpends 保存的记录。
+func (k *Keys) DeleteSpends(records []*SpendRecord) error {
+	batch := new(Batch)
+	for _, record := range records {
+		batch.Delete(spendEntryKey(record))
+		if record.ID != "" {
+			batch.Delete(spendIDKey(record.Scope, record.ID))
+		}
+	}
+	return k.db.Write(batch)
+}
+
+// DeleteExpiredSpends 删除签名时间早于 before 的签名金额记录，返回删除的数量。
+func (k *Keys) DeleteExpiredSpends(before time.Time) (int, error) {
+	batch := new(Batch)
+	expired := 0
+	var decodeErr error
+	err := k.db.Iterate([]byte(spendPrefix), nil, func(key, value []byte) bool {
+		var record SpendRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			decodeErr = err
+			return false
+		}
+		if record.At < before.UnixNano() {
+			batch.Delete(key)
+			if record.ID != "" {
+				batch.Delete(spendIDKey(record.Scope, record.ID))
+			}
+			expired++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	if expired == 0 {
+		return 0, nil
+	}
+	return expired, k.db.Write(batch)
+}
+
+func spendEntryPrefix(scope, chain, token string) []byte {
+	hash := sha256.Sum256([]byte(scope + "\x00" + chain + "\x00" + token))
+	return []byte(spendPrefix + hex.EncodeToString(hash[:16]) + "/")
+}
+
+func spendEntryKey(record *SpendRecord) []byte {
+	id := sha256.Sum256([]byte(record.ID))
+	return fmt.Appendf(spendEntryPrefix(record.Scope, record.Chain, record.Token), "%016x/%s", record.At, hex.EncodeToString(id[:16]))
+}
+
+func spendIDKey(scope, id string) []byte {
+	hash := sha256.Sum256([]byte(scope + "\x00" + id))
+	return []byte(spendIDPrefix + hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,56 @@
+package leveldb
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpends(t *testing.T) {
+	keys, err := NewKeys(NewMemoryStore())
+	assert.NoError(t, err)
+
+	now := time.Unix(1760000000, 0)
+	spend := func(scope, id string, amount string, at time.Time) *SpendRecord {
+		return &SpendRecord{Scope: scope, ID: id, Chain: "eip155:1", Token: "native", Amount: amount, At: at.UnixNano()}
+	}
+	first := []*SpendRecord{spend("key:aa01", "tx1", "100", now.Add(-2*time.Hour)), spend("consumer:c1", "tx1", "100", now.Add(-2*time.Hour))}
+	assert.NoError(t, keys.RecordSpends(first))
+	second := []*SpendRecord{spend("key:aa01", "tx2", "30", now)}
+	assert.NoError(t, keys.RecordSpends(second))
+
+	spent, err := keys.SpentSince("key:aa01", "eip155:1", "native", now.Add(-3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(130), spent)
+	spent, err = keys.SpentSince("key:aa01", "eip155:1", "native", now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), spent)
+	// 金额按链和代币分别统计
+	spent, err = keys.SpentSince("key:aa01", "eip155:5", "native", now.Add(-3*time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, spent.Sign())
+
+	exist, err := keys.HasSpend("consumer:c1", "tx1")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = keys.HasSpend("consumer:c1", "tx2")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	assert.NoError(t, keys.DeleteSpends(second))
+	exist, err = keys.HasSpend("key:aa01", "tx2")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	expired, err := keys.DeleteExpiredSpends(now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, expired)
+	spent, err = keys.SpentSince("consumer:c1", "eip155:1", "native", now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, spent.Sign())
+	exist, err = keys.HasSpend("key:aa01", "tx1")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
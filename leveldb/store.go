@@ -0,0 +1,82 @@
+package leveldb
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const (
+	// DbBackendLevelDB 将数据保存在 goleveldb 数据库目录中
+	DbBackendLevelDB = "leveldb"
+	// DbBackendBolt 将数据保存在 bbolt 数据库文件中
+	DbBackendBolt = "bolt"
+	// DbBackendMemory 将数据保存在内存中，进程退出后数据丢失，仅用于测试
+	DbBackendMemory = "memory"
+)
+
+// ErrNotFound 表示 KeyStore 中不存在要读取的键。
+var ErrNotFound = leveldb.ErrNotFound
+
+// KeyStore 是 Keys 使用的有序键值存储，键按字节序排列。
+// 所有实现都必须通过 store_test.go 中的一致性测试。
+type KeyStore interface {
+	// Get 读取键对应的值，键不存在时返回 ErrNotFound
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	// Iterate 按键的顺序遍历所有以 prefix 开头、且不小于 start 的键值对。
+	// start 为空时从 prefix 的第一个键开始遍历；fn 返回 false 时停止遍历。
+	// 传给 fn 的 key 和 value 只在本次回调中有效，需要保留时应自行拷贝；fn 中不能写入 KeyStore。
+	Iterate(prefix, start []byte, fn func(key, value []byte) bool) error
+	// Write 原子地写入 batch 中的所有修改，返回时修改已经持久化
+	Write(batch *Batch) error
+	// Snapshot 返回当前数据的一致性快照，使用后需要调用 Release 释放
+	Snapshot() (Snapshot, error)
+	Close() error
+}
+
+// Snapshot 是 KeyStore 在某一时刻的只读视图。
+type Snapshot interface {
+	Iterate(prefix, start []byte, fn func(key, value []byte) bool) error
+	Release()
+}
+
+// batchOp 是 Batch 中的一个修改，value 为 nil 表示删除。
+type batchOp struct {
+	key   []byte
+	value []byte
+}
+
+// Batch 记录一组需要原子写入 KeyStore 的修改。
+type Batch struct {
+	ops []batchOp
+}
+
+func (b *Batch) Put(key, value []byte) {
+	b.ops = append(b.ops, batchOp{key: append([]byte{}, key...), value: append([]byte{}, value...)})
+}
+
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, batchOp{key: append([]byte{}, key...)})
+}
+
+// Len 返回 Batch 中的修改数量。
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// OpenStore 按 backend 打开 path 下的 KeyStore，backend 为空时使用 goleveldb。
+func OpenStore(backend, path string) (KeyStore, error) {
+	switch backend {
+	case DbBackendLevelDB, "":
+		return NewLevelStore(path)
+	case DbBackendBolt:
+		return NewBoltStore(path)
+	case DbBackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unsupported db backend %q", backend)
+	}
+}
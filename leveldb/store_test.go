@@ -0,0 +1,134 @@
+package leveldb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// storeBackends 是一致性测试覆盖的所有 KeyStore 实现。
+var storeBackends = []string{DbBackendLevelDB, DbBackendBolt, DbBackendMemory}
+
+func openTestStore(t *testing.T, backend string) KeyStore {
+	db, err := OpenStore(backend, t.TempDir())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func iterateKeys(t *testing.T, iterate func(prefix, start []byte, fn func(key, value []byte) bool) error, prefix, start string, limit int) []string {
+	var keys []string
+	err := iterate([]byte(prefix), []byte(start), func(key, value []byte) bool {
+		keys = append(keys, string(key)+"="+string(value))
+		return len(keys) < limit
+	})
+	assert.NoError(t, err)
+	return keys
+}
+
+func TestKeyStoreConformance(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend, func(t *testing.T) {
+			db := openTestStore(t, backend)
+
+			_, err := db.Get([]byte("a"))
+			assert.ErrorIs(t, err, ErrNotFound)
+			exist, err := db.Has([]byte("a"))
+			assert.NoError(t, err)
+			assert.False(t, exist)
+
+			value := []byte("1")
+			assert.NoError(t, db.Put([]byte("a"), value))
+			value[0] = 'x'
+			data, err := db.Get([]byte("a"))
+			assert.NoError(t, err)
+			assert.Equal(t, "1", string(data))
+			assert.NoError(t, db.Put([]byte("a"), []byte("2")))
+			data, err = db.Get([]byte("a"))
+			assert.NoError(t, err)
+			assert.Equal(t, "2", string(data))
+			exist, err = db.Has([]byte("a"))
+			assert.NoError(t, err)
+			assert.True(t, exist)
+			assert.NoError(t, db.Delete([]byte("a")))
+			_, err = db.Get([]byte("a"))
+			assert.ErrorIs(t, err, ErrNotFound)
+			assert.NoError(t, db.Delete([]byte("a")))
+
+			batch := new(Batch)
+			for i := 0; i < 5; i++ {
+				batch.Put([]byte(fmt.Sprintf("p/%d", i)), []byte(fmt.Sprint(i)))
+			}
+			batch.Put([]byte("q/0"), []byte("0"))
+			batch.Put([]byte("o/0"), []byte("0"))
+			batch.Delete([]byte("p/4"))
+			assert.Equal(t, 8, batch.Len())
+			assert.NoError(t, db.Write(batch))
+
+			assert.Equal(t, []string{"p/0=0", "p/1=1", "p/2=2", "p/3=3"}, iterateKeys(t, db.Iterate, "p/", "", 100))
+			assert.Equal(t, []string{"p/2=2", "p/3=3"}, iterateKeys(t, db.Iterate, "p/", "p/2", 100))
+			assert.Equal(t, []string{"p/0=0", "p/1=1"}, iterateKeys(t, db.Iterate, "p/", "", 2))
+			assert.Equal(t, []string{"p/0=0"}, iterateKeys(t, db.Iterate, "p/", "a", 1))
+			assert.Empty(t, iterateKeys(t, db.Iterate, "p/", "q", 100))
+			assert.Len(t, iterateKeys(t, db.Iterate, "", "", 100), 6)
+
+			snapshot, err := db.Snapshot()
+			assert.NoError(t, err)
+			assert.NoError(t, db.Put([]byte("p/5"), []byte("5")))
+			assert.NoError(t, db.Delete([]byte("p/0")))
+			assert.Equal(t, []string{"p/0=0", "p/1=1", "p/2=2", "p/3=3"}, iterateKeys(t, snapshot.Iterate, "p/", "", 100))
+			snapshot.Release()
+			assert.Equal(t, []string{"p/1=1", "p/2=2", "p/3=3", "p/5=5"}, iterateKeys(t, db.Iterate, "p/", "", 100))
+		})
+	}
+}
+
+func TestKeysBackends(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend, func(t *testing.T) {
+			keys, err := NewKeys(openTestStore(t, backend))
+			assert.NoError(t, err)
+			assert.NoError(t, keys.StoreKeys([]Key{
+				{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa"},
+				{PrivateKey: "02", Pubkey: "aa02", Type: "eddsa"},
+			}))
+			privateKey, isOk := keys.GetPrivateKey("aa02")
+			assert.True(t, isOk)
+			assert.Equal(t, "02", privateKey)
+			metas, nextPageToken, err := keys.ListKeys(KeyFilter{Type: "ecdsa"}, "", 10)
+			assert.NoError(t, err)
+			assert.Empty(t, nextPageToken)
+			assert.Len(t, metas, 1)
+			assert.Equal(t, "aa01", metas[0].Pubkey)
+		})
+	}
+}
+
+// failingStore 是写入 Batch 时总是失败的 KeyStore。
+type failingStore struct {
+	KeyStore
+}
+
+func (db failingStore) Write(*Batch) error {
+	return errors.New("disk full")
+}
+
+func TestStoreKeysAtomic(t *testing.T) {
+	keys, err := NewKeys(failingStore{NewMemoryStore()})
+	assert.NoError(t, err)
+	err = keys.StoreKeys([]Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa"},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa"},
+	})
+	assert.EqualError(t, err, "disk full")
+	metas, _, err := keys.ListKeys(KeyFilter{}, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, metas)
+	for _, pubkey := range []string{"aa01", "aa02"} {
+		exist, err := keys.HasKey(pubkey)
+		assert.NoError(t, err)
+		assert.False(t, exist)
+	}
+}
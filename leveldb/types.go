@@ -1,7 +1,110 @@
 package leveldb
 
+const (
+	// KeyRecordVersion 是当前密钥记录的版本号
+	KeyRecordVersion = 1
+
+	// BackendLocal 表示私钥保存在本地 leveldb 中
+	BackendLocal = "local"
+	// BackendHsm 表示私钥保存在云 HSM 中
+	BackendHsm = "hsm"
+
+	// OriginGenerated 表示密钥由本服务生成
+	OriginGenerated = "generated"
+	// OriginImported 表示密钥由外部导入
+	OriginImported = "imported"
+	// OriginLegacy 表示记录来源之前创建、由迁移补写元数据的早期密钥，
+	// 其中没有调用方的密钥只有管理员可以使用，需要通过 AssignKeyOwner 指定调用方
+	OriginLegacy = "legacy"
+
+	// KeyStatusActive 表示密钥可以正常签名
+	KeyStatusActive = "active"
+	// KeyStatusDisabled 表示密钥被临时禁用，可以重新启用
+	KeyStatusDisabled = "disabled"
+	// KeyStatusArchived 表示密钥已归档，不再使用但仍保留私钥
+	KeyStatusArchived = "archived"
+	// KeyStatusPendingDestruction 表示密钥等待销毁，等待期内可以取消销毁
+	KeyStatusPendingDestruction = "pending_destruction"
+	// KeyStatusDestroyed 表示密钥的私钥已被删除
+	KeyStatusDestroyed = "destroyed"
+)
+
 // 定义一个Key结构体，包含私钥和公钥
 type Key struct {
 	PrivateKey string
 	Pubkey     string
+	// 以下字段作为密钥的元数据与私钥一起保存
+	CompressPubkey string
+	Type           string
+	Consumer       string
+	Labels         []string
+	Backend        string
+	DerivationPath string
+	Origin         string
+}
+
+// KeyMeta 是密钥的元数据，不包含私钥，同时保存在二级索引中。
+type KeyMeta struct {
+	Pubkey         string `json:"pubkey"`
+	CompressPubkey string `json:"compressPubkey"`
+	// Type 是密钥的算法，取值为 protobuf.CryptoType
+	Type      string `json:"type"`
+	CreatedAt int64  `json:"createdAt"`
+	// Consumer 是创建密钥的调用方
+	Consumer       string   `json:"consumer"`
+	Labels         []string `json:"labels,omitempty"`
+	Backend        string   `json:"backend"`
+	DerivationPath string   `json:"derivationPath,omitempty"`
+	Status         string   `json:"status"`
+	// Origin 表示密钥是生成的还是导入的，迁移的早期密钥为 OriginLegacy
+	Origin string `json:"origin,omitempty"`
+	// DestroyAt 是等待销毁的密钥被销毁的时间，单位为秒
+	DestroyAt int64 `json:"destroyAt,omitempty"`
+}
+
+// KeyRecord 是保存在公钥下的带版本号的密钥记录，包含私钥及其元数据。
+type KeyRecord struct {
+	Version    int    `json:"version"`
+	PrivateKey string `json:"privateKey"`
+	// Encrypted 表示 PrivateKey 是用封存主密钥加密的密文
+	Encrypted bool `json:"encrypted,omitempty"`
+	KeyMeta
+}
+
+// KeyFilter 是查询密钥列表时的过滤条件，零值字段表示不过滤。
+type KeyFilter struct {
+	Type          string
+	CreatedAfter  int64
+	CreatedBefore int64
+	Label         string
+	Consumer      string
+	Status        string
+}
+
+// Match 判断密钥元数据是否满足过滤条件。
+func (f KeyFilter) Match(meta *KeyMeta) bool {
+	if f.Type != "" && meta.Type != f.Type {
+		return false
+	}
+	if f.CreatedAfter > 0 && meta.CreatedAt < f.CreatedAfter {
+		return false
+	}
+	if f.CreatedBefore > 0 && meta.CreatedAt > f.CreatedBefore {
+		return false
+	}
+	if f.Consumer != "" && meta.Consumer != f.Consumer {
+		return false
+	}
+	if f.Status != "" && meta.Status != f.Status {
+		return false
+	}
+	if f.Label != "" {
+		for _, label := range meta.Labels {
+			if label == f.Label {
+				return true
+			}
+		}
+		return false
+	}
+	return true
 }
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// levelDBCollector reads the statistics of a leveldb database on every scrape.
+type levelDBCollector struct {
+	db *leveldb.DB
+
+	writeDelays       *prometheus.Desc
+	writeDelaySeconds *prometheus.Desc
+	writePaused       *prometheus.Desc
+	aliveSnapshots    *prometheus.Desc
+	aliveIterators    *prometheus.Desc
+	ioWrite           *prometheus.Desc
+	ioRead            *prometheus.Desc
+	blockCacheSize    *prometheus.Desc
+	openedTables      *prometheus.Desc
+	levelSize         *prometheus.Desc
+	levelTables       *prometheus.Desc
+	levelRead         *prometheus.Desc
+	levelWrite        *prometheus.Desc
+	levelCompaction   *prometheus.Desc
+	compactions       *prometheus.Desc
+}
+
+// RegisterLevelDB exposes the statistics returned by DB.Stats of db.
+func (m *Metrics) RegisterLevelDB(db *leveldb.DB) {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "leveldb", name), help, labels, nil)
+	}
+	m.registry.MustRegister(&levelDBCollector{
+		db:                db,
+		writeDelays:       desc("write_delays_total", "Number of writes delayed by compactions"),
+		writeDelaySeconds: desc("write_delay_seconds_total", "Time writes were delayed by compactions"),
+		writePaused:       desc("write_paused", "Whether writes are paused by compactions"),
+		aliveSnapshots:    desc("alive_snapshots", "Number of open snapshots"),
+		aliveIterators:    desc("alive_iterators", "Number of open iterators"),
+		ioWrite:           desc("io_write_bytes_total", "Bytes written to disk"),
+		ioRead:            desc("io_read_bytes_total", "Bytes read from disk"),
+		blockCacheSize:    desc("block_cache_bytes", "Size of the block cache"),
+		openedTables:      desc("opened_tables", "Number of open table files"),
+		levelSize:         desc("level_size_bytes", "Size of the tables by level", "level"),
+		levelTables:       desc("level_tables", "Number of tables by level", "level"),
+		levelRead:         desc("level_compaction_read_bytes_total", "Bytes read by the compactions by level", "level"),
+		levelWrite:        desc("level_compaction_write_bytes_total", "Bytes written by the compactions by level", "level"),
+		levelCompaction:   desc("level_compaction_seconds_total", "Time spent in the compactions by level", "level"),
+		compactions:       desc("compactions_total", "Number of compactions by kind", "kind"),
+	})
+}
+
+func (c *levelDBCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		c.writeDelays, c.writeDelaySeconds, c.writePaused, c.aliveSnapshots, c.aliveIterators, c.ioWrite, c.ioRead,
+		c.blockCacheSize, c.openedTables, c.levelSize, c.levelTables, c.levelRead, c.levelWrite, c.levelCompaction, c.compactions,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *levelDBCollector) Collect(ch chan<- prometheus.Metric) {
+	var stats leveldb.DBStats
+	if err := c.db.Stats(&stats); err != nil {
+		log.Warn("read leveldb stats fail", "err", err)
+		return
+	}
+	var writePaused float64
+	if stats.WritePaused {
+		writePaused = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.writeDelays, prometheus.CounterValue, float64(stats.WriteDelayCount))
+	ch <- prometheus.MustNewConstMetric(c.writeDelaySeconds, prometheus.CounterValue, stats.WriteDelayDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.writePaused, prometheus.GaugeValue, writePaused)
+	ch <- prometheus.MustNewConstMetric(c.aliveSnapshots, prometheus.GaugeValue, float64(stats.AliveSnapshots))
+	ch <- prometheus.MustNewConstMetric(c.aliveIterators, prometheus.GaugeValue, float64(stats.AliveIterators))
+	ch <- prometheus.MustNewConstMetric(c.ioWrite, prometheus.CounterValue, float64(stats.IOWrite))
+	ch <- prometheus.MustNewConstMetric(c.ioRead, prometheus.CounterValue, float64(stats.IORead))
+	ch <- prometheus.MustNewConstMetric(c.blockCacheSize, prometheus.GaugeValue, float64(stats.BlockCacheSize))
+	ch <- prometheus.MustNewConstMetric(c.openedTables, prometheus.GaugeValue, float64(stats.OpenedTablesCount))
+	for i := range stats.LevelSizes {
+		level := strconv.Itoa(i)
+		ch <- prometheus.MustNewConstMetric(c.levelSize, prometheus.GaugeValue, float64(stats.LevelSizes[i]), level)
+		ch <- prometheus.MustNewConstMetric(c.levelTables, prometheus.GaugeValue, float64(stats.LevelTablesCounts[i]), level)
+		ch <- prometheus.MustNewConstMetric(c.levelRead, prometheus.CounterValue, float64(stats.LevelRead[i]), level)
+		ch <- prometheus.MustNewConstMetric(c.levelWrite, prometheus.CounterValue, float64(stats.LevelWrite[i]), level)
+		ch <- prometheus.MustNewConstMetric(c.levelCompaction, prometheus.CounterValue, stats.LevelDurations[i].Seconds(), level)
+	}
+	ch <- prometheus.MustNewConstMetric(c.compactions, prometheus.CounterValue, float64(stats.MemComp), "memory")
+	ch <- prometheus.MustNewConstMetric(c.compactions, prometheus.CounterValue, float64(stats.Level0Comp), "level0")
+	ch <- prometheus.MustNewConstMetric(c.compactions, prometheus.CounterValue, float64(stats.NonLevel0Comp), "non_level0")
+	ch <- prometheus.MustNewConstMetric(c.compactions, prometheus.CounterValue, float64(stats.SeekComp), "seek")
+}
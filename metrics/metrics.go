@@ -0,0 +1,113 @@
+// Package metrics holds the Prometheus metrics of the signing service.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const Namespace = "signature"
+
+// Metrics records what the signing service does. Every method is safe for concurrent use.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	rpcRequests      *prometheus.CounterVec
+	rpcDuration      *prometheus.HistogramVec
+	signatures       *prometheus.CounterVec
+	keys             *prometheus.CounterVec
+	hsmCalls         *prometheus.CounterVec
+	hsmDuration      *prometheus.HistogramVec
+	policyRejections *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+	)
+	factory := promauto.With(registry)
+	return &Metrics{
+		registry: registry,
+		rpcRequests: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "rpc",
+			Name:      "requests_total",
+			Help:      "Number of served gRPC calls by method and result code",
+		}, []string{"method", "code"}),
+		rpcDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "rpc",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the gRPC calls by method and result code",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}, []string{"method", "code"}),
+		signatures: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "signatures_total",
+			Help:      "Number of signatures produced by crypto type and key backend",
+		}, []string{"type", "backend"}),
+		keys: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "keys_created_total",
+			Help:      "Number of keys added to the key store by crypto type and origin",
+		}, []string{"type", "origin"}),
+		hsmCalls: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "hsm",
+			Name:      "calls_total",
+			Help:      "Number of cloud HSM calls by method and result",
+		}, []string{"method", "result"}),
+		hsmDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "hsm",
+			Name:      "call_duration_seconds",
+			Help:      "Duration of the cloud HSM calls by method",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"method"}),
+		policyRejections: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "policy",
+			Name:      "rejections_total",
+			Help:      "Number of sign requests refused by the transaction policy by rule",
+		}, []string{"rule"}),
+	}
+}
+
+// Registry returns the registry holding every metric of the service.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// RecordRPC records a served gRPC call, code is the ReturnCode of the response or the gRPC status of the error.
+func (m *Metrics) RecordRPC(method, code string, duration time.Duration) {
+	m.rpcRequests.WithLabelValues(method, code).Inc()
+	m.rpcDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RecordSignature(cryptoType, backend string) {
+	m.signatures.WithLabelValues(cryptoType, backend).Inc()
+}
+
+func (m *Metrics) RecordKeysCreated(cryptoType, origin string, number int) {
+	m.keys.WithLabelValues(cryptoType, origin).Add(float64(number))
+}
+
+// RecordHSMCall records a cloud HSM call that started at start.
+func (m *Metrics) RecordHSMCall(method string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.hsmCalls.WithLabelValues(method, result).Inc()
+	m.hsmDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// RecordPolicyRejection records a sign request refused by rule, rule is empty when no rule applies to the key.
+func (m *Metrics) RecordPolicyRejection(rule string) {
+	m.policyRejections.WithLabelValues(rule).Inc()
+}
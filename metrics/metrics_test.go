@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRPC("signTxMessage", "SUCCESS", time.Millisecond)
+	m.RecordRPC("signTxMessage", "SUCCESS", time.Millisecond)
+	m.RecordRPC("signTxMessage", "POLICY_VIOLATION", time.Millisecond)
+	m.RecordHSMCall("AsymmetricSign", time.Now(), errors.New("unavailable"))
+	m.RecordKeysCreated("ecdsa", "generated", 3)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.rpcRequests.WithLabelValues("signTxMessage", "SUCCESS")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.hsmCalls.WithLabelValues("AsymmetricSign", "error")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.keys.WithLabelValues("ecdsa", "generated")))
+
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	m.RegisterLevelDB(db)
+	assert.NoError(t, db.Put([]byte("k"), []byte("v"), nil))
+	count, err := testutil.GatherAndCount(m.Registry(), "signature_leveldb_write_paused", "signature_leveldb_compactions_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+}
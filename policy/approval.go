@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// DefaultApprovalTTL is the time a signing request waits for its approvals when Approval.TTL is not set.
+const DefaultApprovalTTL = 24 * time.Hour
+
+// Key types of an approver.
+const (
+	ApproverECDSA = "ecdsa"
+	ApproverEdDSA = "eddsa"
+)
+
+// Approver is an identity allowed to approve signing requests with its own key.
+type Approver struct {
+	ID string `json:"id"`
+	// Type is ApproverECDSA, a secp256k1 key, or ApproverEdDSA, an ed25519 key, ApproverECDSA when empty
+	Type string `json:"type"`
+	// PublicKey is the hex encoded public key, uncompressed or compressed for a secp256k1 key
+	PublicKey string `json:"publicKey"`
+
+	publicKey []byte
+}
+
+// Approval requires the signatures of a rule to be approved by Required of the Approvers.
+type Approval struct {
+	// Above is the amount by token above which a transaction needs the approval, in the smallest
+	// unit of the token; tokens not listed need no approval. Every signature of the keys of the
+	// rule, bare message hashes included, needs the approval when Above is empty
+	Above map[string]string `json:"above"`
+	// Required is the number of distinct approvers approving a request
+	Required int `json:"required"`
+	// Approvers are the ids of the approvers allowed to approve
+	Approvers []string `json:"approvers"`
+	// TTL is the time a request waits for its approvals, DefaultApprovalTTL when empty
+	TTL Duration `json:"ttl"`
+
+	above map[string]*big.Int
+}
+
+// ApprovalRequirement is an approval a request needs before it is signed.
+type ApprovalRequirement struct {
+	Rule      string
+	Required  int
+	Approvers []string
+	TTL       time.Duration
+}
+
+// Verify reports whether signature is the signature of digest by the approver.
+// A secp256k1 signature is [R || S] or [R || S || V].
+func (a *Approver) Verify(digest, signature []byte) bool {
+	switch a.Type {
+	case ApproverEdDSA:
+		return ed25519.Verify(a.publicKey, digest, signature)
+	default:
+		if len(signature) != 64 && len(signature) != 65 {
+			return false
+		}
+		return crypto.VerifySignature(a.publicKey, digest, signature[:64])
+	}
+}
+
+func (a *Approver) init() error {
+	if a.ID == "" {
+		return errors.New("approver id is empty")
+	}
+	publicKey, err := hex.DecodeString(strings.TrimPrefix(a.PublicKey, "0x"))
+	if err != nil {
+		return fmt.Errorf("approver %s: decode public key: %w", a.ID, err)
+	}
+	switch a.Type {
+	case "", ApproverECDSA:
+		a.Type = ApproverECDSA
+		if _, err := crypto.DecompressPubkey(publicKey); err != nil {
+			if _, err := crypto.UnmarshalPubkey(publicKey); err != nil {
+				return fmt.Errorf("approver %s: invalid secp256k1 public key", a.ID)
+			}
+		}
+	case ApproverEdDSA:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("approver %s: invalid ed25519 public key", a.ID)
+		}
+	default:
+		return fmt.Errorf("approver %s: type must be %s or %s", a.ID, ApproverECDSA, ApproverEdDSA)
+	}
+	a.publicKey = publicKey
+	return nil
+}
+
+func (a *Approval) init(approvers map[string]*Approver) error {
+	if a.Required <= 0 || a.Required > len(a.Approvers) {
+		return fmt.Errorf("required approvals must be between 1 and the %d approvers", len(a.Approvers))
+	}
+	for i, id := range a.Approvers {
+		if _, isOk := approvers[id]; !isOk {
+			return fmt.Errorf("unknown approver %q", id)
+		}
+		if slices.Contains(a.Approvers[:i], id) {
+			return fmt.Errorf("duplicate approver %q", id)
+		}
+	}
+	if a.TTL < 0 {
+		return errors.New("approval ttl must not be negative")
+	}
+	a.above = make(map[string]*big.Int, len(a.Above))
+	for token, value := range a.Above {
+		above, err := parseAmount(value)
+		if err != nil {
+			return fmt.Errorf("approval threshold of %s: %w", token, err)
+		}
+		a.above[normalizeAddress(token)] = above
+	}
+	return nil
+}
+
+// applies reports whether a request of intent needs the approval.
+func (a *Approval) applies(intent *Intent) bool {
+	if len(a.above) == 0 {
+		return true
+	}
+	if intent == nil {
+		return false
+	}
+	above, isOk := a.above[intent.Token]
+	return isOk && intent.Amount.Cmp(above) > 0
+}
+
+func (a *Approval) requirement(rule string) *ApprovalRequirement {
+	ttl := time.Duration(a.TTL)
+	if ttl == 0 {
+		ttl = DefaultApprovalTTL
+	}
+	return &ApprovalRequirement{
+		Rule:      rule,
+		Required:  a.Required,
+		Approvers: slices.Clone(a.Approvers),
+		TTL:       ttl,
+	}
+}
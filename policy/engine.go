@@ -0,0 +1,289 @@
+package policy
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyInfo describes the key a signature is requested with.
+type KeyInfo struct {
+	Pubkey   string
+	Consumer string
+	Labels   []string
+}
+
+// Request is a signing request evaluated by the Engine.
+type Request struct {
+	Key KeyInfo
+	// Consumer is the id of the consumer requesting the signature, the scope of the consumer window limits
+	Consumer string
+	// Approved is set when the approvals the rules require are given
+	Approved bool
+	// Intent is nil for a bare message hash
+	Intent *Intent
+	Time   time.Time
+}
+
+// Violation is returned when a request breaks a rule.
+type Violation struct {
+	// Rule is the name of the broken rule, empty when no rule applies to the key
+	Rule   string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	if v.Rule == "" {
+		return "policy violation: " + v.Reason
+	}
+	return fmt.Sprintf("policy violation: rule %q: %s", v.Rule, v.Reason)
+}
+
+// Engine evaluates signing requests against a Policy.
+type Engine struct {
+	policy  *Policy
+	tracker SpendTracker
+	// mu makes the window limit check and the record of the amount atomic
+	mu sync.Mutex
+}
+
+// NewEngine returns an Engine evaluating p. The amounts signed are recorded by tracker,
+// in memory for the longest window of p when tracker is nil.
+func NewEngine(p *Policy, tracker SpendTracker) *Engine {
+	if tracker == nil {
+		tracker = NewMemoryTracker(p.MaxWindow())
+	}
+	return &Engine{policy: p, tracker: tracker}
+}
+
+// Authorization is a granted request. Its amount is counted against the window limits
+// until Release is called, which must be done when the signature is not produced.
+type Authorization struct {
+	// Approvals are the approvals the request needs before it is signed, its amount is not
+	// counted until it is authorized again with Request.Approved set
+	Approvals []*ApprovalRequirement
+
+	release func()
+}
+
+// Release stops counting the amount of the request against the window limits.
+func (a *Authorization) Release() {
+	if a.release != nil {
+		a.release()
+		a.release = nil
+	}
+}
+
+// Authorize evaluates req against every rule applying to its key, returning a *Violation
+// describing the first broken rule.
+func (e *Engine) Authorize(req *Request) (*Authorization, error) {
+	rules := e.rules(&req.Key)
+	if len(rules) == 0 {
+		if e.policy.DefaultAction == ActionAllow {
+			return &Authorization{}, nil
+		}
+		return nil, &Violation{Reason: "no policy rule applies to the key"}
+	}
+
+	var approvals []*ApprovalRequirement
+	for _, rule := range rules {
+		if err := rule.check(req); err != nil {
+			return nil, err
+		}
+		if rule.Approval != nil && !req.Approved && rule.Approval.applies(req.Intent) {
+			approvals = append(approvals, rule.Approval.requirement(rule.Name))
+		}
+	}
+	if req.Intent == nil || req.Intent.Amount.Sign() == 0 {
+		return &Authorization{Approvals: approvals}, nil
+	}
+
+	intent := req.Intent
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// scopes are the scopes the amount is recorded in, checked tells the scopes already evaluated
+	var scopes []string
+	checked := make(map[string]bool)
+	// the same unsigned transaction signed by another key is another transaction
+	var id string
+	if intent.ID != "" {
+		id = intent.ID + "/" + req.Key.Pubkey
+	}
+	for _, rule := range rules {
+		for _, limit := range rule.WindowLimits {
+			if limit.Token != intent.Token {
+				continue
+			}
+			scope := limit.scope(req)
+			counted, isOk := checked[scope]
+			if !isOk {
+				// another input of the same transaction is already counted
+				if id != "" {
+					recorded, err := e.tracker.Recorded(scope, id)
+					if err != nil {
+						return nil, err
+					}
+					counted = recorded
+				}
+				checked[scope] = counted
+				if !counted {
+					scopes = append(scopes, scope)
+				}
+			}
+			if counted {
+				continue
+			}
+			window := time.Duration(limit.Window)
+			spent, err := e.tracker.Spent(scope, intent.Chain, intent.Token, req.Time.Add(-window))
+			if err != nil {
+				return nil, err
+			}
+			if new(big.Int).Add(spent, intent.Amount).Cmp(limit.max) > 0 {
+				return nil, &Violation{Rule: rule.Name, Reason: fmt.Sprintf(
+					"amount %s of %s would exceed the cap %s per %s of the %s, %s already signed",
+					intent.Amount, intent.Token, limit.max, window, limit.Scope, spent)}
+			}
+		}
+	}
+	// the window limits are checked again once the request is approved
+	if len(approvals) > 0 {
+		return &Authorization{Approvals: approvals}, nil
+	}
+	if len(scopes) == 0 {
+		return &Authorization{}, nil
+	}
+	release, err := e.tracker.Record(scopes, id, intent.Chain, intent.Token, intent.Amount, req.Time)
+	if err != nil {
+		return nil, err
+	}
+	return &Authorization{release: release}, nil
+}
+
+// Allowance is what is left of a window limit.
+type Allowance struct {
+	Rule   string
+	Scope  string
+	Window time.Duration
+	Token  string
+	Max    *big.Int
+	// Spent is the amount signed in the window, Remaining the amount that can still be signed
+	Spent     *big.Int
+	Remaining *big.Int
+}
+
+// Allowance returns the remaining amounts of the window limits applying to the key and the
+// consumer of req on chain, for token or for every token when token is empty.
+func (e *Engine) Allowance(req *Request, chain, token string) ([]*Allowance, error) {
+	token = normalizeAddress(token)
+	var allowances []*Allowance
+	for _, rule := range e.rules(&req.Key) {
+		for _, limit := range rule.WindowLimits {
+			if token != "" && limit.Token != token {
+				continue
+			}
+			window := time.Duration(limit.Window)
+			spent, err := e.tracker.Spent(limit.scope(req), chain, limit.Token, req.Time.Add(-window))
+			if err != nil {
+				return nil, err
+			}
+			remaining := new(big.Int).Sub(limit.max, spent)
+			if remaining.Sign() < 0 {
+				remaining.SetInt64(0)
+			}
+			allowances = append(allowances, &Allowance{
+				Rule:      rule.Name,
+				Scope:     limit.Scope,
+				Window:    window,
+				Token:     limit.Token,
+				Max:       new(big.Int).Set(limit.max),
+				Spent:     spent,
+				Remaining: remaining,
+			})
+		}
+	}
+	return allowances, nil
+}
+
+// rules returns the rules applying to key.
+func (e *Engine) rules(key *KeyInfo) []*Rule {
+	var rules []*Rule
+	for _, rule := range e.policy.Rules {
+		if rule.Keys.matches(key) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// scope returns the tracker scope the limit counts the amounts of req in.
+func (l *WindowLimit) scope(req *Request) string {
+	if l.Scope == ScopeConsumer {
+		return ScopeConsumer + ":" + req.Consumer
+	}
+	return ScopeKey + ":" + req.Key.Pubkey
+}
+
+func (s *KeySelector) matches(key *KeyInfo) bool {
+	if len(s.Pubkeys) > 0 && !slices.Contains(s.Pubkeys, key.Pubkey) {
+		return false
+	}
+	if len(s.Consumers) > 0 && !slices.Contains(s.Consumers, key.Consumer) {
+		return false
+	}
+	if len(s.Labels) > 0 && !slices.ContainsFunc(key.Labels, func(label string) bool {
+		return slices.Contains(s.Labels, label)
+	}) {
+		return false
+	}
+	return true
+}
+
+// check evaluates every restriction of the rule but the window limits.
+func (r *Rule) check(req *Request) error {
+	violation := func(format string, args ...any) error {
+		return &Violation{Rule: r.Name, Reason: fmt.Sprintf(format, args...)}
+	}
+	if len(r.TimeWindows) > 0 && !slices.ContainsFunc(r.TimeWindows, func(w *TimeWindow) bool { return w.contains(req.Time) }) {
+		return violation("signing is not allowed at %s", req.Time.UTC().Format(time.RFC3339))
+	}
+
+	intent := req.Intent
+	if intent == nil {
+		if !r.AllowHashSigning {
+			return violation("signing a bare message hash is not allowed for the key, submit the unsigned transaction")
+		}
+		return nil
+	}
+	if len(r.Chains) > 0 && !slices.Contains(r.Chains, intent.Chain) {
+		return violation("chain %s is not allowed", intent.Chain)
+	}
+	for _, transfer := range intent.Transfers {
+		if slices.Contains(r.DenyTo, transfer.To) {
+			return violation("recipient %s is denied", transfer.To)
+		}
+		if len(r.AllowTo) > 0 && !slices.Contains(r.AllowTo, transfer.To) {
+			return violation("recipient %s is not allowed", displayRecipient(transfer.To))
+		}
+	}
+	if len(r.Tokens) > 0 && !slices.Contains(r.Tokens, intent.Token) {
+		return violation("token %s is not allowed", intent.Token)
+	}
+	if len(r.Methods) > 0 && intent.Method != "" &&
+		!slices.Contains(r.Methods, strings.ToLower(intent.Method)) && !slices.Contains(r.Methods, intent.Selector) {
+		return violation("contract method %s is not allowed", intent.Method)
+	}
+	if max, isOk := r.maxValue[intent.Token]; isOk && intent.Amount.Cmp(max) > 0 {
+		return violation("amount %s of %s exceeds the cap %s per transaction", intent.Amount, intent.Token, max)
+	}
+	return nil
+}
+
+func displayRecipient(to string) string {
+	if to == "" {
+		return "of a contract creation"
+	}
+	return to
+}
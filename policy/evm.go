@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ERC-20 methods decoded into the recipient and the amount of a token transfer.
+var (
+	erc20Transfer     = [4]byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	erc20TransferFrom = [4]byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+	erc20Approve      = [4]byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+)
+
+// EVMTransaction is an unsigned EVM transaction submitted for signing.
+type EVMTransaction struct {
+	tx     *types.Transaction
+	signer types.Signer
+	hash   common.Hash
+	intent *Intent
+}
+
+// ParseEVMTransaction decodes a hex encoded unsigned transaction, either legacy RLP or EIP-2718 typed.
+// The chain id carried by the transaction must match chainID when both are set; chainID is
+// required when the transaction does not carry one.
+func ParseEVMTransaction(unsignedTx string, chainID uint64) (*EVMTransaction, error) {
+	raw, err := hexutil.Decode(addHexPrefix(unsignedTx))
+	if err != nil {
+		return nil, fmt.Errorf("decode hex: %w", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	if v, r, s := tx.RawSignatureValues(); r.Sign() != 0 || s.Sign() != 0 || (tx.Type() != types.LegacyTxType && v.Sign() != 0) {
+		return nil, errors.New("transaction is already signed")
+	}
+
+	id := new(big.Int).SetUint64(chainID)
+	// typed transactions carry the chain id, the EIP-155 signing preimage of a legacy transaction in V
+	txChainID := tx.ChainId()
+	if tx.Type() == types.LegacyTxType {
+		txChainID, _, _ = tx.RawSignatureValues()
+	}
+	if txChainID.Sign() != 0 {
+		if chainID != 0 && txChainID.Cmp(id) != 0 {
+			return nil, fmt.Errorf("transaction chain id %s does not match chain id %d", txChainID, chainID)
+		}
+		id = txChainID
+	}
+	if id.Sign() == 0 {
+		return nil, errors.New("chain id is required")
+	}
+	signer := types.LatestSignerForChainID(id)
+	hash := signer.Hash(tx)
+	return &EVMTransaction{
+		tx:     tx,
+		signer: signer,
+		hash:   hash,
+		intent: evmIntent(tx, id, hash),
+	}, nil
+}
+
+func (t *EVMTransaction) SigningHash() []byte {
+	return t.hash[:]
+}
+
+func (t *EVMTransaction) Intent() *Intent {
+	return t.intent
+}
+
+// Sign returns the hex encoded signed transaction. V of signature is 0 or 1.
+func (t *EVMTransaction) Sign(signature []byte) (string, error) {
+	signed, err := t.tx.WithSignature(t.signer, signature)
+	if err != nil {
+		return "", err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(raw), nil
+}
+
+// evmIntent derives the intent of tx. ERC-20 transfers and approvals without native value are
+// decoded into a transfer of the token, other contract calls transfer their native value.
+func evmIntent(tx *types.Transaction, chainID *big.Int, hash common.Hash) *Intent {
+	transfer := &Transfer{Amount: new(big.Int).Set(tx.Value())}
+	intent := &Intent{
+		ID:        hash.Hex(),
+		Chain:     "eip155:" + chainID.String(),
+		Transfers: []*Transfer{transfer},
+		Amount:    transfer.Amount,
+		Token:     NativeToken,
+	}
+	if tx.To() == nil {
+		intent.Method = MethodCreate
+		return intent
+	}
+	transfer.To = normalizeAddress(tx.To().Hex())
+	data := tx.Data()
+	if len(data) < 4 {
+		return intent
+	}
+	intent.Selector = hexutil.Encode(data[:4])
+	intent.Method = intent.Selector
+	if tx.Value().Sign() != 0 {
+		return intent
+	}
+
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	args := data[4:]
+	var to []byte
+	var amount []byte
+	var method string
+	switch {
+	case selector == erc20Transfer && len(args) == 64:
+		to, amount, method = args[:32], args[32:64], "transfer"
+	case selector == erc20Approve && len(args) == 64:
+		to, amount, method = args[:32], args[32:64], "approve"
+	case selector == erc20TransferFrom && len(args) == 96:
+		to, amount, method = args[32:64], args[64:96], "transferFrom"
+	default:
+		return intent
+	}
+	// an address argument is left padded with 12 zero bytes
+	if !bytes.Equal(to[:12], make([]byte, 12)) {
+		return intent
+	}
+	intent.Method = method
+	intent.Token = transfer.To
+	transfer.To = normalizeAddress(common.BytesToAddress(to).Hex())
+	transfer.Amount = new(big.Int).SetBytes(amount)
+	intent.Amount = transfer.Amount
+	return intent
+}
+
+func addHexPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return "0x" + s[2:]
+	}
+	return "0x" + s
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(address)
+}
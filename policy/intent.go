@@ -0,0 +1,49 @@
+// Package policy evaluates signing requests against declarative transaction rules.
+package policy
+
+import "math/big"
+
+const (
+	// NativeToken is the Intent.Token of the native asset of a chain.
+	NativeToken = "native"
+	// MethodCreate is the Intent.Method of a contract creation.
+	MethodCreate = "create"
+)
+
+// Intent is what a structured signing request does, derived from the transaction being signed.
+type Intent struct {
+	// ID identifies the transaction: its amount is counted once against the window limits
+	// when the same key signs it several times, e.g. once per input of a UTXO transaction
+	ID string
+	// Chain is the CAIP-2 id of the chain, e.g. eip155:1
+	Chain string
+	// Transfers are the payments of the transaction
+	Transfers []*Transfer
+	// Amount is the value leaving the key in the smallest unit of Token, the fee of a UTXO transaction included
+	Amount *big.Int
+	// Token is NativeToken or the lower case contract address of the transferred token
+	Token string
+	// Method is the name of a decoded contract method, MethodCreate, the hex selector of
+	// other contract calls or empty for a plain transfer
+	Method string
+	// Selector is the hex selector of the called contract method, empty when no method is called
+	Selector string
+}
+
+// Transfer is one payment of a transaction.
+type Transfer struct {
+	// To is the recipient: the receiver of a token transfer or approval, the called contract
+	// or the receiver of the native value otherwise, empty for a contract creation
+	To     string
+	Amount *big.Int
+}
+
+// Transaction is an unsigned transaction submitted for signing.
+type Transaction interface {
+	// SigningHash returns the hash signed with the key.
+	SigningHash() []byte
+	// Intent returns what the transaction does.
+	Intent() *Intent
+	// Sign returns the encoded transaction carrying signature, a 65 bytes [R || S || V] ECDSA signature.
+	Sign(signature []byte) (string, error)
+}
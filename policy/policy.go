@@ -0,0 +1,306 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Actions applied to the keys no rule applies to.
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// Policy is the content of the policy file.
+type Policy struct {
+	// DefaultAction is applied to the keys no rule applies to, allow or deny, deny when empty
+	DefaultAction string  `json:"defaultAction"`
+	Rules         []*Rule `json:"rules"`
+	// Approvers are the identities the approvals of the rules are given by
+	Approvers []*Approver `json:"approvers"`
+
+	approvers map[string]*Approver
+}
+
+// Rule restricts the signatures of the keys it applies to. A signature must satisfy
+// every rule applying to its key; an empty list places no restriction.
+type Rule struct {
+	Name string      `json:"name"`
+	Keys KeySelector `json:"keys"`
+	// AllowHashSigning allows signing bare message hashes, which can not be checked against the rule
+	AllowHashSigning bool `json:"allowHashSigning"`
+	// Chains are the allowed CAIP-2 chain ids, e.g. eip155:1
+	Chains []string `json:"chains"`
+	// AllowTo are the allowed recipients
+	AllowTo []string `json:"allowTo"`
+	// DenyTo are the denied recipients
+	DenyTo []string `json:"denyTo"`
+	// Tokens are the allowed tokens, NativeToken or token contract addresses
+	Tokens []string `json:"tokens"`
+	// Methods are the allowed contract methods, decoded method names or hex selectors
+	Methods []string `json:"methods"`
+	// MaxValue caps the amount of one transaction by token, in the smallest unit of the token
+	MaxValue map[string]string `json:"maxValue"`
+	// WindowLimits cap the amount signed by a key or a consumer over rolling windows
+	WindowLimits []*WindowLimit `json:"windowLimits"`
+	// TimeWindows are the times signatures are allowed at
+	TimeWindows []*TimeWindow `json:"timeWindows"`
+	// Approval holds the signatures of the rule until they are approved, nil to sign them at once
+	Approval *Approval `json:"approval"`
+
+	maxValue map[string]*big.Int
+}
+
+// KeySelector selects the keys a rule applies to. A key is selected when it matches every
+// non-empty list; an empty selector selects every key.
+type KeySelector struct {
+	Pubkeys   []string `json:"pubkeys"`
+	Labels    []string `json:"labels"`
+	Consumers []string `json:"consumers"`
+}
+
+// Scopes of a window limit.
+const (
+	// ScopeKey counts the amounts signed by each key on its own
+	ScopeKey = "key"
+	// ScopeConsumer counts the amounts signed by a consumer across the keys of the rule
+	ScopeConsumer = "consumer"
+)
+
+// WindowLimit caps the amount of a token signed on one chain over a rolling window.
+type WindowLimit struct {
+	Token string `json:"token"`
+	// Scope is ScopeKey or ScopeConsumer, ScopeKey when empty
+	Scope  string   `json:"scope"`
+	Window Duration `json:"window"`
+	// Max is the cap in the smallest unit of the token
+	Max string `json:"max"`
+
+	max *big.Int
+}
+
+// TimeWindow is a daily time range signatures are allowed in.
+type TimeWindow struct {
+	// Days are the allowed week days, e.g. mon, every day when empty
+	Days []string `json:"days"`
+	// Start and End are the bounds of the range as 15:04; the range wraps past midnight when End is before Start
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Location is the IANA time zone of the range, UTC when empty
+	Location string `json:"location"`
+
+	days       []time.Weekday
+	start, end int
+	location   *time.Location
+}
+
+// Duration is a time.Duration written as a Go duration string in JSON, e.g. 24h,
+// or as one of the aliases hour, day and week.
+type Duration time.Duration
+
+var durationAliases = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if duration, isOk := durationAliases[strings.ToLower(s)]; isOk {
+		*d = Duration(duration)
+		return nil
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Load reads and validates the policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("decode policy file: %w", err)
+	}
+	if err := policy.init(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// init validates the policy and prepares its rules for evaluation.
+func (p *Policy) init() error {
+	switch p.DefaultAction {
+	case "":
+		p.DefaultAction = ActionDeny
+	case ActionAllow, ActionDeny:
+	default:
+		return fmt.Errorf("default action must be %s or %s", ActionAllow, ActionDeny)
+	}
+	p.approvers = make(map[string]*Approver, len(p.Approvers))
+	for _, approver := range p.Approvers {
+		if err := approver.init(); err != nil {
+			return err
+		}
+		if _, isOk := p.approvers[approver.ID]; isOk {
+			return fmt.Errorf("duplicate approver %q", approver.ID)
+		}
+		p.approvers[approver.ID] = approver
+	}
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i)
+		}
+		if err := rule.init(); err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+		if rule.Approval != nil {
+			if err := rule.Approval.init(p.approvers); err != nil {
+				return fmt.Errorf("%s: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) init() error {
+	r.AllowTo = normalizeAddresses(r.AllowTo)
+	r.DenyTo = normalizeAddresses(r.DenyTo)
+	r.Tokens = normalizeAddresses(r.Tokens)
+	r.Methods = normalizeAddresses(r.Methods)
+	r.maxValue = make(map[string]*big.Int, len(r.MaxValue))
+	for token, value := range r.MaxValue {
+		max, err := parseAmount(value)
+		if err != nil {
+			return fmt.Errorf("max value of %s: %w", token, err)
+		}
+		r.maxValue[normalizeAddress(token)] = max
+	}
+	for _, limit := range r.WindowLimits {
+		if limit.Window <= 0 {
+			return errors.New("window of a window limit must be positive")
+		}
+		max, err := parseAmount(limit.Max)
+		if err != nil {
+			return fmt.Errorf("window limit of %s: %w", limit.Token, err)
+		}
+		switch limit.Scope {
+		case "":
+			limit.Scope = ScopeKey
+		case ScopeKey, ScopeConsumer:
+		default:
+			return fmt.Errorf("scope of a window limit must be %s or %s", ScopeKey, ScopeConsumer)
+		}
+		limit.Token = normalizeAddress(limit.Token)
+		limit.max = max
+	}
+	for _, window := range r.TimeWindows {
+		if err := window.init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *TimeWindow) init() error {
+	for _, day := range w.Days {
+		weekday, isOk := weekdays[strings.ToLower(day)]
+		if !isOk {
+			return fmt.Errorf("invalid day %q", day)
+		}
+		w.days = append(w.days, weekday)
+	}
+	var err error
+	if w.start, err = parseClock(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseClock(w.End); err != nil {
+		return err
+	}
+	if w.location, err = time.LoadLocation(w.Location); err != nil {
+		return err
+	}
+	return nil
+}
+
+// contains reports whether t is in the time window.
+func (w *TimeWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	inRange := w.start <= minute && minute < w.end
+	if w.end <= w.start {
+		// the range wraps past midnight, the early part belongs to the day it started on
+		inRange = minute >= w.start || minute < w.end
+		if minute < w.end {
+			day = (day + 6) % 7
+		}
+	}
+	return inRange && (len(w.days) == 0 || slices.Contains(w.days, day))
+}
+
+// Approver returns the approver of id.
+func (p *Policy) Approver(id string) (*Approver, bool) {
+	approver, isOk := p.approvers[id]
+	return approver, isOk
+}
+
+// MaxWindow returns the longest window of the window limits, the amounts signed
+// before it no longer count.
+func (p *Policy) MaxWindow() time.Duration {
+	var window time.Duration
+	for _, rule := range p.Rules {
+		for _, limit := range rule.WindowLimits {
+			window = max(window, time.Duration(limit.Window))
+		}
+	}
+	return window
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	amount, isOk := new(big.Int).SetString(s, 10)
+	if !isOk || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+func normalizeAddresses(addresses []string) []string {
+	normalized := make([]string, len(addresses))
+	for i, address := range addresses {
+		normalized[i] = normalizeAddress(address)
+	}
+	return normalized
+}
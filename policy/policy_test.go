@@ -0,0 +1,402 @@
+package policy
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcecdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	recipient = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	usdt      = common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+)
+
+func encodeTx(t *testing.T, tx *types.Transaction) string {
+	raw, err := tx.MarshalBinary()
+	assert.NoError(t, err)
+	return hexutil.Encode(raw)
+}
+
+func erc20Call(selector [4]byte, args ...[]byte) []byte {
+	data := append([]byte{}, selector[:]...)
+	for _, arg := range args {
+		data = append(data, common.LeftPadBytes(arg, 32)...)
+	}
+	return data
+}
+
+func TestParseEVMTransaction(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     7,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(100),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     big.NewInt(1000),
+	})
+	parsed, err := ParseEVMTransaction(encodeTx(t, tx), 0)
+	assert.NoError(t, err)
+	hash := types.LatestSignerForChainID(big.NewInt(1)).Hash(tx)
+	assert.Equal(t, &Intent{
+		ID:        hash.Hex(),
+		Chain:     "eip155:1",
+		Transfers: []*Transfer{{To: "0x00000000000000000000000000000000000000aa", Amount: big.NewInt(1000)}},
+		Amount:    big.NewInt(1000),
+		Token:     NativeToken,
+	}, parsed.Intent())
+	_, err = ParseEVMTransaction(encodeTx(t, tx), 5)
+	assert.Error(t, err)
+
+	// the signed transaction is sent by the signing key
+	signature, err := crypto.Sign(parsed.SigningHash(), privateKey)
+	assert.NoError(t, err)
+	signedTx, err := parsed.Sign(signature)
+	assert.NoError(t, err)
+	var signed types.Transaction
+	assert.NoError(t, signed.UnmarshalBinary(hexutil.MustDecode(signedTx)))
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), &signed)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(privateKey.PublicKey), sender)
+	_, err = ParseEVMTransaction(signedTx, 0)
+	assert.Error(t, err)
+
+	// a legacy transaction needs a chain id
+	legacy := types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(1),
+		Gas:      60000,
+		To:       &usdt,
+		Data:     erc20Call(erc20Transfer, recipient.Bytes(), big.NewInt(5e6).Bytes()),
+	})
+	_, err = ParseEVMTransaction(encodeTx(t, legacy), 0)
+	assert.Error(t, err)
+	parsed, err = ParseEVMTransaction(trimHexPrefix(encodeTx(t, legacy)), 56)
+	assert.NoError(t, err)
+	hash = types.NewEIP155Signer(big.NewInt(56)).Hash(legacy)
+	assert.Equal(t, &Intent{
+		ID:        hash.Hex(),
+		Chain:     "eip155:56",
+		Transfers: []*Transfer{{To: "0x00000000000000000000000000000000000000aa", Amount: big.NewInt(5e6)}},
+		Amount:    big.NewInt(5e6),
+		Token:     "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		Method:    "transfer",
+		Selector:  "0xa9059cbb",
+	}, parsed.Intent())
+	assert.Equal(t, hash[:], parsed.SigningHash())
+
+	call := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &usdt, Value: big.NewInt(3), Data: []byte{1, 2, 3, 4, 5}})
+	parsed, err = ParseEVMTransaction(encodeTx(t, call), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01020304", parsed.Intent().Method)
+	assert.Equal(t, NativeToken, parsed.Intent().Token)
+	assert.Equal(t, big.NewInt(3), parsed.Intent().Amount)
+}
+
+func trimHexPrefix(s string) string {
+	return s[2:]
+}
+
+func loadPolicy(t *testing.T, policy string) *Policy {
+	var p Policy
+	assert.NoError(t, json.Unmarshal([]byte(policy), &p))
+	assert.NoError(t, p.init())
+	return &p
+}
+
+func transfer(to, token string, amount int64) *Intent {
+	intent := &Intent{
+		Chain:     "eip155:1",
+		Transfers: []*Transfer{{To: to, Amount: big.NewInt(amount)}},
+		Amount:    big.NewInt(amount),
+		Token:     token,
+	}
+	if token != NativeToken {
+		intent.Method = "transfer"
+		intent.Selector = "0x" + hex.EncodeToString(erc20Transfer[:])
+	}
+	return intent
+}
+
+func TestEngine(t *testing.T) {
+	p := loadPolicy(t, `{
+		"rules": [
+			{
+				"name": "hot",
+				"keys": {"labels": ["hot"]},
+				"chains": ["eip155:1"],
+				"denyTo": ["0x00000000000000000000000000000000000000BB"],
+				"tokens": ["native", "0xdAC17F958D2ee523a2206206994597C13D831ec7"],
+				"methods": ["transfer"],
+				"maxValue": {"native": "100"},
+				"windowLimits": [{"token": "native", "window": "1h", "max": "250"}]
+			},
+			{
+				"name": "office hours",
+				"keys": {"consumers": ["alice"]},
+				"allowHashSigning": true,
+				"timeWindows": [{"days": ["mon", "tue", "wed", "thu", "fri"], "start": "09:00", "end": "18:00"}]
+			}
+		]
+	}`)
+	engine := NewEngine(p, nil)
+	hot := KeyInfo{Pubkey: "aa01", Consumer: "bob", Labels: []string{"hot"}}
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	authorize := func(key KeyInfo, intent *Intent, at time.Time) (*Authorization, *Violation) {
+		auth, err := engine.Authorize(&Request{Key: key, Intent: intent, Time: at})
+		if err == nil {
+			return auth, nil
+		}
+		violation, isOk := err.(*Violation)
+		assert.True(t, isOk)
+		return nil, violation
+	}
+
+	// no rule applies and the default action is deny
+	_, violation := authorize(KeyInfo{Pubkey: "aa02"}, transfer("0xaa", NativeToken, 1), monday)
+	assert.Equal(t, "", violation.Rule)
+
+	_, violation = authorize(hot, nil, monday)
+	assert.Equal(t, "hot", violation.Rule)
+	assert.Contains(t, violation.Error(), "bare message hash")
+
+	_, violation = authorize(hot, transfer("0x00000000000000000000000000000000000000bb", NativeToken, 1), monday)
+	assert.Contains(t, violation.Reason, "is denied")
+	_, violation = authorize(hot, transfer("0xaa", "0x01", 1), monday)
+	assert.Contains(t, violation.Reason, "token 0x01")
+	_, violation = authorize(hot, &Intent{Chain: "eip155:1", Amount: big.NewInt(0), Token: NativeToken, Method: "0x12345678", Selector: "0x12345678"}, monday)
+	assert.Contains(t, violation.Reason, "contract method")
+	_, violation = authorize(hot, &Intent{Chain: "eip155:5", Amount: big.NewInt(1), Token: NativeToken}, monday)
+	assert.Contains(t, violation.Reason, "chain eip155:5")
+	_, violation = authorize(hot, transfer("0xaa", NativeToken, 101), monday)
+	assert.Contains(t, violation.Reason, "per transaction")
+	_, violation = authorize(hot, transfer("0xaa", "0xdac17f958d2ee523a2206206994597c13d831ec7", 1000), monday)
+	assert.Nil(t, violation)
+
+	// the rolling window counts the amounts signed in the last hour
+	_, violation = authorize(hot, transfer("0xaa", NativeToken, 100), monday)
+	assert.Nil(t, violation)
+	auth, violation := authorize(hot, transfer("0xaa", NativeToken, 100), monday.Add(30*time.Minute))
+	assert.Nil(t, violation)
+	_, violation = authorize(hot, transfer("0xaa", NativeToken, 100), monday.Add(40*time.Minute))
+	assert.Contains(t, violation.Reason, "200 already signed")
+	auth.Release()
+	_, violation = authorize(hot, transfer("0xaa", NativeToken, 100), monday.Add(40*time.Minute))
+	assert.Nil(t, violation)
+	_, violation = authorize(hot, transfer("0xaa", NativeToken, 100), monday.Add(61*time.Minute))
+	assert.Nil(t, violation)
+
+	// every rule applying to the key must be satisfied
+	both := KeyInfo{Pubkey: "aa03", Consumer: "alice", Labels: []string{"hot"}}
+	_, violation = authorize(both, nil, monday)
+	assert.Equal(t, "hot", violation.Rule)
+	alice := KeyInfo{Pubkey: "aa04", Consumer: "alice"}
+	_, violation = authorize(alice, nil, monday)
+	assert.Nil(t, violation)
+	_, violation = authorize(alice, nil, monday.Add(9*time.Hour))
+	assert.Equal(t, "office hours", violation.Rule)
+	_, violation = authorize(alice, nil, monday.Add(-2*24*time.Hour))
+	assert.Equal(t, "office hours", violation.Rule)
+}
+
+func TestWindowLimitScopes(t *testing.T) {
+	p := loadPolicy(t, `{
+		"rules": [{
+			"name": "daily",
+			"windowLimits": [
+				{"token": "native", "window": "day", "max": "100"},
+				{"token": "native", "scope": "consumer", "window": "week", "max": "150"}
+			]
+		}]
+	}`)
+	engine := NewEngine(p, nil)
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	request := func(pubkey, id string, amount int64, at time.Time) *Request {
+		intent := transfer("0xaa", NativeToken, amount)
+		intent.ID = id
+		return &Request{Key: KeyInfo{Pubkey: pubkey}, Consumer: "bob", Intent: intent, Time: at}
+	}
+
+	_, err := engine.Authorize(request("aa01", "tx1", 80, monday))
+	assert.NoError(t, err)
+	// another input of the same transaction is counted once
+	_, err = engine.Authorize(request("aa01", "tx1", 80, monday))
+	assert.NoError(t, err)
+	_, err = engine.Authorize(request("aa01", "tx2", 30, monday))
+	assert.ErrorContains(t, err, "per 24h0m0s of the key")
+	// the consumer cap spans its keys, the same transaction signed by another key counts again
+	_, err = engine.Authorize(request("aa02", "tx1", 80, monday))
+	assert.ErrorContains(t, err, "per 168h0m0s of the consumer")
+	_, err = engine.Authorize(request("aa02", "tx1", 70, monday))
+	assert.NoError(t, err)
+
+	allowances, err := engine.Allowance(&Request{Key: KeyInfo{Pubkey: "aa01"}, Consumer: "bob", Time: monday.Add(25 * time.Hour)}, "eip155:1", "")
+	assert.NoError(t, err)
+	assert.Len(t, allowances, 2)
+	assert.Equal(t, ScopeKey, allowances[0].Scope)
+	assert.Equal(t, big.NewInt(100), allowances[0].Remaining)
+	assert.Equal(t, ScopeConsumer, allowances[1].Scope)
+	assert.Equal(t, big.NewInt(150), allowances[1].Spent)
+	assert.Zero(t, allowances[1].Remaining.Sign())
+
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"week"`), &d))
+	assert.Equal(t, Duration(7*24*time.Hour), d)
+	assert.Error(t, (&Policy{Rules: []*Rule{{WindowLimits: []*WindowLimit{{Window: d, Max: "1", Scope: "chain"}}}}}).init())
+}
+
+func TestApproval(t *testing.T) {
+	alice, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	bobPublic, bob, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	p := loadPolicy(t, fmt.Sprintf(`{
+		"approvers": [
+			{"id": "alice", "publicKey": "%x"},
+			{"id": "bob", "type": "eddsa", "publicKey": "%x"}
+		],
+		"rules": [{
+			"name": "withdrawals",
+			"windowLimits": [{"token": "native", "window": "day", "max": "1500"}],
+			"approval": {"above": {"native": "1000"}, "required": 2, "approvers": ["alice", "bob"], "ttl": "hour"}
+		}]
+	}`, crypto.CompressPubkey(&alice.PublicKey), bobPublic))
+	engine := NewEngine(p, nil)
+	key := KeyInfo{Pubkey: "aa01"}
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	auth, err := engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", NativeToken, 1000), Time: monday})
+	assert.NoError(t, err)
+	assert.Empty(t, auth.Approvals)
+	auth, err = engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", NativeToken, 1001), Time: monday})
+	assert.ErrorContains(t, err, "would exceed the cap")
+	auth, err = engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", NativeToken, 500), Time: monday.Add(time.Minute)})
+	assert.NoError(t, err)
+	auth.Release()
+
+	// a held request is not counted until it is approved
+	auth, err = engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", "0x01", 5000), Time: monday})
+	assert.NoError(t, err)
+	assert.Empty(t, auth.Approvals)
+	p.Rules[0].WindowLimits = nil
+	auth, err = engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", NativeToken, 1001), Time: monday})
+	assert.NoError(t, err)
+	assert.Equal(t, []*ApprovalRequirement{{Rule: "withdrawals", Required: 2, Approvers: []string{"alice", "bob"}, TTL: time.Hour}}, auth.Approvals)
+	auth, err = engine.Authorize(&Request{Key: key, Intent: transfer("0xaa", NativeToken, 1001), Time: monday, Approved: true})
+	assert.NoError(t, err)
+	assert.Empty(t, auth.Approvals)
+
+	digest := crypto.Keccak256([]byte("request"))
+	approver, isOk := p.Approver("alice")
+	assert.True(t, isOk)
+	signature, err := crypto.Sign(digest, alice)
+	assert.NoError(t, err)
+	assert.True(t, approver.Verify(digest, signature))
+	assert.False(t, approver.Verify(crypto.Keccak256([]byte("other")), signature))
+	approver, isOk = p.Approver("bob")
+	assert.True(t, isOk)
+	assert.True(t, approver.Verify(digest, ed25519.Sign(bob, digest)))
+	assert.False(t, approver.Verify(digest, signature))
+
+	assert.Error(t, (&Approval{Required: 2, Approvers: []string{"alice"}}).init(p.approvers))
+	assert.Error(t, (&Approval{Required: 1, Approvers: []string{"carol"}}).init(p.approvers))
+}
+
+func TestParseBitcoinPSBT(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	pubkey := crypto.CompressPubkey(&privateKey.PublicKey)
+	params := &chaincfg.RegressionNetParams
+	own, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubkey), params)
+	assert.NoError(t, err)
+	ownScript, err := txscript.PayToAddrScript(own)
+	assert.NoError(t, err)
+	to, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), params)
+	assert.NoError(t, err)
+	toScript, err := txscript.PayToAddrScript(to)
+	assert.NoError(t, err)
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(60000, toScript))
+	tx.AddTxOut(wire.NewTxOut(39000, ownScript))
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	assert.NoError(t, err)
+	encode := func() string {
+		b64, err := packet.B64Encode()
+		assert.NoError(t, err)
+		return b64
+	}
+	// the fee is unknown without the witness utxo
+	_, err = ParseBitcoinPSBT(encode(), 0, "regtest", pubkey)
+	assert.Error(t, err)
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(100000, ownScript)
+
+	_, err = ParseBitcoinPSBT(encode(), 1, "regtest", pubkey)
+	assert.Error(t, err)
+	_, err = ParseBitcoinPSBT(encode(), 0, "litecoin", pubkey)
+	assert.Error(t, err)
+	parsed, err := ParseBitcoinPSBT(encode(), 0, "regtest", pubkey)
+	assert.NoError(t, err)
+	assert.Equal(t, &Intent{
+		ID:        tx.TxHash().String(),
+		Chain:     "bip122:0f9188f13cb7b2c71f2a335e3a4fc328",
+		Transfers: []*Transfer{{To: to.EncodeAddress(), Amount: big.NewInt(60000)}},
+		Amount:    big.NewInt(61000),
+		Token:     NativeToken,
+	}, parsed.Intent())
+
+	// the partial signature verifies against the signing hash
+	signature, err := crypto.Sign(parsed.SigningHash(), privateKey)
+	assert.NoError(t, err)
+	signedPSBT, err := parsed.Sign(signature)
+	assert.NoError(t, err)
+	signed, err := psbt.NewFromRawBytes(strings.NewReader(signedPSBT), true)
+	assert.NoError(t, err)
+	assert.Len(t, signed.Inputs[0].PartialSigs, 1)
+	partial := signed.Inputs[0].PartialSigs[0]
+	assert.Equal(t, pubkey, partial.PubKey)
+	assert.Equal(t, byte(txscript.SigHashAll), partial.Signature[len(partial.Signature)-1])
+	sig, err := btcecdsa.ParseDERSignature(partial.Signature[:len(partial.Signature)-1])
+	assert.NoError(t, err)
+	key, err := btcec.ParsePubKey(pubkey)
+	assert.NoError(t, err)
+	assert.True(t, sig.Verify(parsed.SigningHash(), key))
+}
+
+func TestTimeWindow(t *testing.T) {
+	window := &TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00", Location: "Asia/Shanghai"}
+	assert.NoError(t, window.init())
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.NoError(t, err)
+	friday := time.Date(2026, 10, 23, 0, 0, 0, 0, shanghai)
+	assert.False(t, window.contains(friday.Add(21*time.Hour)))
+	assert.True(t, window.contains(friday.Add(23*time.Hour)))
+	assert.True(t, window.contains(friday.Add(25*time.Hour)))
+	assert.False(t, window.contains(friday.Add(1*time.Hour)))
+	assert.False(t, window.contains(friday.Add(27*time.Hour)))
+
+	assert.Error(t, (&TimeWindow{Start: "25:00", End: "02:00"}).init())
+	assert.Error(t, (&TimeWindow{Days: []string{"someday"}, Start: "01:00", End: "02:00"}).init())
+}
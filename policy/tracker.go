@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"math/big"
+	"sync"
+	"time"
+)
+
+// SpendTracker records the amounts signed in every scope of the window limits, the scope
+// being "key:" followed by the public key or "consumer:" followed by the consumer id.
+type SpendTracker interface {
+	// Spent returns the amount of token signed in scope on chain since since.
+	Spent(scope, chain, token string, since time.Time) (*big.Int, error)
+	// Recorded reports whether the transaction id is already recorded in scope.
+	Recorded(scope, id string) (bool, error)
+	// Record records amount of token signed on chain at at by the transaction id in every scope,
+	// the returned function removes the records again.
+	Record(scopes []string, id, chain, token string, amount *big.Int, at time.Time) (func(), error)
+}
+
+// MemoryTracker is a SpendTracker keeping the records in memory, they are lost on restart.
+type MemoryTracker struct {
+	retention time.Duration
+	mu        sync.Mutex
+	spends    map[spendKey][]*spend
+	ids       map[string]map[string]int
+}
+
+type spendKey struct {
+	scope, chain, token string
+}
+
+type spend struct {
+	id     string
+	amount *big.Int
+	at     time.Time
+}
+
+// NewMemoryTracker returns a MemoryTracker forgetting the records older than retention.
+func NewMemoryTracker(retention time.Duration) *MemoryTracker {
+	return &MemoryTracker{
+		retention: retention,
+		spends:    make(map[spendKey][]*spend),
+		ids:       make(map[string]map[string]int),
+	}
+}
+
+func (t *MemoryTracker) Spent(scope, chain, token string, since time.Time) (*big.Int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	total := new(big.Int)
+	for _, s := range t.spends[spendKey{scope, chain, token}] {
+		if !s.at.Before(since) {
+			total.Add(total, s.amount)
+		}
+	}
+	return total, nil
+}
+
+func (t *MemoryTracker) Recorded(scope, id string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ids[scope][id] > 0, nil
+}
+
+func (t *MemoryTracker) Record(scopes []string, id, chain, token string, amount *big.Int, at time.Time) (func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records := make(map[spendKey]*spend, len(scopes))
+	for _, scope := range scopes {
+		key := spendKey{scope, chain, token}
+		// the records are appended in time order, drop the expired ones at the front
+		spends := t.spends[key]
+		for len(spends) > 0 && at.Sub(spends[0].at) > t.retention {
+			t.forget(scope, spends[0].id)
+			spends = spends[1:]
+		}
+		record := &spend{id: id, amount: new(big.Int).Set(amount), at: at}
+		t.spends[key] = append(spends, record)
+		records[key] = record
+		if t.ids[scope] == nil {
+			t.ids[scope] = make(map[string]int)
+		}
+		t.ids[scope][id]++
+	}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for key, record := range records {
+			spends := t.spends[key]
+			for i, s := range spends {
+				if s == record {
+					t.spends[key] = append(spends[:i:i], spends[i+1:]...)
+					t.forget(key.scope, record.id)
+					break
+				}
+			}
+			if len(t.spends[key]) == 0 {
+				delete(t.spends, key)
+			}
+		}
+	}, nil
+}
+
+// forget drops one record of the transaction id in scope.
+func (t *MemoryTracker) forget(scope, id string) {
+	ids := t.ids[scope]
+	if ids[id]--; ids[id] <= 0 {
+		delete(ids, id)
+	}
+	if len(ids) == 0 {
+		delete(t.ids, scope)
+	}
+}
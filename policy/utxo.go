@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// bitcoinNetworks are the networks of ParseBitcoinPSBT by name, mainnet when empty.
+var bitcoinNetworks = map[string]*chaincfg.Params{
+	"":                                &chaincfg.MainNetParams,
+	chaincfg.MainNetParams.Name:       &chaincfg.MainNetParams,
+	chaincfg.TestNet3Params.Name:      &chaincfg.TestNet3Params,
+	chaincfg.SigNetParams.Name:        &chaincfg.SigNetParams,
+	chaincfg.RegressionNetParams.Name: &chaincfg.RegressionNetParams,
+	chaincfg.SimNetParams.Name:        &chaincfg.SimNetParams,
+}
+
+// BitcoinTransaction is one input of an unsigned bitcoin transaction, given as a PSBT, submitted for signing.
+type BitcoinTransaction struct {
+	packet *psbt.Packet
+	index  int
+	pubkey []byte
+	hash   []byte
+	intent *Intent
+}
+
+// ParseBitcoinPSBT decodes a base64 PSBT to sign its input inputIndex with the key of compressedPubkey.
+// The input must spend a P2WPKH output of the key with SIGHASH_ALL, and every input must carry its
+// witness UTXO so that the fee is known. The outputs paying back to the key are change and not transfers.
+func ParseBitcoinPSBT(unsignedPSBT string, inputIndex uint32, network string, compressedPubkey []byte) (*BitcoinTransaction, error) {
+	params, isOk := bitcoinNetworks[network]
+	if !isOk {
+		return nil, fmt.Errorf("unknown bitcoin network %q", network)
+	}
+	packet, err := psbt.NewFromRawBytes(strings.NewReader(unsignedPSBT), true)
+	if err != nil {
+		return nil, fmt.Errorf("decode psbt: %w", err)
+	}
+	tx := packet.UnsignedTx
+	index := int(inputIndex)
+	if index >= len(packet.Inputs) {
+		return nil, fmt.Errorf("input index %d out of range, the transaction has %d inputs", index, len(packet.Inputs))
+	}
+	if sighashType := packet.Inputs[index].SighashType; sighashType != 0 && sighashType != txscript.SigHashAll {
+		return nil, errors.New("only SIGHASH_ALL inputs are signed")
+	}
+
+	address, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(compressedPubkey), params)
+	if err != nil {
+		return nil, err
+	}
+	ownScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut, len(tx.TxIn))
+	inputValue := new(big.Int)
+	for i, in := range packet.Inputs {
+		if in.WitnessUtxo == nil {
+			return nil, fmt.Errorf("input %d has no witness utxo", i)
+		}
+		prevOuts[tx.TxIn[i].PreviousOutPoint] = in.WitnessUtxo
+		inputValue.Add(inputValue, big.NewInt(in.WitnessUtxo.Value))
+	}
+	spent := packet.Inputs[index].WitnessUtxo
+	if !bytes.Equal(spent.PkScript, ownScript) {
+		return nil, fmt.Errorf("input %d does not spend a P2WPKH output of the key", index)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts))
+	hash, err := txscript.CalcWitnessSigHash(ownScript, sigHashes, txscript.SigHashAll, tx, index, spent.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	intent := &Intent{
+		ID:     tx.TxHash().String(),
+		Chain:  "bip122:" + params.GenesisHash.String()[:32],
+		Amount: new(big.Int),
+		Token:  NativeToken,
+	}
+	outputValue := new(big.Int)
+	for _, out := range tx.TxOut {
+		value := big.NewInt(out.Value)
+		outputValue.Add(outputValue, value)
+		if bytes.Equal(out.PkScript, ownScript) {
+			continue
+		}
+		intent.Transfers = append(intent.Transfers, &Transfer{To: outputRecipient(out.PkScript, params), Amount: value})
+		intent.Amount.Add(intent.Amount, value)
+	}
+	fee := new(big.Int).Sub(inputValue, outputValue)
+	if fee.Sign() < 0 {
+		return nil, errors.New("outputs spend more than the inputs")
+	}
+	intent.Amount.Add(intent.Amount, fee)
+
+	return &BitcoinTransaction{
+		packet: packet,
+		index:  index,
+		pubkey: compressedPubkey,
+		hash:   hash,
+		intent: intent,
+	}, nil
+}
+
+func (t *BitcoinTransaction) SigningHash() []byte {
+	return t.hash
+}
+
+func (t *BitcoinTransaction) Intent() *Intent {
+	return t.intent
+}
+
+// Sign returns the base64 PSBT carrying the DER encoded signature of the input as a partial signature.
+func (t *BitcoinTransaction) Sign(signature []byte) (string, error) {
+	if len(signature) < 64 {
+		return "", fmt.Errorf("invalid signature length %d", len(signature))
+	}
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:64]) {
+		return "", errors.New("invalid signature")
+	}
+	sig := append(ecdsa.NewSignature(&r, &s).Serialize(), byte(txscript.SigHashAll))
+	updater, err := psbt.NewUpdater(t.packet)
+	if err != nil {
+		return "", err
+	}
+	if _, err := updater.Sign(t.index, sig, t.pubkey, nil, nil); err != nil {
+		return "", err
+	}
+	return t.packet.B64Encode()
+}
+
+// outputRecipient returns the address an output pays to, or its hex script when it does not pay to one address.
+func outputRecipient(pkScript []byte, params *chaincfg.Params) string {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addresses) != 1 {
+		return fmt.Sprintf("script:%x", pkScript)
+	}
+	return normalizeAddress(addresses[0].EncodeAddress())
+}
@@ -9,6 +9,8 @@ type CryptoType string
 const (
 	ECDSA CryptoType = "ecdsa"
 	EDDSA CryptoType = "eddsa"
+	// BLS is BLS12-381 with the signature scheme of Ethereum consensus
+	BLS CryptoType = "bls"
 )
 
 func ParseTransactionType(s string) (CryptoType, error) {
@@ -17,6 +19,8 @@ func ParseTransactionType(s string) (CryptoType, error) {
 		return ECDSA, nil
 	case string(EDDSA):
 		return EDDSA, nil
+	case string(BLS):
+		return BLS, nil
 	default:
 		return "", errors.New("unknown transaction type")
 	}
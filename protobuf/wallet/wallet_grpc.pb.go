@@ -23,6 +23,26 @@ const (
 	WalletService_GetSupportSignWay_FullMethodName   = "/wallet.WalletService/getSupportSignWay"
 	WalletService_ExportPublicKeyList_FullMethodName = "/wallet.WalletService/exportPublicKeyList"
 	WalletService_SignTxMessage_FullMethodName       = "/wallet.WalletService/signTxMessage"
+	WalletService_BatchSignTxMessage_FullMethodName  = "/wallet.WalletService/batchSignTxMessage"
+	WalletService_SignTxMessageStream_FullMethodName = "/wallet.WalletService/signTxMessageStream"
+	WalletService_VerifySignature_FullMethodName     = "/wallet.WalletService/verifySignature"
+	WalletService_RecoverPublicKey_FullMethodName    = "/wallet.WalletService/recoverPublicKey"
+	WalletService_ListKeys_FullMethodName            = "/wallet.WalletService/listKeys"
+	WalletService_GetKey_FullMethodName              = "/wallet.WalletService/getKey"
+	WalletService_HasKey_FullMethodName              = "/wallet.WalletService/hasKey"
+	WalletService_UpdateKeyStatus_FullMethodName     = "/wallet.WalletService/updateKeyStatus"
+	WalletService_ImportKey_FullMethodName           = "/wallet.WalletService/importKey"
+	WalletService_BackupKeystore_FullMethodName      = "/wallet.WalletService/backupKeystore"
+	WalletService_SealStatus_FullMethodName          = "/wallet.WalletService/sealStatus"
+	WalletService_Unseal_FullMethodName              = "/wallet.WalletService/unseal"
+	WalletService_Seal_FullMethodName                = "/wallet.WalletService/seal"
+	WalletService_GrantKeyAccess_FullMethodName      = "/wallet.WalletService/grantKeyAccess"
+	WalletService_RevokeKeyAccess_FullMethodName     = "/wallet.WalletService/revokeKeyAccess"
+	WalletService_GetAllowance_FullMethodName        = "/wallet.WalletService/getAllowance"
+	WalletService_GetSignRequest_FullMethodName      = "/wallet.WalletService/getSignRequest"
+	WalletService_ListSignRequests_FullMethodName    = "/wallet.WalletService/listSignRequests"
+	WalletService_ApproveSignRequest_FullMethodName  = "/wallet.WalletService/approveSignRequest"
+	WalletService_RejectSignRequest_FullMethodName   = "/wallet.WalletService/rejectSignRequest"
 )
 
 // WalletServiceClient is the client API for WalletService service.
@@ -32,6 +52,26 @@ type WalletServiceClient interface {
 	GetSupportSignWay(ctx context.Context, in *SupportSignWayRequest, opts ...grpc.CallOption) (*SupportSignWayResponse, error)
 	ExportPublicKeyList(ctx context.Context, in *ExportPublicKeyRequest, opts ...grpc.CallOption) (*ExportPublicKeyResponse, error)
 	SignTxMessage(ctx context.Context, in *SignTxMessageRequest, opts ...grpc.CallOption) (*SignTxMessageResponse, error)
+	BatchSignTxMessage(ctx context.Context, in *BatchSignTxMessageRequest, opts ...grpc.CallOption) (*BatchSignTxMessageResponse, error)
+	SignTxMessageStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SignTxMessageStreamRequest, SignTxMessageStreamResponse], error)
+	VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error)
+	RecoverPublicKey(ctx context.Context, in *RecoverPublicKeyRequest, opts ...grpc.CallOption) (*RecoverPublicKeyResponse, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+	GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*GetKeyResponse, error)
+	HasKey(ctx context.Context, in *HasKeyRequest, opts ...grpc.CallOption) (*HasKeyResponse, error)
+	UpdateKeyStatus(ctx context.Context, in *UpdateKeyStatusRequest, opts ...grpc.CallOption) (*UpdateKeyStatusResponse, error)
+	ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*ImportKeyResponse, error)
+	BackupKeystore(ctx context.Context, in *BackupKeystoreRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BackupKeystoreResponse], error)
+	SealStatus(ctx context.Context, in *SealStatusRequest, opts ...grpc.CallOption) (*SealStatusResponse, error)
+	Unseal(ctx context.Context, in *UnsealRequest, opts ...grpc.CallOption) (*UnsealResponse, error)
+	Seal(ctx context.Context, in *SealRequest, opts ...grpc.CallOption) (*SealResponse, error)
+	GrantKeyAccess(ctx context.Context, in *KeyAccessRequest, opts ...grpc.CallOption) (*KeyAccessResponse, error)
+	RevokeKeyAccess(ctx context.Context, in *KeyAccessRequest, opts ...grpc.CallOption) (*KeyAccessResponse, error)
+	GetAllowance(ctx context.Context, in *GetAllowanceRequest, opts ...grpc.CallOption) (*GetAllowanceResponse, error)
+	GetSignRequest(ctx context.Context, in *GetSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error)
+	ListSignRequests(ctx context.Context, in *ListSignRequestsRequest, opts ...grpc.CallOption) (*ListSignRequestsResponse, error)
+	ApproveSignRequest(ctx context.Context, in *ApproveSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error)
+	RejectSignRequest(ctx context.Context, in *ApproveSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error)
 }
 
 type walletServiceClient struct {
@@ -72,6 +112,218 @@ func (c *walletServiceClient) SignTxMessage(ctx context.Context, in *SignTxMessa
 	return out, nil
 }
 
+func (c *walletServiceClient) BatchSignTxMessage(ctx context.Context, in *BatchSignTxMessageRequest, opts ...grpc.CallOption) (*BatchSignTxMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchSignTxMessageResponse)
+	err := c.cc.Invoke(ctx, WalletService_BatchSignTxMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) SignTxMessageStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SignTxMessageStreamRequest, SignTxMessageStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WalletService_ServiceDesc.Streams[0], WalletService_SignTxMessageStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SignTxMessageStreamRequest, SignTxMessageStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_SignTxMessageStreamClient = grpc.BidiStreamingClient[SignTxMessageStreamRequest, SignTxMessageStreamResponse]
+
+func (c *walletServiceClient) VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifySignatureResponse)
+	err := c.cc.Invoke(ctx, WalletService_VerifySignature_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) RecoverPublicKey(ctx context.Context, in *RecoverPublicKeyRequest, opts ...grpc.CallOption) (*RecoverPublicKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecoverPublicKeyResponse)
+	err := c.cc.Invoke(ctx, WalletService_RecoverPublicKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, WalletService_ListKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*GetKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetKeyResponse)
+	err := c.cc.Invoke(ctx, WalletService_GetKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) HasKey(ctx context.Context, in *HasKeyRequest, opts ...grpc.CallOption) (*HasKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HasKeyResponse)
+	err := c.cc.Invoke(ctx, WalletService_HasKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) UpdateKeyStatus(ctx context.Context, in *UpdateKeyStatusRequest, opts ...grpc.CallOption) (*UpdateKeyStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateKeyStatusResponse)
+	err := c.cc.Invoke(ctx, WalletService_UpdateKeyStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*ImportKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportKeyResponse)
+	err := c.cc.Invoke(ctx, WalletService_ImportKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) BackupKeystore(ctx context.Context, in *BackupKeystoreRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BackupKeystoreResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WalletService_ServiceDesc.Streams[1], WalletService_BackupKeystore_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BackupKeystoreRequest, BackupKeystoreResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_BackupKeystoreClient = grpc.ServerStreamingClient[BackupKeystoreResponse]
+
+func (c *walletServiceClient) SealStatus(ctx context.Context, in *SealStatusRequest, opts ...grpc.CallOption) (*SealStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SealStatusResponse)
+	err := c.cc.Invoke(ctx, WalletService_SealStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) Unseal(ctx context.Context, in *UnsealRequest, opts ...grpc.CallOption) (*UnsealResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnsealResponse)
+	err := c.cc.Invoke(ctx, WalletService_Unseal_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) Seal(ctx context.Context, in *SealRequest, opts ...grpc.CallOption) (*SealResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SealResponse)
+	err := c.cc.Invoke(ctx, WalletService_Seal_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GrantKeyAccess(ctx context.Context, in *KeyAccessRequest, opts ...grpc.CallOption) (*KeyAccessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyAccessResponse)
+	err := c.cc.Invoke(ctx, WalletService_GrantKeyAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) RevokeKeyAccess(ctx context.Context, in *KeyAccessRequest, opts ...grpc.CallOption) (*KeyAccessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyAccessResponse)
+	err := c.cc.Invoke(ctx, WalletService_RevokeKeyAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GetAllowance(ctx context.Context, in *GetAllowanceRequest, opts ...grpc.CallOption) (*GetAllowanceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAllowanceResponse)
+	err := c.cc.Invoke(ctx, WalletService_GetAllowance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GetSignRequest(ctx context.Context, in *GetSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignRequestResponse)
+	err := c.cc.Invoke(ctx, WalletService_GetSignRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ListSignRequests(ctx context.Context, in *ListSignRequestsRequest, opts ...grpc.CallOption) (*ListSignRequestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSignRequestsResponse)
+	err := c.cc.Invoke(ctx, WalletService_ListSignRequests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ApproveSignRequest(ctx context.Context, in *ApproveSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignRequestResponse)
+	err := c.cc.Invoke(ctx, WalletService_ApproveSignRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) RejectSignRequest(ctx context.Context, in *ApproveSignRequestRequest, opts ...grpc.CallOption) (*SignRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignRequestResponse)
+	err := c.cc.Invoke(ctx, WalletService_RejectSignRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WalletServiceServer is the server API for WalletService service.
 // All implementations should embed UnimplementedWalletServiceServer
 // for forward compatibility.
@@ -79,6 +331,26 @@ type WalletServiceServer interface {
 	GetSupportSignWay(context.Context, *SupportSignWayRequest) (*SupportSignWayResponse, error)
 	ExportPublicKeyList(context.Context, *ExportPublicKeyRequest) (*ExportPublicKeyResponse, error)
 	SignTxMessage(context.Context, *SignTxMessageRequest) (*SignTxMessageResponse, error)
+	BatchSignTxMessage(context.Context, *BatchSignTxMessageRequest) (*BatchSignTxMessageResponse, error)
+	SignTxMessageStream(grpc.BidiStreamingServer[SignTxMessageStreamRequest, SignTxMessageStreamResponse]) error
+	VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error)
+	RecoverPublicKey(context.Context, *RecoverPublicKeyRequest) (*RecoverPublicKeyResponse, error)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	GetKey(context.Context, *GetKeyRequest) (*GetKeyResponse, error)
+	HasKey(context.Context, *HasKeyRequest) (*HasKeyResponse, error)
+	UpdateKeyStatus(context.Context, *UpdateKeyStatusRequest) (*UpdateKeyStatusResponse, error)
+	ImportKey(context.Context, *ImportKeyRequest) (*ImportKeyResponse, error)
+	BackupKeystore(*BackupKeystoreRequest, grpc.ServerStreamingServer[BackupKeystoreResponse]) error
+	SealStatus(context.Context, *SealStatusRequest) (*SealStatusResponse, error)
+	Unseal(context.Context, *UnsealRequest) (*UnsealResponse, error)
+	Seal(context.Context, *SealRequest) (*SealResponse, error)
+	GrantKeyAccess(context.Context, *KeyAccessRequest) (*KeyAccessResponse, error)
+	RevokeKeyAccess(context.Context, *KeyAccessRequest) (*KeyAccessResponse, error)
+	GetAllowance(context.Context, *GetAllowanceRequest) (*GetAllowanceResponse, error)
+	GetSignRequest(context.Context, *GetSignRequestRequest) (*SignRequestResponse, error)
+	ListSignRequests(context.Context, *ListSignRequestsRequest) (*ListSignRequestsResponse, error)
+	ApproveSignRequest(context.Context, *ApproveSignRequestRequest) (*SignRequestResponse, error)
+	RejectSignRequest(context.Context, *ApproveSignRequestRequest) (*SignRequestResponse, error)
 }
 
 // UnimplementedWalletServiceServer should be embedded to have
@@ -97,6 +369,66 @@ func (UnimplementedWalletServiceServer) ExportPublicKeyList(context.Context, *Ex
 func (UnimplementedWalletServiceServer) SignTxMessage(context.Context, *SignTxMessageRequest) (*SignTxMessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SignTxMessage not implemented")
 }
+func (UnimplementedWalletServiceServer) BatchSignTxMessage(context.Context, *BatchSignTxMessageRequest) (*BatchSignTxMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSignTxMessage not implemented")
+}
+func (UnimplementedWalletServiceServer) SignTxMessageStream(grpc.BidiStreamingServer[SignTxMessageStreamRequest, SignTxMessageStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SignTxMessageStream not implemented")
+}
+func (UnimplementedWalletServiceServer) VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifySignature not implemented")
+}
+func (UnimplementedWalletServiceServer) RecoverPublicKey(context.Context, *RecoverPublicKeyRequest) (*RecoverPublicKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecoverPublicKey not implemented")
+}
+func (UnimplementedWalletServiceServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedWalletServiceServer) GetKey(context.Context, *GetKeyRequest) (*GetKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKey not implemented")
+}
+func (UnimplementedWalletServiceServer) HasKey(context.Context, *HasKeyRequest) (*HasKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HasKey not implemented")
+}
+func (UnimplementedWalletServiceServer) UpdateKeyStatus(context.Context, *UpdateKeyStatusRequest) (*UpdateKeyStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateKeyStatus not implemented")
+}
+func (UnimplementedWalletServiceServer) ImportKey(context.Context, *ImportKeyRequest) (*ImportKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportKey not implemented")
+}
+func (UnimplementedWalletServiceServer) BackupKeystore(*BackupKeystoreRequest, grpc.ServerStreamingServer[BackupKeystoreResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BackupKeystore not implemented")
+}
+func (UnimplementedWalletServiceServer) SealStatus(context.Context, *SealStatusRequest) (*SealStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SealStatus not implemented")
+}
+func (UnimplementedWalletServiceServer) Unseal(context.Context, *UnsealRequest) (*UnsealResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unseal not implemented")
+}
+func (UnimplementedWalletServiceServer) Seal(context.Context, *SealRequest) (*SealResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Seal not implemented")
+}
+func (UnimplementedWalletServiceServer) GrantKeyAccess(context.Context, *KeyAccessRequest) (*KeyAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GrantKeyAccess not implemented")
+}
+func (UnimplementedWalletServiceServer) RevokeKeyAccess(context.Context, *KeyAccessRequest) (*KeyAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeKeyAccess not implemented")
+}
+func (UnimplementedWalletServiceServer) GetAllowance(context.Context, *GetAllowanceRequest) (*GetAllowanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAllowance not implemented")
+}
+func (UnimplementedWalletServiceServer) GetSignRequest(context.Context, *GetSignRequestRequest) (*SignRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignRequest not implemented")
+}
+func (UnimplementedWalletServiceServer) ListSignRequests(context.Context, *ListSignRequestsRequest) (*ListSignRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSignRequests not implemented")
+}
+func (UnimplementedWalletServiceServer) ApproveSignRequest(context.Context, *ApproveSignRequestRequest) (*SignRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveSignRequest not implemented")
+}
+func (UnimplementedWalletServiceServer) RejectSignRequest(context.Context, *ApproveSignRequestRequest) (*SignRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectSignRequest not implemented")
+}
 func (UnimplementedWalletServiceServer) testEmbeddedByValue() {}
 
 // UnsafeWalletServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -171,6 +503,348 @@ func _WalletService_SignTxMessage_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletService_BatchSignTxMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSignTxMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).BatchSignTxMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_BatchSignTxMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).BatchSignTxMessage(ctx, req.(*BatchSignTxMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_SignTxMessageStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WalletServiceServer).SignTxMessageStream(&grpc.GenericServerStream[SignTxMessageStreamRequest, SignTxMessageStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_SignTxMessageStreamServer = grpc.BidiStreamingServer[SignTxMessageStreamRequest, SignTxMessageStreamResponse]
+
+func _WalletService_VerifySignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifySignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).VerifySignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_VerifySignature_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).VerifySignature(ctx, req.(*VerifySignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_RecoverPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecoverPublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).RecoverPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_RecoverPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).RecoverPublicKey(ctx, req.(*RecoverPublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GetKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_GetKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GetKey(ctx, req.(*GetKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_HasKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HasKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).HasKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_HasKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).HasKey(ctx, req.(*HasKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_UpdateKeyStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateKeyStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).UpdateKeyStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_UpdateKeyStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).UpdateKeyStatus(ctx, req.(*UpdateKeyStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ImportKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ImportKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ImportKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ImportKey(ctx, req.(*ImportKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_BackupKeystore_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupKeystoreRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletServiceServer).BackupKeystore(m, &grpc.GenericServerStream[BackupKeystoreRequest, BackupKeystoreResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_BackupKeystoreServer = grpc.ServerStreamingServer[BackupKeystoreResponse]
+
+func _WalletService_SealStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SealStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).SealStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_SealStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).SealStatus(ctx, req.(*SealStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_Unseal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsealRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).Unseal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_Unseal_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).Unseal(ctx, req.(*UnsealRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_Seal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SealRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).Seal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_Seal_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).Seal(ctx, req.(*SealRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GrantKeyAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GrantKeyAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_GrantKeyAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GrantKeyAccess(ctx, req.(*KeyAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_RevokeKeyAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).RevokeKeyAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_RevokeKeyAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).RevokeKeyAccess(ctx, req.(*KeyAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GetAllowance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllowanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GetAllowance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_GetAllowance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GetAllowance(ctx, req.(*GetAllowanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GetSignRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GetSignRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_GetSignRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GetSignRequest(ctx, req.(*GetSignRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ListSignRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSignRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ListSignRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ListSignRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ListSignRequests(ctx, req.(*ListSignRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ApproveSignRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApproveSignRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ApproveSignRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ApproveSignRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ApproveSignRequest(ctx, req.(*ApproveSignRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_RejectSignRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApproveSignRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).RejectSignRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_RejectSignRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).RejectSignRequest(ctx, req.(*ApproveSignRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // WalletService_ServiceDesc is the grpc.ServiceDesc for WalletService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -190,7 +864,91 @@ var WalletService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "signTxMessage",
 			Handler:    _WalletService_SignTxMessage_Handler,
 		},
+		{
+			MethodName: "batchSignTxMessage",
+			Handler:    _WalletService_BatchSignTxMessage_Handler,
+		},
+		{
+			MethodName: "verifySignature",
+			Handler:    _WalletService_VerifySignature_Handler,
+		},
+		{
+			MethodName: "recoverPublicKey",
+			Handler:    _WalletService_RecoverPublicKey_Handler,
+		},
+		{
+			MethodName: "listKeys",
+			Handler:    _WalletService_ListKeys_Handler,
+		},
+		{
+			MethodName: "getKey",
+			Handler:    _WalletService_GetKey_Handler,
+		},
+		{
+			MethodName: "hasKey",
+			Handler:    _WalletService_HasKey_Handler,
+		},
+		{
+			MethodName: "updateKeyStatus",
+			Handler:    _WalletService_UpdateKeyStatus_Handler,
+		},
+		{
+			MethodName: "importKey",
+			Handler:    _WalletService_ImportKey_Handler,
+		},
+		{
+			MethodName: "sealStatus",
+			Handler:    _WalletService_SealStatus_Handler,
+		},
+		{
+			MethodName: "unseal",
+			Handler:    _WalletService_Unseal_Handler,
+		},
+		{
+			MethodName: "seal",
+			Handler:    _WalletService_Seal_Handler,
+		},
+		{
+			MethodName: "grantKeyAccess",
+			Handler:    _WalletService_GrantKeyAccess_Handler,
+		},
+		{
+			MethodName: "revokeKeyAccess",
+			Handler:    _WalletService_RevokeKeyAccess_Handler,
+		},
+		{
+			MethodName: "getAllowance",
+			Handler:    _WalletService_GetAllowance_Handler,
+		},
+		{
+			MethodName: "getSignRequest",
+			Handler:    _WalletService_GetSignRequest_Handler,
+		},
+		{
+			MethodName: "listSignRequests",
+			Handler:    _WalletService_ListSignRequests_Handler,
+		},
+		{
+			MethodName: "approveSignRequest",
+			Handler:    _WalletService_ApproveSignRequest_Handler,
+		},
+		{
+			MethodName: "rejectSignRequest",
+			Handler:    _WalletService_RejectSignRequest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "signTxMessageStream",
+			Handler:       _WalletService_SignTxMessageStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "backupKeystore",
+			Handler:       _WalletService_BackupKeystore_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "wallet.proto",
 }
@@ -0,0 +1,93 @@
+package ethsigner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// Server serves the JSON-RPC signer over HTTP.
+type Server struct {
+	conn     *grpc.ClientConn
+	rpc      *rpc.Server
+	srv      *http.Server
+	listener net.Listener
+}
+
+// NewHandler returns the HTTP handler of the JSON-RPC API of signer.
+func NewHandler(signer *Signer) (http.Handler, *rpc.Server, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", signer); err != nil {
+		return nil, nil, err
+	}
+	if err := server.RegisterName("personal", &PersonalSigner{signer: signer}); err != nil {
+		return nil, nil, err
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// continues the trace of the caller in the WalletService calls
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		server.ServeHTTP(w, r.WithContext(WithConsumerToken(ctx, strings.TrimSpace(token))))
+	})
+	return handler, server, nil
+}
+
+// StartServer listens on host:port and serves the signer of the keys of the gRPC listener at
+// target until Stop is called. The connection to target is established lazily.
+func StartServer(target string, host string, port int) (*Server, error) {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	handler, rpcServer, err := NewHandler(NewSigner(wallet.NewWalletServiceClient(conn)))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	s := &Server{
+		conn:     conn,
+		rpc:      rpcServer,
+		srv:      &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("eth signer server stopped", "err", err)
+		}
+	}()
+	log.Info("start eth signer server", "addr", listener.Addr())
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop stops serving and closes the connection to the gRPC listener.
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	s.rpc.Stop()
+	return errors.Join(err, s.conn.Close())
+}
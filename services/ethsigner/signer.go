@@ -0,0 +1,354 @@
+// Package ethsigner serves the ECDSA keys of the key store as an Ethereum JSON-RPC remote signer,
+// so that geth/Clef-style clients and tools like Foundry can use the service as an external signer.
+//
+// It implements eth_accounts, eth_sign, eth_signTransaction, eth_signTypedData_v4 and personal_sign.
+// The accounts are the addresses of the active ECDSA keys the caller may use. The consumer token of
+// the caller is taken from the "Authorization: Bearer <token>" header, and every call is forwarded
+// to the gRPC listener of the service, so it goes through the same authentication, quotas, seal,
+// policy and audit as the gRPC calls.
+package ethsigner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// ListKeysPageSize is the page size of the ListKeys calls listing the accounts of a caller.
+const ListKeysPageSize = 1000
+
+// JSON-RPC error codes of the signer.
+const (
+	// ErrCodeUnauthorized is answered when the caller may not use the account, see EIP-1193
+	ErrCodeUnauthorized = 4100
+	// ErrCodeInvalidParams is answered for a transaction or typed data that can not be signed
+	ErrCodeInvalidParams = -32602
+	// ErrCodeServer is answered when the service refuses or fails to sign
+	ErrCodeServer = -32000
+)
+
+// Error is a JSON-RPC error reporting why the service did not sign.
+type Error struct {
+	Code    int
+	Message string
+	Data    *ErrorData
+}
+
+// ErrorData is the data of an Error.
+type ErrorData struct {
+	// ReturnCode is the ReturnCode of the WalletService call
+	ReturnCode string `json:"return_code,omitempty"`
+	// ApprovalRequestID is the id of the sign request held for approval
+	ApprovalRequestID string `json:"approval_request_id,omitempty"`
+	// GrpcCode is the gRPC status code of the WalletService call that failed
+	GrpcCode string `json:"grpc_code,omitempty"`
+}
+
+func (e *Error) Error() string  { return e.Message }
+func (e *Error) ErrorCode() int { return e.Code }
+
+func (e *Error) ErrorData() interface{} {
+	if e.Data == nil {
+		return nil
+	}
+	return e.Data
+}
+
+type tokenKey struct{}
+
+// WithConsumerToken returns a copy of ctx carrying the consumer token of the caller.
+func WithConsumerToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+func consumerToken(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// Signer signs with the keys of the WalletService the JSON-RPC calls are forwarded to.
+type Signer struct {
+	client wallet.WalletServiceClient
+	// pubkeys maps the addresses to the public keys they are derived from, whether the caller
+	// may use the key is checked by the WalletService on every signature
+	pubkeys sync.Map
+}
+
+func NewSigner(client wallet.WalletServiceClient) *Signer {
+	return &Signer{client: client}
+}
+
+// Accounts returns the addresses of the active ECDSA keys the caller may use.
+func (s *Signer) Accounts(ctx context.Context) ([]common.Address, error) {
+	addresses := make([]common.Address, 0)
+	err := s.listKeys(ctx, func(address common.Address) bool {
+		addresses = append(addresses, address)
+		return true
+	})
+	return addresses, err
+}
+
+// listKeys calls fn with the address of every active ECDSA key the caller may use until fn returns false.
+func (s *Signer) listKeys(ctx context.Context, fn func(address common.Address) bool) error {
+	pageToken := ""
+	for {
+		resp, err := s.client.ListKeys(ctx, &wallet.ListKeysRequest{
+			ConsumerToken: consumerToken(ctx),
+			Type:          string(protobuf.ECDSA),
+			Status:        leveldb.KeyStatusActive,
+			PageSize:      ListKeysPageSize,
+			PageToken:     pageToken,
+		})
+		if err != nil {
+			return grpcError(err)
+		}
+		if resp.Code != wallet.ReturnCode_SUCCESS {
+			return returnCodeError(resp.Code, resp.Msg, "")
+		}
+		for _, key := range resp.Keys {
+			pubkey, err := crypto.UnmarshalPubkey(common.FromHex(key.PublicKey.GetPubkey()))
+			if err != nil {
+				continue
+			}
+			address := crypto.PubkeyToAddress(*pubkey)
+			s.pubkeys.Store(address, key.PublicKey.GetPubkey())
+			if !fn(address) {
+				return nil
+			}
+		}
+		if resp.NextPageToken == "" {
+			return nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// pubkey returns the public key of the account address.
+func (s *Signer) pubkey(ctx context.Context, address common.Address) (string, error) {
+	if pubkey, isOk := s.pubkeys.Load(address); isOk {
+		return pubkey.(string), nil
+	}
+	found := false
+	err := s.listKeys(ctx, func(listed common.Address) bool {
+		found = listed == address
+		return !found
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", &Error{Code: ErrCodeUnauthorized, Message: fmt.Sprintf("unknown account %s", address.Hex())}
+	}
+	pubkey, _ := s.pubkeys.Load(address)
+	return pubkey.(string), nil
+}
+
+// Sign signs data prefixed with "\x19Ethereum Signed Message:\n" and its length, see EIP-191,
+// as eth_sign does.
+func (s *Signer) Sign(ctx context.Context, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	return s.signHash(ctx, address, accounts.TextHash(data))
+}
+
+// SignTypedData_v4 signs the EIP-712 typed data as eth_signTypedData_v4 does.
+func (s *Signer) SignTypedData_v4(ctx context.Context, address common.Address, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: fmt.Sprintf("invalid typed data: %v", err)}
+	}
+	return s.signHash(ctx, address, hash)
+}
+
+// signHash signs hash with the key of address and returns the signature with V 27 or 28.
+func (s *Signer) signHash(ctx context.Context, address common.Address, hash []byte) (hexutil.Bytes, error) {
+	resp, err := s.signTxMessage(ctx, address, &wallet.SignTxMessageRequest{MessageHash: hexutil.Encode(hash)})
+	if err != nil {
+		return nil, err
+	}
+	signature := common.FromHex(resp.Signature)
+	if len(signature) != crypto.SignatureLength {
+		return nil, &Error{Code: ErrCodeServer, Message: "invalid signature length"}
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// SignTransactionResult is the result of eth_signTransaction.
+type SignTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// SignTransaction signs the transaction of args as eth_signTransaction does. The signer does not
+// talk to a node, the nonce, the gas, the fees and the chain id must be set.
+func (s *Signer) SignTransaction(ctx context.Context, args TransactionArgs) (*SignTransactionResult, error) {
+	if args.From == nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "from is required"}
+	}
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: err.Error()}
+	}
+	unsignedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: err.Error()}
+	}
+	resp, err := s.signTxMessage(ctx, *args.From, &wallet.SignTxMessageRequest{
+		UnsignedTx: hexutil.Encode(unsignedTx),
+		ChainId:    args.ChainID.ToInt().Uint64(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw := common.FromHex(resp.SignedTx)
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, &Error{Code: ErrCodeServer, Message: fmt.Sprintf("decode signed tx: %v", err)}
+	}
+	return &SignTransactionResult{Raw: raw, Tx: signed}, nil
+}
+
+// signTxMessage signs req with the key of address on behalf of the caller.
+func (s *Signer) signTxMessage(ctx context.Context, address common.Address, req *wallet.SignTxMessageRequest) (*wallet.SignTxMessageResponse, error) {
+	pubkey, err := s.pubkey(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	req.ConsumerToken = consumerToken(ctx)
+	req.Type = string(protobuf.ECDSA)
+	req.PublicKey = pubkey
+	resp, err := s.client.SignTxMessage(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if resp.Code != wallet.ReturnCode_SUCCESS {
+		return nil, returnCodeError(resp.Code, resp.Msg, resp.ApprovalRequestId)
+	}
+	return resp, nil
+}
+
+// PersonalSigner serves personal_sign, which takes its parameters in the reverse order of eth_sign.
+type PersonalSigner struct {
+	signer *Signer
+}
+
+// Sign signs data as eth_sign does, the password is ignored as the keys are not locked by passwords.
+func (p *PersonalSigner) Sign(ctx context.Context, data hexutil.Bytes, address common.Address, _ *string) (hexutil.Bytes, error) {
+	return p.signer.Sign(ctx, address, data)
+}
+
+func returnCodeError(code wallet.ReturnCode, msg string, approvalRequestID string) error {
+	errCode := ErrCodeServer
+	if code == wallet.ReturnCode_PERMISSION_DENIED {
+		errCode = ErrCodeUnauthorized
+	}
+	return &Error{Code: errCode, Message: msg, Data: &ErrorData{ReturnCode: code.String(), ApprovalRequestID: approvalRequestID}}
+}
+
+func grpcError(err error) error {
+	st := status.Convert(err)
+	return &Error{Code: ErrCodeServer, Message: st.Message(), Data: &ErrorData{GrpcCode: st.Code().String()}}
+}
+
+// TransactionArgs are the arguments of eth_signTransaction.
+type TransactionArgs struct {
+	From                 *common.Address   `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Gas                  *hexutil.Uint64   `json:"gas"`
+	GasPrice             *hexutil.Big      `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big      `json:"value"`
+	Nonce                *hexutil.Uint64   `json:"nonce"`
+	Data                 *hexutil.Bytes    `json:"data"`
+	Input                *hexutil.Bytes    `json:"input"`
+	AccessList           *types.AccessList `json:"accessList"`
+	ChainID              *hexutil.Big      `json:"chainId"`
+}
+
+// ToTransaction returns the unsigned transaction of args: a dynamic fee transaction when
+// maxFeePerGas is set, an access list transaction when gasPrice and accessList are set, a
+// legacy transaction otherwise.
+func (args *TransactionArgs) ToTransaction() (*types.Transaction, error) {
+	switch {
+	case args.Nonce == nil:
+		return nil, fmt.Errorf("nonce is required")
+	case args.Gas == nil:
+		return nil, fmt.Errorf("gas is required")
+	case args.ChainID == nil || args.ChainID.ToInt().Sign() <= 0:
+		return nil, fmt.Errorf("chainId is required")
+	case args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil):
+		return nil, fmt.Errorf("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	case args.GasPrice == nil && args.MaxFeePerGas == nil:
+		return nil, fmt.Errorf("gasPrice or maxFeePerGas is required")
+	case args.Data != nil && args.Input != nil && !strings.EqualFold(args.Data.String(), args.Input.String()):
+		return nil, fmt.Errorf("both data and input specified with different values")
+	}
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	chainID := args.ChainID.ToInt()
+
+	if args.MaxFeePerGas != nil {
+		tip := new(big.Int)
+		if args.MaxPriorityFeePerGas != nil {
+			tip = args.MaxPriorityFeePerGas.ToInt()
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      uint64(*args.Nonce),
+			GasTipCap:  tip,
+			GasFeeCap:  args.MaxFeePerGas.ToInt(),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+	if args.AccessList != nil {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      uint64(*args.Nonce),
+			GasPrice:   args.GasPrice.ToInt(),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+	// the chain id of a legacy transaction is passed to the service along the transaction
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    uint64(*args.Nonce),
+		GasPrice: args.GasPrice.ToInt(),
+		Gas:      uint64(*args.Gas),
+		To:       args.To,
+		Value:    value,
+		Data:     data,
+	}), nil
+}
@@ -0,0 +1,180 @@
+package ethsigner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// fakeWalletClient holds a single key usable by the consumer token "token".
+type fakeWalletClient struct {
+	wallet.WalletServiceClient
+	key *ecdsa.PrivateKey
+}
+
+func (f *fakeWalletClient) pubkey() string {
+	return hex.EncodeToString(crypto.FromECDSAPub(&f.key.PublicKey))
+}
+
+func (f *fakeWalletClient) ListKeys(_ context.Context, in *wallet.ListKeysRequest, _ ...grpc.CallOption) (*wallet.ListKeysResponse, error) {
+	if in.ConsumerToken != "token" {
+		return &wallet.ListKeysResponse{Code: wallet.ReturnCode_PERMISSION_DENIED, Msg: "unknown consumer token"}, nil
+	}
+	return &wallet.ListKeysResponse{Code: wallet.ReturnCode_SUCCESS, Keys: []*wallet.KeyInfo{{PublicKey: &wallet.PublicKey{Pubkey: f.pubkey()}}}}, nil
+}
+
+func (f *fakeWalletClient) SignTxMessage(_ context.Context, in *wallet.SignTxMessageRequest, _ ...grpc.CallOption) (*wallet.SignTxMessageResponse, error) {
+	if in.ConsumerToken != "token" || in.PublicKey != f.pubkey() {
+		return &wallet.SignTxMessageResponse{Code: wallet.ReturnCode_PERMISSION_DENIED, Msg: "key is not accessible by the consumer"}, nil
+	}
+	hash := common.FromHex(in.MessageHash)
+	var tx *policy.EVMTransaction
+	if in.UnsignedTx != "" {
+		var err error
+		if tx, err = policy.ParseEVMTransaction(in.UnsignedTx, in.ChainId); err != nil {
+			return nil, err
+		}
+		hash = tx.SigningHash()
+	}
+	signature, err := crypto.Sign(hash, f.key)
+	if err != nil {
+		return nil, err
+	}
+	resp := &wallet.SignTxMessageResponse{Code: wallet.ReturnCode_SUCCESS, Signature: hex.EncodeToString(signature)}
+	if tx != nil {
+		if resp.SignedTx, err = tx.Sign(signature); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func newTestClient(t *testing.T, token string) (*rpc.Client, common.Address) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	handler, rpcServer, err := NewHandler(NewSigner(&fakeWalletClient{key: key}))
+	assert.NoError(t, err)
+	t.Cleanup(rpcServer.Stop)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client, err := rpc.Dial(srv.URL)
+	assert.NoError(t, err)
+	t.Cleanup(client.Close)
+	client.SetHeader("Authorization", "Bearer "+token)
+	return client, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func recoverAddress(t *testing.T, hash []byte, signature hexutil.Bytes) common.Address {
+	assert.Len(t, signature, crypto.SignatureLength)
+	assert.Contains(t, []byte{27, 28}, signature[crypto.RecoveryIDOffset])
+	sig := common.CopyBytes(signature)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pubkey, err := crypto.SigToPub(hash, sig)
+	assert.NoError(t, err)
+	return crypto.PubkeyToAddress(*pubkey)
+}
+
+func TestSignerMessages(t *testing.T) {
+	client, address := newTestClient(t, "token")
+	ctx := context.Background()
+
+	var addresses []common.Address
+	assert.NoError(t, client.CallContext(ctx, &addresses, "eth_accounts"))
+	assert.Equal(t, []common.Address{address}, addresses)
+
+	message := hexutil.Bytes("hello")
+	var signature hexutil.Bytes
+	assert.NoError(t, client.CallContext(ctx, &signature, "eth_sign", address, message))
+	assert.Equal(t, address, recoverAddress(t, accounts.TextHash(message), signature))
+
+	var personal hexutil.Bytes
+	assert.NoError(t, client.CallContext(ctx, &personal, "personal_sign", message, address, ""))
+	assert.Equal(t, signature, personal)
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "chainId", Type: "uint256"}},
+			"Mail":         {{Name: "to", Type: "address"}, {Name: "contents", Type: "string"}},
+		},
+		PrimaryType: "Mail",
+		Domain:      apitypes.TypedDataDomain{Name: "Test", ChainId: (*math.HexOrDecimal256)(big.NewInt(1))},
+		Message:     apitypes.TypedDataMessage{"to": address.Hex(), "contents": "hi"},
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	assert.NoError(t, err)
+	assert.NoError(t, client.CallContext(ctx, &signature, "eth_signTypedData_v4", address, typedData))
+	assert.Equal(t, address, recoverAddress(t, hash, signature))
+
+	err = client.CallContext(ctx, &signature, "eth_sign", common.Address{1}, message)
+	var rpcErr rpc.Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, ErrCodeUnauthorized, rpcErr.ErrorCode())
+}
+
+func TestSignerTransaction(t *testing.T) {
+	client, address := newTestClient(t, "token")
+	to := common.Address{2}
+	nonce, gas := hexutil.Uint64(3), hexutil.Uint64(21000)
+	args := TransactionArgs{
+		From:                 &address,
+		To:                   &to,
+		Gas:                  &gas,
+		MaxFeePerGas:         (*hexutil.Big)(big.NewInt(2e9)),
+		MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(1e9)),
+		Value:                (*hexutil.Big)(big.NewInt(1e18)),
+		Nonce:                &nonce,
+		ChainID:              (*hexutil.Big)(big.NewInt(11155111)),
+	}
+	var result SignTransactionResult
+	assert.NoError(t, client.CallContext(context.Background(), &result, "eth_signTransaction", args))
+	tx := new(types.Transaction)
+	assert.NoError(t, tx.UnmarshalBinary(result.Raw))
+	assert.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, address, sender)
+	assert.Equal(t, tx.Hash(), result.Tx.Hash())
+
+	// a legacy transaction carries its chain id in the signature
+	args.MaxFeePerGas, args.MaxPriorityFeePerGas, args.GasPrice = nil, nil, (*hexutil.Big)(big.NewInt(1e9))
+	assert.NoError(t, client.CallContext(context.Background(), &result, "eth_signTransaction", args))
+	assert.NoError(t, tx.UnmarshalBinary(result.Raw))
+	assert.Equal(t, big.NewInt(11155111), tx.ChainId())
+	sender, err = types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, address, sender)
+
+	args.Nonce = nil
+	err = client.CallContext(context.Background(), &result, "eth_signTransaction", args)
+	var rpcErr rpc.Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, ErrCodeInvalidParams, rpcErr.ErrorCode())
+}
+
+func TestSignerUnknownConsumer(t *testing.T) {
+	client, _ := newTestClient(t, "other")
+	var addresses []common.Address
+	err := client.CallContext(context.Background(), &addresses, "eth_accounts")
+	var rpcErr rpc.Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, ErrCodeUnauthorized, rpcErr.ErrorCode())
+}
@@ -0,0 +1,349 @@
+// Package rest serves the WalletService over HTTP/JSON for the callers that do not speak gRPC.
+//
+// Every method of the WalletService is served at POST /v1/{method}, {method} being the name of the
+// method in wallet.proto, with the request message as the JSON body and the response message as the
+// JSON response. The calls are forwarded to the gRPC listener of the service, so they go through the
+// same authentication, quotas, seal, policy and audit as the gRPC calls.
+//
+// A stream of responses is written as newline delimited JSON, one message per line; a method taking
+// a stream of requests reads them the same way from the request body. An error is answered with the
+// HTTP status matching its gRPC code and a google.rpc.Status body, an error ending a stream that
+// already returned messages is written as a last line {"error": Status}.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+const (
+	// PathPrefix is the path prefix of the WalletService methods.
+	PathPrefix = "/v1/"
+	// OpenAPIPath is the path of the OpenAPI document of the gateway.
+	OpenAPIPath = "/openapi.json"
+	// MaxRequestBodySize is the maximum size of the body of a unary call.
+	MaxRequestBodySize = 64 << 20
+	// MaxMessageSize is the maximum size of a message exchanged with the gRPC listener.
+	MaxMessageSize = 1024 * 1024 * 30000
+
+	jsonContentType   = "application/json"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	unmarshalOptions = protojson.UnmarshalOptions{}
+)
+
+type method struct {
+	desc       protoreflect.MethodDescriptor
+	fullMethod string
+	input      protoreflect.MessageType
+	output     protoreflect.MessageType
+}
+
+// Gateway forwards the HTTP/JSON calls of the WalletService to its gRPC listener.
+type Gateway struct {
+	conn    *grpc.ClientConn
+	methods map[string]*method
+	openAPI []byte
+	mux     *http.ServeMux
+}
+
+// NewGateway creates a gateway forwarding to the gRPC listener at target. The connection is
+// established lazily, the listener does not have to be up yet.
+func NewGateway(target string) (*Gateway, error) {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// continues the trace of the HTTP caller in the gRPC call
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(MaxMessageSize), grpc.MaxCallSendMsgSize(MaxMessageSize)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return newGateway(conn)
+}
+
+func newGateway(conn *grpc.ClientConn) (*Gateway, error) {
+	service, err := walletService()
+	if err != nil {
+		return nil, err
+	}
+	openAPI, err := json.MarshalIndent(OpenAPI(service), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	g := &Gateway{
+		conn:    conn,
+		methods: make(map[string]*method),
+		openAPI: openAPI,
+		mux:     http.NewServeMux(),
+	}
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		desc := methods.Get(i)
+		input, err := protoregistry.GlobalTypes.FindMessageByName(desc.Input().FullName())
+		if err != nil {
+			return nil, fmt.Errorf("find input of %s: %w", desc.FullName(), err)
+		}
+		output, err := protoregistry.GlobalTypes.FindMessageByName(desc.Output().FullName())
+		if err != nil {
+			return nil, fmt.Errorf("find output of %s: %w", desc.FullName(), err)
+		}
+		g.methods[string(desc.Name())] = &method{
+			desc:       desc,
+			fullMethod: fmt.Sprintf("/%s/%s", service.FullName(), desc.Name()),
+			input:      input,
+			output:     output,
+		}
+	}
+	g.mux.HandleFunc("POST "+PathPrefix+"{method}", g.serveMethod)
+	g.mux.HandleFunc("GET "+OpenAPIPath, g.serveOpenAPI)
+	return g, nil
+}
+
+// walletService returns the descriptor of the WalletService.
+func walletService() (protoreflect.ServiceDescriptor, error) {
+	name := protoreflect.FullName(wallet.WalletService_ServiceDesc.ServiceName)
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	service, isOk := desc.(protoreflect.ServiceDescriptor)
+	if !isOk {
+		return nil, fmt.Errorf("%s is not a service", name)
+	}
+	return service, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// Close closes the connection to the gRPC listener.
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	_, _ = w.Write(g.openAPI)
+}
+
+func (g *Gateway) serveMethod(w http.ResponseWriter, r *http.Request) {
+	m, isOk := g.methods[r.PathValue("method")]
+	if !isOk {
+		writeError(w, status.Errorf(codes.NotFound, "unknown method %s", r.PathValue("method")))
+		return
+	}
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	if m.desc.IsStreamingClient() || m.desc.IsStreamingServer() {
+		g.serveStream(ctx, w, r, m)
+		return
+	}
+	req, err := readRequest(http.MaxBytesReader(w, r.Body, MaxRequestBodySize), m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := m.output.New().Interface()
+	if err := g.conn.Invoke(ctx, m.fullMethod, req, resp); err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := marshalOptions.Marshal(resp)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "marshal response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	_, _ = w.Write(body)
+}
+
+// serveStream forwards a streaming call, the requests of a client stream are read from the body
+// while the responses are written.
+func (g *Gateway) serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request, m *method) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := g.conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(m.desc.Name()),
+		ServerStreams: m.desc.IsStreamingServer(),
+		ClientStreams: m.desc.IsStreamingClient(),
+	}, m.fullMethod)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	sendErr := make(chan error, 1)
+	if m.desc.IsStreamingClient() {
+		// the responses are written before the body is read to the end
+		if err := controller.EnableFullDuplex(); err != nil {
+			log.Debug("enable full duplex fail", "err", err)
+		}
+		go func() {
+			if err := sendRequests(stream, r.Body, m); err != nil {
+				sendErr <- err
+				cancel()
+			}
+		}()
+	} else {
+		req, err := readRequest(http.MaxBytesReader(w, r.Body, MaxRequestBodySize), m)
+		if err == nil {
+			err = stream.SendMsg(req)
+		}
+		if err == nil {
+			err = stream.CloseSend()
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, err)
+			return
+		}
+	}
+
+	started := false
+	for {
+		resp := m.output.New().Interface()
+		err := stream.RecvMsg(resp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// a bad request line cancels the stream, it is reported instead of the cancellation
+			select {
+			case err = <-sendErr:
+			default:
+			}
+			if !started {
+				writeError(w, err)
+				return
+			}
+			line, _ := json.Marshal(map[string]json.RawMessage{"error": statusBody(err)})
+			_, _ = w.Write(append(line, '\n'))
+			return
+		}
+		line, err := marshalOptions.Marshal(resp)
+		if err != nil {
+			log.Error("marshal stream response fail", "method", m.fullMethod, "err", err)
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		_, _ = w.Write(append(line, '\n'))
+		_ = controller.Flush()
+	}
+	if !started {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// sendRequests sends the requests read from body, one JSON message after the other, and closes the
+// sending side of stream once body ends.
+func sendRequests(stream grpc.ClientStream, body io.Reader, m *method) error {
+	decoder := json.NewDecoder(body)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return stream.CloseSend()
+		} else if err != nil {
+			return status.Errorf(codes.InvalidArgument, "read request: %v", err)
+		}
+		req := m.input.New().Interface()
+		if err := unmarshalOptions.Unmarshal(raw, req); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+		}
+		if err := stream.SendMsg(req); err != nil {
+			// the error of the stream is returned by RecvMsg
+			return nil
+		}
+	}
+}
+
+// readRequest reads the request message of m from body, an empty body is an empty request.
+func readRequest(body io.Reader, m *method) (proto.Message, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "read request: %v", err)
+	}
+	req := m.input.New().Interface()
+	if strings.TrimSpace(string(data)) == "" {
+		return req, nil
+	}
+	if err := unmarshalOptions.Unmarshal(data, req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	return req, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(HTTPStatus(status.Code(err)))
+	_, _ = w.Write(statusBody(err))
+}
+
+// statusBody returns the google.rpc.Status of err as JSON.
+func statusBody(err error) []byte {
+	body, marshalErr := protojson.Marshal(status.Convert(err).Proto())
+	if marshalErr != nil {
+		return []byte(`{"code":13,"message":"marshal status"}`)
+	}
+	return body
+}
+
+// HTTPStatus returns the HTTP status answered for a gRPC code.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
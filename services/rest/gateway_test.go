@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+type fakeWalletService struct {
+	wallet.UnimplementedWalletServiceServer
+}
+
+func (fakeWalletService) GetSupportSignWay(_ context.Context, in *wallet.SupportSignWayRequest) (*wallet.SupportSignWayResponse, error) {
+	if in.ConsumerToken != "token" {
+		return nil, status.Error(codes.PermissionDenied, "unknown consumer")
+	}
+	return &wallet.SupportSignWayResponse{Code: wallet.ReturnCode_SUCCESS, Support: in.Type == "ecdsa"}, nil
+}
+
+func (fakeWalletService) BackupKeystore(_ *wallet.BackupKeystoreRequest, stream wallet.WalletService_BackupKeystoreServer) error {
+	for _, chunk := range []string{"ab", "cd"} {
+		if err := stream.Send(&wallet.BackupKeystoreResponse{Code: wallet.ReturnCode_SUCCESS, Chunk: []byte(chunk)}); err != nil {
+			return err
+		}
+	}
+	return status.Error(codes.DataLoss, "backup interrupted")
+}
+
+func (fakeWalletService) SignTxMessageStream(stream wallet.WalletService_SignTxMessageStreamServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&wallet.SignTxMessageStreamResponse{RequestId: in.RequestId, Signature: "sig-" + in.MessageHash}); err != nil {
+			return err
+		}
+	}
+}
+
+func newTestGateway(t *testing.T) *httptest.Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	gs := grpc.NewServer()
+	wallet.RegisterWalletServiceServer(gs, fakeWalletService{})
+	go func() { _ = gs.Serve(listener) }()
+	t.Cleanup(gs.Stop)
+
+	g, err := NewGateway(listener.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = g.Close() })
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, srv *httptest.Server, method, body string) (int, string) {
+	resp, err := http.Post(srv.URL+PathPrefix+method, jsonContentType, strings.NewReader(body))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestGatewayUnary(t *testing.T) {
+	srv := newTestGateway(t)
+
+	code, body := post(t, srv, "getSupportSignWay", `{"consumer_token": "token", "type": "ecdsa"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"Code": "SUCCESS", "msg": "", "support": true}`, body)
+
+	code, body = post(t, srv, "getSupportSignWay", `{"consumer_token": "other"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.JSONEq(t, `{"code": 7, "message": "unknown consumer"}`, body)
+
+	code, _ = post(t, srv, "getSupportSignWay", `{"unknown_field": 1}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = post(t, srv, "noSuchMethod", `{}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = post(t, srv, "verifySignature", `{}`)
+	assert.Equal(t, http.StatusNotImplemented, code)
+}
+
+func TestGatewayStreams(t *testing.T) {
+	srv := newTestGateway(t)
+
+	code, body := post(t, srv, "backupKeystore", `{"consumer_token": "token"}`)
+	assert.Equal(t, http.StatusOK, code)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	assert.Len(t, lines, 3)
+	assert.JSONEq(t, `{"Code": "SUCCESS", "msg": "", "chunk": "YWI="}`, lines[0])
+	assert.JSONEq(t, `{"error": {"code": 15, "message": "backup interrupted"}}`, lines[2])
+
+	resp, err := http.Post(srv.URL+PathPrefix+"signTxMessageStream", ndjsonContentType, strings.NewReader(
+		`{"request_id": "1", "message_hash": "aa"}`+"\n"+`{"request_id": "2", "message_hash": "bb"}`+"\n"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, ndjsonContentType, resp.Header.Get("Content-Type"))
+	var signatures []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line struct {
+			RequestID string `json:"request_id"`
+			Signature string `json:"signature"`
+		}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		signatures = append(signatures, line.RequestID+":"+line.Signature)
+	}
+	assert.Equal(t, []string{"1:sig-aa", "2:sig-bb"}, signatures)
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv := newTestGateway(t)
+	resp, err := http.Get(srv.URL + OpenAPIPath)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var doc Document
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+	assert.Len(t, doc.Paths, len(wallet.WalletService_ServiceDesc.Methods)+len(wallet.WalletService_ServiceDesc.Streams))
+	sign := doc.Paths[PathPrefix+"signTxMessage"].Post
+	assert.Equal(t, schemaRef+"wallet.SignTxMessageRequest", sign.RequestBody.Content[jsonContentType].Schema.Ref)
+	assert.Equal(t, "uint64", doc.Components.Schemas["wallet.SignTxMessageRequest"].Properties["chain_id"].Format)
+	assert.Contains(t, doc.Paths[PathPrefix+"backupKeystore"].Post.Responses["200"].Content, ndjsonContentType)
+}
@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the document of the gateway follows.
+const OpenAPIVersion = "3.0.3"
+
+const (
+	schemaRef       = "#/components/schemas/"
+	statusSchemaRef = schemaRef + "google.rpc.Status"
+)
+
+// Schema is an OpenAPI schema object, only the fields used by the gateway are declared.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Tags        []string             `json:"tags,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type PathItem struct {
+	Post *Operation `json:"post,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Document is the OpenAPI document of the gateway.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// OpenAPI describes the HTTP/JSON methods of service, derived from its descriptor so that it
+// follows wallet.proto.
+func OpenAPI(service protoreflect.ServiceDescriptor) *Document {
+	doc := &Document{
+		OpenAPI: OpenAPIVersion,
+		Info: Info{
+			Title:       string(service.FullName()),
+			Description: "HTTP/JSON gateway of the " + string(service.Name()) + ", the JSON names of the fields are the names in wallet.proto",
+			Version:     "v1",
+		},
+		Paths: make(map[string]*PathItem),
+		Components: Components{Schemas: map[string]*Schema{
+			"google.rpc.Status": {
+				Type:        "object",
+				Description: "The error of a call, code is its gRPC status code",
+				Properties: map[string]*Schema{
+					"code":    {Type: "integer", Format: "int32"},
+					"message": {Type: "string"},
+					"details": {Type: "array", Items: &Schema{Type: "object"}},
+				},
+			},
+		}},
+	}
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		addMessageSchemas(doc.Components.Schemas, method.Input())
+		addMessageSchemas(doc.Components.Schemas, method.Output())
+
+		requestType, requestDescription := jsonContentType, ""
+		if method.IsStreamingClient() {
+			requestType, requestDescription = ndjsonContentType, "A stream of requests, one JSON message per line"
+		}
+		responseType, responseDescription := jsonContentType, "The response"
+		if method.IsStreamingServer() {
+			responseType = ndjsonContentType
+			responseDescription = `A stream of responses, one JSON message per line, an error ending the stream is written as a last line {"error": google.rpc.Status}`
+		}
+		doc.Paths[PathPrefix+string(method.Name())] = &PathItem{Post: &Operation{
+			OperationID: string(method.Name()),
+			Tags:        []string{string(service.Name())},
+			RequestBody: &RequestBody{
+				Description: requestDescription,
+				Required:    true,
+				Content:     map[string]MediaType{requestType: {Schema: &Schema{Ref: schemaRef + string(method.Input().FullName())}}},
+			},
+			Responses: map[string]*Response{
+				"200": {
+					Description: responseDescription,
+					Content:     map[string]MediaType{responseType: {Schema: &Schema{Ref: schemaRef + string(method.Output().FullName())}}},
+				},
+				"default": {
+					Description: "The error of the call, the HTTP status matches its gRPC status code",
+					Content:     map[string]MediaType{jsonContentType: {Schema: &Schema{Ref: statusSchemaRef}}},
+				},
+			},
+		}}
+	}
+	return doc
+}
+
+// addMessageSchemas adds the schema of message and of every message it refers to.
+func addMessageSchemas(schemas map[string]*Schema, message protoreflect.MessageDescriptor) {
+	name := string(message.FullName())
+	if _, isOk := schemas[name]; isOk {
+		return
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	schemas[name] = schema
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		schema.Properties[string(field.Name())] = fieldSchema(schemas, field)
+	}
+}
+
+func fieldSchema(schemas map[string]*Schema, field protoreflect.FieldDescriptor) *Schema {
+	if field.IsMap() {
+		return &Schema{Type: "object", AdditionalProperties: valueSchema(schemas, field.MapValue())}
+	}
+	if field.IsList() {
+		return &Schema{Type: "array", Items: valueSchema(schemas, field)}
+	}
+	return valueSchema(schemas, field)
+}
+
+// valueSchema returns the schema of a single value of field, encoded the way protojson does.
+func valueSchema(schemas map[string]*Schema, field protoreflect.FieldDescriptor) *Schema {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson encodes the 64-bit integers as strings
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		enum := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			enum = append(enum, string(values.Get(i).Name()))
+		}
+		return &Schema{Type: "string", Enum: enum, Description: string(field.Enum().FullName())}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		addMessageSchemas(schemas, field.Message())
+		return &Schema{Ref: schemaRef + string(field.Message().FullName())}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Server serves a Gateway over HTTP.
+type Server struct {
+	gateway  *Gateway
+	srv      *http.Server
+	listener net.Listener
+}
+
+// StartServer listens on host:port and serves g until Stop is called.
+func StartServer(g *Gateway, host string, port int) (*Server, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		gateway:  g,
+		srv:      &http.Server{Handler: g, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("rest server stopped", "err", err)
+		}
+	}()
+	log.Info("start rest server", "addr", listener.Addr())
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop stops serving and closes the connection of the gateway to the gRPC listener.
+func (s *Server) Stop(ctx context.Context) error {
+	return errors.Join(s.srv.Shutdown(ctx), s.gateway.Close())
+}
@@ -0,0 +1,396 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/tracing"
+)
+
+// signRequestDigestDomain separates the digests signed by the approvers from any other message they sign.
+const signRequestDigestDomain = "web3-wallet-sign sign request\x00"
+
+// holdSignRequest stores the request of in as a sign request waiting for approvals and returns its id.
+func (s *RpcServer) holdSignRequest(ctx context.Context, c *caller, cryptoType protobuf.CryptoType, record *leveldb.KeyRecord, messageHash string, tx policy.Transaction, in *signInput, approvals []*policy.ApprovalRequirement) (string, error) {
+	now := time.Now()
+	ttl := approvals[0].TTL
+	request := &leveldb.SignRequest{
+		ID:           uuid.NewString(),
+		Consumer:     c.id,
+		Admin:        c.admin,
+		Pubkey:       record.Pubkey,
+		Type:         string(cryptoType),
+		MessageHash:  messageHash,
+		UnsignedTx:   in.unsignedTx,
+		ChainID:      in.chainID,
+		UnsignedPSBT: in.unsignedPSBT,
+		InputIndex:   in.inputIndex,
+		Network:      in.network,
+		Status:       leveldb.SignRequestPending,
+		CreatedAt:    now.Unix(),
+		UpdatedAt:    now.Unix(),
+	}
+	if tx != nil {
+		intent := tx.Intent()
+		request.Chain = intent.Chain
+		request.Token = intent.Token
+		request.Amount = intent.Amount.String()
+	}
+	for _, approval := range approvals {
+		ttl = min(ttl, approval.TTL)
+		request.Requirements = append(request.Requirements, &leveldb.ApprovalRequirement{
+			Rule:      approval.Rule,
+			Required:  approval.Required,
+			Approvers: approval.Approvers,
+		})
+	}
+	request.ExpiresAt = now.Add(ttl).Unix()
+	_, span := tracer.Start(ctx, "keystore.PutSignRequest")
+	err := s.db.PutSignRequest(request)
+	tracing.End(span, err)
+	if err != nil {
+		return "", errors.Wrap(err, "store sign request fail")
+	}
+	log.Info("sign request pending approval", "id", request.ID, "consumer", c.id, "pubkey", record.Pubkey)
+	return request.ID, nil
+}
+
+func (s *RpcServer) GetSignRequest(_ context.Context, in *wallet.GetSignRequestRequest) (*wallet.SignRequestResponse, error) {
+	resp := &wallet.SignRequestResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	request, err := s.db.GetSignRequest(in.RequestId)
+	if errors.Is(err, leveldb.ErrSignRequestNotFound) || (err == nil && !c.admin && request.Consumer != c.id) {
+		resp.Msg = "sign request not found"
+		return resp, nil
+	}
+	if err != nil {
+		log.Error("get sign request fail", "err", err)
+		return nil, err
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "get sign request success"
+	resp.Request = toSignRequest(request, time.Now())
+	return resp, nil
+}
+
+func (s *RpcServer) ListSignRequests(_ context.Context, in *wallet.ListSignRequestsRequest) (*wallet.ListSignRequestsResponse, error) {
+	resp := &wallet.ListSignRequestsResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	now := time.Now()
+	requests, err := s.db.ListSignRequests(func(request *leveldb.SignRequest) bool {
+		return (c.admin || request.Consumer == c.id) && (in.Status == "" || signRequestStatus(request, now) == in.Status)
+	})
+	if err != nil {
+		log.Error("list sign requests fail", "err", err)
+		return nil, err
+	}
+	for _, request := range requests {
+		resp.Requests = append(resp.Requests, toSignRequest(request, now))
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "list sign requests success"
+	return resp, nil
+}
+
+func (s *RpcServer) ApproveSignRequest(ctx context.Context, in *wallet.ApproveSignRequestRequest) (*wallet.SignRequestResponse, error) {
+	return s.decideSignRequest(ctx, in, true)
+}
+
+func (s *RpcServer) RejectSignRequest(ctx context.Context, in *wallet.ApproveSignRequestRequest) (*wallet.SignRequestResponse, error) {
+	return s.decideSignRequest(ctx, in, false)
+}
+
+// decideSignRequest records the approval or the rejection of a sign request by an approver, and signs
+// the request once every approval it requires is given. A single rejection rejects the request.
+func (s *RpcServer) decideSignRequest(ctx context.Context, in *wallet.ApproveSignRequestRequest, approve bool) (*wallet.SignRequestResponse, error) {
+	resp := &wallet.SignRequestResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	if s.policy == nil {
+		resp.Msg = "no transaction policy is configured"
+		return resp, nil
+	}
+	unlock := s.signRequestLocks.lock(in.RequestId)
+	defer unlock()
+
+	request, err := s.db.GetSignRequest(in.RequestId)
+	if errors.Is(err, leveldb.ErrSignRequestNotFound) {
+		resp.Msg = "sign request not found"
+		return resp, nil
+	}
+	if err != nil {
+		log.Error("get sign request fail", "err", err)
+		return nil, err
+	}
+	now := time.Now()
+	if status := signRequestStatus(request, now); status != leveldb.SignRequestPending {
+		if status != request.Status {
+			request.Status = status
+			request.UpdatedAt = now.Unix()
+			if err := s.db.PutSignRequest(request); err != nil {
+				log.Error("store sign request fail", "err", err)
+				return nil, err
+			}
+		}
+		resp.Msg = "sign request is " + status
+		resp.Request = toSignRequest(request, now)
+		return resp, nil
+	}
+
+	approver, isOk := s.Policy.Approver(in.Approver)
+	if !isOk || !slices.ContainsFunc(request.Requirements, func(requirement *leveldb.ApprovalRequirement) bool {
+		return slices.Contains(requirement.Approvers, in.Approver)
+	}) {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "not an approver of the sign request"
+		return resp, nil
+	}
+	digest := approveDigest(request)
+	if !approve {
+		digest = rejectDigest(request)
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(in.Signature, "0x"))
+	if err != nil || !approver.Verify(digest, signature) {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "invalid approver signature"
+		return resp, nil
+	}
+
+	decision := &leveldb.SignApproval{Approver: in.Approver, Signature: hex.EncodeToString(signature), At: now.Unix()}
+	request.UpdatedAt = now.Unix()
+	if !approve {
+		request.Rejection = decision
+		request.Status = leveldb.SignRequestRejected
+		if err := s.db.PutSignRequest(request); err != nil {
+			log.Error("store sign request fail", "err", err)
+			return nil, err
+		}
+		log.Info("sign request rejected", "id", request.ID, "approver", in.Approver)
+		resp.Code = wallet.ReturnCode_SUCCESS
+		resp.Msg = "sign request rejected"
+		resp.Request = toSignRequest(request, now)
+		return resp, nil
+	}
+
+	// approving twice is not an error, it retries the signature of an approved request
+	if !slices.ContainsFunc(request.Approvals, func(approval *leveldb.SignApproval) bool { return approval.Approver == in.Approver }) {
+		request.Approvals = append(request.Approvals, decision)
+		log.Info("sign request approved", "id", request.ID, "approver", in.Approver)
+	}
+	if request.Approved() {
+		err := s.releaseSignRequest(ctx, request)
+		if err != nil && signErrorCode(err) == wallet.ReturnCode_ERROR && !errors.Is(err, errInvalidUnsignedTx) {
+			// the request stays pending with its approvals, approving it again retries the signature
+			if err := s.db.PutSignRequest(request); err != nil {
+				log.Error("store sign request fail", "err", err)
+				return nil, err
+			}
+			resp.Msg = "sign approved request fail: " + err.Error()
+			resp.Request = toSignRequest(request, now)
+			return resp, nil
+		}
+	}
+	if err := s.db.PutSignRequest(request); err != nil {
+		log.Error("store sign request fail", "err", err)
+		return nil, err
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "sign request approved"
+	resp.Request = toSignRequest(request, now)
+	return resp, nil
+}
+
+// releaseSignRequest signs an approved request on behalf of the consumer that made it. The request is
+// signed or failed afterwards unless the error returned is worth a retry.
+func (s *RpcServer) releaseSignRequest(ctx context.Context, request *leveldb.SignRequest) error {
+	signed, err := s.signMessage(ctx, &caller{id: request.Consumer, admin: request.Admin}, protobuf.CryptoType(request.Type), &signInput{
+		publicKey:    request.Pubkey,
+		messageHash:  request.MessageHash,
+		unsignedTx:   request.UnsignedTx,
+		chainID:      request.ChainID,
+		unsignedPSBT: request.UnsignedPSBT,
+		inputIndex:   request.InputIndex,
+		network:      request.Network,
+		approved:     true,
+	})
+	if err != nil {
+		if signErrorCode(err) != wallet.ReturnCode_ERROR || errors.Is(err, errInvalidUnsignedTx) {
+			request.Status = leveldb.SignRequestFailed
+			request.Error = err.Error()
+			log.Warn("approved sign request fail", "id", request.ID, "err", err)
+		}
+		return err
+	}
+	request.Status = leveldb.SignRequestSigned
+	request.Signature = signed.signature
+	request.SignedTx = signed.signedTx
+	log.Info("approved sign request signed", "id", request.ID)
+	return nil
+}
+
+// expireSignRequestsLoop periodically expires the sign requests past their expiry and deletes
+// the finished requests older than SignRequestRetention.
+func (s *RpcServer) expireSignRequestsLoop(ctx context.Context) {
+	ticker := time.NewTicker(SignRequestExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			if err := s.expireSignRequests(time.Now()); err != nil {
+				log.Error("expire sign requests fail", "err", err)
+			}
+		}
+	}
+}
+
+func (s *RpcServer) expireSignRequests(now time.Time) error {
+	requests, err := s.db.ListSignRequests(func(request *leveldb.SignRequest) bool {
+		return request.Status == leveldb.SignRequestPending && now.Unix() >= request.ExpiresAt
+	})
+	if err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if err := s.expireSignRequest(request.ID, now); err != nil {
+			return err
+		}
+	}
+
+	retention := now.Add(-SignRequestRetention).Unix()
+	finished, err := s.db.ListSignRequests(func(request *leveldb.SignRequest) bool {
+		return request.Status != leveldb.SignRequestPending && request.UpdatedAt < retention
+	})
+	if err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteSignRequests(finished)
+	if err != nil {
+		return err
+	}
+	if len(requests) > 0 || deleted > 0 {
+		log.Info("expire sign requests", "expired", len(requests), "deleted", deleted)
+	}
+	return nil
+}
+
+// expireSignRequest marks the request id expired unless it was decided in the meantime.
+func (s *RpcServer) expireSignRequest(id string, now time.Time) error {
+	unlock := s.signRequestLocks.lock(id)
+	defer unlock()
+	request, err := s.db.GetSignRequest(id)
+	if err != nil {
+		return err
+	}
+	if signRequestStatus(request, now) != leveldb.SignRequestExpired || request.Status == leveldb.SignRequestExpired {
+		return nil
+	}
+	request.Status = leveldb.SignRequestExpired
+	request.UpdatedAt = now.Unix()
+	return s.db.PutSignRequest(request)
+}
+
+// signRequestStatus returns the status of request at now, a pending request past its expiry is expired.
+func signRequestStatus(request *leveldb.SignRequest, now time.Time) string {
+	if request.Status == leveldb.SignRequestPending && now.Unix() >= request.ExpiresAt {
+		return leveldb.SignRequestExpired
+	}
+	return request.Status
+}
+
+// approveDigest is the digest an approver signs to approve request. It binds the key, the signed
+// message and the expiry of the request, so that an approval can not be replayed on another request.
+func approveDigest(request *leveldb.SignRequest) []byte {
+	h := sha256.New()
+	h.Write([]byte(signRequestDigestDomain))
+	for _, field := range []string{request.ID, request.Consumer, request.Pubkey, request.Type, request.MessageHash, request.Chain, request.Token, request.Amount} {
+		// every field is length prefixed so that the concatenation is unambiguous
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		h.Write([]byte(field))
+	}
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(request.ExpiresAt)))
+	return h.Sum(nil)
+}
+
+// rejectDigest is the digest an approver signs to reject request.
+func rejectDigest(request *leveldb.SignRequest) []byte {
+	digest := sha256.Sum256(append([]byte("reject\x00"), approveDigest(request)...))
+	return digest[:]
+}
+
+func toSignRequest(request *leveldb.SignRequest, now time.Time) *wallet.SignRequest {
+	out := &wallet.SignRequest{
+		Id:            request.ID,
+		Consumer:      request.Consumer,
+		PublicKey:     request.Pubkey,
+		Type:          request.Type,
+		MessageHash:   request.MessageHash,
+		UnsignedTx:    request.UnsignedTx,
+		ChainId:       request.ChainID,
+		UnsignedPsbt:  request.UnsignedPSBT,
+		InputIndex:    request.InputIndex,
+		Network:       request.Network,
+		Chain:         request.Chain,
+		Token:         request.Token,
+		Amount:        request.Amount,
+		Status:        signRequestStatus(request, now),
+		ApproveDigest: hex.EncodeToString(approveDigest(request)),
+		RejectDigest:  hex.EncodeToString(rejectDigest(request)),
+		Signature:     request.Signature,
+		SignedTx:      request.SignedTx,
+		Error:         request.Error,
+		CreatedAt:     request.CreatedAt,
+		ExpiresAt:     request.ExpiresAt,
+	}
+	for _, requirement := range request.Requirements {
+		out.Requirements = append(out.Requirements, &wallet.ApprovalRequirement{
+			Rule:      requirement.Rule,
+			Required:  uint32(requirement.Required),
+			Approvers: requirement.Approvers,
+		})
+	}
+	for _, approval := range request.Approvals {
+		out.ApprovedBy = append(out.ApprovedBy, approval.Approver)
+	}
+	if request.Rejection != nil {
+		out.RejectedBy = request.Rejection.Approver
+	}
+	return out
+}
+
+// pendingApprovalMsg is the message of a sign request held for approval.
+func pendingApprovalMsg(id string) string {
+	return fmt.Sprintf("sign request %s is pending approval", id)
+}
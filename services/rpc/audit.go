@@ -0,0 +1,353 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+)
+
+// auditExemptMethods are the WalletService methods that use no key and are not audited.
+var auditExemptMethods = map[string]bool{
+	wallet.WalletService_GetSupportSignWay_FullMethodName: true,
+	wallet.WalletService_VerifySignature_FullMethodName:   true,
+	wallet.WalletService_RecoverPublicKey_FullMethodName:  true,
+	wallet.WalletService_SealStatus_FullMethodName:        true,
+}
+
+// auditRequiredMethods are the WalletService methods returning signatures. Their responses are
+// only returned once audited, a signature missing from the audit log is never handed out.
+var auditRequiredMethods = map[string]bool{
+	wallet.WalletService_SignTxMessage_FullMethodName:       true,
+	wallet.WalletService_BatchSignTxMessage_FullMethodName:  true,
+	wallet.WalletService_SignTxMessageStream_FullMethodName: true,
+	wallet.WalletService_ApproveSignRequest_FullMethodName:  true,
+}
+
+var errAuditKey = fmt.Errorf("%w: the audit key only signs audit anchors", errKeyNotAccessible)
+
+var errAuditUnavailable = status.Error(codes.Unavailable, "audit log is unavailable")
+
+// auditUnaryInterceptor appends the calls of the WalletService methods to the audit log once they are
+// served, one entry per key of the call. It runs first so that the calls refused by the other
+// interceptors are audited as well. The calls returning signatures fail when they can not be audited.
+func (s *RpcServer) auditUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if auditedMethod(info.FullMethod) {
+		if auditErr := s.appendAudit(s.auditEntries(info.FullMethod, req, resp, err)); auditErr != nil && auditRequiredMethods[info.FullMethod] {
+			return nil, errAuditUnavailable
+		}
+	}
+	return resp, err
+}
+
+// auditStreamInterceptor audits every request of a sign stream before its response is sent,
+// and the other streams once they end.
+func (s *RpcServer) auditStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !auditedMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	if info.FullMethod != wallet.WalletService_SignTxMessageStream_FullMethodName {
+		err := handler(srv, ss)
+		_ = s.appendAudit([]*leveldb.AuditEntry{s.auditEntry(info.FullMethod, "", callResult(nil, err))})
+		return err
+	}
+	stream := &auditServerStream{ServerStream: ss, s: s, requests: make(map[string]*wallet.SignTxMessageStreamRequest)}
+	err := handler(srv, stream)
+	if err != nil {
+		_ = s.appendAudit([]*leveldb.AuditEntry{s.auditEntry(info.FullMethod, stream.consumer, callResult(nil, err))})
+	}
+	return err
+}
+
+type auditServerStream struct {
+	grpc.ServerStream
+	s *RpcServer
+
+	mu sync.Mutex
+	// consumer is the caller resolved from the first request of the stream
+	consumer string
+	requests map[string]*wallet.SignTxMessageStreamRequest
+}
+
+func (ss *auditServerStream) RecvMsg(m any) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if in, isOk := m.(*wallet.SignTxMessageStreamRequest); isOk {
+		ss.mu.Lock()
+		if ss.consumer == "" {
+			ss.consumer = ss.s.auditConsumer(in.ConsumerToken)
+		}
+		ss.requests[in.RequestId] = in
+		ss.mu.Unlock()
+	}
+	return nil
+}
+
+func (ss *auditServerStream) SendMsg(m any) error {
+	resp, isOk := m.(*wallet.SignTxMessageStreamResponse)
+	if !isOk {
+		return ss.ServerStream.SendMsg(m)
+	}
+	ss.mu.Lock()
+	in := ss.requests[resp.RequestId]
+	delete(ss.requests, resp.RequestId)
+	consumer := ss.consumer
+	ss.mu.Unlock()
+	if in != nil {
+		entry := ss.s.auditEntry(wallet.WalletService_SignTxMessageStream_FullMethodName, consumer, resp.Code.String())
+		entry.Pubkey = in.PublicKey
+		entry.MessageHash = ss.s.auditMessageHash(&signInput{
+			publicKey:    in.PublicKey,
+			messageHash:  in.MessageHash,
+			unsignedTx:   in.UnsignedTx,
+			chainID:      in.ChainId,
+			unsignedPSBT: in.UnsignedPsbt,
+			inputIndex:   in.InputIndex,
+			network:      in.Network,
+		})
+		// the response is not sent when it can not be audited, which ends the stream
+		if err := ss.s.appendAudit([]*leveldb.AuditEntry{entry}); err != nil {
+			return errAuditUnavailable
+		}
+	}
+	return ss.ServerStream.SendMsg(m)
+}
+
+// auditEntries returns the audit entries of a unary call: one per generated key or batch item, one otherwise.
+func (s *RpcServer) auditEntries(method string, req, resp any, err error) []*leveldb.AuditEntry {
+	var consumer string
+	if in, isOk := req.(interface{ GetConsumerToken() string }); isOk {
+		consumer = s.auditConsumer(in.GetConsumerToken())
+	}
+	result := callResult(resp, err)
+
+	switch in := req.(type) {
+	case *wallet.BatchSignTxMessageRequest:
+		results, _ := resp.(*wallet.BatchSignTxMessageResponse)
+		entries := make([]*leveldb.AuditEntry, len(in.Items))
+		for i, item := range in.Items {
+			entries[i] = s.auditEntry(method, consumer, result)
+			entries[i].Pubkey = item.PublicKey
+			entries[i].MessageHash = s.auditMessageHash(&signInput{
+				publicKey:    item.PublicKey,
+				messageHash:  item.MessageHash,
+				unsignedTx:   item.UnsignedTx,
+				chainID:      item.ChainId,
+				unsignedPSBT: item.UnsignedPsbt,
+				inputIndex:   item.InputIndex,
+				network:      item.Network,
+			})
+			if results != nil && i < len(results.Results) {
+				entries[i].Result = results.Results[i].Code.String()
+			}
+		}
+		if len(entries) > 0 {
+			return entries
+		}
+	case *wallet.ExportPublicKeyRequest:
+		if out, isOk := resp.(*wallet.ExportPublicKeyResponse); isOk && len(out.PublicKey) > 0 {
+			entries := make([]*leveldb.AuditEntry, len(out.PublicKey))
+			for i, publicKey := range out.PublicKey {
+				entries[i] = s.auditEntry(method, consumer, result)
+				entries[i].Pubkey = publicKey.Pubkey
+			}
+			return entries
+		}
+	case *wallet.SignTxMessageRequest:
+		entry := s.auditEntry(method, consumer, result)
+		entry.Pubkey = in.PublicKey
+		entry.MessageHash = s.auditMessageHash(&signInput{
+			publicKey:    in.PublicKey,
+			messageHash:  in.MessageHash,
+			unsignedTx:   in.UnsignedTx,
+			chainID:      in.ChainId,
+			unsignedPSBT: in.UnsignedPsbt,
+			inputIndex:   in.InputIndex,
+			network:      in.Network,
+		})
+		return []*leveldb.AuditEntry{entry}
+	case *wallet.ImportKeyRequest:
+		entry := s.auditEntry(method, consumer, result)
+		if out, isOk := resp.(*wallet.ImportKeyResponse); isOk && out.PublicKey != nil {
+			entry.Pubkey = out.PublicKey.Pubkey
+		}
+		return []*leveldb.AuditEntry{entry}
+	case *wallet.ApproveSignRequestRequest:
+		// the approver signs the request, the key is the one of the request
+		entry := s.auditEntry(method, "approver:"+in.Approver, result)
+		if out, isOk := resp.(*wallet.SignRequestResponse); isOk && out.Request != nil {
+			entry.Pubkey = out.Request.PublicKey
+			entry.MessageHash = out.Request.MessageHash
+		}
+		return []*leveldb.AuditEntry{entry}
+	}
+
+	entry := s.auditEntry(method, consumer, result)
+	if in, isOk := req.(interface{ GetPublicKey() string }); isOk {
+		entry.Pubkey = in.GetPublicKey()
+	}
+	return []*leveldb.AuditEntry{entry}
+}
+
+func (s *RpcServer) auditEntry(method, consumer, result string) *leveldb.AuditEntry {
+	return &leveldb.AuditEntry{
+		Time:     time.Now().UnixNano(),
+		Consumer: consumer,
+		RPC:      shortMethod(method),
+		Result:   result,
+	}
+}
+
+// auditConsumer returns the consumer id of token, the token itself is never written to the audit log.
+func (s *RpcServer) auditConsumer(token string) string {
+	c, isOk := s.resolveCaller(token)
+	if !isOk {
+		return "unknown"
+	}
+	return c.id
+}
+
+// auditMessageHash returns the hash a sign request asks to sign, the signing hash of its transaction
+// when it carries one. It is empty when the transaction can not be parsed.
+func (s *RpcServer) auditMessageHash(in *signInput) string {
+	switch {
+	case in.messageHash != "":
+		return in.messageHash
+	case in.unsignedTx != "":
+		tx, err := policy.ParseEVMTransaction(in.unsignedTx, in.chainID)
+		if err != nil {
+			return ""
+		}
+		return hexutil.Encode(tx.SigningHash())
+	case in.unsignedPSBT != "":
+		meta, isOk := s.db.GetKeyMeta(in.publicKey)
+		if !isOk {
+			return ""
+		}
+		tx, err := policy.ParseBitcoinPSBT(in.unsignedPSBT, in.inputIndex, in.network, common.FromHex(meta.CompressPubkey))
+		if err != nil {
+			return ""
+		}
+		return hexutil.Encode(tx.SigningHash())
+	}
+	return ""
+}
+
+// callResult returns the ReturnCode of resp, or the gRPC status code of err when the call failed.
+func callResult(resp any, err error) string {
+	if err != nil {
+		return "grpc:" + status.Code(err).String()
+	}
+	if out, isOk := resp.(interface{ GetCode() wallet.ReturnCode }); isOk {
+		return out.GetCode().String()
+	}
+	return wallet.ReturnCode_SUCCESS.String()
+}
+
+func auditedMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+wallet.WalletService_ServiceDesc.ServiceName+"/") && !auditExemptMethods[method]
+}
+
+// appendAudit appends entries to the audit log, logging a failure.
+func (s *RpcServer) appendAudit(entries []*leveldb.AuditEntry) error {
+	err := s.db.AppendAudit(entries)
+	if err != nil {
+		log.Error("append audit log fail", "entries", len(entries), "err", err)
+	}
+	return err
+}
+
+// checkAuditKey makes sure the audit key exists and can sign anchors.
+func (s *RpcServer) checkAuditKey() error {
+	meta, isOk := s.db.GetKeyMeta(s.AuditKey)
+	if !isOk {
+		return errors.New("audit key not found")
+	}
+	if meta.Type != string(protobuf.ECDSA) {
+		return errors.New("audit key must be an ecdsa key")
+	}
+	return nil
+}
+
+// isAuditKey reports whether publicKey is the audit key, which signs nothing but the audit anchors.
+func (s *RpcServer) isAuditKey(publicKey string) bool {
+	return s.AuditKey != "" && strings.EqualFold(strings.TrimPrefix(publicKey, "0x"), strings.TrimPrefix(s.AuditKey, "0x"))
+}
+
+// anchorAuditLoop periodically signs the head of the audit log with the audit key.
+func (s *RpcServer) anchorAuditLoop(ctx context.Context) {
+	interval := s.AuditAnchorInterval
+	if interval <= 0 {
+		interval = DefaultAuditAnchorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			if err := s.anchorAudit(); err != nil {
+				log.Error("anchor audit log fail", "err", err)
+			}
+		}
+	}
+}
+
+// anchorAudit signs the head of the audit log unless it is already anchored.
+func (s *RpcServer) anchorAudit() error {
+	if s.db.Sealed() {
+		log.Warn("key store is sealed, skip anchoring the audit log")
+		return nil
+	}
+	head, err := s.db.GetAuditHead()
+	if err != nil {
+		return err
+	}
+	anchors, err := s.db.ListAuditAnchors()
+	if err != nil {
+		return err
+	}
+	if head.Seq == 0 || (len(anchors) > 0 && anchors[len(anchors)-1].Seq >= head.Seq) {
+		return nil
+	}
+
+	record, isOk := s.db.GetKeyRecord(s.AuditKey)
+	if !isOk {
+		return errors.New("audit key not found")
+	}
+	privateKey, err := s.db.DecryptPrivateKey(record)
+	if err != nil {
+		return err
+	}
+	anchor := &leveldb.AuditAnchor{Seq: head.Seq, Hash: head.Hash, Time: time.Now().Unix(), Pubkey: record.Pubkey}
+	if anchor.Signature, err = ssm.SignECDSAMessage(privateKey, hex.EncodeToString(anchor.Digest())); err != nil {
+		return err
+	}
+	if err := s.db.PutAuditAnchor(anchor); err != nil {
+		return err
+	}
+	log.Info("anchor audit log", "seq", anchor.Seq, "hash", anchor.Hash, "signature", anchor.Signature)
+	return nil
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// failingWriteStore fails every Write while fail is set, the audit log is appended with Write.
+type failingWriteStore struct {
+	leveldb.KeyStore
+	fail atomic.Bool
+}
+
+func (s *failingWriteStore) Write(batch *leveldb.Batch) error {
+	if s.fail.Load() {
+		return errors.New("disk full")
+	}
+	return s.KeyStore.Write(batch)
+}
+
+func TestAuditRequired(t *testing.T) {
+	store := &failingWriteStore{KeyStore: leveldb.NewMemoryStore()}
+	db, err := leveldb.NewKeys(store)
+	assert.NoError(t, err)
+	client := dialTestServer(t, newTestServer(t, &RpcServerConfig{}, db))
+	pubkey := exportKeys(t, client, "alice-token", "ecdsa", 1)[0]
+	messageHash := "0x" + strings.Repeat("ab", 32)
+	store.fail.Store(true)
+
+	// no signature is returned without its audit entry
+	_, err = client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "ecdsa", PublicKey: pubkey, MessageHash: messageHash})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = client.BatchSignTxMessage(t.Context(), &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: []*wallet.SignTxMessageItem{{Type: "ecdsa", PublicKey: pubkey, MessageHash: messageHash}}})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	stream, err := client.SignTxMessageStream(t.Context())
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{RequestId: "1", ConsumerToken: "alice-token", Type: "ecdsa", PublicKey: pubkey, MessageHash: messageHash}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// the calls returning no signature are still served
+	key, err := client.GetKey(t.Context(), &wallet.GetKeyRequest{ConsumerToken: "alice-token", PublicKey: pubkey})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, key.Code)
+
+	store.fail.Store(false)
+	resp, err := client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "ecdsa", PublicKey: pubkey, MessageHash: messageHash})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+}
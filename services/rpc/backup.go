@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// BackupChunkSize is the size of the chunks a backup is streamed in.
+const BackupChunkSize = 1024 * 1024
+
+// BackupKeystore streams an encrypted backup of a consistent snapshot of the key store.
+// Only the admin may back up the key store.
+func (s *RpcServer) BackupKeystore(in *wallet.BackupKeystoreRequest, stream wallet.WalletService_BackupKeystoreServer) error {
+	if !s.isAdmin(in.ConsumerToken) {
+		return stream.Send(&wallet.BackupKeystoreResponse{
+			Code: wallet.ReturnCode_PERMISSION_DENIED,
+			Msg:  "only admin can backup key store",
+		})
+	}
+	var buf bytes.Buffer
+	count, err := s.db.Backup(&buf, in.Passphrase)
+	if err != nil {
+		log.Error("backup key store fail", "err", err)
+		return stream.Send(&wallet.BackupKeystoreResponse{
+			Code: wallet.ReturnCode_ERROR,
+			Msg:  "backup key store fail: " + err.Error(),
+		})
+	}
+	log.Info("backup key store", "entries", count, "size", buf.Len())
+
+	msg := fmt.Sprintf("backup %d entries", count)
+	for buf.Len() > 0 {
+		if err := stream.Send(&wallet.BackupKeystoreResponse{
+			Code:  wallet.ReturnCode_SUCCESS,
+			Msg:   msg,
+			Chunk: buf.Next(BackupChunkSize),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
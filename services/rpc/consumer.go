@@ -0,0 +1,351 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// AdminConsumerID is the consumer id of the admin token when it is not listed in the consumers file.
+const AdminConsumerID = "admin"
+
+// tokenConsumerIDPrefix prefixes the consumer ids derived from the tokens without a consumers file.
+const tokenConsumerIDPrefix = "token:"
+
+var errKeyNotAccessible = errors.New("key is not accessible by the consumer")
+
+// Consumer maps the token a client sends as consumer_token to the consumer id owning its keys.
+type Consumer struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	// Limits overrides the default limits, a zero field keeps the default
+	Limits ConsumerLimits `json:"limits"`
+}
+
+// ConsumerLimits bounds the keys and signatures of one consumer, a zero field means no limit.
+type ConsumerLimits struct {
+	// MaxKeys is the maximum number of keys owned by the consumer, destroyed keys excluded
+	MaxKeys int `json:"maxKeys,omitempty"`
+	// MaxKeysPerRequest is the maximum number of keys created by one exportPublicKeyList call,
+	// it may only be lower than MaxExportKeysNumber
+	MaxKeysPerRequest   int `json:"maxKeysPerRequest,omitempty"`
+	SignaturesPerSecond int `json:"signaturesPerSecond,omitempty"`
+	SignaturesPerMinute int `json:"signaturesPerMinute,omitempty"`
+	SignaturesPerDay    int `json:"signaturesPerDay,omitempty"`
+}
+
+// withDefaults fills the zero fields of l with the fields of defaults.
+func (l ConsumerLimits) withDefaults(defaults ConsumerLimits) ConsumerLimits {
+	if l.MaxKeys == 0 {
+		l.MaxKeys = defaults.MaxKeys
+	}
+	if l.MaxKeysPerRequest == 0 {
+		l.MaxKeysPerRequest = defaults.MaxKeysPerRequest
+	}
+	if l.SignaturesPerSecond == 0 {
+		l.SignaturesPerSecond = defaults.SignaturesPerSecond
+	}
+	if l.SignaturesPerMinute == 0 {
+		l.SignaturesPerMinute = defaults.SignaturesPerMinute
+	}
+	if l.SignaturesPerDay == 0 {
+		l.SignaturesPerDay = defaults.SignaturesPerDay
+	}
+	return l
+}
+
+func (l ConsumerLimits) validate() error {
+	if l.MaxKeys < 0 || l.MaxKeysPerRequest < 0 || l.SignaturesPerSecond < 0 || l.SignaturesPerMinute < 0 || l.SignaturesPerDay < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if l.MaxKeysPerRequest > MaxExportKeysNumber {
+		return fmt.Errorf("maxKeysPerRequest must not be greater than %d", MaxExportKeysNumber)
+	}
+	return nil
+}
+
+// ConsumersConfig is the content of the consumers file.
+type ConsumersConfig struct {
+	// DefaultLimits applies to every listed consumer. The limits need listed consumers: without them
+	// every token is a consumer, so a client could escape its limits by sending another token
+	DefaultLimits ConsumerLimits `json:"defaultLimits"`
+	Consumers     []Consumer     `json:"consumers"`
+}
+
+// LoadConsumers reads the consumers file, a JSON document of the form
+// {"defaultLimits": {...}, "consumers": [{"id": "...", "token": "...", "limits": {...}}]}.
+func LoadConsumers(path string) (*ConsumersConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config ConsumersConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decode consumers file: %w", err)
+	}
+	if err := config.DefaultLimits.validate(); err != nil {
+		return nil, fmt.Errorf("default limits: %w", err)
+	}
+	if len(config.Consumers) == 0 && config.DefaultLimits != (ConsumerLimits{}) {
+		return nil, errors.New("default limits need at least one consumer")
+	}
+	ids := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for i, consumer := range config.Consumers {
+		if consumer.ID == "" || consumer.Token == "" {
+			return nil, fmt.Errorf("consumer %d: id and token must not be empty", i)
+		}
+		if strings.HasPrefix(consumer.ID, tokenConsumerIDPrefix) {
+			return nil, fmt.Errorf("consumer %q: id must not start with %q", consumer.ID, tokenConsumerIDPrefix)
+		}
+		// the consumer would own the keys of the admin token without being the admin
+		if consumer.ID == AdminConsumerID {
+			return nil, fmt.Errorf("consumer %q: id is reserved for the admin token", consumer.ID)
+		}
+		if ids[consumer.ID] {
+			return nil, fmt.Errorf("duplicate consumer id %q", consumer.ID)
+		}
+		if tokens[consumer.Token] {
+			return nil, fmt.Errorf("consumer %q: duplicate token", consumer.ID)
+		}
+		if err := consumer.Limits.validate(); err != nil {
+			return nil, fmt.Errorf("consumer %q: %w", consumer.ID, err)
+		}
+		ids[consumer.ID] = true
+		tokens[consumer.Token] = true
+	}
+	return &config, nil
+}
+
+// caller is the identity a request is served for.
+type caller struct {
+	// id owns the keys created by the caller
+	id    string
+	admin bool
+}
+
+// tokenConsumerID returns the consumer id of a token without a consumers file. Only a hash of the
+// token is used, so that the ids stored with the keys and returned to other consumers reveal no token.
+func tokenConsumerID(consumerToken string) string {
+	hash := sha256.Sum256([]byte(consumerToken))
+	return tokenConsumerIDPrefix + hex.EncodeToString(hash[:16])
+}
+
+// isTokenConsumerID reports whether id is a consumer id returned by tokenConsumerID.
+func isTokenConsumerID(id string) bool {
+	hash, isOk := strings.CutPrefix(id, tokenConsumerIDPrefix)
+	if !isOk || len(hash) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && strings.ToLower(hash) == hash
+}
+
+// resolveCaller resolves the consumer token of a request, an empty token is rejected. Without a
+// consumers file the consumer id is derived from the token; otherwise tokens that are not listed
+// are rejected, except the admin token.
+func (s *RpcServer) resolveCaller(consumerToken string) (*caller, bool) {
+	if consumerToken == "" {
+		return nil, false
+	}
+	admin := s.isAdmin(consumerToken)
+	if len(s.Consumers) == 0 {
+		return &caller{id: tokenConsumerID(consumerToken), admin: admin}, true
+	}
+	for _, consumer := range s.Consumers {
+		if subtle.ConstantTimeCompare([]byte(consumerToken), []byte(consumer.Token)) == 1 {
+			return &caller{id: consumer.ID, admin: admin}, true
+		}
+	}
+	if admin {
+		return &caller{id: AdminConsumerID, admin: true}, true
+	}
+	return nil, false
+}
+
+// isConsumer reports whether id is a consumer id requests can be served for.
+func (s *RpcServer) isConsumer(id string) bool {
+	if len(s.Consumers) == 0 {
+		return isTokenConsumerID(id)
+	}
+	if id == AdminConsumerID && s.AdminToken != "" {
+		return true
+	}
+	for _, consumer := range s.Consumers {
+		if consumer.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// consumerLimits returns the limits of the consumer id. Consumers are only limited when they are
+// listed in the consumers file, otherwise their ids are derived from tokens chosen by the clients.
+func (s *RpcServer) consumerLimits(id string) ConsumerLimits {
+	if len(s.Consumers) == 0 {
+		return ConsumerLimits{}
+	}
+	for _, consumer := range s.Consumers {
+		if consumer.ID == id {
+			return consumer.Limits.withDefaults(s.DefaultLimits)
+		}
+	}
+	return s.DefaultLimits
+}
+
+// reassignConsumers moves the keys and grants still owned by a consumer token, stored before the
+// consumer ids were derived from the tokens, to the consumer id of that token. With a consumers file
+// the keys owned by the id derived from a listed token move to the configured consumer id too.
+func (s *RpcServer) reassignConsumers() error {
+	var rename func(consumer string) (string, bool)
+	if len(s.Consumers) == 0 {
+		rename = func(consumer string) (string, bool) {
+			if consumer == "" || isTokenConsumerID(consumer) {
+				return "", false
+			}
+			return tokenConsumerID(consumer), true
+		}
+	} else {
+		consumers := make(map[string]string, 2*len(s.Consumers)+2)
+		for _, consumer := range s.Consumers {
+			consumers[consumer.Token] = consumer.ID
+			consumers[tokenConsumerID(consumer.Token)] = consumer.ID
+		}
+		if s.AdminToken != "" {
+			if _, isOk := consumers[s.AdminToken]; !isOk {
+				consumers[s.AdminToken] = AdminConsumerID
+				consumers[tokenConsumerID(s.AdminToken)] = AdminConsumerID
+			}
+		}
+		rename = func(consumer string) (string, bool) {
+			id, isOk := consumers[consumer]
+			return id, isOk
+		}
+	}
+	reassigned, err := s.db.RenameConsumers(rename)
+	if err != nil {
+		return err
+	}
+	if reassigned > 0 {
+		log.Info("reassign keys to consumer ids", "keys", reassigned)
+	}
+	return nil
+}
+
+// canUseKey reports whether the caller may sign with a key: its owner, the consumers it was
+// granted to and the admin may. The keys without owner, such as the migrated legacy keys, may only
+// be used by the admin until they are assigned an owner with the assign-key-owner command.
+func (s *RpcServer) canUseKey(c *caller, meta *leveldb.KeyMeta) (bool, error) {
+	if c.admin || (meta.Consumer != "" && meta.Consumer == c.id) {
+		return true, nil
+	}
+	return s.db.HasKeyGrant(meta.Pubkey, c.id)
+}
+
+// visibleKeyMeta returns the metadata of a key the caller may use, hiding the keys of other consumers.
+func (s *RpcServer) visibleKeyMeta(c *caller, publicKey string) (*leveldb.KeyMeta, bool, error) {
+	meta, isOk := s.db.GetKeyMeta(publicKey)
+	if !isOk {
+		return nil, false, nil
+	}
+	usable, err := s.canUseKey(c, meta)
+	if err != nil || !usable {
+		return nil, false, err
+	}
+	return meta, true, nil
+}
+
+func (s *RpcServer) GrantKeyAccess(_ context.Context, in *wallet.KeyAccessRequest) (*wallet.KeyAccessResponse, error) {
+	return s.changeKeyAccess(in, true)
+}
+
+func (s *RpcServer) RevokeKeyAccess(_ context.Context, in *wallet.KeyAccessRequest) (*wallet.KeyAccessResponse, error) {
+	return s.changeKeyAccess(in, false)
+}
+
+// changeKeyAccess grants or revokes the access of in.Grantee to a key. Only the key owner and the admin may do so.
+func (s *RpcServer) changeKeyAccess(in *wallet.KeyAccessRequest, grant bool) (*wallet.KeyAccessResponse, error) {
+	resp := &wallet.KeyAccessResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	meta, isOk, err := s.visibleKeyMeta(c, in.PublicKey)
+	if err != nil {
+		log.Error("get key fail", "err", err)
+		return nil, err
+	}
+	if !isOk {
+		resp.Msg = "key not found"
+		return resp, nil
+	}
+	if !s.canManageKey(c, meta) {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "only the key owner or admin can change key access"
+		return resp, nil
+	}
+	if meta.Consumer == "" {
+		resp.Msg = "key without owner can not be granted, assign it an owner first"
+		return resp, nil
+	}
+	if in.Grantee == meta.Consumer {
+		resp.Msg = "grantee is the key owner"
+		return resp, nil
+	}
+
+	if grant {
+		if !s.isConsumer(in.Grantee) {
+			resp.Msg = "unknown grantee"
+			return resp, nil
+		}
+		err = s.db.GrantKey(in.PublicKey, in.Grantee)
+		if err == nil {
+			log.Info("grant key access", "key", in.PublicKey, "grantee", in.Grantee)
+		}
+		resp.Msg = "grant key access success"
+	} else {
+		var revoked bool
+		revoked, err = s.db.RevokeKey(in.PublicKey, in.Grantee)
+		if err == nil && revoked {
+			log.Info("revoke key access", "key", in.PublicKey, "grantee", in.Grantee)
+		}
+		resp.Msg = "revoke key access success"
+	}
+	if errors.Is(err, leveldb.ErrKeyNotFound) {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
+	if err != nil {
+		log.Error("change key access fail", "err", err)
+		return nil, err
+	}
+
+	grants, err := s.db.ListKeyGrants(in.PublicKey)
+	if err != nil {
+		log.Error("list key grants fail", "err", err)
+		return nil, err
+	}
+	for _, grant := range grants {
+		resp.Grants = append(resp.Grants, &wallet.KeyGrant{
+			Grantee:   grant.Grantee,
+			GrantedAt: grant.GrantedAt,
+		})
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	return resp, nil
+}
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+)
+
+func TestResolveCaller(t *testing.T) {
+	s := newTestServer(t, &RpcServerConfig{AdminToken: "admin-token"}, newTestKeys(t))
+	_, isOk := s.resolveCaller("")
+	assert.False(t, isOk)
+	c, isOk := s.resolveCaller("alice-token")
+	assert.True(t, isOk)
+	assert.False(t, c.admin)
+	assert.Equal(t, tokenConsumerID("alice-token"), c.id)
+	assert.NotContains(t, c.id, "alice-token")
+	assert.True(t, s.isConsumer(c.id))
+	assert.False(t, s.isConsumer("alice-token"))
+	assert.False(t, s.isConsumer(""))
+	c, isOk = s.resolveCaller("admin-token")
+	assert.True(t, isOk)
+	assert.True(t, c.admin)
+
+	s = newTestServer(t, &RpcServerConfig{
+		AdminToken: "admin-token",
+		Consumers:  []Consumer{{ID: "alice", Token: "alice-token"}},
+	}, newTestKeys(t))
+	c, isOk = s.resolveCaller("alice-token")
+	assert.True(t, isOk)
+	assert.Equal(t, "alice", c.id)
+	c, isOk = s.resolveCaller("admin-token")
+	assert.True(t, isOk)
+	assert.Equal(t, AdminConsumerID, c.id)
+	_, isOk = s.resolveCaller("bob-token")
+	assert.False(t, isOk)
+	assert.False(t, s.isConsumer(tokenConsumerID("bob-token")))
+}
+
+func TestKeyOwnerIsNotToken(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{}, newTestKeys(t))
+	resp, err := s.ExportPublicKeyList(ctx, &wallet.ExportPublicKeyRequest{Type: "ecdsa", Number: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+
+	resp, err = s.ExportPublicKeyList(ctx, &wallet.ExportPublicKeyRequest{ConsumerToken: "alice-token", Type: "ecdsa", Number: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	pubkey := resp.PublicKey[0].Pubkey
+
+	key, err := s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "alice-token", PublicKey: pubkey})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, key.Code)
+	assert.Equal(t, tokenConsumerID("alice-token"), key.Key.Consumer)
+	key, err = s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "bob-token", PublicKey: pubkey})
+	assert.NoError(t, err)
+	assert.NotEqual(t, wallet.ReturnCode_SUCCESS, key.Code)
+
+	access, err := s.GrantKeyAccess(ctx, &wallet.KeyAccessRequest{ConsumerToken: "alice-token", PublicKey: pubkey, Grantee: "bob-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "unknown grantee", access.Msg)
+	access, err = s.GrantKeyAccess(ctx, &wallet.KeyAccessRequest{ConsumerToken: "alice-token", PublicKey: pubkey, Grantee: tokenConsumerID("bob-token")})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, access.Code)
+	for _, grant := range access.Grants {
+		assert.NotContains(t, grant.Grantee, "bob-token")
+	}
+	key, err = s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "bob-token", PublicKey: pubkey})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, key.Code)
+}
+
+func TestReassignConsumerTokens(t *testing.T) {
+	db := newTestKeys(t)
+	// keys stored while the consumer id was the token itself
+	assert.NoError(t, db.StoreKeys([]leveldb.Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: "alice-token", Origin: leveldb.OriginGenerated},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa", Origin: leveldb.OriginLegacy},
+		{PrivateKey: "03", Pubkey: "aa03", Type: "ecdsa", Origin: leveldb.OriginGenerated},
+	}))
+	assert.NoError(t, db.GrantKey("aa01", "bob-token"))
+
+	s := newTestServer(t, &RpcServerConfig{}, db)
+	meta, isOk := db.GetKeyMeta("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, tokenConsumerID("alice-token"), meta.Consumer)
+	grants, err := db.ListKeyGrants("aa01")
+	assert.NoError(t, err)
+	assert.Len(t, grants, 1)
+	assert.Equal(t, tokenConsumerID("bob-token"), grants[0].Grantee)
+	bob, _ := s.resolveCaller("bob-token")
+	usable, err := s.canUseKey(bob, meta)
+	assert.NoError(t, err)
+	assert.True(t, usable)
+
+	// the keys without owner, the migrated legacy keys included, are the admin's only
+	meta, isOk = db.GetKeyMeta("aa02")
+	assert.True(t, isOk)
+	usable, err = s.canUseKey(bob, meta)
+	assert.NoError(t, err)
+	assert.False(t, usable)
+	meta, isOk = db.GetKeyMeta("aa03")
+	assert.True(t, isOk)
+	usable, err = s.canUseKey(bob, meta)
+	assert.NoError(t, err)
+	assert.False(t, usable)
+	usable, err = s.canUseKey(&caller{id: AdminConsumerID, admin: true}, meta)
+	assert.NoError(t, err)
+	assert.True(t, usable)
+
+	// a consumers file then moves the keys of the token to the configured id
+	newTestServer(t, &RpcServerConfig{Consumers: []Consumer{{ID: "alice", Token: "alice-token"}, {ID: "bob", Token: "bob-token"}}}, db)
+	meta, isOk = db.GetKeyMeta("aa01")
+	assert.True(t, isOk)
+	assert.Equal(t, "alice", meta.Consumer)
+	grants, err = db.ListKeyGrants("aa01")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", grants[0].Grantee)
+	for _, pubkey := range []string{"aa01", "aa02", "aa03"} {
+		meta, _ = db.GetKeyMeta(pubkey)
+		assert.False(t, strings.Contains(meta.Consumer, "token"))
+	}
+}
+
+func TestLegacyKeyOwner(t *testing.T) {
+	privateKey, pubkey, _, err := ssm.CreateECDSAKeyPair()
+	assert.NoError(t, err)
+	// a raw entry stored before the key records and the consumer namespaces
+	store := leveldb.NewMemoryStore()
+	assert.NoError(t, store.Put([]byte(pubkey), common.FromHex(privateKey)))
+	db, err := leveldb.NewKeys(store)
+	assert.NoError(t, err)
+	client := dialTestServer(t, newTestServer(t, &RpcServerConfig{AdminToken: "admin-token"}, db))
+	sign := func(consumerToken string) *wallet.SignTxMessageResponse {
+		resp, err := client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{
+			ConsumerToken: consumerToken,
+			Type:          "ecdsa",
+			PublicKey:     pubkey,
+			MessageHash:   "0x" + strings.Repeat("ab", 32),
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, sign("alice-token").Code)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, sign("admin-token").Code)
+	grant, err := client.GrantKeyAccess(t.Context(), &wallet.KeyAccessRequest{ConsumerToken: "admin-token", PublicKey: pubkey, Grantee: tokenConsumerID("alice-token")})
+	assert.NoError(t, err)
+	assert.Equal(t, "key without owner can not be granted, assign it an owner first", grant.Msg)
+
+	// the assign-key-owner command moves the key to the namespace of the consumer
+	_, err = db.AssignKeyOwner(pubkey, tokenConsumerID("alice-token"))
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, sign("alice-token").Code)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, sign("bob-token").Code)
+}
@@ -1,15 +1,30 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
 	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
 	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
 	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+	"github.com/qiaopengjun5162/web3-wallet-sign/tracing"
 )
 
 func (s *RpcServer) GetSupportSignWay(_ context.Context, in *wallet.SupportSignWayRequest) (*wallet.SupportSignWayResponse, error) {
@@ -28,23 +43,71 @@ func (s *RpcServer) GetSupportSignWay(_ context.Context, in *wallet.SupportSignW
 	return resp, nil
 }
 
-func (s *RpcServer) ExportPublicKeyList(_ context.Context, in *wallet.ExportPublicKeyRequest) (*wallet.ExportPublicKeyResponse, error) {
+func (s *RpcServer) ExportPublicKeyList(ctx context.Context, in *wallet.ExportPublicKeyRequest) (*wallet.ExportPublicKeyResponse, error) {
 	resp := &wallet.ExportPublicKeyResponse{
 		Code: wallet.ReturnCode_ERROR,
 	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
 	cryptoType, err := protobuf.ParseTransactionType(in.Type)
 	if err != nil {
 		resp.Msg = "input type error"
 		return resp, nil
 	}
-	if in.Number > 10000 {
-		resp.Msg = "Number must be less than 100000"
+	if in.Number > MaxExportKeysNumber {
+		resp.Msg = fmt.Sprintf("number must not be greater than %d", MaxExportKeysNumber)
+		return resp, nil
+	}
+	if len(in.RequestId) > MaxRequestIDLength {
+		resp.Msg = fmt.Sprintf("request id must not be longer than %d", MaxRequestIDLength)
 		return resp, nil
 	}
 
+	// A retry with the same request id returns the keys of the first request, concurrent retries run one after another
+	var request *leveldb.KeyRequest
+	if in.RequestId != "" {
+		unlock := s.requestLocks.lock(c.id + "\x00" + in.RequestId)
+		defer unlock()
+		now := time.Now()
+		request = &leveldb.KeyRequest{
+			RequestID: in.RequestId,
+			Consumer:  c.id,
+			Type:      string(cryptoType),
+			Number:    int(in.Number),
+			Labels:    in.Labels,
+			CreatedAt: now.Unix(),
+		}
+		previous, isOk, err := s.db.GetKeyRequest(c.id, in.RequestId)
+		if err != nil {
+			log.Error("get key request fail", "err", err)
+			return nil, err
+		}
+		if isOk && s.isKeyRequestReplay(previous, now) {
+			if !previous.SameParams(request) {
+				resp.Msg = "request id was already used with different parameters"
+				return resp, nil
+			}
+			for _, key := range previous.Keys {
+				resp.PublicKey = append(resp.PublicKey, &wallet.PublicKey{
+					CompressPubkey: key.CompressPubkey,
+					Pubkey:         key.Pubkey,
+				})
+			}
+			resp.Code = wallet.ReturnCode_SUCCESS
+			resp.Msg = "create keys success"
+			resp.Replayed = true
+			return resp, nil
+		}
+	}
+
 	var keyList []leveldb.Key
 	var retKeyList []*wallet.PublicKey
 
+	_, span := tracer.Start(ctx, "crypto.CreateKeyPairs", trace.WithAttributes(attribute.String("crypto.type", string(cryptoType)), attribute.Int64("keys", int64(in.Number))))
 	for counter := 0; counter < int(in.Number); counter++ {
 		var priKeyStr, pubKeyStr, compressPubkeyStr string
 		var err error
@@ -55,17 +118,27 @@ func (s *RpcServer) ExportPublicKeyList(_ context.Context, in *wallet.ExportPubl
 		case protobuf.EDDSA:
 			priKeyStr, pubKeyStr, err = ssm.CreateEdDSAKeyPair()
 			compressPubkeyStr = pubKeyStr
+		case protobuf.BLS:
+			priKeyStr, pubKeyStr, err = ssm.CreateBLSKeyPair()
+			compressPubkeyStr = pubKeyStr
 		default:
-			return nil, errors.New("unsupported key type")
+			err = errors.New("unsupported key type")
 		}
 		if err != nil {
 			log.Error("create key pair fail", "err", err)
+			tracing.End(span, err)
 			return nil, err
 		}
 
 		keyItem := leveldb.Key{
-			PrivateKey: priKeyStr,
-			Pubkey:     pubKeyStr,
+			PrivateKey:     priKeyStr,
+			Pubkey:         pubKeyStr,
+			CompressPubkey: compressPubkeyStr,
+			Type:           string(cryptoType),
+			Consumer:       c.id,
+			Labels:         in.Labels,
+			Backend:        leveldb.BackendLocal,
+			Origin:         leveldb.OriginGenerated,
 		}
 		pukItem := &wallet.PublicKey{
 			CompressPubkey: compressPubkeyStr,
@@ -74,48 +147,612 @@ func (s *RpcServer) ExportPublicKeyList(_ context.Context, in *wallet.ExportPubl
 		retKeyList = append(retKeyList, pukItem)
 		keyList = append(keyList, keyItem)
 	}
-	isOk := s.db.StoreKeys(keyList)
-	if !isOk {
-		log.Error("store keys fail", "isOk", isOk)
-		return nil, errors.New("store keys fail")
+	span.End()
+	_, span = tracer.Start(ctx, "keystore.StoreKeys", trace.WithAttributes(attribute.Int("keys", len(keyList))))
+	if request != nil {
+		for _, key := range retKeyList {
+			request.Keys = append(request.Keys, leveldb.RequestKey{
+				Pubkey:         key.Pubkey,
+				CompressPubkey: key.CompressPubkey,
+			})
+		}
+		err = s.db.StoreRequestKeys(request, keyList)
+	} else {
+		err = s.db.StoreKeys(keyList)
 	}
+	tracing.End(span, err)
+	if err != nil {
+		// the keys are written in one batch, so none of them was saved
+		log.Error("store keys fail", "err", err)
+		resp.Msg = "store keys fail, no key was created: " + err.Error()
+		return resp, nil
+	}
+	s.metrics.RecordKeysCreated(string(cryptoType), leveldb.OriginGenerated, len(keyList))
 	resp.Code = wallet.ReturnCode_SUCCESS
 	resp.Msg = "create keys success"
 	resp.PublicKey = retKeyList
 	return resp, nil
 }
 
-func (s *RpcServer) SignTxMessage(_ context.Context, in *wallet.SignTxMessageRequest) (*wallet.SignTxMessageResponse, error) {
+func (s *RpcServer) SignTxMessage(ctx context.Context, in *wallet.SignTxMessageRequest) (*wallet.SignTxMessageResponse, error) {
 	resp := &wallet.SignTxMessageResponse{
 		Code: wallet.ReturnCode_ERROR,
 	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
 	cryptoType, err := protobuf.ParseTransactionType(in.Type)
 	if err != nil {
 		resp.Msg = "input type error"
 		return resp, nil
 	}
 
-	privateKey, isOk := s.db.GetPrivateKey(in.PublicKey)
+	signed, err := s.signMessage(ctx, c, cryptoType, &signInput{
+		publicKey:    in.PublicKey,
+		messageHash:  in.MessageHash,
+		unsignedTx:   in.UnsignedTx,
+		chainID:      in.ChainId,
+		unsignedPSBT: in.UnsignedPsbt,
+		inputIndex:   in.InputIndex,
+		network:      in.Network,
+	})
+	if err != nil {
+		if code := signErrorCode(err); code != wallet.ReturnCode_ERROR || errors.Is(err, errInvalidUnsignedTx) || errors.Is(err, errKeyTypeMismatch) {
+			resp.Code = code
+			resp.Msg = err.Error()
+			return resp, nil
+		}
+		return nil, err
+	}
+	if signed.approvalRequestID != "" {
+		resp.Code = wallet.ReturnCode_PENDING_APPROVAL
+		resp.Msg = pendingApprovalMsg(signed.approvalRequestID)
+		resp.ApprovalRequestId = signed.approvalRequestID
+		return resp, nil
+	}
+	resp.Msg = "sign tx message success"
+	resp.Signature = signed.signature
+	resp.SignedTx = signed.signedTx
+	resp.Code = wallet.ReturnCode_SUCCESS
+	return resp, nil
+}
+
+func (s *RpcServer) BatchSignTxMessage(ctx context.Context, in *wallet.BatchSignTxMessageRequest) (*wallet.BatchSignTxMessageResponse, error) {
+	resp := &wallet.BatchSignTxMessageResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
 	if !isOk {
-		return nil, errors.New("get private key by public key fail")
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	if len(in.Items) == 0 {
+		resp.Msg = "items must not be empty"
+		return resp, nil
+	}
+	if len(in.Items) > MaxBatchSignItems {
+		resp.Msg = fmt.Sprintf("items must be at most %d", MaxBatchSignItems)
+		return resp, nil
 	}
 
-	var signature string
-	var err2 error
+	workers := s.BatchSignWorkers
+	if workers <= 0 {
+		workers = DefaultBatchSignWorkers
+	}
+
+	results := make([]*wallet.SignTxMessageResult, len(in.Items))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, item := range in.Items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = &wallet.SignTxMessageResult{Index: uint64(i), Code: wallet.ReturnCode_ERROR, Msg: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, item *wallet.SignTxMessageItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = s.signItem(ctx, c, i, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Code != wallet.ReturnCode_SUCCESS {
+			failed++
+		}
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = fmt.Sprintf("batch sign tx message done, success = %d, failed = %d", len(results)-failed, failed)
+	resp.Results = results
+	return resp, nil
+}
+
+func (s *RpcServer) VerifySignature(_ context.Context, in *wallet.VerifySignatureRequest) (*wallet.VerifySignatureResponse, error) {
+	resp := &wallet.VerifySignatureResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	cryptoType, err := protobuf.ParseTransactionType(in.Type)
+	if err != nil {
+		resp.Msg = "input type error"
+		return resp, nil
+	}
 
+	publicKey, err := decodeHex(in.PublicKey)
+	if err != nil {
+		resp.Msg = "invalid public key: " + err.Error()
+		return resp, nil
+	}
+	messageHash, err := decodeHex(in.MessageHash)
+	if err != nil {
+		resp.Msg = "invalid message hash: " + err.Error()
+		return resp, nil
+	}
+	signature, err := decodeHex(in.Signature)
+	if err != nil {
+		resp.Msg = "invalid signature: " + err.Error()
+		return resp, nil
+	}
+	// the lengths are checked first, ed25519.Verify panics on a public key of another length
+	var publicKeySizes, signatureSizes []int
 	switch cryptoType {
 	case protobuf.ECDSA:
-		signature, err2 = ssm.SignECDSAMessage(privateKey, in.MessageHash)
+		// a 65-byte signature carries a recovery id, which is not checked
+		publicKeySizes, signatureSizes = []int{33, 65}, []int{64, crypto.SignatureLength}
 	case protobuf.EDDSA:
-		signature, err2 = ssm.SignEdDSAMessage(privateKey, in.MessageHash)
+		publicKeySizes, signatureSizes = []int{ed25519.PublicKeySize}, []int{ed25519.SignatureSize}
+	case protobuf.BLS:
+		publicKeySizes, signatureSizes = []int{48}, []int{96}
 	default:
 		return nil, errors.New("unsupported key type")
 	}
-	if err2 != nil {
-		return nil, err2
+	if !slices.Contains(publicKeySizes, len(publicKey)) {
+		resp.Msg = fmt.Sprintf("invalid public key length %d", len(publicKey))
+		return resp, nil
 	}
-	resp.Msg = "sign tx message success"
-	resp.Signature = signature
+	if !slices.Contains(signatureSizes, len(signature)) {
+		resp.Msg = fmt.Sprintf("invalid signature length %d", len(signature))
+		return resp, nil
+	}
+
+	var valid bool
+	switch cryptoType {
+	case protobuf.ECDSA:
+		valid, err = ssm.VerifyEcdsaSignature(hex.EncodeToString(publicKey), hex.EncodeToString(messageHash), hex.EncodeToString(signature))
+		if err != nil {
+			resp.Msg = "verify signature fail: " + err.Error()
+			return resp, nil
+		}
+	case protobuf.EDDSA:
+		valid = ssm.VerifyEdDSASign(hex.EncodeToString(publicKey), hex.EncodeToString(messageHash), hex.EncodeToString(signature))
+	case protobuf.BLS:
+		valid = ssm.VerifyBLSSignature(hex.EncodeToString(publicKey), hex.EncodeToString(messageHash), hex.EncodeToString(signature))
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "verify signature success"
+	resp.Valid = valid
+	return resp, nil
+}
+
+func (s *RpcServer) RecoverPublicKey(_ context.Context, in *wallet.RecoverPublicKeyRequest) (*wallet.RecoverPublicKeyResponse, error) {
+	resp := &wallet.RecoverPublicKeyResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	pubKeyStr, compressPubkeyStr, address, err := ssm.RecoverEcdsaPublicKey(in.MessageHash, in.Signature)
+	if err != nil {
+		resp.Msg = "recover public key fail: " + err.Error()
+		return resp, nil
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "recover public key success"
+	resp.PublicKey = &wallet.PublicKey{
+		CompressPubkey: compressPubkeyStr,
+		Pubkey:         pubKeyStr,
+	}
+	resp.Address = address
+	return resp, nil
+}
+
+func (s *RpcServer) ListKeys(_ context.Context, in *wallet.ListKeysRequest) (*wallet.ListKeysResponse, error) {
+	resp := &wallet.ListKeysResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	if in.Type != "" {
+		if _, err := protobuf.ParseTransactionType(in.Type); err != nil {
+			resp.Msg = "input type error"
+			return resp, nil
+		}
+	}
+	pageSize := int(in.PageSize)
+	if pageSize <= 0 {
+		pageSize = DefaultListKeysPageSize
+	}
+	if pageSize > MaxListKeysPageSize {
+		resp.Msg = fmt.Sprintf("page size must be at most %d", MaxListKeysPageSize)
+		return resp, nil
+	}
+
+	filter := leveldb.KeyFilter{
+		Type:          in.Type,
+		CreatedAfter:  in.CreatedAfter,
+		CreatedBefore: in.CreatedBefore,
+		Label:         in.Label,
+		Consumer:      in.Consumer,
+		Status:        in.Status,
+	}
+	// the admin lists every key, other consumers only the keys they may use
+	var metas []*leveldb.KeyMeta
+	var nextPageToken string
+	var err error
+	if c.admin {
+		metas, nextPageToken, err = s.db.ListKeys(filter, in.PageToken, pageSize)
+	} else {
+		metas, nextPageToken, err = s.db.ListConsumerKeys(c.id, filter, in.PageToken, pageSize)
+	}
+	if err != nil {
+		log.Error("list keys fail", "err", err)
+		return nil, err
+	}
+	for _, meta := range metas {
+		resp.Keys = append(resp.Keys, toKeyInfo(meta))
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "list keys success"
+	resp.NextPageToken = nextPageToken
+	return resp, nil
+}
+
+func (s *RpcServer) GetKey(_ context.Context, in *wallet.GetKeyRequest) (*wallet.GetKeyResponse, error) {
+	resp := &wallet.GetKeyResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	meta, isOk, err := s.visibleKeyMeta(c, in.PublicKey)
+	if err != nil {
+		log.Error("get key fail", "err", err)
+		return nil, err
+	}
+	if !isOk {
+		resp.Msg = "key not found"
+		return resp, nil
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "get key success"
+	resp.Key = toKeyInfo(meta)
+	return resp, nil
+}
+
+func (s *RpcServer) HasKey(_ context.Context, in *wallet.HasKeyRequest) (*wallet.HasKeyResponse, error) {
+	resp := &wallet.HasKeyResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	_, exist, err := s.visibleKeyMeta(c, in.PublicKey)
+	if err != nil {
+		log.Error("check key exist fail", "err", err)
+		return nil, err
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "check key exist success"
+	resp.Exist = exist
+	return resp, nil
+}
+
+func (s *RpcServer) UpdateKeyStatus(_ context.Context, in *wallet.UpdateKeyStatusRequest) (*wallet.UpdateKeyStatusResponse, error) {
+	resp := &wallet.UpdateKeyStatusResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	switch in.Status {
+	case leveldb.KeyStatusActive, leveldb.KeyStatusDisabled, leveldb.KeyStatusArchived, leveldb.KeyStatusPendingDestruction:
+	default:
+		resp.Msg = "input status error"
+		return resp, nil
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	meta, isOk, err := s.visibleKeyMeta(c, in.PublicKey)
+	if err != nil {
+		log.Error("get key fail", "err", err)
+		return nil, err
+	}
+	if !isOk {
+		resp.Msg = "key not found"
+		return resp, nil
+	}
+	if !s.canManageKey(c, meta) {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "only the key creator or admin can update key status"
+		return resp, nil
+	}
+
+	destroyAt := time.Now().Add(s.keyDestructionDelay()).Unix()
+	meta, err = s.db.SetKeyStatus(in.PublicKey, in.Status, destroyAt)
+	if errors.Is(err, leveldb.ErrInvalidKeyStatus) || errors.Is(err, leveldb.ErrKeyNotFound) {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
+	if err != nil {
+		log.Error("update key status fail", "err", err)
+		return nil, err
+	}
+	log.Info("update key status", "key", in.PublicKey, "status", in.Status)
 	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "update key status success"
+	resp.Key = toKeyInfo(meta)
 	return resp, nil
 }
+
+// decodeHex decodes a hex string with or without 0x.
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+func toKeyInfo(meta *leveldb.KeyMeta) *wallet.KeyInfo {
+	return &wallet.KeyInfo{
+		PublicKey: &wallet.PublicKey{
+			CompressPubkey: meta.CompressPubkey,
+			Pubkey:         meta.Pubkey,
+		},
+		Type:           meta.Type,
+		CreatedAt:      meta.CreatedAt,
+		Consumer:       meta.Consumer,
+		Labels:         meta.Labels,
+		Backend:        meta.Backend,
+		DerivationPath: meta.DerivationPath,
+		Status:         meta.Status,
+		DestroyAt:      meta.DestroyAt,
+		Origin:         meta.Origin,
+	}
+}
+
+// signItem signs a single batch item, reporting any failure in the result instead of failing the batch.
+func (s *RpcServer) signItem(ctx context.Context, c *caller, index int, item *wallet.SignTxMessageItem) *wallet.SignTxMessageResult {
+	result := &wallet.SignTxMessageResult{
+		Index: uint64(index),
+		Code:  wallet.ReturnCode_ERROR,
+	}
+	signed, err := s.signRequest(ctx, c, item.Type, &signInput{
+		publicKey:    item.PublicKey,
+		messageHash:  item.MessageHash,
+		unsignedTx:   item.UnsignedTx,
+		chainID:      item.ChainId,
+		unsignedPSBT: item.UnsignedPsbt,
+		inputIndex:   item.InputIndex,
+		network:      item.Network,
+	})
+	if err != nil {
+		result.Code = signErrorCode(err)
+		result.Msg = err.Error()
+		return result
+	}
+	if signed.approvalRequestID != "" {
+		result.Code = wallet.ReturnCode_PENDING_APPROVAL
+		result.Msg = pendingApprovalMsg(signed.approvalRequestID)
+		result.ApprovalRequestId = signed.approvalRequestID
+		return result
+	}
+	result.Code = wallet.ReturnCode_SUCCESS
+	result.Msg = "sign tx message success"
+	result.Signature = signed.signature
+	result.SignedTx = signed.signedTx
+	return result
+}
+
+// signInput is what a sign request asks to sign: a bare message hash, an unsigned EVM
+// transaction or an input of an unsigned bitcoin PSBT whose signing hash is signed.
+type signInput struct {
+	publicKey    string
+	messageHash  string
+	unsignedTx   string
+	chainID      uint64
+	unsignedPSBT string
+	inputIndex   uint32
+	network      string
+	// approved is set when the approvals the policy requires are given
+	approved bool
+}
+
+type signOutput struct {
+	signature string
+	// signedTx is set when an unsigned transaction was signed
+	signedTx string
+	// approvalRequestID is set instead of the signature when the request is held for approval
+	approvalRequestID string
+}
+
+// signRequest parses the CryptoType of a sign request and signs it with the key of in.publicKey.
+func (s *RpcServer) signRequest(ctx context.Context, c *caller, txType string, in *signInput) (*signOutput, error) {
+	cryptoType, err := protobuf.ParseTransactionType(txType)
+	if err != nil {
+		return nil, errors.New("input type error")
+	}
+	return s.signMessage(ctx, c, cryptoType, in)
+}
+
+// signMessage looks up the private key of in.publicKey and signs the message hash of in with it.
+// Keys the caller may not use are refused with errKeyNotAccessible, keys that are not active with errKeyNotActive
+// and requests breaking the transaction policy with a *policy.Violation. Requests the policy requires
+// approvals for are stored as sign requests and signed once approved.
+func (s *RpcServer) signMessage(ctx context.Context, c *caller, cryptoType protobuf.CryptoType, in *signInput) (*signOutput, error) {
+	if in.unsignedTx != "" || in.unsignedPSBT != "" {
+		if in.unsignedTx != "" && in.unsignedPSBT != "" {
+			return nil, fmt.Errorf("%w: unsigned tx and unsigned psbt must not both be set", errInvalidUnsignedTx)
+		}
+		if cryptoType != protobuf.ECDSA {
+			return nil, fmt.Errorf("%w: only ecdsa keys sign transactions", errInvalidUnsignedTx)
+		}
+	}
+	var tx policy.Transaction
+	if in.unsignedTx != "" {
+		evmTx, err := policy.ParseEVMTransaction(in.unsignedTx, in.chainID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidUnsignedTx, err)
+		}
+		tx = evmTx
+	}
+
+	_, span := tracer.Start(ctx, "keystore.GetKeyRecord", trace.WithAttributes(attribute.String("key.pubkey", in.publicKey)))
+	record, isOk := s.db.GetKeyRecord(in.publicKey)
+	if !isOk {
+		err := errors.New("get private key by public key fail")
+		tracing.End(span, err)
+		return nil, err
+	}
+	span.End()
+	usable, err := s.canUseKey(c, &record.KeyMeta)
+	if err != nil {
+		return nil, errors.Wrap(err, "check key access fail")
+	}
+	if !usable {
+		return nil, errKeyNotAccessible
+	}
+	if s.isAuditKey(record.Pubkey) {
+		return nil, errAuditKey
+	}
+	// a key only signs on its own curve, so that one secret is never used on two curves
+	if string(cryptoType) != record.Type {
+		return nil, fmt.Errorf("%w: the key is a %s key, not %s", errKeyTypeMismatch, record.Type, cryptoType)
+	}
+	if cryptoType == protobuf.BLS && !s.fromWeb3Signer(ctx) {
+		return nil, errUnprotectedBLS
+	}
+	if record.Status != leveldb.KeyStatusActive {
+		return nil, fmt.Errorf("%w: %s", errKeyNotActive, record.Status)
+	}
+	// the input of a PSBT is bound to the key, it is parsed once the key is known
+	if in.unsignedPSBT != "" {
+		btcTx, err := policy.ParseBitcoinPSBT(in.unsignedPSBT, in.inputIndex, in.network, common.FromHex(record.CompressPubkey))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidUnsignedTx, err)
+		}
+		tx = btcTx
+	}
+
+	messageHash := in.messageHash
+	if tx != nil {
+		signingHash := tx.SigningHash()
+		if messageHash != "" && !bytes.Equal(common.FromHex(messageHash), signingHash) {
+			return nil, fmt.Errorf("%w: message hash is not the signing hash %s", errInvalidUnsignedTx, hexutil.Encode(signingHash))
+		}
+		messageHash = hexutil.Encode(signingHash)
+	}
+
+	if s.policy != nil {
+		request := &policy.Request{
+			Key:      policy.KeyInfo{Pubkey: record.Pubkey, Consumer: record.Consumer, Labels: record.Labels},
+			Consumer: c.id,
+			Approved: in.approved,
+			Time:     time.Now(),
+		}
+		if tx != nil {
+			request.Intent = tx.Intent()
+		}
+		_, span := tracer.Start(ctx, "policy.Authorize")
+		auth, err := s.policy.Authorize(request)
+		tracing.End(span, err)
+		if err != nil {
+			var violation *policy.Violation
+			if errors.As(err, &violation) {
+				s.metrics.RecordPolicyRejection(violation.Rule)
+			}
+			return nil, err
+		}
+		if len(auth.Approvals) > 0 {
+			id, err := s.holdSignRequest(ctx, c, cryptoType, record, messageHash, tx, in, auth.Approvals)
+			if err != nil {
+				return nil, err
+			}
+			return &signOutput{approvalRequestID: id}, nil
+		}
+		out, err := s.sign(ctx, cryptoType, record, messageHash, tx)
+		if err != nil {
+			// no signature is produced, the amount is not counted against the window limits
+			auth.Release()
+		}
+		return out, err
+	}
+	return s.sign(ctx, cryptoType, record, messageHash, tx)
+}
+
+// sign signs messageHash with the private key of record, and tx with the signature when it is not nil.
+func (s *RpcServer) sign(ctx context.Context, cryptoType protobuf.CryptoType, record *leveldb.KeyRecord, messageHash string, tx policy.Transaction) (*signOutput, error) {
+	_, span := tracer.Start(ctx, "keystore.DecryptPrivateKey")
+	privateKey, err := s.db.DecryptPrivateKey(record)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt private key fail")
+	}
+
+	out := &signOutput{}
+	_, span = tracer.Start(ctx, "crypto.Sign", trace.WithAttributes(attribute.String("crypto.type", string(cryptoType)), attribute.String("key.backend", record.Backend)))
+	switch cryptoType {
+	case protobuf.ECDSA:
+		out.signature, err = ssm.SignECDSAMessage(privateKey, messageHash)
+	case protobuf.EDDSA:
+		out.signature, err = ssm.SignEdDSAMessage(privateKey, messageHash)
+	case protobuf.BLS:
+		out.signature, err = ssm.SignBLSMessage(privateKey, messageHash)
+	default:
+		err = errors.New("unsupported key type")
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.RecordSignature(string(cryptoType), record.Backend)
+	if tx == nil {
+		return out, nil
+	}
+	signature, err := hex.DecodeString(out.signature)
+	if err != nil {
+		return nil, err
+	}
+	if out.signedTx, err = tx.Sign(signature); err != nil {
+		return nil, errors.Wrap(err, "assemble signed tx fail")
+	}
+	return out, nil
+}
+
+// signErrorCode maps an error returned by signMessage to the ReturnCode reported to the caller.
+func signErrorCode(err error) wallet.ReturnCode {
+	var violation *policy.Violation
+	switch {
+	case errors.Is(err, errKeyNotActive):
+		return wallet.ReturnCode_KEY_NOT_ACTIVE
+	case errors.Is(err, errKeyNotAccessible):
+		return wallet.ReturnCode_PERMISSION_DENIED
+	case errors.As(err, &violation):
+		return wallet.ReturnCode_POLICY_VIOLATION
+	default:
+		return wallet.ReturnCode_ERROR
+	}
+}
@@ -0,0 +1,381 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/services/web3signer"
+	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+)
+
+func listKeyPubkeys(t *testing.T, s *RpcServer, consumerToken string) []string {
+	resp, err := s.ListKeys(context.Background(), &wallet.ListKeysRequest{ConsumerToken: consumerToken})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	var pubkeys []string
+	for _, key := range resp.Keys {
+		pubkeys = append(pubkeys, key.PublicKey.Pubkey)
+	}
+	return pubkeys
+}
+
+func TestListKeysScope(t *testing.T) {
+	ctx := context.Background()
+	db := newTestKeys(t)
+	assert.NoError(t, db.StoreKeys([]leveldb.Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: tokenConsumerID("alice-token"), Origin: leveldb.OriginGenerated},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "ecdsa", Consumer: tokenConsumerID("bob-token"), Origin: leveldb.OriginGenerated},
+		{PrivateKey: "03", Pubkey: "aa03", Type: "ecdsa", Consumer: tokenConsumerID("alice-token"), Origin: leveldb.OriginGenerated},
+		{PrivateKey: "04", Pubkey: "aa04", Type: "ecdsa", Origin: leveldb.OriginLegacy},
+		{PrivateKey: "05", Pubkey: "aa05", Type: "ecdsa", Origin: leveldb.OriginImported},
+	}))
+	assert.NoError(t, db.GrantKey("aa03", tokenConsumerID("bob-token")))
+	s := newTestServer(t, &RpcServerConfig{AdminToken: "admin-token"}, db)
+
+	// the own and granted keys only, the keys without owner are the admin's
+	assert.Equal(t, []string{"aa01", "aa03"}, listKeyPubkeys(t, s, "alice-token"))
+	assert.Equal(t, []string{"aa02", "aa03"}, listKeyPubkeys(t, s, "bob-token"))
+	assert.Empty(t, listKeyPubkeys(t, s, "carol-token"))
+	assert.Equal(t, []string{"aa01", "aa02", "aa03", "aa04", "aa05"}, listKeyPubkeys(t, s, "admin-token"))
+
+	resp, err := s.ListKeys(ctx, &wallet.ListKeysRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+	resp, err = s.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "alice-token", PageSize: MaxListKeysPageSize + 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "page size must be at most 1000", resp.Msg)
+
+	for _, pubkey := range []string{"aa01", "aa04", "aa05"} {
+		key, err := s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "carol-token", PublicKey: pubkey})
+		assert.NoError(t, err)
+		assert.Equal(t, "key not found", key.Msg)
+		exist, err := s.HasKey(ctx, &wallet.HasKeyRequest{ConsumerToken: "carol-token", PublicKey: pubkey})
+		assert.NoError(t, err)
+		assert.False(t, exist.Exist)
+	}
+	key, err := s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "bob-token", PublicKey: "aa03"})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, key.Code)
+	assert.Equal(t, tokenConsumerID("alice-token"), key.Key.Consumer)
+}
+
+func TestListKeys(t *testing.T) {
+	ctx := context.Background()
+	db := newTestKeys(t)
+	assert.NoError(t, db.StoreKeys([]leveldb.Key{
+		{PrivateKey: "01", Pubkey: "aa01", Type: "ecdsa", Consumer: tokenConsumerID("alice-token"), Labels: []string{"hot"}},
+		{PrivateKey: "02", Pubkey: "aa02", Type: "eddsa", Consumer: tokenConsumerID("bob-token")},
+		{PrivateKey: "03", Pubkey: "aa03", Type: "ecdsa", Consumer: tokenConsumerID("alice-token")},
+		{PrivateKey: "04", Pubkey: "aa04", Type: "ecdsa", Consumer: tokenConsumerID("bob-token"), Labels: []string{"cold", "hot"}},
+		{PrivateKey: "05", Pubkey: "aa05", Type: "ecdsa", Consumer: tokenConsumerID("alice-token")},
+	}))
+	s := newTestServer(t, &RpcServerConfig{AdminToken: "admin-token"}, db)
+
+	// the admin lists every key
+	list := func(in *wallet.ListKeysRequest) []string {
+		in.ConsumerToken = "admin-token"
+		resp, err := s.ListKeys(ctx, in)
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+		var pubkeys []string
+		for _, key := range resp.Keys {
+			pubkeys = append(pubkeys, key.PublicKey.Pubkey)
+		}
+		return pubkeys
+	}
+	assert.Equal(t, []string{"aa01", "aa02", "aa03", "aa04", "aa05"}, list(&wallet.ListKeysRequest{}))
+	assert.Equal(t, []string{"aa02"}, list(&wallet.ListKeysRequest{Type: "eddsa"}))
+	assert.Equal(t, []string{"aa01", "aa04"}, list(&wallet.ListKeysRequest{Label: "hot"}))
+	assert.Equal(t, []string{"aa01", "aa03", "aa05"}, list(&wallet.ListKeysRequest{Consumer: tokenConsumerID("alice-token")}))
+	assert.Empty(t, list(&wallet.ListKeysRequest{CreatedAfter: time.Now().Add(time.Hour).Unix()}))
+
+	// the pages follow each other until the next page token is empty
+	var pubkeys []string
+	var pageToken string
+	for pages := 0; ; pages++ {
+		resp, err := s.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "admin-token", Consumer: tokenConsumerID("alice-token"), PageSize: 2, PageToken: pageToken})
+		assert.NoError(t, err)
+		for _, key := range resp.Keys {
+			pubkeys = append(pubkeys, key.PublicKey.Pubkey)
+		}
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			assert.Equal(t, 1, pages)
+			break
+		}
+	}
+	assert.Equal(t, []string{"aa01", "aa03", "aa05"}, pubkeys)
+
+	resp, err := s.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "admin-token", Type: "rsa"})
+	assert.NoError(t, err)
+	assert.Equal(t, "input type error", resp.Msg)
+	resp, err = s.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "admin-token", PageSize: MaxListKeysPageSize + 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "page size must be at most 1000", resp.Msg)
+
+	key, err := s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "admin-token", PublicKey: "aa04"})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, key.Code)
+	assert.Equal(t, tokenConsumerID("bob-token"), key.Key.Consumer)
+	assert.Equal(t, []string{"cold", "hot"}, key.Key.Labels)
+	key, err = s.GetKey(ctx, &wallet.GetKeyRequest{ConsumerToken: "admin-token", PublicKey: "aa06"})
+	assert.NoError(t, err)
+	assert.Equal(t, "key not found", key.Msg)
+	for pubkey, exist := range map[string]bool{"aa01": true, "aa06": false} {
+		resp, err := s.HasKey(ctx, &wallet.HasKeyRequest{ConsumerToken: "admin-token", PublicKey: pubkey})
+		assert.NoError(t, err)
+		assert.Equal(t, exist, resp.Exist, pubkey)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{}, newTestKeys(t))
+	messageHash := "0x" + strings.Repeat("ab", 32)
+
+	ecdsaPrivateKey, ecdsaPubkey, _, err := ssm.CreateECDSAKeyPair()
+	assert.NoError(t, err)
+	ecdsaSignature, err := ssm.SignECDSAMessage(ecdsaPrivateKey, messageHash)
+	assert.NoError(t, err)
+	eddsaPrivateKey, eddsaPubkey, err := ssm.CreateEdDSAKeyPair()
+	assert.NoError(t, err)
+	eddsaSignature, err := ssm.SignEdDSAMessage(eddsaPrivateKey, strings.TrimPrefix(messageHash, "0x"))
+	assert.NoError(t, err)
+	blsPrivateKey, blsPubkey, err := ssm.CreateBLSKeyPair()
+	assert.NoError(t, err)
+	blsSignature, err := ssm.SignBLSMessage(blsPrivateKey, messageHash)
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		cryptoType, pubkey, signature string
+	}{
+		{"ecdsa", ecdsaPubkey, ecdsaSignature},
+		{"eddsa", eddsaPubkey, eddsaSignature},
+		{"bls", blsPubkey, blsSignature},
+	} {
+		// every field is accepted with and without 0x
+		for _, prefix := range []string{"", "0x"} {
+			resp, err := s.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+				Type:        test.cryptoType,
+				PublicKey:   prefix + test.pubkey,
+				MessageHash: messageHash,
+				Signature:   prefix + test.signature,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, test.cryptoType)
+			assert.True(t, resp.Valid, test.cryptoType)
+		}
+
+		resp, err := s.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+			Type:        test.cryptoType,
+			PublicKey:   test.pubkey[2:],
+			MessageHash: messageHash,
+			Signature:   test.signature,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_ERROR, resp.Code)
+		assert.Contains(t, resp.Msg, "invalid public key length")
+		resp, err = s.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+			Type:        test.cryptoType,
+			PublicKey:   test.pubkey,
+			MessageHash: messageHash,
+			Signature:   test.signature[4:],
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_ERROR, resp.Code)
+		assert.Contains(t, resp.Msg, "invalid signature length")
+		resp, err = s.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+			Type:        test.cryptoType,
+			PublicKey:   test.pubkey,
+			MessageHash: "0xzz",
+			Signature:   test.signature,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_ERROR, resp.Code)
+		assert.Contains(t, resp.Msg, "invalid message hash")
+	}
+}
+
+func TestBatchSignTxMessage(t *testing.T) {
+	ctx := context.Background()
+	for _, workers := range []int{1, 4} {
+		s := newTestServer(t, &RpcServerConfig{BatchSignWorkers: workers}, newTestKeys(t))
+		client := dialTestServer(t, s)
+		pubkeys := exportKeys(t, client, "alice-token", "ecdsa", 3)
+		bobPubkey := exportKeys(t, client, "bob-token", "ecdsa", 1)[0]
+		status, err := client.UpdateKeyStatus(ctx, &wallet.UpdateKeyStatusRequest{ConsumerToken: "alice-token", PublicKey: pubkeys[2], Status: "disabled"})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, status.Code)
+
+		var items []*wallet.SignTxMessageItem
+		for i := 0; i < 20; i++ {
+			items = append(items, &wallet.SignTxMessageItem{
+				Type:        "ecdsa",
+				PublicKey:   pubkeys[i%2],
+				MessageHash: fmt.Sprintf("%064x", i),
+			})
+		}
+		items = append(items,
+			&wallet.SignTxMessageItem{Type: "ecdsa", PublicKey: strings.Repeat("ab", 65), MessageHash: fmt.Sprintf("%064x", 1)},
+			&wallet.SignTxMessageItem{Type: "ecdsa", PublicKey: bobPubkey, MessageHash: fmt.Sprintf("%064x", 1)},
+			&wallet.SignTxMessageItem{Type: "ecdsa", PublicKey: pubkeys[2], MessageHash: fmt.Sprintf("%064x", 1)},
+			&wallet.SignTxMessageItem{Type: "rsa", PublicKey: pubkeys[0], MessageHash: fmt.Sprintf("%064x", 1)},
+		)
+		resp, err := client.BatchSignTxMessage(ctx, &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: items})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+		assert.Equal(t, "batch sign tx message done, success = 20, failed = 4", resp.Msg)
+		assert.Len(t, resp.Results, len(items))
+		for i, result := range resp.Results {
+			// the results are in the order of the items whatever the order they are signed in
+			assert.Equal(t, uint64(i), result.Index)
+			if i >= 20 {
+				continue
+			}
+			assert.Equal(t, wallet.ReturnCode_SUCCESS, result.Code)
+			verify, err := client.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+				Type:        "ecdsa",
+				PublicKey:   items[i].PublicKey,
+				MessageHash: items[i].MessageHash,
+				Signature:   result.Signature,
+			})
+			assert.NoError(t, err)
+			assert.True(t, verify.Valid)
+		}
+		// a failed item does not fail the batch
+		assert.Equal(t, wallet.ReturnCode_ERROR, resp.Results[20].Code)
+		assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Results[21].Code)
+		assert.Equal(t, wallet.ReturnCode_KEY_NOT_ACTIVE, resp.Results[22].Code)
+		assert.Equal(t, wallet.ReturnCode_ERROR, resp.Results[23].Code)
+		assert.Equal(t, "input type error", resp.Results[23].Msg)
+	}
+}
+
+func TestBatchSignTxMessageItems(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	resp, err := client.BatchSignTxMessage(ctx, &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "items must not be empty", resp.Msg)
+	items := make([]*wallet.SignTxMessageItem, MaxBatchSignItems+1)
+	for i := range items {
+		items[i] = &wallet.SignTxMessageItem{}
+	}
+	resp, err = client.BatchSignTxMessage(ctx, &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: items})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("items must be at most %d", MaxBatchSignItems), resp.Msg)
+
+	// the items not started when the call is canceled fail with the error of the context
+	pubkey := exportKeys(t, client, "alice-token", "ecdsa", 1)[0]
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	items = []*wallet.SignTxMessageItem{{Type: "ecdsa", PublicKey: pubkey, MessageHash: fmt.Sprintf("%064x", 1)}}
+	resp, err = s.BatchSignTxMessage(canceled, &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: append(items, items[0], items[0])})
+	assert.NoError(t, err)
+	for i, result := range resp.Results {
+		assert.Equal(t, uint64(i), result.Index)
+		if result.Code != wallet.ReturnCode_SUCCESS {
+			assert.Equal(t, context.Canceled.Error(), result.Msg)
+		}
+	}
+}
+
+func TestUpdateKeyStatus(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{AdminToken: "admin-token", KeyDestructionDelay: time.Hour}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkey := exportKeys(t, client, "alice-token", "ecdsa", 1)[0]
+	access, err := client.GrantKeyAccess(ctx, &wallet.KeyAccessRequest{ConsumerToken: "alice-token", PublicKey: pubkey, Grantee: tokenConsumerID("bob-token")})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, access.Code)
+
+	update := func(consumerToken, status string) *wallet.UpdateKeyStatusResponse {
+		resp, err := client.UpdateKeyStatus(ctx, &wallet.UpdateKeyStatusRequest{ConsumerToken: consumerToken, PublicKey: pubkey, Status: status})
+		assert.NoError(t, err)
+		return resp
+	}
+	// a grantee may sign with the key but not manage it
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, update("bob-token", leveldb.KeyStatusDisabled).Code)
+	assert.Equal(t, "key not found", update("carol-token", leveldb.KeyStatusDisabled).Msg)
+	assert.Equal(t, "input status error", update("alice-token", leveldb.KeyStatusDestroyed).Msg)
+
+	resp := update("alice-token", leveldb.KeyStatusPendingDestruction)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), resp.Key.DestroyAt, 5)
+	resp = update("alice-token", leveldb.KeyStatusActive)
+	assert.Equal(t, wallet.ReturnCode_ERROR, resp.Code)
+	assert.Contains(t, resp.Msg, "invalid key status transition")
+	sign, err := client.SignTxMessage(ctx, &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "ecdsa", PublicKey: pubkey, MessageHash: fmt.Sprintf("%064x", 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_KEY_NOT_ACTIVE, sign.Code)
+
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, update("admin-token", leveldb.KeyStatusDisabled).Code)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, update("alice-token", leveldb.KeyStatusActive).Code)
+}
+
+func TestSignTxMessageBLS(t *testing.T) {
+	s := newTestServer(t, &RpcServerConfig{Web3SignerEnabled: true}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkey := exportKeys(t, client, "alice-token", "bls", 1)[0]
+	messageHash := "0x" + strings.Repeat("ab", 32)
+
+	// the BLS keys only sign for the web3signer server, which checks the slashing protection first
+	resp, err := client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "bls", PublicKey: pubkey, MessageHash: messageHash})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+	assert.Equal(t, errUnprotectedBLS.Error(), resp.Msg)
+	batch, err := client.BatchSignTxMessage(t.Context(), &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: []*wallet.SignTxMessageItem{{Type: "bls", PublicKey: pubkey, MessageHash: messageHash}}})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, batch.Results[0].Code)
+	ctx := metadata.AppendToOutgoingContext(t.Context(), web3signer.SignerTokenMetadataKey, "guessed")
+	resp, err = client.SignTxMessage(ctx, &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "bls", PublicKey: pubkey, MessageHash: messageHash})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+
+	ctx = metadata.AppendToOutgoingContext(t.Context(), web3signer.SignerTokenMetadataKey, s.web3SignerToken)
+	resp, err = client.SignTxMessage(ctx, &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "bls", PublicKey: pubkey, MessageHash: messageHash})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	assert.True(t, ssm.VerifyBLSSignature(pubkey, strings.TrimPrefix(messageHash, "0x"), resp.Signature))
+}
+
+func TestSignTxMessageKeyType(t *testing.T) {
+	client := dialTestServer(t, newTestServer(t, &RpcServerConfig{}, newTestKeys(t)))
+	keys := map[string]string{
+		"ecdsa": exportKeys(t, client, "alice-token", "ecdsa", 1)[0],
+		"eddsa": exportKeys(t, client, "alice-token", "eddsa", 1)[0],
+	}
+	messageHash := "0x" + strings.Repeat("ab", 32)
+
+	// a key only signs with the type it was created with
+	for keyType, pubkey := range keys {
+		for _, cryptoType := range []string{"ecdsa", "eddsa", "bls"} {
+			resp, err := client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: cryptoType, PublicKey: pubkey, MessageHash: messageHash})
+			assert.NoError(t, err)
+			if cryptoType == keyType {
+				assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+				continue
+			}
+			assert.Equal(t, wallet.ReturnCode_ERROR, resp.Code)
+			assert.Equal(t, fmt.Sprintf("key type mismatch: the key is a %s key, not %s", keyType, cryptoType), resp.Msg)
+		}
+	}
+	batch, err := client.BatchSignTxMessage(t.Context(), &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: []*wallet.SignTxMessageItem{
+		{Type: "eddsa", PublicKey: keys["ecdsa"], MessageHash: messageHash},
+		{Type: "ecdsa", PublicKey: keys["eddsa"], MessageHash: messageHash},
+	}})
+	assert.NoError(t, err)
+	for _, result := range batch.Results {
+		assert.Equal(t, wallet.ReturnCode_ERROR, result.Code)
+		assert.Contains(t, result.Msg, "key type mismatch")
+	}
+}
@@ -0,0 +1,232 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+var errKeyStoreNotOpen = status.Error(codes.Unavailable, "key store is not open")
+
+// KeyStoreOpener opens the key store the server signs with.
+type KeyStoreOpener func() (*leveldb.Keys, error)
+
+// healthState is what the readiness of the server depends on besides the seal of the key store.
+type healthState struct {
+	mu sync.Mutex
+	// keyStoreErr is the last error met opening the key store
+	keyStoreErr error
+	// hsmErr is the last error met reaching KMS, only checked when HsmEnable is set
+	hsmErr  error
+	serving bool
+}
+
+// openKeyStoreLoop retries to open the key store until it succeeds.
+func (s *RpcServer) openKeyStoreLoop(ctx context.Context) {
+	ticker := time.NewTicker(KeyStoreOpenRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			db, err := s.openKeyStore()
+			if err != nil {
+				log.Warn("open key store fail", "err", err)
+				s.setKeyStoreErr(err)
+				continue
+			}
+			if s.Stopped() {
+				_ = db.Close()
+				return
+			}
+			if err := s.initKeyStore(ctx, db); err != nil {
+				log.Error("init key store fail", "err", err)
+				s.setKeyStoreErr(err)
+			}
+			return
+		}
+	}
+}
+
+// initKeyStore sets up everything that depends on the key store and serves the WalletService.
+func (s *RpcServer) initKeyStore(ctx context.Context, db *leveldb.Keys) error {
+	s.db = db
+	if config, isOk := s.db.SealConfig(); isOk && s.db.Sealed() {
+		log.Warn("key store is sealed, submit unseal shares to serve signing requests", "threshold", config.Threshold, "total", config.Total)
+	}
+	if store, isOk := db.LevelStore(); isOk {
+		s.metrics.RegisterLevelDB(store.DB)
+	}
+	if s.Policy != nil {
+		s.policy = policy.NewEngine(s.Policy, &spendLedger{db: db})
+	}
+	if err := s.reassignConsumers(); err != nil {
+		log.Error("reassign keys to consumer ids fail", "err", err)
+		return err
+	}
+	if s.AuditKey != "" {
+		if err := s.checkAuditKey(); err != nil {
+			log.Error("check audit key fail", "key", s.AuditKey, "err", err)
+			return err
+		}
+		go s.anchorAuditLoop(ctx)
+	}
+	go s.destroyKeysLoop(ctx)
+	go s.expireKeyRequestsLoop(ctx)
+	if s.policy != nil {
+		go s.expireSpendsLoop(ctx)
+		go s.expireSignRequestsLoop(ctx)
+	}
+	s.setKeyStoreErr(nil)
+	s.keyStoreOpen.Store(true)
+	s.updateHealth()
+	return nil
+}
+
+func (s *RpcServer) setKeyStoreErr(err error) {
+	s.healthState.mu.Lock()
+	s.healthState.keyStoreErr = err
+	s.healthState.mu.Unlock()
+	s.updateHealth()
+}
+
+// ready reports whether the server can sign, with the reason when it can not.
+func (s *RpcServer) ready() (bool, string) {
+	s.healthState.mu.Lock()
+	keyStoreErr, hsmErr := s.healthState.keyStoreErr, s.healthState.hsmErr
+	s.healthState.mu.Unlock()
+	switch {
+	case !s.keyStoreOpen.Load():
+		if keyStoreErr != nil {
+			return false, "key store is not open: " + keyStoreErr.Error()
+		}
+		return false, "key store is not open"
+	case s.db.Sealed():
+		return false, "key store is sealed"
+	case s.HsmEnable && hsmErr != nil:
+		return false, "kms is unreachable: " + hsmErr.Error()
+	}
+	return true, ""
+}
+
+// updateHealth reports the readiness of the server to the gRPC health service.
+func (s *RpcServer) updateHealth() {
+	if s.Stopped() {
+		return
+	}
+	serving, reason := s.ready()
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if !serving {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	s.healthState.mu.Lock()
+	changed := s.healthState.serving != serving
+	s.healthState.serving = serving
+	s.healthState.mu.Unlock()
+	s.health.SetServingStatus("", servingStatus)
+	s.health.SetServingStatus(wallet.WalletService_ServiceDesc.ServiceName, servingStatus)
+	if changed && serving {
+		log.Info("rpc service is ready")
+	} else if changed {
+		log.Warn("rpc service is not ready", "reason", reason)
+	}
+}
+
+// healthLoop periodically checks KMS when HsmEnable is set and refreshes the health of the server.
+func (s *RpcServer) healthLoop(ctx context.Context) {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.checkHSM(ctx)
+		s.updateHealth()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+		}
+	}
+}
+
+func (s *RpcServer) checkHSM(ctx context.Context) {
+	if !s.HsmEnable {
+		return
+	}
+	err := errors.New("hsm client is not created")
+	if s.HsmClient != nil {
+		pingCtx, cancel := context.WithTimeout(ctx, HealthCheckInterval/2)
+		err = s.HsmClient.Ping(pingCtx)
+		cancel()
+	}
+	s.healthState.mu.Lock()
+	s.healthState.hsmErr = err
+	s.healthState.mu.Unlock()
+}
+
+// blockedKeyStoreNotOpen reports whether method must be refused because the key store is not open yet.
+func (s *RpcServer) blockedKeyStoreNotOpen(method string) bool {
+	return strings.HasPrefix(method, "/"+wallet.WalletService_ServiceDesc.ServiceName+"/") && !s.keyStoreOpen.Load()
+}
+
+func (s *RpcServer) keyStoreUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.blockedKeyStoreNotOpen(info.FullMethod) {
+		return nil, errKeyStoreNotOpen
+	}
+	return handler(ctx, req)
+}
+
+func (s *RpcServer) keyStoreStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if s.blockedKeyStoreNotOpen(info.FullMethod) {
+		return errKeyStoreNotOpen
+	}
+	return handler(srv, ss)
+}
+
+// startHealthServer serves /healthz, answered as long as the process runs, and /readyz, answered
+// with 503 and the reason while the server can not sign, for the probes that do not speak gRPC.
+func (s *RpcServer) startHealthServer() (*http.Server, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.HealthHostname, strconv.Itoa(s.HealthPort)))
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if serving, reason := s.ready(); !serving {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("health server stopped", "err", err)
+		}
+	}()
+	log.Info("start health server", "addr", listener.Addr())
+	return srv, nil
+}
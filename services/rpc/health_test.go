@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/hsm"
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// fakeKMS answers the Ping of the HSM client, failing while down is set.
+type fakeKMS struct {
+	kmspb.UnimplementedKeyManagementServiceServer
+	down atomic.Bool
+}
+
+func (f *fakeKMS) GetPublicKey(context.Context, *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error) {
+	if f.down.Load() {
+		return nil, status.Error(codes.PermissionDenied, "key is disabled")
+	}
+	return &kmspb.PublicKey{}, nil
+}
+
+// newTestHSMClient returns an HSM client calling f over an in-memory listener.
+func newTestHSMClient(t *testing.T, f *fakeKMS) *hsm.HSMClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	kmspb.RegisterKeyManagementServiceServer(gs, f)
+	go func() { _ = gs.Serve(listener) }()
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	client, err := kms.NewKeyManagementClient(t.Context(), option.WithGRPCConn(conn))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return &hsm.HSMClient{Ctx: t.Context(), KeyName: "test-key", KmsClient: client}
+}
+
+// checkHealth runs one round of healthLoop and returns the status reported for the WalletService and the server.
+func checkHealth(t *testing.T, s *RpcServer) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	s.checkHSM(t.Context())
+	s.updateHealth()
+	resp, err := s.health.Check(t.Context(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	serviceResp, err := s.health.Check(t.Context(), &healthpb.HealthCheckRequest{Service: wallet.WalletService_ServiceDesc.ServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, resp.Status, serviceResp.Status)
+	return resp.Status
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *RpcServer
+		want   healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name: "unsealed",
+			server: func(t *testing.T) *RpcServer {
+				return newTestServer(t, &RpcServerConfig{}, newTestKeys(t))
+			},
+			want: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "sealed",
+			server: func(t *testing.T) *RpcServer {
+				db := newTestKeys(t)
+				_, err := db.InitSeal(3, 2)
+				assert.NoError(t, err)
+				return newTestServer(t, &RpcServerConfig{}, db)
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "key store not open",
+			server: func(t *testing.T) *RpcServer {
+				errLocked := errors.New("resource temporarily unavailable")
+				s, err := NewRpcServer(func() (*leveldb.Keys, error) { return nil, errLocked }, &RpcServerConfig{})
+				assert.NoError(t, err)
+				_, err = s.openKeyStore()
+				s.setKeyStoreErr(err)
+				return s
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "kms reachable",
+			server: func(t *testing.T) *RpcServer {
+				s := newTestServer(t, &RpcServerConfig{HsmEnable: true}, newTestKeys(t))
+				s.HsmClient = newTestHSMClient(t, &fakeKMS{})
+				return s
+			},
+			want: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "kms ping failure",
+			server: func(t *testing.T) *RpcServer {
+				s := newTestServer(t, &RpcServerConfig{HsmEnable: true}, newTestKeys(t))
+				f := &fakeKMS{}
+				f.down.Store(true)
+				s.HsmClient = newTestHSMClient(t, f)
+				return s
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "kms client not created",
+			server: func(t *testing.T) *RpcServer {
+				s := newTestServer(t, &RpcServerConfig{HsmEnable: true}, newTestKeys(t))
+				s.HsmClient = nil
+				return s
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checkHealth(t, tt.server(t)))
+		})
+	}
+}
+
+func TestHealthRecovery(t *testing.T) {
+	ctx := context.Background()
+	db := newTestKeys(t)
+	shares, err := db.InitSeal(3, 2)
+	assert.NoError(t, err)
+	s := newTestServer(t, &RpcServerConfig{HsmEnable: true, AdminToken: "admin-token"}, db)
+	f := &fakeKMS{}
+	f.down.Store(true)
+	s.HsmClient = newTestHSMClient(t, f)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, s))
+
+	// the server is not ready before both the key store is unsealed and KMS answers
+	for _, share := range shares[:2] {
+		resp, err := s.Unseal(ctx, &wallet.UnsealRequest{Share: share.String()})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+	}
+	assert.False(t, db.Sealed())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, s))
+	f.down.Store(false)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkHealth(t, s))
+
+	resp, err := s.Seal(ctx, &wallet.SealRequest{ConsumerToken: "admin-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, s))
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+)
+
+var errKeyExists = errors.New("key already exists")
+
+func (s *RpcServer) ImportKey(_ context.Context, in *wallet.ImportKeyRequest) (*wallet.ImportKeyResponse, error) {
+	resp := &wallet.ImportKeyResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	publicKey, err := ImportKey(s.db, c.id, in)
+	if err != nil {
+		resp.Msg = "import key fail: " + err.Error()
+		return resp, nil
+	}
+	s.metrics.RecordKeysCreated(in.Type, leveldb.OriginImported, 1)
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "import key success"
+	resp.PublicKey = publicKey
+	return resp, nil
+}
+
+// ImportKey parses the private key of in, validates it against the requested CryptoType and
+// stores it through Keys.StoreKeys with metadata marking it as imported and owned by consumer.
+// It is shared by the ImportKey RPC and the import-key command.
+func ImportKey(db *leveldb.Keys, consumer string, in *wallet.ImportKeyRequest) (*wallet.PublicKey, error) {
+	cryptoType, err := protobuf.ParseTransactionType(in.Type)
+	if err != nil {
+		return nil, errors.New("input type error")
+	}
+
+	var priKeyStr, pubKeyStr, compressPubkeyStr string
+	derivationPath := in.DerivationPath
+	switch cryptoType {
+	case protobuf.ECDSA:
+		priKeyStr, pubKeyStr, compressPubkeyStr, err = ssm.ImportECDSAKey(in.Format, in.Key, in.Passphrase, derivationPath)
+		if in.Format == ssm.ImportFormatMnemonic && derivationPath == "" {
+			derivationPath = ssm.DefaultECDSADerivationPath
+		}
+	case protobuf.EDDSA:
+		priKeyStr, pubKeyStr, err = ssm.ImportEdDSAKey(in.Format, in.Key, in.Passphrase, derivationPath)
+		compressPubkeyStr = pubKeyStr
+		if in.Format == ssm.ImportFormatMnemonic && derivationPath == "" {
+			derivationPath = ssm.DefaultEdDSADerivationPath
+		}
+	case protobuf.BLS:
+		// an EIP-2335 keystore records the EIP-2334 path its key was derived at
+		priKeyStr, pubKeyStr, derivationPath, err = ssm.ImportBLSKey(in.Format, in.Key, in.Passphrase)
+		compressPubkeyStr = pubKeyStr
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.Format != ssm.ImportFormatMnemonic && cryptoType != protobuf.BLS {
+		derivationPath = ""
+	}
+
+	exist, err := db.HasKey(pubKeyStr)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return nil, errKeyExists
+	}
+
+	keyItem := leveldb.Key{
+		PrivateKey:     priKeyStr,
+		Pubkey:         pubKeyStr,
+		CompressPubkey: compressPubkeyStr,
+		Type:           string(cryptoType),
+		Consumer:       consumer,
+		Labels:         in.Labels,
+		Backend:        leveldb.BackendLocal,
+		DerivationPath: derivationPath,
+		Origin:         leveldb.OriginImported,
+	}
+	if err := db.StoreKeys([]leveldb.Key{keyItem}); err != nil {
+		return nil, err
+	}
+	log.Info("import key", "key", pubKeyStr, "format", in.Format)
+	return &wallet.PublicKey{
+		CompressPubkey: compressPubkeyStr,
+		Pubkey:         pubKeyStr,
+	}, nil
+}
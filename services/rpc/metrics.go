@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// metricsUnaryInterceptor records the count and the latency of every unary call by method and result code.
+func (s *RpcServer) metricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.metrics.RecordRPC(shortMethod(info.FullMethod), callResult(resp, err), time.Since(start))
+	return resp, err
+}
+
+// metricsStreamInterceptor records streams once they end, the requests of a sign stream are counted by their signatures.
+func (s *RpcServer) metricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.metrics.RecordRPC(shortMethod(info.FullMethod), callResult(nil, err), time.Since(start))
+	return err
+}
+
+// shortMethod returns the method name of a full gRPC method name.
+func shortMethod(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// signLimiterIdleTimeout is how long an unused sign limiter is kept. All its token buckets,
+// the daily one included, are full again after it, so dropping it loses no state.
+const signLimiterIdleTimeout = 24 * time.Hour
+
+// quotaState holds the token buckets limiting the signatures of every consumer and
+// serializes the key creation of a consumer so that its key count can not be exceeded.
+type quotaState struct {
+	mu        sync.Mutex
+	limiters  map[string]*signLimiter
+	lastPrune time.Time
+	keyLocks  keyedMutex
+}
+
+// signLimiter holds one token bucket per configured signature window of a consumer.
+type signLimiter struct {
+	buckets  []*rate.Limiter
+	lastUsed time.Time
+}
+
+func newSignLimiter(limits ConsumerLimits) *signLimiter {
+	l := &signLimiter{}
+	for _, window := range []struct {
+		limit  int
+		period time.Duration
+	}{
+		{limits.SignaturesPerSecond, time.Second},
+		{limits.SignaturesPerMinute, time.Minute},
+		{limits.SignaturesPerDay, 24 * time.Hour},
+	} {
+		if window.limit > 0 {
+			l.buckets = append(l.buckets, rate.NewLimiter(rate.Limit(float64(window.limit)/window.period.Seconds()), window.limit))
+		}
+	}
+	return l
+}
+
+// reserve takes n tokens from every bucket. When a bucket has not enough tokens nothing is
+// taken and the time to wait before retrying is returned; ok is false when n can never be taken.
+func (l *signLimiter) reserve(now time.Time, n int) (retryAfter time.Duration, ok bool) {
+	reservations := make([]*rate.Reservation, 0, len(l.buckets))
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	for _, bucket := range l.buckets {
+		r := bucket.ReserveN(now, n)
+		if !r.OK() {
+			cancel()
+			return 0, false
+		}
+		reservations = append(reservations, r)
+		retryAfter = max(retryAfter, r.DelayFrom(now))
+	}
+	if retryAfter > 0 {
+		cancel()
+	}
+	return retryAfter, true
+}
+
+// consumerSignLimiter returns the sign limiter of the consumer id, nil when its signatures are not limited.
+func (s *RpcServer) consumerSignLimiter(id string, now time.Time) *signLimiter {
+	limits := s.consumerLimits(id)
+	if limits.SignaturesPerSecond == 0 && limits.SignaturesPerMinute == 0 && limits.SignaturesPerDay == 0 {
+		return nil
+	}
+	s.quota.mu.Lock()
+	defer s.quota.mu.Unlock()
+	if s.quota.limiters == nil {
+		s.quota.limiters = make(map[string]*signLimiter)
+	}
+	if now.Sub(s.quota.lastPrune) > signLimiterIdleTimeout {
+		for key, limiter := range s.quota.limiters {
+			if now.Sub(limiter.lastUsed) > signLimiterIdleTimeout {
+				delete(s.quota.limiters, key)
+			}
+		}
+		s.quota.lastPrune = now
+	}
+	limiter, isOk := s.quota.limiters[id]
+	if !isOk {
+		limiter = newSignLimiter(limits)
+		s.quota.limiters[id] = limiter
+	}
+	limiter.lastUsed = now
+	return limiter
+}
+
+// reserveSignatures takes n signatures from the rate limits of the consumer id,
+// returning a ResourceExhausted error with a retry hint when a limit is reached.
+func (s *RpcServer) reserveSignatures(id string, n int) error {
+	now := time.Now()
+	limiter := s.consumerSignLimiter(id, now)
+	if limiter == nil {
+		return nil
+	}
+	s.quota.mu.Lock()
+	retryAfter, ok := limiter.reserve(now, n)
+	s.quota.mu.Unlock()
+	if !ok {
+		return quotaExceeded(id, fmt.Sprintf("%d signatures exceed the signature limit of the consumer", n), 0)
+	}
+	if retryAfter > 0 {
+		return quotaExceeded(id, "signature rate limit exceeded", retryAfter)
+	}
+	return nil
+}
+
+// reserveKeys checks that the consumer id may create n more keys. When the number of keys of the
+// consumer is limited, the key creation of the consumer is serialized until the returned unlock is called.
+func (s *RpcServer) reserveKeys(id string, n int) (func(), error) {
+	limits := s.consumerLimits(id)
+	if limits.MaxKeysPerRequest > 0 && n > limits.MaxKeysPerRequest {
+		return nil, quotaExceeded(id, fmt.Sprintf("number must not be greater than %d", limits.MaxKeysPerRequest), 0)
+	}
+	if limits.MaxKeys == 0 {
+		return func() {}, nil
+	}
+	unlock := s.quota.keyLocks.lock(id)
+	count, err := s.db.CountConsumerKeys(id)
+	if err != nil {
+		unlock()
+		log.Error("count consumer keys fail", "err", err)
+		return nil, status.Error(codes.Internal, "count consumer keys fail")
+	}
+	if count+n > limits.MaxKeys {
+		unlock()
+		return nil, quotaExceeded(id, fmt.Sprintf("consumer owns %d keys, the limit is %d", count, limits.MaxKeys), 0)
+	}
+	return unlock, nil
+}
+
+// quotaExceeded returns a ResourceExhausted error. A positive retryAfter is attached as RetryInfo
+// and added to the message; without it the request will not succeed before the limits change.
+func quotaExceeded(id, msg string, retryAfter time.Duration) error {
+	if retryAfter > 0 {
+		// round up so that a client retrying after the hinted delay is not refused again
+		retryAfter = retryAfter.Round(time.Millisecond) + time.Millisecond
+		msg = fmt.Sprintf("%s, retry after %s", msg, retryAfter)
+	}
+	st := status.New(codes.ResourceExhausted, msg)
+	quotaFailure := &errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{Subject: "consumer:" + id, Description: msg}},
+	}
+	withDetails, err := st.WithDetails(quotaFailure)
+	if err == nil && retryAfter > 0 {
+		withDetails, err = withDetails.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	}
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// quotaUnaryInterceptor enforces the key and signature limits of the consumer of a request.
+// Requests with an unknown consumer token are passed on and refused by the handler.
+func (s *RpcServer) quotaUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	switch in := req.(type) {
+	case *wallet.SignTxMessageRequest:
+		if c, isOk := s.resolveCaller(in.ConsumerToken); isOk {
+			if err := s.reserveSignatures(c.id, 1); err != nil {
+				return nil, err
+			}
+		}
+	case *wallet.BatchSignTxMessageRequest:
+		if c, isOk := s.resolveCaller(in.ConsumerToken); isOk && len(in.Items) > 0 {
+			if err := s.reserveSignatures(c.id, len(in.Items)); err != nil {
+				return nil, err
+			}
+		}
+	case *wallet.ExportPublicKeyRequest:
+		c, isOk := s.resolveCaller(in.ConsumerToken)
+		if !isOk {
+			break
+		}
+		if in.RequestId != "" {
+			// a retry returning the keys of an earlier request creates no key
+			if previous, isOk, err := s.db.GetKeyRequest(c.id, in.RequestId); err == nil && isOk && s.isKeyRequestReplay(previous, time.Now()) {
+				break
+			}
+		}
+		unlock, err := s.reserveKeys(c.id, int(in.Number))
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	case *wallet.ImportKeyRequest:
+		c, isOk := s.resolveCaller(in.ConsumerToken)
+		if !isOk {
+			break
+		}
+		unlock, err := s.reserveKeys(c.id, 1)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	return handler(ctx, req)
+}
+
+// quotaStreamInterceptor enforces the signature limits on every request of a sign stream,
+// ending the stream with a ResourceExhausted error when a limit is reached.
+func (s *RpcServer) quotaStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if info.FullMethod != wallet.WalletService_SignTxMessageStream_FullMethodName {
+		return handler(srv, ss)
+	}
+	return handler(srv, &quotaServerStream{ServerStream: ss, s: s})
+}
+
+type quotaServerStream struct {
+	grpc.ServerStream
+	s *RpcServer
+}
+
+func (ss *quotaServerStream) RecvMsg(m any) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	in, isOk := m.(*wallet.SignTxMessageStreamRequest)
+	if !isOk {
+		return nil
+	}
+	c, isOk := ss.s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		return nil
+	}
+	return ss.s.reserveSignatures(c.id, 1)
+}
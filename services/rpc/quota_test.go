@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+func TestSignLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newSignLimiter(ConsumerLimits{SignaturesPerSecond: 2, SignaturesPerDay: 3})
+	assert.Len(t, limiter.buckets, 2)
+	for i := 0; i < 2; i++ {
+		retryAfter, ok := limiter.reserve(now, 1)
+		assert.True(t, ok)
+		assert.Zero(t, retryAfter)
+	}
+	retryAfter, ok := limiter.reserve(now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+	// a refused reservation takes no token
+	retryAfter, ok = limiter.reserve(now.Add(500*time.Millisecond), 1)
+	assert.True(t, ok)
+	assert.Zero(t, retryAfter)
+	// the daily bucket is empty now
+	retryAfter, ok = limiter.reserve(now.Add(time.Minute), 1)
+	assert.True(t, ok)
+	assert.Greater(t, retryAfter, time.Hour)
+	_, ok = limiter.reserve(now, 4)
+	assert.False(t, ok)
+}
+
+func TestLoadConsumers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "consumers.json")
+	for _, test := range []struct {
+		content string
+		err     string
+	}{
+		{`{"consumers": [{"id": "alice", "token": "alice-token", "limits": {"maxKeys": 2}}]}`, ""},
+		{`{"defaultLimits": {"signaturesPerSecond": 1}}`, "default limits need at least one consumer"},
+		{`{"consumers": [{"id": "alice", "token": ""}]}`, "consumer 0: id and token must not be empty"},
+		{`{"consumers": [{"id": "token:alice", "token": "alice-token"}]}`, `consumer "token:alice": id must not start with "token:"`},
+		{`{"consumers": [{"id": "admin", "token": "alice-token"}]}`, `consumer "admin": id is reserved for the admin token`},
+		{`{"consumers": [{"id": "alice", "token": "t1"}, {"id": "alice", "token": "t2"}]}`, `duplicate consumer id "alice"`},
+		{`{"consumers": [{"id": "alice", "token": "t1", "limits": {"maxKeys": -1}}]}`, `consumer "alice": limits must not be negative`},
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+		config, err := LoadConsumers(path)
+		if test.err == "" {
+			assert.NoError(t, err)
+			assert.Equal(t, 2, config.Consumers[0].Limits.MaxKeys)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+}
+
+// assertQuotaExceeded checks that err is a ResourceExhausted error of the consumer id,
+// with a retry hint when retry is set.
+func assertQuotaExceeded(t *testing.T, err error, id string, retry bool) {
+	t.Helper()
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code(), st.Message())
+	var violation *errdetails.QuotaFailure
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.QuotaFailure:
+			violation = detail
+		case *errdetails.RetryInfo:
+			retryInfo = detail
+		}
+	}
+	if assert.NotNil(t, violation) {
+		assert.Equal(t, "consumer:"+id, violation.Violations[0].Subject)
+	}
+	assert.Equal(t, retry, retryInfo != nil)
+}
+
+func TestQuotaUnaryInterceptor(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{
+		Consumers: []Consumer{
+			{ID: "alice", Token: "alice-token", Limits: ConsumerLimits{MaxKeys: 3, MaxKeysPerRequest: 2, SignaturesPerMinute: 3}},
+			{ID: "bob", Token: "bob-token"},
+		},
+	}, newTestKeys(t))
+	client := dialTestServer(t, s)
+
+	_, err := client.ExportPublicKeyList(ctx, &wallet.ExportPublicKeyRequest{ConsumerToken: "alice-token", Type: "ecdsa", Number: 3})
+	assertQuotaExceeded(t, err, "alice", false)
+	pubkeys := exportKeys(t, client, "alice-token", "ecdsa", 2)
+	_, err = client.ExportPublicKeyList(ctx, &wallet.ExportPublicKeyRequest{ConsumerToken: "alice-token", Type: "ecdsa", Number: 2})
+	assertQuotaExceeded(t, err, "alice", false)
+	pubkeys = append(pubkeys, exportKeys(t, client, "alice-token", "ecdsa", 1)...)
+	_, err = client.ImportKey(ctx, &wallet.ImportKeyRequest{ConsumerToken: "alice-token", Type: "ecdsa"})
+	assertQuotaExceeded(t, err, "alice", false)
+	// a retry of a request that created keys creates none
+	resp, err := client.ExportPublicKeyList(ctx, &wallet.ExportPublicKeyRequest{ConsumerToken: "bob-token", Type: "ecdsa", Number: 5, RequestId: "r1"})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+
+	sign := func(consumerToken string) error {
+		_, err := client.SignTxMessage(ctx, &wallet.SignTxMessageRequest{
+			ConsumerToken: consumerToken,
+			Type:          "ecdsa",
+			PublicKey:     pubkeys[0],
+			MessageHash:   fmt.Sprintf("%064x", 1),
+		})
+		return err
+	}
+	batch := func(n int) error {
+		items := make([]*wallet.SignTxMessageItem, n)
+		for i := range items {
+			items[i] = &wallet.SignTxMessageItem{Type: "ecdsa", PublicKey: pubkeys[0], MessageHash: fmt.Sprintf("%064x", i)}
+		}
+		_, err := client.BatchSignTxMessage(ctx, &wallet.BatchSignTxMessageRequest{ConsumerToken: "alice-token", Items: items})
+		return err
+	}
+	assertQuotaExceeded(t, batch(4), "alice", false)
+	assert.NoError(t, batch(2))
+	assert.NoError(t, sign("alice-token"))
+	assertQuotaExceeded(t, sign("alice-token"), "alice", true)
+	assertQuotaExceeded(t, batch(1), "alice", true)
+	// the limits of a consumer do not apply to the others
+	assert.NoError(t, sign("bob-token"))
+	// unknown tokens are refused by the handler, not by the quotas
+	assert.NoError(t, sign("carol-token"))
+}
+
+func TestQuotaExpiredRequestID(t *testing.T) {
+	db := newTestKeys(t)
+	s := newTestServer(t, &RpcServerConfig{
+		Consumers:          []Consumer{{ID: "alice", Token: "alice-token", Limits: ConsumerLimits{MaxKeys: 2}}},
+		RequestIDRetention: time.Hour,
+	}, db)
+	client := dialTestServer(t, s)
+	// a record older than the retention, not deleted yet
+	assert.NoError(t, db.StoreRequestKeys(&leveldb.KeyRequest{RequestID: "r1", Consumer: "alice", Type: "ecdsa", Number: 3, CreatedAt: time.Now().Add(-2 * time.Hour).Unix()}, nil))
+
+	// the request creates new keys, so the limits apply
+	_, err := client.ExportPublicKeyList(context.Background(), &wallet.ExportPublicKeyRequest{ConsumerToken: "alice-token", Type: "ecdsa", Number: 3, RequestId: "r1"})
+	assertQuotaExceeded(t, err, "alice", false)
+	count, err := db.CountConsumerKeys("alice")
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestQuotaWithoutConsumers(t *testing.T) {
+	// without listed consumers every token is its own consumer, so nothing is limited
+	s := newTestServer(t, &RpcServerConfig{DefaultLimits: ConsumerLimits{MaxKeys: 1, SignaturesPerMinute: 1}}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkeys := exportKeys(t, client, "alice-token", "ecdsa", 2)
+	for i := 0; i < 3; i++ {
+		resp, err := client.SignTxMessage(context.Background(), &wallet.SignTxMessageRequest{
+			ConsumerToken: "alice-token",
+			Type:          "ecdsa",
+			PublicKey:     pubkeys[0],
+			MessageHash:   fmt.Sprintf("%064x", i),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	}
+}
+
+func TestQuotaStreamInterceptor(t *testing.T) {
+	s := newTestServer(t, &RpcServerConfig{
+		Consumers: []Consumer{{ID: "alice", Token: "alice-token", Limits: ConsumerLimits{SignaturesPerMinute: 2}}},
+	}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkey := exportKeys(t, client, "alice-token", "ecdsa", 1)[0]
+
+	stream, err := client.SignTxMessageStream(context.Background())
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{
+			ConsumerToken: "alice-token",
+			RequestId:     fmt.Sprint(i),
+			Type:          "ecdsa",
+			PublicKey:     pubkey,
+			MessageHash:   fmt.Sprintf("%064x", i),
+		}))
+		resp, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	}
+	// the request over the limit ends the stream
+	assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{
+		ConsumerToken: "alice-token",
+		RequestId:     "2",
+		Type:          "ecdsa",
+		PublicKey:     pubkey,
+		MessageHash:   fmt.Sprintf("%064x", 2),
+	}))
+	_, err = stream.Recv()
+	assertQuotaExceeded(t, err, "alice", true)
+}
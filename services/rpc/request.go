@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+)
+
+// keyedMutex serializes the callers locking the same key, e.g. the retries of one request id.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key and returns the function unlocking it.
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, isOk := m.locks[key]
+	if !isOk {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (s *RpcServer) requestIDRetention() time.Duration {
+	if s.RequestIDRetention <= 0 {
+		return DefaultRequestIDRetention
+	}
+	return s.RequestIDRetention
+}
+
+// isKeyRequestReplay reports whether a request with the id of request at now returns its keys.
+// Records older than the request id retention are only kept until they are deleted, the request
+// creates new keys then.
+func (s *RpcServer) isKeyRequestReplay(request *leveldb.KeyRequest, now time.Time) bool {
+	return request.CreatedAt >= now.Add(-s.requestIDRetention()).Unix()
+}
+
+// expireKeyRequestsLoop periodically deletes the key generation requests older than the request id retention.
+func (s *RpcServer) expireKeyRequestsLoop(ctx context.Context) {
+	ticker := time.NewTicker(KeyRequestExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			expired, err := s.db.DeleteExpiredRequests(time.Now().Add(-s.requestIDRetention()).Unix())
+			if err != nil {
+				log.Error("delete expired key requests fail", "err", err)
+				continue
+			}
+			if expired > 0 {
+				log.Info("delete expired key requests", "requests", expired)
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/shamir"
+)
+
+// sealExemptMethods are the WalletService methods served while the key store is sealed.
+var sealExemptMethods = map[string]bool{
+	wallet.WalletService_SealStatus_FullMethodName: true,
+	wallet.WalletService_Unseal_FullMethodName:     true,
+	wallet.WalletService_Seal_FullMethodName:       true,
+}
+
+var errSealed = status.Error(codes.Unavailable, "key store is sealed")
+
+// unsealProgress collects the unseal shares submitted by the operators until the threshold is reached.
+type unsealProgress struct {
+	mu     sync.Mutex
+	shares []*shamir.Share
+}
+
+// reset wipes and discards the shares submitted so far, the caller must hold mu.
+func (p *unsealProgress) reset() {
+	for _, share := range p.shares {
+		for i := range share.Value {
+			share.Value[i] = 0
+		}
+	}
+	p.shares = nil
+}
+
+func (s *RpcServer) SealStatus(ctx context.Context, in *wallet.SealStatusRequest) (*wallet.SealStatusResponse, error) {
+	config, isOk := s.db.SealConfig()
+	if !isOk {
+		return &wallet.SealStatusResponse{
+			Code: wallet.ReturnCode_SUCCESS,
+			Msg:  "key store seal is not initialized",
+		}, nil
+	}
+	s.unseal.mu.Lock()
+	progress := len(s.unseal.shares)
+	s.unseal.mu.Unlock()
+	return &wallet.SealStatusResponse{
+		Code:        wallet.ReturnCode_SUCCESS,
+		Msg:         "get seal status success",
+		Initialized: true,
+		Sealed:      s.db.Sealed(),
+		Threshold:   uint32(config.Threshold),
+		Total:       uint32(config.Total),
+		Progress:    uint32(progress),
+	}, nil
+}
+
+// Unseal accepts one unseal share. Once the threshold of shares of the key store is reached,
+// the master key is recovered and the key store is unsealed. A share of another group, a duplicated
+// share or a wrong master key is refused, and a wrong master key also discards the submitted shares.
+// Only the admin may discard the submitted shares with ResetProgress.
+func (s *RpcServer) Unseal(ctx context.Context, in *wallet.UnsealRequest) (*wallet.UnsealResponse, error) {
+	resp := &wallet.UnsealResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	config, isOk := s.db.SealConfig()
+	if !isOk {
+		resp.Msg = "key store seal is not initialized"
+		return resp, nil
+	}
+	resp.Threshold = uint32(config.Threshold)
+
+	s.unseal.mu.Lock()
+	defer s.unseal.mu.Unlock()
+	resp.Sealed = s.db.Sealed()
+	if in.ResetProgress {
+		if !s.isAdmin(in.ConsumerToken) {
+			resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+			resp.Msg = "only admin can reset unseal progress"
+			resp.Progress = uint32(len(s.unseal.shares))
+			return resp, nil
+		}
+		s.unseal.reset()
+		resp.Code = wallet.ReturnCode_SUCCESS
+		resp.Msg = "unseal progress reset"
+		return resp, nil
+	}
+	if !resp.Sealed {
+		resp.Code = wallet.ReturnCode_SUCCESS
+		resp.Msg = "key store is already unsealed"
+		return resp, nil
+	}
+
+	share, err := shamir.ParseShare(strings.TrimSpace(in.Share))
+	if err != nil {
+		resp.Msg = "invalid unseal share: " + err.Error()
+		resp.Progress = uint32(len(s.unseal.shares))
+		return resp, nil
+	}
+	if share.GroupID != config.GroupID || int(share.Threshold) != config.Threshold {
+		resp.Msg = shamir.ErrShareGroup.Error()
+		resp.Progress = uint32(len(s.unseal.shares))
+		return resp, nil
+	}
+	for _, submitted := range s.unseal.shares {
+		if submitted.Index == share.Index {
+			resp.Msg = fmt.Sprintf("unseal share %d was already submitted", share.Index)
+			resp.Progress = uint32(len(s.unseal.shares))
+			return resp, nil
+		}
+	}
+	s.unseal.shares = append(s.unseal.shares, share)
+	resp.Progress = uint32(len(s.unseal.shares))
+	log.Info("unseal share accepted", "index", share.Index, "progress", resp.Progress, "threshold", config.Threshold)
+	if len(s.unseal.shares) < config.Threshold {
+		resp.Code = wallet.ReturnCode_SUCCESS
+		resp.Msg = fmt.Sprintf("unseal share accepted, %d of %d", resp.Progress, config.Threshold)
+		return resp, nil
+	}
+
+	masterKey, err := shamir.CombineShares(s.unseal.shares)
+	s.unseal.reset()
+	resp.Progress = 0
+	if err == nil {
+		err = s.db.Unseal(masterKey)
+		for i := range masterKey {
+			masterKey[i] = 0
+		}
+	}
+	if err != nil {
+		log.Error("unseal key store fail", "err", err)
+		resp.Msg = "unseal key store fail, submit the shares again: " + err.Error()
+		return resp, nil
+	}
+	log.Info("key store unsealed")
+	s.updateHealth()
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "key store unsealed"
+	resp.Sealed = false
+	return resp, nil
+}
+
+// Seal seals the key store again, wiping the master key from memory. Only the admin may seal the key store.
+func (s *RpcServer) Seal(ctx context.Context, in *wallet.SealRequest) (*wallet.SealResponse, error) {
+	if !s.isAdmin(in.ConsumerToken) {
+		return &wallet.SealResponse{
+			Code: wallet.ReturnCode_PERMISSION_DENIED,
+			Msg:  "only admin can seal key store",
+		}, nil
+	}
+	s.unseal.mu.Lock()
+	defer s.unseal.mu.Unlock()
+	s.unseal.reset()
+	if err := s.db.Seal(); err != nil {
+		return &wallet.SealResponse{
+			Code: wallet.ReturnCode_ERROR,
+			Msg:  err.Error(),
+		}, nil
+	}
+	log.Info("key store sealed")
+	s.updateHealth()
+	return &wallet.SealResponse{
+		Code: wallet.ReturnCode_SUCCESS,
+		Msg:  "key store sealed",
+	}, nil
+}
+
+// blockedWhileSealed reports whether method must be refused because the key store is sealed.
+func (s *RpcServer) blockedWhileSealed(method string) bool {
+	if !strings.HasPrefix(method, "/"+wallet.WalletService_ServiceDesc.ServiceName+"/") || sealExemptMethods[method] {
+		return false
+	}
+	return s.db.Sealed()
+}
+
+func (s *RpcServer) sealUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.blockedWhileSealed(info.FullMethod) {
+		return nil, errSealed
+	}
+	return handler(ctx, req)
+}
+
+func (s *RpcServer) sealStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if s.blockedWhileSealed(info.FullMethod) {
+		return errSealed
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+func TestUnseal(t *testing.T) {
+	ctx := context.Background()
+	db := newTestKeys(t)
+	shares, err := db.InitSeal(3, 2)
+	assert.NoError(t, err)
+	s := newTestServer(t, &RpcServerConfig{AdminToken: "admin-token"}, db)
+	client := dialTestServer(t, s)
+
+	_, err = client.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "alice-token"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	resp, err := client.Unseal(ctx, &wallet.UnsealRequest{Share: shares[0].String()})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	assert.Equal(t, uint32(1), resp.Progress)
+
+	// only the admin may discard the shares submitted by the other operators
+	for _, consumerToken := range []string{"", "alice-token"} {
+		resp, err = client.Unseal(ctx, &wallet.UnsealRequest{ConsumerToken: consumerToken, ResetProgress: true})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+		assert.Equal(t, uint32(1), resp.Progress)
+	}
+	resp, err = client.Unseal(ctx, &wallet.UnsealRequest{ConsumerToken: "admin-token", ResetProgress: true})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	sealStatus, err := client.SealStatus(ctx, &wallet.SealStatusRequest{})
+	assert.NoError(t, err)
+	assert.Zero(t, sealStatus.Progress)
+
+	for _, share := range shares[1:] {
+		resp, err = client.Unseal(ctx, &wallet.UnsealRequest{Share: share.String()})
+		assert.NoError(t, err)
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code)
+	}
+	assert.False(t, resp.Sealed)
+	_, err = client.ListKeys(ctx, &wallet.ListKeysRequest{ConsumerToken: "alice-token"})
+	assert.NoError(t, err)
+}
@@ -2,61 +2,319 @@ package rpc
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 
 	"github.com/qiaopengjun5162/web3-wallet-sign/hsm"
 	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/metrics"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
 	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/services/ethsigner"
+	"github.com/qiaopengjun5162/web3-wallet-sign/services/rest"
+	"github.com/qiaopengjun5162/web3-wallet-sign/services/web3signer"
+	"github.com/qiaopengjun5162/web3-wallet-sign/tracing"
 )
 
 const MaxReceivedMessageSize = 1024 * 1024 * 30000
 
+const (
+	// MaxBatchSignItems is the maximum number of items accepted by one BatchSignTxMessage call.
+	MaxBatchSignItems = 10000
+	// DefaultBatchSignWorkers is used when RpcServerConfig.BatchSignWorkers is not set.
+	DefaultBatchSignWorkers = 16
+	// DefaultStreamSignConcurrency is used when RpcServerConfig.StreamSignConcurrency is not set.
+	DefaultStreamSignConcurrency = 32
+	// DefaultListKeysPageSize is used when ListKeysRequest.page_size is not set.
+	DefaultListKeysPageSize = 100
+	// MaxListKeysPageSize is the maximum page size of ListKeys.
+	MaxListKeysPageSize = 1000
+	// DefaultKeyDestructionDelay is used when RpcServerConfig.KeyDestructionDelay is not set.
+	DefaultKeyDestructionDelay = 7 * 24 * time.Hour
+	// KeyDestructionInterval is the interval at which keys pending destruction are checked.
+	KeyDestructionInterval = time.Minute
+	// DefaultRequestIDRetention is used when RpcServerConfig.RequestIDRetention is not set.
+	DefaultRequestIDRetention = 24 * time.Hour
+	// KeyRequestExpireInterval is the interval at which expired key generation requests are deleted.
+	KeyRequestExpireInterval = 10 * time.Minute
+	// SpendExpireInterval is the interval at which the amounts signed before every policy window are deleted.
+	SpendExpireInterval = 10 * time.Minute
+	// SignRequestExpireInterval is the interval at which sign requests past their expiry are expired.
+	SignRequestExpireInterval = time.Minute
+	// SignRequestRetention is how long a signed, rejected, expired or failed sign request is kept.
+	SignRequestRetention = 7 * 24 * time.Hour
+	// DefaultAuditAnchorInterval is used when RpcServerConfig.AuditAnchorInterval is not set.
+	DefaultAuditAnchorInterval = time.Hour
+	// KeyStoreOpenRetryInterval is the interval at which opening the key store is retried after a failure.
+	KeyStoreOpenRetryInterval = 5 * time.Second
+	// HealthCheckInterval is the interval at which KMS is checked and the health of the server refreshed.
+	HealthCheckInterval = 10 * time.Second
+	// MaxRequestIDLength is the maximum length of the request id of ExportPublicKeyList.
+	MaxRequestIDLength = 128
+	// MaxExportKeysNumber is the maximum number of keys created by one ExportPublicKeyList call.
+	MaxExportKeysNumber = 10000
+)
+
+var tracer = otel.Tracer("github.com/qiaopengjun5162/web3-wallet-sign/services/rpc")
+
+var (
+	errKeyNotActive      = errors.New("key is not active")
+	errInvalidUnsignedTx = errors.New("invalid unsigned tx")
+	errKeyTypeMismatch   = errors.New("key type mismatch")
+)
+
 type RpcServerConfig struct {
 	GrpcHostname string
 	GrpcPort     int
 	KeyPath      string
 	KeyName      string
 	HsmEnable    bool
+	// MetricsEnabled serves the Prometheus metrics on MetricsHostname:MetricsPort
+	MetricsEnabled  bool
+	MetricsHostname string
+	MetricsPort     int
+	// HealthEnabled serves /healthz and /readyz on HealthHostname:HealthPort
+	HealthEnabled  bool
+	HealthHostname string
+	HealthPort     int
+	// RestEnabled serves the WalletService over HTTP/JSON on RestHostname:RestPort
+	RestEnabled  bool
+	RestHostname string
+	RestPort     int
+	// EthRpcEnabled serves the ECDSA keys as an Ethereum JSON-RPC remote signer on EthRpcHostname:EthRpcPort
+	EthRpcEnabled  bool
+	EthRpcHostname string
+	EthRpcPort     int
+	// Web3SignerEnabled serves the BLS keys of Web3SignerConsumerToken with the eth2 API of Web3Signer
+	// on Web3SignerHostname:Web3SignerPort
+	Web3SignerEnabled       bool
+	Web3SignerHostname      string
+	Web3SignerPort          int
+	Web3SignerConsumerToken string
+	// Web3SignerAuthToken is the bearer token of the requests of the Web3Signer listener, it may only
+	// be empty when the listener is bound to a loopback address
+	Web3SignerAuthToken string
+	// Web3SignerGenesisForkVersion is the hex encoded genesis fork version of the network the
+	// validator registrations are signed for
+	Web3SignerGenesisForkVersion string
+	// Tracing configures the export of the spans of the service
+	Tracing tracing.Config
+	// BatchSignWorkers bounds the number of concurrent signers of one BatchSignTxMessage call
+	BatchSignWorkers int
+	// StreamSignConcurrency bounds the number of in-flight sign requests of one SignTxMessageStream
+	StreamSignConcurrency int
+	// AdminToken is the consumer token allowed to manage every key, empty to disable
+	AdminToken string
+	// KeyDestructionDelay is the waiting period of a key pending destruction
+	KeyDestructionDelay time.Duration
+	// RequestIDRetention is how long the request id of ExportPublicKeyList is remembered
+	RequestIDRetention time.Duration
+	// Consumers maps consumer tokens to consumer ids, empty to use the token as the consumer id
+	Consumers []Consumer
+	// DefaultLimits bounds the keys and signatures of every consumer without its own limits
+	DefaultLimits ConsumerLimits
+	// Policy is checked before every signature, nil to sign every request
+	Policy *policy.Policy
+	// AuditKey is the public key of the ecdsa key signing the audit log anchors, empty to disable anchoring
+	AuditKey string
+	// AuditAnchorInterval is the interval at which the head of the audit log is anchored
+	AuditAnchorInterval time.Duration
 }
 
 type RpcServer struct {
 	*RpcServerConfig
-	db        *leveldb.Keys
-	HsmClient *hsm.HSMClient
+	openKeyStore KeyStoreOpener
+	// db is set once the key store is open, it is only read after keyStoreOpen is observed
+	db           *leveldb.Keys
+	keyStoreOpen atomic.Bool
+	HsmClient    *hsm.HSMClient
+	policy       *policy.Engine
+	metrics      *metrics.Metrics
+	// metricsServer is nil unless MetricsEnabled is set
+	metricsServer *metrics.Server
+	// shutdownTracing flushes the spans not exported yet
+	shutdownTracing func(context.Context) error
+	health          *health.Server
+	healthState     healthState
+	// healthServer is nil unless HealthEnabled is set
+	healthServer *http.Server
+	// restServer is nil unless RestEnabled is set
+	restServer *rest.Server
+	// ethSignerServer is nil unless EthRpcEnabled is set
+	ethSignerServer *ethsigner.Server
+	// web3SignerServer is nil unless Web3SignerEnabled is set
+	web3SignerServer *web3signer.Server
+	// grpcServer serves the WalletService once Start returns
+	grpcServer *grpc.Server
+	// web3SignerToken identifies the calls of web3SignerServer, the only caller signing with the BLS keys.
+	// It is empty unless Web3SignerEnabled is set
+	web3SignerToken string
 
 	wallet.UnimplementedWalletServiceServer
-	stopped atomic.Bool
+	stopped      atomic.Bool
+	unseal       unsealProgress
+	requestLocks keyedMutex
+	quota        quotaState
+	// signRequestLocks serializes the decisions on one sign request
+	signRequestLocks keyedMutex
 }
 
 func (s *RpcServer) Stop(ctx context.Context) error {
 	s.stopped.Store(true)
-	return nil
+	// the probes see the server going away before it stops answering
+	s.health.Shutdown()
+	var errs []error
+	if s.healthServer != nil {
+		errs = append(errs, s.healthServer.Shutdown(ctx))
+	}
+	if s.restServer != nil {
+		errs = append(errs, s.restServer.Stop(ctx))
+	}
+	if s.ethSignerServer != nil {
+		errs = append(errs, s.ethSignerServer.Stop(ctx))
+	}
+	if s.web3SignerServer != nil {
+		errs = append(errs, s.web3SignerServer.Stop(ctx))
+	}
+	if s.grpcServer != nil {
+		stopGrpcServer(ctx, s.grpcServer)
+	}
+	// the calls in flight are done, the key store can be released for the next instance
+	if s.keyStoreOpen.Load() {
+		errs = append(errs, s.db.Close())
+	}
+	if s.metricsServer != nil {
+		errs = append(errs, s.metricsServer.Stop(ctx))
+	}
+	if s.shutdownTracing != nil {
+		errs = append(errs, s.shutdownTracing(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// stopGrpcServer waits for the calls in flight until ctx is done, then closes their connections.
+func stopGrpcServer(ctx context.Context, gs *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		gs.Stop()
+		<-done
+	}
 }
 
 func (s *RpcServer) Stopped() bool {
 	return s.stopped.Load()
 }
 
-func NewRpcServer(db *leveldb.Keys, config *RpcServerConfig) (*RpcServer, error) {
+// NewRpcServer creates a server signing with the key store opened by openKeyStore. The key store
+// is opened by Start, the server reports NOT_SERVING to the health checks until it is open.
+func NewRpcServer(openKeyStore KeyStoreOpener, config *RpcServerConfig) (*RpcServer, error) {
 	hsmClient, err := hsm.NewHSMClient(context.Background(), config.KeyPath, config.KeyName)
 	if err != nil {
 		log.Error("new hsm client fail", "err", err)
 	}
-	return &RpcServer{
+	server := &RpcServer{
 		RpcServerConfig: config,
-		db:              db,
+		openKeyStore:    openKeyStore,
 		HsmClient:       hsmClient,
-	}, nil
+		metrics:         metrics.NewMetrics(),
+		health:          health.NewServer(),
+	}
+	if hsmClient != nil {
+		hsmClient.Metrics = server.metrics
+	}
+	if config.Web3SignerEnabled {
+		server.web3SignerToken = rand.Text()
+	}
+	server.updateHealth()
+	return server, nil
 }
 
 func (s *RpcServer) Start(ctx context.Context) error {
+	shutdownTracing, err := tracing.Setup(ctx, s.Tracing)
+	if err != nil {
+		log.Error("set up tracing fail", "err", err)
+		return err
+	}
+	s.shutdownTracing = shutdownTracing
+	if s.MetricsEnabled {
+		metricsServer, err := metrics.StartServer(s.metrics, s.MetricsHostname, s.MetricsPort)
+		if err != nil {
+			log.Error("start metrics server fail", "err", err)
+			return err
+		}
+		s.metricsServer = metricsServer
+	}
+	if s.HealthEnabled {
+		healthServer, err := s.startHealthServer()
+		if err != nil {
+			log.Error("start health server fail", "err", err)
+			return err
+		}
+		s.healthServer = healthServer
+	}
+	if s.RestEnabled {
+		gateway, err := rest.NewGateway(s.grpcTarget())
+		if err != nil {
+			log.Error("create rest gateway fail", "err", err)
+			return err
+		}
+		restServer, err := rest.StartServer(gateway, s.RestHostname, s.RestPort)
+		if err != nil {
+			log.Error("start rest server fail", "err", err)
+			return err
+		}
+		s.restServer = restServer
+	}
+	if s.EthRpcEnabled {
+		ethSignerServer, err := ethsigner.StartServer(s.grpcTarget(), s.EthRpcHostname, s.EthRpcPort)
+		if err != nil {
+			log.Error("start eth signer server fail", "err", err)
+			return err
+		}
+		s.ethSignerServer = ethSignerServer
+	}
+	if s.Web3SignerEnabled {
+		web3SignerServer, err := web3signer.StartServer(s.grpcTarget(), s.Web3SignerConsumerToken, s.web3SignerToken, s.Web3SignerAuthToken, common.FromHex(s.Web3SignerGenesisForkVersion),
+			&slashingProtection{s: s}, s.Web3SignerHostname, s.Web3SignerPort)
+		if err != nil {
+			log.Error("start web3signer server fail", "err", err)
+			return err
+		}
+		s.web3SignerServer = web3SignerServer
+	}
+	// the key store of a previous instance may still be locked, opening it is retried in the background
+	if db, err := s.openKeyStore(); err != nil {
+		log.Error("open key store fail, retrying", "err", err, "interval", KeyStoreOpenRetryInterval)
+		s.setKeyStoreErr(err)
+		go s.openKeyStoreLoop(ctx)
+	} else if err := s.initKeyStore(ctx, db); err != nil {
+		return err
+	}
+	go s.healthLoop(ctx)
+	s.grpcServer = s.newGrpcServer()
 	go func(s *RpcServer) {
 		addr := fmt.Sprintf("%s:%d", s.GrpcHostname, s.GrpcPort)
 		log.Info("start rpc services", "addr", addr)
@@ -65,22 +323,92 @@ func (s *RpcServer) Start(ctx context.Context) error {
 			log.Error("Could not start tcp listener. ")
 		}
 
-		opt := grpc.MaxRecvMsgSize(MaxReceivedMessageSize)
-
-		gs := grpc.NewServer(
-			opt,
-			grpc.ChainUnaryInterceptor(
-				nil,
-			),
-		)
-		reflection.Register(gs)
-
-		wallet.RegisterWalletServiceServer(gs, s)
-
 		log.Info("Grpc info", "port", s.GrpcPort, "address", listener.Addr())
-		if err := gs.Serve(listener); err != nil {
+		if err := s.grpcServer.Serve(listener); err != nil {
 			log.Error("Could not GRPC services")
 		}
 	}(s)
 	return nil
 }
+
+// newGrpcServer returns a gRPC server serving the WalletService and the health service through the interceptors.
+func (s *RpcServer) newGrpcServer() *grpc.Server {
+	gs := grpc.NewServer(
+		grpc.MaxRecvMsgSize(MaxReceivedMessageSize),
+		// continues the trace context of the callers and starts a server span for every call
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			s.metricsUnaryInterceptor,
+			s.keyStoreUnaryInterceptor,
+			s.auditUnaryInterceptor,
+			s.sealUnaryInterceptor,
+			s.quotaUnaryInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			s.metricsStreamInterceptor,
+			s.keyStoreStreamInterceptor,
+			s.auditStreamInterceptor,
+			s.sealStreamInterceptor,
+			s.quotaStreamInterceptor,
+		),
+	)
+	reflection.Register(gs)
+
+	wallet.RegisterWalletServiceServer(gs, s)
+	healthpb.RegisterHealthServer(gs, s.health)
+	return gs
+}
+
+// grpcTarget returns the address the rest gateway and the eth signer call the gRPC listener at.
+func (s *RpcServer) grpcTarget() string {
+	host := s.GrpcHostname
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.GrpcPort))
+}
+
+// destroyKeysLoop periodically destroys the keys whose destruction waiting period has elapsed.
+func (s *RpcServer) destroyKeysLoop(ctx context.Context) {
+	ticker := time.NewTicker(KeyDestructionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			destroyed, err := s.db.DestroyDueKeys(time.Now().Unix())
+			if err != nil {
+				log.Error("destroy due keys fail", "err", err)
+				continue
+			}
+			if destroyed > 0 {
+				log.Info("destroy due keys", "keys", destroyed)
+			}
+		}
+	}
+}
+
+func (s *RpcServer) keyDestructionDelay() time.Duration {
+	if s.KeyDestructionDelay <= 0 {
+		return DefaultKeyDestructionDelay
+	}
+	return s.KeyDestructionDelay
+}
+
+// isAdmin reports whether consumerToken is the configured admin token.
+func (s *RpcServer) isAdmin(consumerToken string) bool {
+	return s.AdminToken != "" && subtle.ConstantTimeCompare([]byte(consumerToken), []byte(s.AdminToken)) == 1
+}
+
+// canManageKey reports whether the caller may change the lifecycle and the access of a key:
+// only the consumer that created the key and the admin may do so.
+func (s *RpcServer) canManageKey(c *caller, meta *leveldb.KeyMeta) bool {
+	if c.admin {
+		return true
+	}
+	return c.id != "" && c.id == meta.Consumer
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// newTestServer returns a server serving the in-memory key store db.
+func newTestServer(t *testing.T, config *RpcServerConfig, db *leveldb.Keys) *RpcServer {
+	t.Helper()
+	s, err := NewRpcServer(func() (*leveldb.Keys, error) { return db, nil }, config)
+	assert.NoError(t, err)
+	assert.NoError(t, s.initKeyStore(t.Context(), db))
+	return s
+}
+
+func newTestKeys(t *testing.T) *leveldb.Keys {
+	t.Helper()
+	db, err := leveldb.NewKeys(leveldb.NewMemoryStore())
+	assert.NoError(t, err)
+	return db
+}
+
+// dialTestServer serves s over an in-memory listener through the interceptors of the gRPC listener
+// and returns a client of it.
+func dialTestServer(t *testing.T, s *RpcServer) wallet.WalletServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	gs := s.newGrpcServer()
+	go func() { _ = gs.Serve(listener) }()
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return wallet.NewWalletServiceClient(conn)
+}
+
+// exportKeys creates number keys of cryptoType for consumerToken and returns their public keys.
+func exportKeys(t *testing.T, client wallet.WalletServiceClient, consumerToken, cryptoType string, number int) []string {
+	t.Helper()
+	resp, err := client.ExportPublicKeyList(context.Background(), &wallet.ExportPublicKeyRequest{
+		ConsumerToken: consumerToken,
+		Type:          cryptoType,
+		Number:        uint64(number),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+	pubkeys := make([]string, len(resp.PublicKey))
+	for i, key := range resp.PublicKey {
+		pubkeys[i] = key.Pubkey
+	}
+	return pubkeys
+}
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/services/web3signer"
+)
+
+// errUnprotectedBLS refuses the signatures with the BLS keys not requested through the web3signer API,
+// which checks the blocks and attestations against the slashing protection records before signing.
+var errUnprotectedBLS = fmt.Errorf("%w: bls keys only sign through the web3signer api", errKeyNotAccessible)
+
+// slashingProtection is the web3signer.SlashingProtection keeping the blocks and attestations
+// signed by the validators in the key store.
+type slashingProtection struct {
+	s *RpcServer
+}
+
+var _ web3signer.SlashingProtection = (*slashingProtection)(nil)
+
+func (p *slashingProtection) CheckAndRecordBlock(genesisValidatorsRoot, pubkey string, slot uint64, signingRoot string) error {
+	if !p.s.keyStoreOpen.Load() {
+		return errKeyStoreNotOpen
+	}
+	return p.s.db.CheckAndRecordBlock(genesisValidatorsRoot, pubkey, slot, signingRoot)
+}
+
+func (p *slashingProtection) CheckAndRecordAttestation(genesisValidatorsRoot, pubkey string, sourceEpoch, targetEpoch uint64, signingRoot string) error {
+	if !p.s.keyStoreOpen.Load() {
+		return errKeyStoreNotOpen
+	}
+	return p.s.db.CheckAndRecordAttestation(genesisValidatorsRoot, pubkey, sourceEpoch, targetEpoch, signingRoot)
+}
+
+// fromWeb3Signer reports whether the call of ctx was made by the web3signer server of the service.
+func (s *RpcServer) fromWeb3Signer(ctx context.Context) bool {
+	if s.web3SignerToken == "" {
+		return false
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(web3signer.SignerTokenMetadataKey) {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.web3SignerToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// spendLedger is the policy.SpendTracker keeping the amounts signed in the key store,
+// so that the window limits survive restarts.
+type spendLedger struct {
+	db *leveldb.Keys
+}
+
+func (l *spendLedger) Spent(scope, chain, token string, since time.Time) (*big.Int, error) {
+	return l.db.SpentSince(scope, chain, token, since)
+}
+
+func (l *spendLedger) Recorded(scope, id string) (bool, error) {
+	return l.db.HasSpend(scope, id)
+}
+
+func (l *spendLedger) Record(scopes []string, id, chain, token string, amount *big.Int, at time.Time) (func(), error) {
+	records := make([]*leveldb.SpendRecord, len(scopes))
+	for i, scope := range scopes {
+		records[i] = &leveldb.SpendRecord{
+			Scope:  scope,
+			ID:     id,
+			Chain:  chain,
+			Token:  token,
+			Amount: amount.String(),
+			At:     at.UnixNano(),
+		}
+	}
+	if err := l.db.RecordSpends(records); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := l.db.DeleteSpends(records); err != nil {
+			log.Error("delete spend records fail", "id", id, "err", err)
+		}
+	}, nil
+}
+
+// expireSpendsLoop periodically deletes the amounts signed before the longest window of the policy.
+func (s *RpcServer) expireSpendsLoop(ctx context.Context) {
+	ticker := time.NewTicker(SpendExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Stopped() {
+				return
+			}
+			expired, err := s.db.DeleteExpiredSpends(time.Now().Add(-s.Policy.MaxWindow()))
+			if err != nil {
+				log.Error("delete expired spend records fail", "err", err)
+				continue
+			}
+			if expired > 0 {
+				log.Info("delete expired spend records", "records", expired)
+			}
+		}
+	}
+}
+
+func (s *RpcServer) GetAllowance(_ context.Context, in *wallet.GetAllowanceRequest) (*wallet.GetAllowanceResponse, error) {
+	resp := &wallet.GetAllowanceResponse{
+		Code: wallet.ReturnCode_ERROR,
+	}
+	c, isOk := s.resolveCaller(in.ConsumerToken)
+	if !isOk {
+		resp.Code = wallet.ReturnCode_PERMISSION_DENIED
+		resp.Msg = "unknown consumer token"
+		return resp, nil
+	}
+	if s.policy == nil {
+		resp.Msg = "no transaction policy is configured"
+		return resp, nil
+	}
+	if in.Chain == "" {
+		resp.Msg = "chain must not be empty"
+		return resp, nil
+	}
+	meta, isOk, err := s.visibleKeyMeta(c, in.PublicKey)
+	if err != nil {
+		log.Error("get key fail", "err", err)
+		return nil, err
+	}
+	if !isOk {
+		resp.Msg = "key not found"
+		return resp, nil
+	}
+
+	allowances, err := s.policy.Allowance(&policy.Request{
+		Key:      policy.KeyInfo{Pubkey: meta.Pubkey, Consumer: meta.Consumer, Labels: meta.Labels},
+		Consumer: c.id,
+		Time:     time.Now(),
+	}, in.Chain, in.Token)
+	if err != nil {
+		log.Error("get allowance fail", "err", err)
+		return nil, err
+	}
+	for _, allowance := range allowances {
+		resp.Allowances = append(resp.Allowances, &wallet.Allowance{
+			Rule:          allowance.Rule,
+			Scope:         allowance.Scope,
+			WindowSeconds: int64(allowance.Window / time.Second),
+			Token:         allowance.Token,
+			Max:           allowance.Max.String(),
+			Spent:         allowance.Spent.String(),
+			Remaining:     allowance.Remaining.String(),
+		})
+	}
+	resp.Code = wallet.ReturnCode_SUCCESS
+	resp.Msg = "get allowance success"
+	return resp, nil
+}
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// SignTxMessageStream signs the requests pushed by the client and sends every signature back,
+// tagged with the request id, as soon as it is ready. Responses may be out of order.
+//
+// At most StreamSignConcurrency requests of one stream are in flight: once the limit is reached
+// no further request is received until a signature has been sent back, so gRPC flow control
+// pushes back on the client. A stream is bound to the consumer token of its first request,
+// which is resolved to the consumer id once.
+func (s *RpcServer) SignTxMessageStream(stream wallet.WalletService_SignTxMessageStreamServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	limit := s.StreamSignConcurrency
+	if limit <= 0 {
+		limit = DefaultStreamSignConcurrency
+	}
+	sem := make(chan struct{}, limit)
+
+	var (
+		wg            sync.WaitGroup
+		sendMu        sync.Mutex
+		sendErr       error
+		consumerToken string
+		c             *caller
+		first         = true
+	)
+	send := func(resp *wallet.SignTxMessageStreamResponse) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if sendErr != nil {
+			return
+		}
+		if err := stream.Send(resp); err != nil {
+			log.Error("send sign stream response fail", "err", err)
+			sendErr = err
+			cancel()
+		}
+	}
+	finish := func(err error) error {
+		wg.Wait()
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return finish(ctx.Err())
+		}
+
+		in, err := recvSignRequest(ctx, stream)
+		if err != nil {
+			<-sem
+			if errors.Is(err, io.EOF) {
+				return finish(nil)
+			}
+			return finish(err)
+		}
+		if first {
+			consumerToken = in.ConsumerToken
+			first = false
+			var isOk bool
+			if c, isOk = s.resolveCaller(consumerToken); !isOk {
+				<-sem
+				send(&wallet.SignTxMessageStreamResponse{
+					RequestId: in.RequestId,
+					Code:      wallet.ReturnCode_PERMISSION_DENIED,
+					Msg:       "unknown consumer token",
+				})
+				return finish(nil)
+			}
+		}
+		if in.ConsumerToken != consumerToken {
+			<-sem
+			send(&wallet.SignTxMessageStreamResponse{
+				RequestId: in.RequestId,
+				Code:      wallet.ReturnCode_ERROR,
+				Msg:       "consumer token does not match the stream",
+			})
+			continue
+		}
+
+		wg.Add(1)
+		go func(in *wallet.SignTxMessageStreamRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resp := &wallet.SignTxMessageStreamResponse{
+				RequestId: in.RequestId,
+				Code:      wallet.ReturnCode_ERROR,
+			}
+			ctx, span := tracer.Start(ctx, "signTxMessageStream.request", trace.WithAttributes(attribute.String("request.id", in.RequestId)))
+			signed, err := s.signRequest(ctx, c, in.Type, &signInput{
+				publicKey:    in.PublicKey,
+				messageHash:  in.MessageHash,
+				unsignedTx:   in.UnsignedTx,
+				chainID:      in.ChainId,
+				unsignedPSBT: in.UnsignedPsbt,
+				inputIndex:   in.InputIndex,
+				network:      in.Network,
+			})
+			if err != nil {
+				resp.Code = signErrorCode(err)
+				resp.Msg = err.Error()
+			} else if signed.approvalRequestID != "" {
+				resp.Code = wallet.ReturnCode_PENDING_APPROVAL
+				resp.Msg = pendingApprovalMsg(signed.approvalRequestID)
+				resp.ApprovalRequestId = signed.approvalRequestID
+			} else {
+				resp.Code = wallet.ReturnCode_SUCCESS
+				resp.Msg = "sign tx message success"
+				resp.Signature = signed.signature
+				resp.SignedTx = signed.signedTx
+			}
+			span.SetAttributes(attribute.String("result", resp.Code.String()))
+			span.End()
+			send(resp)
+		}(in)
+	}
+}
+
+// recvSignRequest receives the next request of stream until ctx is done. A failed send cancels ctx
+// while the client may be waiting for the response rather than sending the next request.
+func recvSignRequest(ctx context.Context, stream wallet.WalletService_SignTxMessageStreamServer) (*wallet.SignTxMessageStreamRequest, error) {
+	type result struct {
+		in  *wallet.SignTxMessageStreamRequest
+		err error
+	}
+	// the receive ends with the stream once the handler returns
+	received := make(chan result, 1)
+	go func() {
+		in, err := stream.Recv()
+		received <- result{in, err}
+	}()
+	select {
+	case r := <-received:
+		return r.in, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+func TestSignTxMessageStream(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &RpcServerConfig{StreamSignConcurrency: 4}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkeys := exportKeys(t, client, "alice-token", "ecdsa", 2)
+
+	stream, err := client.SignTxMessageStream(ctx)
+	assert.NoError(t, err)
+	messageHashes := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		requestID := fmt.Sprintf("request-%d", i)
+		messageHashes[requestID] = fmt.Sprintf("%064x", i)
+		assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{
+			ConsumerToken: "alice-token",
+			RequestId:     requestID,
+			Type:          "ecdsa",
+			PublicKey:     pubkeys[i%2],
+			MessageHash:   messageHashes[requestID],
+		}))
+	}
+	assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{ConsumerToken: "bob-token", RequestId: "other-token"}))
+	assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{ConsumerToken: "alice-token", RequestId: "unknown-key", Type: "ecdsa", PublicKey: "ab"}))
+	assert.NoError(t, stream.CloseSend())
+
+	// every response carries the id of its request, in whatever order they are signed
+	responses := make(map[string]*wallet.SignTxMessageStreamResponse)
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.NotContains(t, responses, resp.RequestId)
+		responses[resp.RequestId] = resp
+	}
+	assert.Len(t, responses, 52)
+	for i := 0; i < 50; i++ {
+		requestID := fmt.Sprintf("request-%d", i)
+		resp := responses[requestID]
+		assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+		verify, err := client.VerifySignature(ctx, &wallet.VerifySignatureRequest{
+			Type:        "ecdsa",
+			PublicKey:   pubkeys[i%2],
+			MessageHash: messageHashes[requestID],
+			Signature:   resp.Signature,
+		})
+		assert.NoError(t, err)
+		assert.True(t, verify.Valid, requestID)
+	}
+	assert.Equal(t, "consumer token does not match the stream", responses["other-token"].Msg)
+	assert.Equal(t, wallet.ReturnCode_ERROR, responses["unknown-key"].Code)
+
+	// a stream opened with an unknown token is answered once and closed
+	s = newTestServer(t, &RpcServerConfig{Consumers: []Consumer{{ID: "alice", Token: "alice-token"}}}, newTestKeys(t))
+	stream, err = dialTestServer(t, s).SignTxMessageStream(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&wallet.SignTxMessageStreamRequest{ConsumerToken: "bob-token", RequestId: "first"}))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", resp.RequestId)
+	assert.Equal(t, wallet.ReturnCode_PERMISSION_DENIED, resp.Code)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+// blockingSignStream is a sign stream whose sends block until released, counting the requests received.
+type blockingSignStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests chan *wallet.SignTxMessageStreamRequest
+	release  chan struct{}
+
+	mu        sync.Mutex
+	received  int
+	responses []*wallet.SignTxMessageStreamResponse
+}
+
+func (ss *blockingSignStream) Context() context.Context { return ss.ctx }
+
+func (ss *blockingSignStream) Recv() (*wallet.SignTxMessageStreamRequest, error) {
+	in, isOk := <-ss.requests
+	if !isOk {
+		return nil, io.EOF
+	}
+	ss.mu.Lock()
+	ss.received++
+	ss.mu.Unlock()
+	return in, nil
+}
+
+func (ss *blockingSignStream) Send(resp *wallet.SignTxMessageStreamResponse) error {
+	<-ss.release
+	ss.mu.Lock()
+	ss.responses = append(ss.responses, resp)
+	ss.mu.Unlock()
+	return nil
+}
+
+func (ss *blockingSignStream) counts() (received, sent int) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.received, len(ss.responses)
+}
+
+func TestSignTxMessageStreamConcurrency(t *testing.T) {
+	const limit = 3
+	s := newTestServer(t, &RpcServerConfig{StreamSignConcurrency: limit}, newTestKeys(t))
+	pubkey := exportKeys(t, dialTestServer(t, s), "alice-token", "ecdsa", 1)[0]
+
+	ss := &blockingSignStream{
+		ctx:      context.Background(),
+		requests: make(chan *wallet.SignTxMessageStreamRequest, 10),
+		release:  make(chan struct{}),
+	}
+	for i := 0; i < 10; i++ {
+		ss.requests <- &wallet.SignTxMessageStreamRequest{
+			ConsumerToken: "alice-token",
+			RequestId:     fmt.Sprint(i),
+			Type:          "ecdsa",
+			PublicKey:     pubkey,
+			MessageHash:   fmt.Sprintf("%064x", i),
+		}
+	}
+	close(ss.requests)
+	done := make(chan error)
+	go func() { done <- s.SignTxMessageStream(ss) }()
+
+	// while no response can be sent, no request is received beyond the limit
+	assert.Eventually(t, func() bool {
+		received, _ := ss.counts()
+		return received == limit
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	received, sent := ss.counts()
+	assert.Equal(t, limit, received)
+	assert.Zero(t, sent)
+
+	// every response sent lets one more request in
+	ss.release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		received, _ := ss.counts()
+		return received == limit+1
+	}, time.Second, time.Millisecond)
+	close(ss.release)
+	assert.NoError(t, <-done)
+	received, sent = ss.counts()
+	assert.Equal(t, 10, received)
+	assert.Equal(t, 10, sent)
+}
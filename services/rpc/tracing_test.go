@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/policy"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// TestSignSpans is the only test installing a tracer provider: the tracer of the package delegates
+// to the first provider installed and keeps it.
+func TestSignSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { _ = provider.Shutdown(t.Context()) })
+
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	assert.NoError(t, os.WriteFile(policyFile, []byte(`{"defaultAction": "allow"}`), 0o600))
+	txPolicy, err := policy.Load(policyFile)
+	assert.NoError(t, err)
+	s := newTestServer(t, &RpcServerConfig{Policy: txPolicy}, newTestKeys(t))
+	client := dialTestServer(t, s)
+	pubkey := exportKeys(t, client, "alice-token", "ecdsa", 1)[0]
+	exporter.Reset()
+
+	resp, err := client.SignTxMessage(t.Context(), &wallet.SignTxMessageRequest{ConsumerToken: "alice-token", Type: "ecdsa", PublicKey: pubkey, MessageHash: "0x" + strings.Repeat("ab", 32)})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.ReturnCode_SUCCESS, resp.Code, resp.Msg)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	server, isOk := spans["wallet.WalletService/signTxMessage"]
+	assert.True(t, isOk, "server span")
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	// the spans of the key store and of the policy are children of the span of the call
+	for _, name := range []string{"keystore.GetKeyRecord", "policy.Authorize", "keystore.DecryptPrivateKey", "crypto.Sign"} {
+		span, isOk := spans[name]
+		if assert.True(t, isOk, name) {
+			assert.Equal(t, server.SpanContext.TraceID(), span.SpanContext.TraceID(), name)
+			assert.Equal(t, server.SpanContext.SpanID(), span.Parent.SpanID(), name)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package web3signer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Preset values of the consensus specs the signed objects depend on, the same on mainnet and the testnets.
+const (
+	SlotsPerEpoch             = 32
+	MaxValidatorsPerCommittee = 2048
+	MaxCommitteesPerSlot      = 64
+	// SyncCommitteeSubnetSize is SYNC_COMMITTEE_SIZE / SYNC_COMMITTEE_SUBNET_COUNT
+	SyncCommitteeSubnetSize = 128
+)
+
+type domainType [4]byte
+
+// The domain types of the signed objects.
+var (
+	domainBeaconProposer              = domainType{0x00, 0x00, 0x00, 0x00}
+	domainBeaconAttester              = domainType{0x01, 0x00, 0x00, 0x00}
+	domainRandao                      = domainType{0x02, 0x00, 0x00, 0x00}
+	domainDeposit                     = domainType{0x03, 0x00, 0x00, 0x00}
+	domainVoluntaryExit               = domainType{0x04, 0x00, 0x00, 0x00}
+	domainSelectionProof              = domainType{0x05, 0x00, 0x00, 0x00}
+	domainAggregateAndProof           = domainType{0x06, 0x00, 0x00, 0x00}
+	domainSyncCommittee               = domainType{0x07, 0x00, 0x00, 0x00}
+	domainSyncCommitteeSelectionProof = domainType{0x08, 0x00, 0x00, 0x00}
+	domainContributionAndProof        = domainType{0x09, 0x00, 0x00, 0x00}
+	domainApplicationBuilder          = domainType{0x00, 0x00, 0x00, 0x01}
+)
+
+// errInvalidRequest is wrapped by the errors of the requests whose signing root can not be computed.
+var errInvalidRequest = errors.New("invalid request")
+
+func invalidRequest(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{errInvalidRequest}, args...)...)
+}
+
+// fixedBytes checks that the field name is n bytes long.
+func fixedBytes(name string, data hexutil.Bytes, n int) error {
+	if len(data) != n {
+		return invalidRequest("%s must be %d bytes", name, n)
+	}
+	return nil
+}
+
+func epochAtSlot(slot Uint64) uint64 {
+	return uint64(slot) / SlotsPerEpoch
+}
+
+// computeDomain returns the domain of domainType for the fork forkVersion of the chain genesisValidatorsRoot.
+func computeDomain(domainType domainType, forkVersion, genesisValidatorsRoot []byte) root {
+	forkDataRoot := containerRoot(bytesRoot(forkVersion), root(genesisValidatorsRoot))
+	var domain root
+	copy(domain[:4], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain
+}
+
+// domain returns the domain of domainType at epoch of the chain of the fork info.
+func (f *ForkInfo) domain(domainType domainType, epoch uint64) (root, error) {
+	if f == nil {
+		return root{}, invalidRequest("fork_info is required")
+	}
+	if err := errors.Join(
+		fixedBytes("fork_info.genesis_validators_root", f.GenesisValidatorsRoot, 32),
+		fixedBytes("fork_info.fork.previous_version", f.Fork.PreviousVersion, 4),
+		fixedBytes("fork_info.fork.current_version", f.Fork.CurrentVersion, 4),
+	); err != nil {
+		return root{}, err
+	}
+	forkVersion := f.Fork.CurrentVersion
+	if epoch < uint64(f.Fork.Epoch) {
+		forkVersion = f.Fork.PreviousVersion
+	}
+	return computeDomain(domainType, forkVersion, f.GenesisValidatorsRoot), nil
+}
+
+// signingRoot returns the root signed for the object of r, genesisForkVersion is the fork version
+// of the genesis of the network the validator registrations are signed for.
+func (r *SignRequest) signingRoot(genesisForkVersion []byte) (root, error) {
+	objectRoot, domain, err := r.objectRootAndDomain(genesisForkVersion)
+	if err != nil {
+		return root{}, err
+	}
+	return containerRoot(objectRoot, domain), nil
+}
+
+func (r *SignRequest) objectRootAndDomain(genesisForkVersion []byte) (root, root, error) {
+	var objectRoot, domain root
+	var err error
+	switch r.Type {
+	case TypeBlockV2:
+		if r.BeaconBlock == nil {
+			return root{}, root{}, invalidRequest("beacon_block is required")
+		}
+		header := r.BeaconBlock.BlockHeader
+		if header == nil {
+			return root{}, root{}, invalidRequest("beacon_block.block_header is required, full blocks are not supported")
+		}
+		if objectRoot, err = header.hashTreeRoot(); err != nil {
+			return root{}, root{}, err
+		}
+		domain, err = r.ForkInfo.domain(domainBeaconProposer, epochAtSlot(header.Slot))
+	case TypeAttestation:
+		if r.Attestation == nil {
+			return root{}, root{}, invalidRequest("attestation is required")
+		}
+		if objectRoot, err = r.Attestation.hashTreeRoot(); err != nil {
+			return root{}, root{}, err
+		}
+		domain, err = r.ForkInfo.domain(domainBeaconAttester, uint64(r.Attestation.Target.Epoch))
+	case TypeAggregationSlot:
+		if r.AggregationSlot == nil {
+			return root{}, root{}, invalidRequest("aggregation_slot is required")
+		}
+		objectRoot = uint64Root(uint64(r.AggregationSlot.Slot))
+		domain, err = r.ForkInfo.domain(domainSelectionProof, epochAtSlot(r.AggregationSlot.Slot))
+	case TypeAggregateAndProof, TypeAggregateAndProofV2:
+		aggregateAndProof, electra, err := r.aggregateAndProof()
+		if err != nil {
+			return root{}, root{}, err
+		}
+		if objectRoot, err = aggregateAndProof.hashTreeRoot(electra); err != nil {
+			return root{}, root{}, err
+		}
+		domain, err = r.ForkInfo.domain(domainAggregateAndProof, epochAtSlot(aggregateAndProof.Aggregate.Data.Slot))
+		return objectRoot, domain, err
+	case TypeRandaoReveal:
+		if r.RandaoReveal == nil {
+			return root{}, root{}, invalidRequest("randao_reveal is required")
+		}
+		objectRoot = uint64Root(uint64(r.RandaoReveal.Epoch))
+		domain, err = r.ForkInfo.domain(domainRandao, uint64(r.RandaoReveal.Epoch))
+	case TypeVoluntaryExit:
+		if r.VoluntaryExit == nil {
+			return root{}, root{}, invalidRequest("voluntary_exit is required")
+		}
+		objectRoot = containerRoot(uint64Root(uint64(r.VoluntaryExit.Epoch)), uint64Root(uint64(r.VoluntaryExit.ValidatorIndex)))
+		domain, err = r.ForkInfo.domain(domainVoluntaryExit, uint64(r.VoluntaryExit.Epoch))
+	case TypeSyncCommitteeMessage:
+		message := r.SyncCommitteeMessage
+		if message == nil {
+			return root{}, root{}, invalidRequest("sync_committee_message is required")
+		}
+		if err := fixedBytes("sync_committee_message.beacon_block_root", message.BeaconBlockRoot, 32); err != nil {
+			return root{}, root{}, err
+		}
+		objectRoot = root(message.BeaconBlockRoot)
+		domain, err = r.ForkInfo.domain(domainSyncCommittee, epochAtSlot(message.Slot))
+	case TypeSyncCommitteeSelectionProof:
+		data := r.SyncAggregatorSelectionData
+		if data == nil {
+			return root{}, root{}, invalidRequest("sync_aggregator_selection_data is required")
+		}
+		objectRoot = containerRoot(uint64Root(uint64(data.Slot)), uint64Root(uint64(data.SubcommitteeIndex)))
+		domain, err = r.ForkInfo.domain(domainSyncCommitteeSelectionProof, epochAtSlot(data.Slot))
+	case TypeSyncCommitteeContributionAndProof:
+		if r.ContributionAndProof == nil {
+			return root{}, root{}, invalidRequest("contribution_and_proof is required")
+		}
+		if objectRoot, err = r.ContributionAndProof.hashTreeRoot(); err != nil {
+			return root{}, root{}, err
+		}
+		domain, err = r.ForkInfo.domain(domainContributionAndProof, epochAtSlot(r.ContributionAndProof.Contribution.Slot))
+	case TypeDeposit:
+		deposit := r.Deposit
+		if deposit == nil {
+			return root{}, root{}, invalidRequest("deposit is required")
+		}
+		if err := errors.Join(
+			fixedBytes("deposit.pubkey", deposit.Pubkey, 48),
+			fixedBytes("deposit.withdrawal_credentials", deposit.WithdrawalCredentials, 32),
+			fixedBytes("deposit.genesis_fork_version", deposit.GenesisForkVersion, 4),
+		); err != nil {
+			return root{}, root{}, err
+		}
+		objectRoot = containerRoot(bytesRoot(deposit.Pubkey), root(deposit.WithdrawalCredentials), uint64Root(uint64(deposit.Amount)))
+		// deposits are valid across the forks of the network
+		domain = computeDomain(domainDeposit, deposit.GenesisForkVersion, make([]byte, 32))
+	case TypeValidatorRegistration:
+		registration := r.ValidatorRegistration
+		if registration == nil {
+			return root{}, root{}, invalidRequest("validator_registration is required")
+		}
+		if err := errors.Join(
+			fixedBytes("validator_registration.fee_recipient", registration.FeeRecipient, 20),
+			fixedBytes("validator_registration.pubkey", registration.Pubkey, 48),
+		); err != nil {
+			return root{}, root{}, err
+		}
+		objectRoot = containerRoot(
+			bytesRoot(registration.FeeRecipient),
+			uint64Root(uint64(registration.GasLimit)),
+			uint64Root(uint64(registration.Timestamp)),
+			bytesRoot(registration.Pubkey),
+		)
+		domain = computeDomain(domainApplicationBuilder, genesisForkVersion, make([]byte, 32))
+	default:
+		return root{}, root{}, invalidRequest("unsupported type %q", r.Type)
+	}
+	return objectRoot, domain, err
+}
+
+// aggregateAndProof returns the aggregate_and_proof of r, and whether its attestation is an Electra one.
+func (r *SignRequest) aggregateAndProof() (*AggregateAndProof, bool, error) {
+	if len(r.AggregateAndProof) == 0 {
+		return nil, false, invalidRequest("aggregate_and_proof is required")
+	}
+	if r.Type == TypeAggregateAndProof {
+		var aggregateAndProof AggregateAndProof
+		if err := unmarshalField("aggregate_and_proof", r.AggregateAndProof, &aggregateAndProof); err != nil {
+			return nil, false, err
+		}
+		return &aggregateAndProof, false, nil
+	}
+	var versioned VersionedAggregateAndProof
+	if err := unmarshalField("aggregate_and_proof", r.AggregateAndProof, &versioned); err != nil {
+		return nil, false, err
+	}
+	switch versioned.Version {
+	case "PHASE0", "ALTAIR", "BELLATRIX", "CAPELLA", "DENEB":
+		return &versioned.Data, false, nil
+	case "ELECTRA", "FULU":
+		return &versioned.Data, true, nil
+	default:
+		return nil, false, invalidRequest("unsupported aggregate_and_proof version %q", versioned.Version)
+	}
+}
+
+func (h *BeaconBlockHeader) hashTreeRoot() (root, error) {
+	if err := errors.Join(
+		fixedBytes("block_header.parent_root", h.ParentRoot, 32),
+		fixedBytes("block_header.state_root", h.StateRoot, 32),
+		fixedBytes("block_header.body_root", h.BodyRoot, 32),
+	); err != nil {
+		return root{}, err
+	}
+	return containerRoot(
+		uint64Root(uint64(h.Slot)),
+		uint64Root(uint64(h.ProposerIndex)),
+		root(h.ParentRoot),
+		root(h.StateRoot),
+		root(h.BodyRoot),
+	), nil
+}
+
+func (c *Checkpoint) hashTreeRoot(name string) (root, error) {
+	if err := fixedBytes(name+".root", c.Root, 32); err != nil {
+		return root{}, err
+	}
+	return containerRoot(uint64Root(uint64(c.Epoch)), root(c.Root)), nil
+}
+
+func (d *AttestationData) hashTreeRoot() (root, error) {
+	if err := fixedBytes("attestation.beacon_block_root", d.BeaconBlockRoot, 32); err != nil {
+		return root{}, err
+	}
+	source, err := d.Source.hashTreeRoot("attestation.source")
+	if err != nil {
+		return root{}, err
+	}
+	target, err := d.Target.hashTreeRoot("attestation.target")
+	if err != nil {
+		return root{}, err
+	}
+	return containerRoot(uint64Root(uint64(d.Slot)), uint64Root(uint64(d.Index)), root(d.BeaconBlockRoot), source, target), nil
+}
+
+func (a *AggregateAndProof) hashTreeRoot(electra bool) (root, error) {
+	if err := errors.Join(
+		fixedBytes("aggregate.signature", a.Aggregate.Signature, 96),
+		fixedBytes("selection_proof", a.SelectionProof, 96),
+	); err != nil {
+		return root{}, err
+	}
+	data, err := a.Aggregate.Data.hashTreeRoot()
+	if err != nil {
+		return root{}, err
+	}
+	var aggregate root
+	if electra {
+		aggregationBits, err := bitlistRoot(a.Aggregate.AggregationBits, MaxValidatorsPerCommittee*MaxCommitteesPerSlot)
+		if err != nil {
+			return root{}, invalidRequest("aggregate.aggregation_bits: %v", err)
+		}
+		committeeBits, err := bitvectorRoot(a.Aggregate.CommitteeBits, MaxCommitteesPerSlot)
+		if err != nil {
+			return root{}, invalidRequest("aggregate.committee_bits: %v", err)
+		}
+		aggregate = containerRoot(aggregationBits, data, bytesRoot(a.Aggregate.Signature), committeeBits)
+	} else {
+		aggregationBits, err := bitlistRoot(a.Aggregate.AggregationBits, MaxValidatorsPerCommittee)
+		if err != nil {
+			return root{}, invalidRequest("aggregate.aggregation_bits: %v", err)
+		}
+		aggregate = containerRoot(aggregationBits, data, bytesRoot(a.Aggregate.Signature))
+	}
+	return containerRoot(uint64Root(uint64(a.AggregatorIndex)), aggregate, bytesRoot(a.SelectionProof)), nil
+}
+
+func (c *ContributionAndProof) hashTreeRoot() (root, error) {
+	contribution := &c.Contribution
+	if err := errors.Join(
+		fixedBytes("contribution.beacon_block_root", contribution.BeaconBlockRoot, 32),
+		fixedBytes("contribution.signature", contribution.Signature, 96),
+		fixedBytes("selection_proof", c.SelectionProof, 96),
+	); err != nil {
+		return root{}, err
+	}
+	aggregationBits, err := bitvectorRoot(contribution.AggregationBits, SyncCommitteeSubnetSize)
+	if err != nil {
+		return root{}, invalidRequest("contribution.aggregation_bits: %v", err)
+	}
+	contributionRoot := containerRoot(
+		uint64Root(uint64(contribution.Slot)),
+		root(contribution.BeaconBlockRoot),
+		uint64Root(uint64(contribution.SubcommitteeIndex)),
+		aggregationBits,
+		bytesRoot(contribution.Signature),
+	)
+	return containerRoot(uint64Root(uint64(c.AggregatorIndex)), contributionRoot, bytesRoot(c.SelectionProof)), nil
+}
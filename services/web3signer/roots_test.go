@@ -0,0 +1,99 @@
+package web3signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func rep(b string, n int) string {
+	return "0x" + strings.Repeat(b, n)
+}
+
+// testForkInfo is a fork at epoch 100 of mainnet.
+const testForkInfo = `{"fork":{"previous_version":"0x04000000","current_version":"0x05000000","epoch":"100"},"genesis_validators_root":"0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"}`
+
+var (
+	testAttestation = fmt.Sprintf(`{"slot":"3232","index":"2","beacon_block_root":"%s","source":{"epoch":"100","root":"%s"},"target":{"epoch":"101","root":"%s"}}`,
+		rep("11", 32), rep("22", 32), rep("33", 32))
+	testBlockHeader = fmt.Sprintf(`{"slot":"3233","proposer_index":"7","parent_root":"%s","state_root":"%s","body_root":"%s"}`,
+		rep("44", 32), rep("55", 32), rep("66", 32))
+)
+
+func TestSigningRoot(t *testing.T) {
+	// the signing roots were computed with another implementation of the consensus specs
+	tests := []struct {
+		typ    string
+		object string
+		root   string
+	}{
+		{TypeBlockV2, `"beacon_block":{"version":"DENEB","block_header":` + testBlockHeader + `}`,
+			"0xcb5d334faf888810d144e40b67e30eb058a1978087c68a3e8376295f239beb62"},
+		// signed with the previous fork version before the fork epoch
+		{TypeBlockV2, `"beacon_block":{"version":"DENEB","block_header":` + strings.Replace(testBlockHeader, `"3233"`, `"3199"`, 1) + `}`,
+			"0xef1f6b68f7f7e988322a5ed55722fb57870057bfa7ceaa995517b72f767251e9"},
+		{TypeAttestation, `"attestation":` + testAttestation,
+			"0xde41eaee92382cf3bff37335f43839e9333275fe5bd0953db11d2b5fbfff19d8"},
+		{TypeAggregationSlot, `"aggregation_slot":{"slot":"3232"}`,
+			"0x9acee3535df5d289e53a8aeb5e2d52f50f7760aefb2c4ed0ea7f5e666cc5aede"},
+		{TypeAggregateAndProof, fmt.Sprintf(`"aggregate_and_proof":{"aggregator_index":"9","aggregate":{"aggregation_bits":"0x0f","data":%s,"signature":"%s"},"selection_proof":"%s"}`,
+			testAttestation, rep("aa", 96), rep("bb", 96)),
+			"0x5f982cd4b5cf152d6a300f465c083466bc93e36021ac8bfe306717bbb6a35e5d"},
+		{TypeAggregateAndProofV2, fmt.Sprintf(`"aggregate_and_proof":{"version":"DENEB","data":{"aggregator_index":"9","aggregate":{"aggregation_bits":"0x0f","data":%s,"signature":"%s"},"selection_proof":"%s"}}`,
+			testAttestation, rep("aa", 96), rep("bb", 96)),
+			"0x5f982cd4b5cf152d6a300f465c083466bc93e36021ac8bfe306717bbb6a35e5d"},
+		{TypeAggregateAndProofV2, fmt.Sprintf(`"aggregate_and_proof":{"version":"ELECTRA","data":{"aggregator_index":"9","aggregate":{"aggregation_bits":"0x0301","data":%s,"signature":"%s","committee_bits":"0x0100000000000000"},"selection_proof":"%s"}}`,
+			testAttestation, rep("aa", 96), rep("bb", 96)),
+			"0x33b3d1d2f278190a8c6c94a6533fecb1323447bb41062d1ff3d7c2bc24f2ebbb"},
+		{TypeRandaoReveal, `"randao_reveal":{"epoch":"101"}`,
+			"0xba3f564f1b3ec0e2aa97b519888bc8eb94429fb141161f73dfbccb4b39540d88"},
+		{TypeVoluntaryExit, `"voluntary_exit":{"epoch":"101","validator_index":"7"}`,
+			"0xdf1ed91bfad923abb405850b42feb94f428259035f463858ad56600894ff6e14"},
+		{TypeSyncCommitteeMessage, fmt.Sprintf(`"sync_committee_message":{"beacon_block_root":"%s","slot":"3232"}`, rep("11", 32)),
+			"0x1f2a2765ef6c2062a42c56eddf1ca5b7d46acd935df357548931636f405847dc"},
+		{TypeSyncCommitteeSelectionProof, `"sync_aggregator_selection_data":{"slot":"3232","subcommittee_index":"1"}`,
+			"0xa9bcf24878391e9db196d3ead01d6a931ff036ae920e0cc2bbf809a68493d62b"},
+		{TypeSyncCommitteeContributionAndProof, fmt.Sprintf(`"contribution_and_proof":{"aggregator_index":"5","contribution":{"slot":"3232","beacon_block_root":"%s","subcommittee_index":"1","aggregation_bits":"%s","signature":"%s"},"selection_proof":"%s"}`,
+			rep("11", 32), rep("ff", 16), rep("aa", 96), rep("bb", 96)),
+			"0x9a15e13b1c44f454d070c734f2c415ec0095bab78160bed03f8359dad90b8325"},
+		{TypeDeposit, fmt.Sprintf(`"deposit":{"pubkey":"%s","withdrawal_credentials":"%s","amount":"32000000000","genesis_fork_version":"0x00000000"}`, rep("88", 48), rep("99", 32)),
+			"0x181b105723dc80e3a12d0a327d70bbbb26f4a79f2c7ce15181b24c398da62dbf"},
+		{TypeValidatorRegistration, fmt.Sprintf(`"validator_registration":{"fee_recipient":"%s","gas_limit":"30000000","timestamp":"1700000000","pubkey":"%s"}`, rep("77", 20), rep("88", 48)),
+			"0xfaa570b68667b6e38b77dce35a88b5f15dbcdc2b5cc0e458fd33ee5cb4a01650"},
+	}
+	for _, test := range tests {
+		var req SignRequest
+		body := fmt.Sprintf(`{"type":%q,"fork_info":%s,%s}`, test.typ, testForkInfo, test.object)
+		assert.NoError(t, json.Unmarshal([]byte(body), &req), test.typ)
+		signingRoot, err := req.signingRoot([]byte{0, 0, 0, 0})
+		assert.NoError(t, err, test.typ)
+		assert.Equal(t, test.root, hexutil.Encode(signingRoot[:]), test.typ)
+	}
+}
+
+func TestDepositDomain(t *testing.T) {
+	domain := computeDomain(domainDeposit, []byte{0, 0, 0, 0}, make([]byte, 32))
+	assert.Equal(t, "0x03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9", hexutil.Encode(domain[:]))
+}
+
+func TestInvalidSignRequest(t *testing.T) {
+	for _, body := range []string{
+		`{"type":"UNKNOWN"}`,
+		`{"type":"ATTESTATION","fork_info":` + testForkInfo + `}`,
+		`{"type":"ATTESTATION","attestation":` + testAttestation + `}`,
+		`{"type":"BLOCK_V2","fork_info":` + testForkInfo + `,"beacon_block":{"version":"PHASE0","block":{}}}`,
+		`{"type":"SYNC_COMMITTEE_MESSAGE","fork_info":` + testForkInfo + `,"sync_committee_message":{"beacon_block_root":"0x11","slot":"1"}}`,
+		// the aggregation bits exceed Bitlist[2048]
+		fmt.Sprintf(`{"type":"AGGREGATE_AND_PROOF","fork_info":%s,"aggregate_and_proof":{"aggregator_index":"9","aggregate":{"aggregation_bits":"%s03","data":%s,"signature":"%s"},"selection_proof":"%s"}}`,
+			testForkInfo, rep("ff", 256), testAttestation, rep("aa", 96), rep("bb", 96)),
+	} {
+		var req SignRequest
+		assert.NoError(t, json.Unmarshal([]byte(body), &req))
+		_, err := req.signingRoot([]byte{0, 0, 0, 0})
+		assert.ErrorIs(t, err, errInvalidRequest, body)
+	}
+}
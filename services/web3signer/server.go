@@ -0,0 +1,171 @@
+package web3signer
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// MaxRequestBodySize is the largest signing request accepted.
+const MaxRequestBodySize = 1 << 20
+
+// SignerTokenMetadataKey is the gRPC metadata the signer token of the server is sent in.
+const SignerTokenMetadataKey = "x-web3signer-token"
+
+// Server serves the eth2 API of a Signer over HTTP.
+type Server struct {
+	conn     *grpc.ClientConn
+	srv      *http.Server
+	listener net.Listener
+}
+
+// NewHandler returns the HTTP handler of the eth2 API of signer. Unless authToken is empty, the
+// requests other than GET /upcheck must carry it in the "Authorization: Bearer <token>" header.
+func NewHandler(signer *Signer, authToken string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /upcheck", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /api/v1/eth2/publicKeys", func(w http.ResponseWriter, r *http.Request) {
+		pubkeys, err := signer.PublicKeys(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pubkeys)
+	})
+	mux.HandleFunc("POST /api/v1/eth2/sign/{identifier}", func(w http.ResponseWriter, r *http.Request) {
+		var req SignRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBodySize)).Decode(&req); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+		signature, err := signer.Sign(r.Context(), r.PathValue("identifier"), &req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if acceptsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{"signature": signature})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(signature))
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authToken != "" && r.URL.Path != "/upcheck" {
+			token, isBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !isBearer || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(authToken)) != 1 {
+				writeError(w, &Error{Status: http.StatusUnauthorized, Message: "invalid auth token"})
+				return
+			}
+		}
+		// continues the trace of the caller in the WalletService calls
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		mux.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// acceptsJSON reports whether the signature is answered as a JSON object rather than as text,
+// the signing endpoint of Web3Signer answers both.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	http.Error(w, e.Message, e.Status)
+}
+
+// StartServer listens on host:port and serves the signer of the BLS keys of consumerToken of
+// the gRPC listener at target until Stop is called. The connection to target is established lazily.
+// Every call to target carries signerToken in the SignerTokenMetadataKey metadata, the WalletService
+// only signs with the BLS keys for the callers presenting it. The requests are authenticated with
+// authToken, which may only be empty when host is a loopback address.
+func StartServer(target string, consumerToken string, signerToken string, authToken string, genesisForkVersion []byte, slashing SlashingProtection, host string, port int) (*Server, error) {
+	if consumerToken == "" {
+		return nil, errors.New("the consumer token of the web3signer server is not set")
+	}
+	if authToken == "" && !isLoopback(host) {
+		return nil, fmt.Errorf("the web3signer server listening on %s needs an auth token", host)
+	}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, SignerTokenMetadataKey, signerToken), method, req, reply, cc, opts...)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := NewSigner(wallet.NewWalletServiceClient(conn), consumerToken, genesisForkVersion, slashing)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	s := &Server{
+		conn:     conn,
+		srv:      &http.Server{Handler: NewHandler(signer, authToken), ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("web3signer server stopped", "err", err)
+		}
+	}()
+	log.Info("start web3signer server", "addr", listener.Addr())
+	return s, nil
+}
+
+// isLoopback reports whether host only accepts connections from the local machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop stops serving and closes the connection to the gRPC listener.
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	return errors.Join(err, s.conn.Close())
+}
@@ -0,0 +1,221 @@
+// Package web3signer serves the BLS keys of the key store with the eth2 API of Web3Signer, so that
+// consensus validator clients like Lighthouse, Teku and Prysm can use the service as an external signer.
+//
+// It implements GET /upcheck, GET /api/v1/eth2/publicKeys and POST /api/v1/eth2/sign/{identifier}.
+// The signing root is computed from the typed object of the request, never taken from the client,
+// and blocks and attestations are checked against the slashing protection records of the key
+// store before they are signed. The keys are those of the configured consumer token, and every
+// signature is requested from the gRPC listener of the service, so it goes through the same
+// authentication, quotas, seal, policy and audit as the gRPC calls.
+//
+// The WalletService refuses to sign with the BLS keys for any other caller, so that no signature
+// of a BLS key escapes the slashing protection. As the listener signs with the keys of the configured
+// consumer token, its requests carry an "Authorization: Bearer <token>" header unless it is only
+// bound to a loopback address.
+package web3signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+)
+
+// ListKeysPageSize is the page size of the ListKeys calls listing the public keys.
+const ListKeysPageSize = 1000
+
+// SlashingProtection records the blocks and attestations signed by the validators. The methods
+// return an error wrapping leveldb.ErrSlashable when the object must not be signed, and record it
+// otherwise. The roots and the public keys are hex encoded without 0x.
+type SlashingProtection interface {
+	CheckAndRecordBlock(genesisValidatorsRoot, pubkey string, slot uint64, signingRoot string) error
+	CheckAndRecordAttestation(genesisValidatorsRoot, pubkey string, sourceEpoch, targetEpoch uint64, signingRoot string) error
+}
+
+// Error is an error answered with an HTTP status.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Signer signs with the BLS keys of the WalletService the requests are forwarded to.
+type Signer struct {
+	client        wallet.WalletServiceClient
+	consumerToken string
+	slashing      SlashingProtection
+	// genesisForkVersion is the fork version of the genesis of the network, the validator
+	// registrations are signed for
+	genesisForkVersion []byte
+	// pubkeys is the set of the public keys listed, whether the consumer may use the key is
+	// checked by the WalletService on every signature
+	pubkeys sync.Map
+}
+
+// NewSigner returns a Signer of the keys of consumerToken on the network of genesisForkVersion.
+func NewSigner(client wallet.WalletServiceClient, consumerToken string, genesisForkVersion []byte, slashing SlashingProtection) (*Signer, error) {
+	if len(genesisForkVersion) != 4 {
+		return nil, fmt.Errorf("invalid genesis fork version %x", genesisForkVersion)
+	}
+	return &Signer{client: client, consumerToken: consumerToken, slashing: slashing, genesisForkVersion: genesisForkVersion}, nil
+}
+
+// PublicKeys returns the 0x prefixed public keys of the active BLS keys of the consumer.
+func (s *Signer) PublicKeys(ctx context.Context) ([]string, error) {
+	pubkeys := make([]string, 0)
+	err := s.listKeys(ctx, func(pubkey string) bool {
+		pubkeys = append(pubkeys, "0x"+pubkey)
+		return true
+	})
+	return pubkeys, err
+}
+
+// listKeys calls fn with the public key of every active BLS key of the consumer until fn returns false.
+func (s *Signer) listKeys(ctx context.Context, fn func(pubkey string) bool) error {
+	pageToken := ""
+	for {
+		resp, err := s.client.ListKeys(ctx, &wallet.ListKeysRequest{
+			ConsumerToken: s.consumerToken,
+			Type:          string(protobuf.BLS),
+			Status:        leveldb.KeyStatusActive,
+			PageSize:      ListKeysPageSize,
+			PageToken:     pageToken,
+		})
+		if err != nil {
+			return grpcError(err)
+		}
+		if resp.Code != wallet.ReturnCode_SUCCESS {
+			return &Error{Status: http.StatusInternalServerError, Message: resp.Msg}
+		}
+		for _, key := range resp.Keys {
+			pubkey := strings.ToLower(key.PublicKey.GetPubkey())
+			s.pubkeys.Store(pubkey, struct{}{})
+			if !fn(pubkey) {
+				return nil
+			}
+		}
+		if resp.NextPageToken == "" {
+			return nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// pubkey returns the public key identifier refers to, hex encoded without 0x.
+func (s *Signer) pubkey(ctx context.Context, identifier string) (string, error) {
+	pubkey := strings.ToLower(strings.TrimPrefix(identifier, "0x"))
+	if _, isOk := s.pubkeys.Load(pubkey); isOk {
+		return pubkey, nil
+	}
+	found := false
+	err := s.listKeys(ctx, func(listed string) bool {
+		found = listed == pubkey
+		return !found
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", &Error{Status: http.StatusNotFound, Message: fmt.Sprintf("public key %s not found", identifier)}
+	}
+	return pubkey, nil
+}
+
+// Sign signs the object of req with the key identifier refers to and returns the 0x prefixed signature.
+func (s *Signer) Sign(ctx context.Context, identifier string, req *SignRequest) (string, error) {
+	pubkey, err := s.pubkey(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+	signingRoot, err := req.signingRoot(s.genesisForkVersion)
+	if err != nil {
+		return "", badRequest(err)
+	}
+	if len(req.SigningRoot) > 0 && !bytes.Equal(req.SigningRoot, signingRoot[:]) {
+		return "", &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("signingRoot %s is not the signing root %s of the %s", req.SigningRoot, hexutil.Encode(signingRoot[:]), req.Type)}
+	}
+	if err := s.checkSlashing(pubkey, req, signingRoot); err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.SignTxMessage(ctx, &wallet.SignTxMessageRequest{
+		ConsumerToken: s.consumerToken,
+		Type:          string(protobuf.BLS),
+		PublicKey:     pubkey,
+		MessageHash:   hexutil.Encode(signingRoot[:]),
+	})
+	if err != nil {
+		return "", grpcError(err)
+	}
+	switch resp.Code {
+	case wallet.ReturnCode_SUCCESS:
+		return hexutil.Encode(common.FromHex(resp.Signature)), nil
+	case wallet.ReturnCode_PERMISSION_DENIED:
+		return "", &Error{Status: http.StatusNotFound, Message: resp.Msg}
+	default:
+		return "", &Error{Status: http.StatusInternalServerError, Message: fmt.Sprintf("%s: %s", resp.Code, resp.Msg)}
+	}
+}
+
+// checkSlashing records the block or the attestation of req, refusing the ones that could get
+// the validator slashed. The other objects are not slashable.
+func (s *Signer) checkSlashing(pubkey string, req *SignRequest, signingRoot root) error {
+	var err error
+	switch req.Type {
+	case TypeBlockV2:
+		err = s.slashing.CheckAndRecordBlock(hex.EncodeToString(req.ForkInfo.GenesisValidatorsRoot), pubkey,
+			uint64(req.BeaconBlock.BlockHeader.Slot), hex.EncodeToString(signingRoot[:]))
+	case TypeAttestation:
+		err = s.slashing.CheckAndRecordAttestation(hex.EncodeToString(req.ForkInfo.GenesisValidatorsRoot), pubkey,
+			uint64(req.Attestation.Source.Epoch), uint64(req.Attestation.Target.Epoch), hex.EncodeToString(signingRoot[:]))
+	default:
+		return nil
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, leveldb.ErrSlashable):
+		return &Error{Status: http.StatusPreconditionFailed, Message: fmt.Sprintf("refused by slashing protection: %v", err)}
+	default:
+		return grpcError(err)
+	}
+}
+
+func badRequest(err error) error {
+	if errors.Is(err, errInvalidRequest) {
+		return &Error{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// grpcError reports the error of a WalletService call, or of the key store, as unavailable when
+// the key store is not open and as an internal error otherwise.
+func grpcError(err error) error {
+	st := status.Convert(err)
+	if st.Code() == codes.Unavailable {
+		return &Error{Status: http.StatusServiceUnavailable, Message: st.Message()}
+	}
+	return &Error{Status: http.StatusInternalServerError, Message: st.Message()}
+}
+
+func unmarshalField(name string, data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return invalidRequest("%s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package web3signer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/qiaopengjun5162/web3-wallet-sign/leveldb"
+	"github.com/qiaopengjun5162/web3-wallet-sign/protobuf/wallet"
+	"github.com/qiaopengjun5162/web3-wallet-sign/ssm"
+)
+
+// fakeWalletClient holds a single BLS key usable by the consumer token "token".
+type fakeWalletClient struct {
+	wallet.WalletServiceClient
+	privateKey string
+	pubkey     string
+}
+
+func (f *fakeWalletClient) ListKeys(_ context.Context, in *wallet.ListKeysRequest, _ ...grpc.CallOption) (*wallet.ListKeysResponse, error) {
+	if in.ConsumerToken != "token" {
+		return &wallet.ListKeysResponse{Code: wallet.ReturnCode_PERMISSION_DENIED, Msg: "unknown consumer token"}, nil
+	}
+	return &wallet.ListKeysResponse{Code: wallet.ReturnCode_SUCCESS, Keys: []*wallet.KeyInfo{{PublicKey: &wallet.PublicKey{Pubkey: f.pubkey}}}}, nil
+}
+
+func (f *fakeWalletClient) SignTxMessage(_ context.Context, in *wallet.SignTxMessageRequest, _ ...grpc.CallOption) (*wallet.SignTxMessageResponse, error) {
+	if in.ConsumerToken != "token" || in.PublicKey != f.pubkey || in.Type != "bls" {
+		return &wallet.SignTxMessageResponse{Code: wallet.ReturnCode_PERMISSION_DENIED, Msg: "key is not accessible by the consumer"}, nil
+	}
+	signature, err := ssm.SignBLSMessage(f.privateKey, in.MessageHash)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet.SignTxMessageResponse{Code: wallet.ReturnCode_SUCCESS, Signature: signature}, nil
+}
+
+func newTestServer(t *testing.T, authToken string) (*httptest.Server, string) {
+	privateKey, pubkey, err := ssm.CreateBLSKeyPair()
+	assert.NoError(t, err)
+	keys, err := leveldb.NewKeys(leveldb.NewMemoryStore())
+	assert.NoError(t, err)
+	signer, err := NewSigner(&fakeWalletClient{privateKey: privateKey, pubkey: pubkey}, "token", []byte{0, 0, 0, 0}, keys)
+	assert.NoError(t, err)
+	srv := httptest.NewServer(NewHandler(signer, authToken))
+	t.Cleanup(srv.Close)
+	return srv, pubkey
+}
+
+func post(t *testing.T, url, accept, body string) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func attestationRequest(source, target int) string {
+	attestation := strings.NewReplacer(`"epoch":"100"`, fmt.Sprintf(`"epoch":"%d"`, source), `"epoch":"101"`, fmt.Sprintf(`"epoch":"%d"`, target)).Replace(testAttestation)
+	return fmt.Sprintf(`{"type":"ATTESTATION","fork_info":%s,"attestation":%s}`, testForkInfo, attestation)
+}
+
+func TestServer(t *testing.T) {
+	srv, pubkey := newTestServer(t, "")
+
+	resp, err := http.Get(srv.URL + "/upcheck")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/api/v1/eth2/publicKeys")
+	assert.NoError(t, err)
+	var pubkeys []string
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&pubkeys))
+	_ = resp.Body.Close()
+	assert.Equal(t, []string{"0x" + pubkey}, pubkeys)
+
+	signURL := srv.URL + "/api/v1/eth2/sign/0x" + pubkey
+	body := attestationRequest(100, 101)
+	var req SignRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &req))
+	signingRoot, err := req.signingRoot([]byte{0, 0, 0, 0})
+	assert.NoError(t, err)
+
+	code, signature := post(t, signURL, "", body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, ssm.VerifyBLSSignature(pubkey, fmt.Sprintf("%x", signingRoot), signature))
+	// the same attestation is signed again
+	code, result := post(t, signURL, "application/json", body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, fmt.Sprintf(`{"signature":%q}`, signature), result)
+
+	// double vote
+	code, _ = post(t, signURL, "", strings.Replace(attestationRequest(100, 101), `"index":"2"`, `"index":"3"`, 1))
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	// surround vote
+	code, _ = post(t, signURL, "", attestationRequest(99, 102))
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	code, _ = post(t, signURL, "", attestationRequest(101, 102))
+	assert.Equal(t, http.StatusOK, code)
+
+	block := fmt.Sprintf(`{"type":"BLOCK_V2","fork_info":%s,"beacon_block":{"version":"DENEB","block_header":%s}}`, testForkInfo, testBlockHeader)
+	code, _ = post(t, signURL, "", block)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = post(t, signURL, "", strings.Replace(block, `"proposer_index":"7"`, `"proposer_index":"8"`, 1))
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+
+	// the signing root of the client must match
+	code, _ = post(t, signURL, "", fmt.Sprintf(`{"type":"RANDAO_REVEAL","fork_info":%s,"randao_reveal":{"epoch":"101"},"signingRoot":"%s"}`, testForkInfo, rep("00", 32)))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post(t, signURL, "", fmt.Sprintf(`{"type":"RANDAO_REVEAL","fork_info":%s,"randao_reveal":{"epoch":"101"},"signingRoot":"0xba3f564f1b3ec0e2aa97b519888bc8eb94429fb141161f73dfbccb4b39540d88"}`, testForkInfo))
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = post(t, signURL, "", `{"type":"ATTESTATION"`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post(t, srv.URL+"/api/v1/eth2/sign/0x"+rep("88", 48)[2:], "", body)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestServerAuth(t *testing.T) {
+	srv, pubkey := newTestServer(t, "secret")
+
+	resp, err := http.Get(srv.URL + "/upcheck")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	for _, authorization := range []string{"", "Bearer other", "secret"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/eth2/publicKeys", nil)
+		assert.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+	code, _ := post(t, srv.URL+"/api/v1/eth2/sign/0x"+pubkey, "", attestationRequest(100, 101))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/eth2/publicKeys", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+}
+
+func TestStartServer(t *testing.T) {
+	keys, err := leveldb.NewKeys(leveldb.NewMemoryStore())
+	assert.NoError(t, err)
+	_, err = StartServer("127.0.0.1:1", "", "signer", "", []byte{0, 0, 0, 0}, keys, "127.0.0.1", 0)
+	assert.EqualError(t, err, "the consumer token of the web3signer server is not set")
+	// the listeners reachable from other machines need an auth token
+	_, err = StartServer("127.0.0.1:1", "token", "signer", "", []byte{0, 0, 0, 0}, keys, "0.0.0.0", 0)
+	assert.EqualError(t, err, "the web3signer server listening on 0.0.0.0 needs an auth token")
+
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		srv, err := StartServer("127.0.0.1:1", "token", "signer", "", []byte{0, 0, 0, 0}, keys, host, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Stop(t.Context()))
+	}
+	srv, err := StartServer("127.0.0.1:1", "token", "signer", "secret", []byte{0, 0, 0, 0}, keys, "0.0.0.0", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Stop(t.Context()))
+}
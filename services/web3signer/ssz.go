@@ -0,0 +1,120 @@
+package web3signer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// the hash tree roots of the SSZ types of the signed objects, see the simple serialize spec of
+// the consensus specs. Only the types the signing requests carry are supported.
+
+type root = [32]byte
+
+// zeroHashes[i] is the root of a subtree of depth i with only zero chunks.
+var zeroHashes = func() [64]root {
+	var hashes [64]root
+	for i := 1; i < len(hashes); i++ {
+		hashes[i] = hashPair(hashes[i-1], hashes[i-1])
+	}
+	return hashes
+}()
+
+func hashPair(a, b root) root {
+	return sha256.Sum256(append(a[:], b[:]...))
+}
+
+// merkleize returns the root of chunks padded with zero chunks to limit, rounded up to a power of two.
+func merkleize(chunks []root, limit int) (root, error) {
+	if len(chunks) > limit {
+		return root{}, fmt.Errorf("%d chunks exceed the limit %d", len(chunks), limit)
+	}
+	depth := 0
+	if limit > 1 {
+		depth = bits.Len(uint(limit - 1))
+	}
+	if len(chunks) == 0 {
+		return zeroHashes[depth], nil
+	}
+	layer := append([]root(nil), chunks...)
+	for i := 0; i < depth; i++ {
+		if len(layer)%2 == 1 {
+			layer = append(layer, zeroHashes[i])
+		}
+		next := make([]root, len(layer)/2)
+		for j := range next {
+			next[j] = hashPair(layer[2*j], layer[2*j+1])
+		}
+		layer = next
+	}
+	return layer[0], nil
+}
+
+// containerRoot returns the root of a container of the roots of its fields.
+func containerRoot(fields ...root) root {
+	r, _ := merkleize(fields, len(fields))
+	return r
+}
+
+func mixInLength(r root, length uint64) root {
+	var chunk root
+	binary.LittleEndian.PutUint64(chunk[:], length)
+	return hashPair(r, chunk)
+}
+
+// pack splits data into chunks, the last one padded with zeros.
+func pack(data []byte) []root {
+	chunks := make([]root, (len(data)+31)/32)
+	for i := range chunks {
+		copy(chunks[i][:], data[32*i:])
+	}
+	return chunks
+}
+
+func uint64Root(v uint64) root {
+	var chunk root
+	binary.LittleEndian.PutUint64(chunk[:], v)
+	return chunk
+}
+
+// bytesRoot returns the root of the fixed size byte vector data.
+func bytesRoot(data []byte) root {
+	chunks := pack(data)
+	r, _ := merkleize(chunks, len(chunks))
+	return r
+}
+
+// bitvectorRoot returns the root of the Bitvector[size] data.
+func bitvectorRoot(data []byte, size int) (root, error) {
+	if len(data) != (size+7)/8 {
+		return root{}, fmt.Errorf("bitvector of %d bytes is not a Bitvector[%d]", len(data), size)
+	}
+	if size%8 != 0 && data[len(data)-1]>>(size%8) != 0 {
+		return root{}, fmt.Errorf("bitvector has bits set beyond Bitvector[%d]", size)
+	}
+	return merkleize(pack(data), (size+255)/256)
+}
+
+// bitlistRoot returns the root of the Bitlist[limit] data, whose last set bit delimits the list.
+func bitlistRoot(data []byte, limit int) (root, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return root{}, errors.New("bitlist has no delimiting bit")
+	}
+	last := data[len(data)-1]
+	length := 8*(len(data)-1) + bits.Len8(last) - 1
+	if length > limit {
+		return root{}, fmt.Errorf("bitlist of %d bits exceeds Bitlist[%d]", length, limit)
+	}
+	list := append([]byte(nil), data...)
+	list[len(list)-1] = last &^ (1 << (bits.Len8(last) - 1))
+	if length%8 == 0 {
+		list = list[:len(list)-1]
+	}
+	r, err := merkleize(pack(list), (limit+255)/256)
+	if err != nil {
+		return root{}, err
+	}
+	return mixInLength(r, uint64(length)), nil
+}
@@ -0,0 +1,175 @@
+package web3signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The types of the signing requests.
+const (
+	TypeBlockV2                           = "BLOCK_V2"
+	TypeAttestation                       = "ATTESTATION"
+	TypeAggregationSlot                   = "AGGREGATION_SLOT"
+	TypeAggregateAndProof                 = "AGGREGATE_AND_PROOF"
+	TypeAggregateAndProofV2               = "AGGREGATE_AND_PROOF_V2"
+	TypeRandaoReveal                      = "RANDAO_REVEAL"
+	TypeVoluntaryExit                     = "VOLUNTARY_EXIT"
+	TypeSyncCommitteeMessage              = "SYNC_COMMITTEE_MESSAGE"
+	TypeSyncCommitteeSelectionProof       = "SYNC_COMMITTEE_SELECTION_PROOF"
+	TypeSyncCommitteeContributionAndProof = "SYNC_COMMITTEE_CONTRIBUTION_AND_PROOF"
+	TypeDeposit                           = "DEPOSIT"
+	TypeValidatorRegistration             = "VALIDATOR_REGISTRATION"
+)
+
+// Uint64 is a uint64 encoded as a decimal string, as the beacon APIs do. Numbers are accepted too.
+type Uint64 uint64
+
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(u), 10))
+}
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64 %s", data)
+	}
+	*u = Uint64(v)
+	return nil
+}
+
+// SignRequest is the body of a signing request. Type tells which of the objects is signed.
+type SignRequest struct {
+	Type     string    `json:"type"`
+	ForkInfo *ForkInfo `json:"fork_info,omitempty"`
+	// SigningRoot is the signing root computed by the client, checked against the one computed by the signer
+	SigningRoot hexutil.Bytes `json:"signingRoot,omitempty"`
+
+	BeaconBlock                 *BeaconBlock                 `json:"beacon_block,omitempty"`
+	Attestation                 *AttestationData             `json:"attestation,omitempty"`
+	AggregationSlot             *AggregationSlot             `json:"aggregation_slot,omitempty"`
+	AggregateAndProof           json.RawMessage              `json:"aggregate_and_proof,omitempty"`
+	RandaoReveal                *RandaoReveal                `json:"randao_reveal,omitempty"`
+	VoluntaryExit               *VoluntaryExit               `json:"voluntary_exit,omitempty"`
+	SyncCommitteeMessage        *SyncCommitteeMessage        `json:"sync_committee_message,omitempty"`
+	SyncAggregatorSelectionData *SyncAggregatorSelectionData `json:"sync_aggregator_selection_data,omitempty"`
+	ContributionAndProof        *ContributionAndProof        `json:"contribution_and_proof,omitempty"`
+	Deposit                     *DepositMessage              `json:"deposit,omitempty"`
+	ValidatorRegistration       *ValidatorRegistration       `json:"validator_registration,omitempty"`
+}
+
+type ForkInfo struct {
+	Fork                  Fork          `json:"fork"`
+	GenesisValidatorsRoot hexutil.Bytes `json:"genesis_validators_root"`
+}
+
+type Fork struct {
+	PreviousVersion hexutil.Bytes `json:"previous_version"`
+	CurrentVersion  hexutil.Bytes `json:"current_version"`
+	Epoch           Uint64        `json:"epoch"`
+}
+
+// BeaconBlock is the block of a BLOCK_V2 request. Only the header of the block is supported,
+// as sent by the validator clients since Bellatrix.
+type BeaconBlock struct {
+	Version     string             `json:"version"`
+	Block       json.RawMessage    `json:"block,omitempty"`
+	BlockHeader *BeaconBlockHeader `json:"block_header,omitempty"`
+}
+
+type BeaconBlockHeader struct {
+	Slot          Uint64        `json:"slot"`
+	ProposerIndex Uint64        `json:"proposer_index"`
+	ParentRoot    hexutil.Bytes `json:"parent_root"`
+	StateRoot     hexutil.Bytes `json:"state_root"`
+	BodyRoot      hexutil.Bytes `json:"body_root"`
+}
+
+type Checkpoint struct {
+	Epoch Uint64        `json:"epoch"`
+	Root  hexutil.Bytes `json:"root"`
+}
+
+type AttestationData struct {
+	Slot            Uint64        `json:"slot"`
+	Index           Uint64        `json:"index"`
+	BeaconBlockRoot hexutil.Bytes `json:"beacon_block_root"`
+	Source          Checkpoint    `json:"source"`
+	Target          Checkpoint    `json:"target"`
+}
+
+// Attestation is an aggregate attestation, CommitteeBits is only set since Electra.
+type Attestation struct {
+	AggregationBits hexutil.Bytes   `json:"aggregation_bits"`
+	Data            AttestationData `json:"data"`
+	Signature       hexutil.Bytes   `json:"signature"`
+	CommitteeBits   hexutil.Bytes   `json:"committee_bits,omitempty"`
+}
+
+type AggregateAndProof struct {
+	AggregatorIndex Uint64        `json:"aggregator_index"`
+	Aggregate       Attestation   `json:"aggregate"`
+	SelectionProof  hexutil.Bytes `json:"selection_proof"`
+}
+
+// VersionedAggregateAndProof is the aggregate_and_proof of an AGGREGATE_AND_PROOF_V2 request.
+type VersionedAggregateAndProof struct {
+	Version string            `json:"version"`
+	Data    AggregateAndProof `json:"data"`
+}
+
+type AggregationSlot struct {
+	Slot Uint64 `json:"slot"`
+}
+
+type RandaoReveal struct {
+	Epoch Uint64 `json:"epoch"`
+}
+
+type VoluntaryExit struct {
+	Epoch          Uint64 `json:"epoch"`
+	ValidatorIndex Uint64 `json:"validator_index"`
+}
+
+type SyncCommitteeMessage struct {
+	BeaconBlockRoot hexutil.Bytes `json:"beacon_block_root"`
+	Slot            Uint64        `json:"slot"`
+}
+
+type SyncAggregatorSelectionData struct {
+	Slot              Uint64 `json:"slot"`
+	SubcommitteeIndex Uint64 `json:"subcommittee_index"`
+}
+
+type SyncCommitteeContribution struct {
+	Slot              Uint64        `json:"slot"`
+	BeaconBlockRoot   hexutil.Bytes `json:"beacon_block_root"`
+	SubcommitteeIndex Uint64        `json:"subcommittee_index"`
+	AggregationBits   hexutil.Bytes `json:"aggregation_bits"`
+	Signature         hexutil.Bytes `json:"signature"`
+}
+
+type ContributionAndProof struct {
+	AggregatorIndex Uint64                    `json:"aggregator_index"`
+	Contribution    SyncCommitteeContribution `json:"contribution"`
+	SelectionProof  hexutil.Bytes             `json:"selection_proof"`
+}
+
+// DepositMessage is the deposit of a DEPOSIT request, signed for the network of GenesisForkVersion.
+type DepositMessage struct {
+	Pubkey                hexutil.Bytes `json:"pubkey"`
+	WithdrawalCredentials hexutil.Bytes `json:"withdrawal_credentials"`
+	Amount                Uint64        `json:"amount"`
+	GenesisForkVersion    hexutil.Bytes `json:"genesis_fork_version"`
+}
+
+// ValidatorRegistration is the registration of the validator with the block builders, see the builder specs.
+type ValidatorRegistration struct {
+	FeeRecipient hexutil.Bytes `json:"fee_recipient"`
+	GasLimit     Uint64        `json:"gas_limit"`
+	Timestamp    Uint64        `json:"timestamp"`
+	Pubkey       hexutil.Bytes `json:"pubkey"`
+}
@@ -0,0 +1,116 @@
+// Package shamir implements Shamir's secret sharing over GF(256).
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the maximum number of shares a secret can be split into.
+const MaxShares = 255
+
+// expTable and logTable hold the exponentials and logarithms of the generator 3
+// in GF(2^8) with the AES reduction polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		// x *= 3
+		x ^= xtime(x)
+	}
+	expTable[255] = expTable[0]
+}
+
+func xtime(x byte) byte {
+	if x&0x80 != 0 {
+		return x<<1 ^ 0x1b
+	}
+	return x << 1
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Split splits secret into n shares, any threshold of which reconstruct the secret.
+//
+// The share of index i (1 <= i <= n) is the evaluation at x = i of random polynomials
+// of degree threshold-1 whose constant terms are the bytes of the secret.
+func Split(secret []byte, n, threshold int) (map[byte][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret is empty")
+	}
+	if threshold < 2 || threshold > n || n > MaxShares {
+		return nil, fmt.Errorf("invalid threshold %d of %d shares", threshold, n)
+	}
+
+	coefficients := make([]byte, threshold)
+	shares := make(map[byte][]byte, n)
+	for x := 1; x <= n; x++ {
+		shares[byte(x)] = make([]byte, len(secret))
+	}
+	for i, secretByte := range secret {
+		coefficients[0] = secretByte
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for x := 1; x <= n; x++ {
+			// Horner's method
+			var y byte
+			for j := threshold - 1; j >= 0; j-- {
+				y = mul(y, byte(x)) ^ coefficients[j]
+			}
+			shares[byte(x)][i] = y
+		}
+	}
+	clear(coefficients)
+	return shares, nil
+}
+
+// Combine reconstructs a secret from shares keyed by their index with Lagrange interpolation at x = 0.
+//
+// Combine cannot tell whether enough shares are given: fewer than the threshold yield a wrong secret.
+func Combine(shares map[byte][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are required")
+	}
+	size := -1
+	for x, share := range shares {
+		if x == 0 {
+			return nil, errors.New("invalid share index 0")
+		}
+		if size >= 0 && len(share) != size {
+			return nil, errors.New("shares have different lengths")
+		}
+		size = len(share)
+	}
+
+	secret := make([]byte, size)
+	for xi, share := range shares {
+		// basis = prod(xj / (xj - xi)) for j != i, subtraction is xor in GF(2^8)
+		basis := byte(1)
+		for xj := range shares {
+			if xj != xi {
+				basis = mul(basis, div(xj, xj^xi))
+			}
+		}
+		for i := range secret {
+			secret[i] ^= mul(share[i], basis)
+		}
+	}
+	return secret, nil
+}
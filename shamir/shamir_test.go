@@ -0,0 +1,89 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGF256(t *testing.T) {
+	// FIPS-197 section 4.2: {57} * {83} = {c1}
+	assert.Equal(t, byte(0xc1), mul(0x57, 0x83))
+	for a := 1; a < 256; a++ {
+		assert.Equal(t, byte(a), div(mul(byte(a), 0x53), 0x53))
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+
+	shares, err := Split(secret, 5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+
+	subsets := [][]byte{{1, 2, 3}, {1, 3, 5}, {2, 4, 5}, {1, 2, 3, 4, 5}}
+	for _, subset := range subsets {
+		parts := make(map[byte][]byte)
+		for _, x := range subset {
+			parts[x] = shares[x]
+		}
+		combined, err := Combine(parts)
+		assert.NoError(t, err)
+		assert.Equal(t, secret, combined)
+	}
+
+	combined, err := Combine(map[byte][]byte{1: shares[1], 2: shares[2]})
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, combined)
+
+	_, err = Split(secret, 3, 4)
+	assert.Error(t, err)
+	_, err = Split(secret, 3, 1)
+	assert.Error(t, err)
+}
+
+func TestShares(t *testing.T) {
+	secret := []byte("master seed of the key store....")
+	shares, err := SplitSecret(secret, 5, 3)
+	assert.NoError(t, err)
+
+	var parsed []*Share
+	for _, share := range shares[2:] {
+		p, err := ParseShare(share.String())
+		assert.NoError(t, err)
+		parsed = append(parsed, p)
+	}
+	combined, err := CombineShares(parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, combined)
+
+	_, err = CombineShares(parsed[:2])
+	assert.Error(t, err)
+	_, err = CombineShares([]*Share{parsed[0], parsed[0], parsed[1]})
+	assert.Error(t, err)
+
+	// a typo is caught by the checksum
+	encoded := []byte(shares[0].String())
+	if encoded[len(SharePrefix)+20] == '0' {
+		encoded[len(SharePrefix)+20] = '1'
+	} else {
+		encoded[len(SharePrefix)+20] = '0'
+	}
+	_, err = ParseShare(string(encoded))
+	assert.ErrorIs(t, err, ErrShareChecksum)
+
+	// shares of another split are rejected
+	others, err := SplitSecret(secret, 5, 3)
+	assert.NoError(t, err)
+	_, err = CombineShares([]*Share{parsed[0], parsed[1], others[0]})
+	assert.ErrorIs(t, err, ErrShareGroup)
+
+	// a share with a consistent checksum but a wrong value is caught by the fingerprint
+	forged := *parsed[0]
+	forged.Value = bytes.Repeat([]byte{0x42}, len(forged.Value))
+	_, err = CombineShares([]*Share{&forged, parsed[1], parsed[2]})
+	assert.ErrorIs(t, err, ErrSecretFingerprint)
+}
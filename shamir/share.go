@@ -0,0 +1,170 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SharePrefix starts every encoded share.
+	SharePrefix = "wss1-"
+
+	shareVersion     = 1
+	fingerprintSize  = 4
+	checksumSize     = 4
+	shareHeaderSize  = 1 + 4 + 1 + 1 + 1 + fingerprintSize
+	minEncodedLength = shareHeaderSize + 1 + checksumSize
+)
+
+var (
+	// ErrShareChecksum means a share was mistyped or corrupted.
+	ErrShareChecksum = errors.New("share checksum mismatch")
+	// ErrShareGroup means shares of different splits are mixed.
+	ErrShareGroup = errors.New("shares belong to different groups")
+	// ErrSecretFingerprint means the reconstructed secret does not match the split secret.
+	ErrSecretFingerprint = errors.New("reconstructed secret does not match its fingerprint")
+)
+
+// Share is one share of a split secret.
+//
+// All shares of a split have the same GroupID, Threshold, Total and Fingerprint, the
+// Fingerprint identifies the secret so a wrong reconstruction is detected.
+type Share struct {
+	GroupID     uint32
+	Threshold   uint8
+	Total       uint8
+	Index       uint8
+	Fingerprint [fingerprintSize]byte
+	Value       []byte
+}
+
+// SplitSecret splits secret into total shares of a new random group, any threshold of which
+// reconstruct the secret with CombineShares.
+func SplitSecret(secret []byte, total, threshold int) ([]*Share, error) {
+	values, err := Split(secret, total, threshold)
+	if err != nil {
+		return nil, err
+	}
+	var group [4]byte
+	if _, err := rand.Read(group[:]); err != nil {
+		return nil, err
+	}
+	groupID := binary.BigEndian.Uint32(group[:])
+	fingerprint := secretFingerprint(groupID, secret)
+
+	shares := make([]*Share, 0, total)
+	for x := 1; x <= total; x++ {
+		shares = append(shares, &Share{
+			GroupID:     groupID,
+			Threshold:   uint8(threshold),
+			Total:       uint8(total),
+			Index:       uint8(x),
+			Fingerprint: fingerprint,
+			Value:       values[byte(x)],
+		})
+	}
+	return shares, nil
+}
+
+// CombineShares reconstructs a secret from at least Threshold shares of the same group.
+func CombineShares(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	first := shares[0]
+	values := make(map[byte][]byte, len(shares))
+	for _, share := range shares {
+		if err := first.CheckSameGroup(share); err != nil {
+			return nil, err
+		}
+		if _, exist := values[share.Index]; exist {
+			return nil, fmt.Errorf("duplicate share %d", share.Index)
+		}
+		values[share.Index] = share.Value
+	}
+	if len(values) < int(first.Threshold) {
+		return nil, fmt.Errorf("need %d shares, got %d", first.Threshold, len(values))
+	}
+
+	secret, err := Combine(values)
+	if err != nil {
+		return nil, err
+	}
+	if secretFingerprint(first.GroupID, secret) != first.Fingerprint {
+		return nil, ErrSecretFingerprint
+	}
+	return secret, nil
+}
+
+// String encodes the share as SharePrefix followed by the hex encoded share and its checksum.
+func (s *Share) String() string {
+	data := []byte{shareVersion}
+	data = binary.BigEndian.AppendUint32(data, s.GroupID)
+	data = append(data, s.Threshold, s.Total, s.Index)
+	data = append(data, s.Fingerprint[:]...)
+	data = append(data, s.Value...)
+	checksum := sha256.Sum256(data)
+	data = append(data, checksum[:checksumSize]...)
+	return SharePrefix + hex.EncodeToString(data)
+}
+
+// ParseShare decodes a share encoded by Share.String, verifying its checksum.
+func ParseShare(encoded string) (*Share, error) {
+	encoded = strings.ToLower(strings.Join(strings.Fields(encoded), ""))
+	if !strings.HasPrefix(encoded, SharePrefix) {
+		return nil, fmt.Errorf("share must start with %s", SharePrefix)
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(encoded, SharePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid share: %w", err)
+	}
+	if len(data) < minEncodedLength {
+		return nil, errors.New("share is too short")
+	}
+	body, checksum := data[:len(data)-checksumSize], data[len(data)-checksumSize:]
+	if sum := sha256.Sum256(body); !bytes.Equal(sum[:checksumSize], checksum) {
+		return nil, ErrShareChecksum
+	}
+	if body[0] != shareVersion {
+		return nil, fmt.Errorf("unsupported share version %d", body[0])
+	}
+
+	share := &Share{
+		GroupID:   binary.BigEndian.Uint32(body[1:5]),
+		Threshold: body[5],
+		Total:     body[6],
+		Index:     body[7],
+		Value:     bytes.Clone(body[shareHeaderSize:]),
+	}
+	copy(share.Fingerprint[:], body[8:shareHeaderSize])
+	if share.Threshold < 2 || share.Threshold > share.Total || share.Index == 0 || share.Index > share.Total {
+		return nil, errors.New("invalid share header")
+	}
+	return share, nil
+}
+
+// CheckSameGroup returns an error if other is not a share of the same split as s,
+// so a share entered during a recovery ceremony can be rejected right away.
+func (s *Share) CheckSameGroup(other *Share) error {
+	if other.GroupID != s.GroupID || other.Fingerprint != s.Fingerprint {
+		return ErrShareGroup
+	}
+	if other.Threshold != s.Threshold || other.Total != s.Total || len(other.Value) != len(s.Value) {
+		return fmt.Errorf("%w: share %d has a different header", ErrShareGroup, other.Index)
+	}
+	return nil
+}
+
+func secretFingerprint(groupID uint32, secret []byte) [fingerprintSize]byte {
+	data := binary.BigEndian.AppendUint32(nil, groupID)
+	sum := sha256.Sum256(append(data, secret...))
+	var fingerprint [fingerprintSize]byte
+	copy(fingerprint[:], sum[:])
+	return fingerprint
+}
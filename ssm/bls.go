@@ -0,0 +1,78 @@
+package ssm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// blsDST is the domain separation tag of the proof of possession BLS scheme used by Ethereum consensus.
+var blsDST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+
+// CreateBLSKeyPair generates a new BLS12-381 key pair, the public key in G1 and the signatures in G2.
+//
+// Returns:
+// - A string representing the 32-byte private key in hexadecimal format.
+// - A string representing the 48-byte compressed public key in hexadecimal format.
+// - An error if the key generation fails.
+func CreateBLSKeyPair() (string, string, error) {
+	ikm := make([]byte, 32)
+	if _, err := rand.Read(ikm); err != nil {
+		log.Error("read key material fail", "err", err)
+		return EmptyHexString, EmptyHexString, err
+	}
+	secretKey := blst.KeyGen(ikm)
+	defer secretKey.Zeroize()
+	return hex.EncodeToString(secretKey.Serialize()), BLSPublicKey(secretKey), nil
+}
+
+// BLSPublicKey returns the compressed public key of secretKey in hexadecimal format.
+func BLSPublicKey(secretKey *blst.SecretKey) string {
+	return hex.EncodeToString(new(blst.P1Affine).From(secretKey).Compress())
+}
+
+// ParseBLSPrivateKey parses a hex encoded 32-byte BLS private key.
+func ParseBLSPrivateKey(privateKey string) (*blst.SecretKey, error) {
+	secretKey := new(blst.SecretKey).Deserialize(common.FromHex(privateKey))
+	if secretKey == nil {
+		return nil, errors.New("invalid bls private key")
+	}
+	return secretKey, nil
+}
+
+// SignBLSMessage signs a given message, usually a 32-byte signing root, with a given BLS private key.
+//
+// The private key and the message are expected to be in hexadecimal format.
+//
+// The function returns the 96-byte compressed signature in hexadecimal format, or an error if any
+// of the operations fail.
+func SignBLSMessage(privateKey string, message string) (string, error) {
+	secretKey, err := ParseBLSPrivateKey(privateKey)
+	if err != nil {
+		return EmptyHexString, err
+	}
+	defer secretKey.Zeroize()
+	signature := new(blst.P2Affine).Sign(secretKey, common.FromHex(message), blsDST)
+	return hex.EncodeToString(signature.Compress()), nil
+}
+
+// VerifyBLSSignature verifies a given message signature using a given BLS public key.
+//
+// The public key, message and signature are expected to be in hexadecimal format.
+//
+// Returns true if the signature is valid, or false if it is not.
+func VerifyBLSSignature(publicKey, message, signature string) bool {
+	pubkey := new(blst.P1Affine).Uncompress(common.FromHex(publicKey))
+	if pubkey == nil {
+		return false
+	}
+	sig := new(blst.P2Affine).Uncompress(common.FromHex(signature))
+	if sig == nil {
+		return false
+	}
+	return sig.Verify(true, pubkey, true, common.FromHex(message), blsDST)
+}
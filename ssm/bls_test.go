@@ -0,0 +1,36 @@
+package ssm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignBLSMessage(t *testing.T) {
+	// the sign test vectors of the consensus specs
+	privateKey := "263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3"
+	secretKey, err := ParseBLSPrivateKey(privateKey)
+	assert.NoError(t, err)
+	publicKey := BLSPublicKey(secretKey)
+	assert.Equal(t, "a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a", publicKey)
+
+	message := "0x0000000000000000000000000000000000000000000000000000000000000000"
+	signature, err := SignBLSMessage(privateKey, message)
+	assert.NoError(t, err)
+	assert.Equal(t, "b6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55", signature)
+	assert.True(t, VerifyBLSSignature(publicKey, message, signature))
+	assert.False(t, VerifyBLSSignature(publicKey, "0x01", signature))
+
+	_, err = ParseBLSPrivateKey("00")
+	assert.Error(t, err)
+}
+
+func TestCreateBLSKeyPair(t *testing.T) {
+	privateKey, publicKey, err := CreateBLSKeyPair()
+	assert.NoError(t, err)
+	assert.Len(t, privateKey, 64)
+	assert.Len(t, publicKey, 96)
+	signature, err := SignBLSMessage(privateKey, "abcd")
+	assert.NoError(t, err)
+	assert.True(t, VerifyBLSSignature(publicKey, "abcd", signature))
+}
@@ -4,6 +4,7 @@ package ssm
 import (
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -95,6 +96,54 @@ func VerifyEcdsaSignature(publicKey, txHash, signature string) (bool, error) {
 		return false, err
 	}
 
+	if len(sigBytes) < 64 {
+		return false, fmt.Errorf("invalid signature length %d", len(sigBytes))
+	}
+
 	// Verify the transaction signature using the public key
 	return crypto.VerifySignature(pubKeyBytes, txHashBytes, sigBytes[:64]), nil
 }
+
+// RecoverEcdsaPublicKey recovers the public key that produced a given transaction signature.
+//
+// The transaction hash is expected to be a 32 bytes hash in hexadecimal format,
+// optionally prefixed with 0x. Shorter or longer hashes are rejected instead of
+// being padded or truncated.
+//
+// The signature is expected to be a 65 bytes [R || S || V] signature in hexadecimal
+// format, where V is either 0/1 or 27/28.
+//
+// Returns:
+// - A string representing the uncompressed public key in hexadecimal format.
+// - A string representing the compressed public key in hexadecimal format.
+// - A string representing the address of the public key.
+// - An error if the signature is malformed or the recovery fails.
+func RecoverEcdsaPublicKey(txHash, signature string) (string, string, string, error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(txHash, "0x"))
+	if err != nil {
+		log.Error("Error converting transaction hash to bytes", "err", err)
+		return EmptyHexString, EmptyHexString, EmptyHexString, err
+	}
+	if len(hash) != common.HashLength {
+		return EmptyHexString, EmptyHexString, EmptyHexString, fmt.Errorf("invalid transaction hash length %d", len(hash))
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		log.Error("Error converting signature to bytes", "err", err)
+		return EmptyHexString, EmptyHexString, EmptyHexString, err
+	}
+	if len(sigBytes) != crypto.SignatureLength {
+		return EmptyHexString, EmptyHexString, EmptyHexString, fmt.Errorf("invalid signature length %d", len(sigBytes))
+	}
+	if sigBytes[crypto.RecoveryIDOffset] >= 27 {
+		sigBytes[crypto.RecoveryIDOffset] -= 27
+	}
+	publicKey, err := crypto.SigToPub(hash, sigBytes)
+	if err != nil {
+		log.Error("recover public key fail", "err", err)
+		return EmptyHexString, EmptyHexString, EmptyHexString, err
+	}
+	pubKeyStr := hex.EncodeToString(crypto.FromECDSAPub(publicKey))
+	compressPubkeyStr := hex.EncodeToString(crypto.CompressPubkey(publicKey))
+	return pubKeyStr, compressPubkeyStr, crypto.PubkeyToAddress(*publicKey).Hex(), nil
+}
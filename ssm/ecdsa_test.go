@@ -50,3 +50,30 @@ func TestVerifyEcdsaSignature(t *testing.T) {
 		t.Error("Signature is invalid")
 	}
 }
+
+func TestRecoverEcdsaPublicKey(t *testing.T) {
+	txHash := "0x3e4f9a460233ec33862da1ac3dabf5b32db01400fba166cdec40ad6dc735b4ab"
+	signature := "f8c9ab615ffd81f74d9db8765e25ce260ba3b4da1c6af2a52dedc697dcff833b6cfe576a1b6b7106a6880d8057639d4b87a67001c69594df29d928d6048912f900"
+
+	_, compressPubkey, address, err := RecoverEcdsaPublicKey(txHash, signature)
+	if err != nil {
+		t.Error("Failed to recover public key:", err)
+	}
+
+	assert.Equal(t, "028846b3ce4376e8d58c83c1c6420a784caa675d7f26c496f499585d09891af8fc", compressPubkey)
+	assert.Equal(t, "0x82565b64e8063674CAea7003979280f4dbC3aAE7", address)
+}
+
+func TestRecoverEcdsaPublicKeyHashLength(t *testing.T) {
+	signature := "f8c9ab615ffd81f74d9db8765e25ce260ba3b4da1c6af2a52dedc697dcff833b6cfe576a1b6b7106a6880d8057639d4b87a67001c69594df29d928d6048912f900"
+
+	for _, txHash := range []string{
+		"0x4f9a460233ec33862da1ac3dabf5b32db01400fba166cdec40ad6dc735b4ab",
+		"0x3e4f9a460233ec33862da1ac3dabf5b32db01400fba166cdec40ad6dc735b4ab00",
+		"0x3e4f9a460233ec33862da1ac3dabf5b32db01400fba166cdec40ad6dc735b4a",
+		"",
+	} {
+		_, _, _, err := RecoverEcdsaPublicKey(txHash, signature)
+		assert.Error(t, err, txHash)
+	}
+}
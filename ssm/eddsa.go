@@ -46,10 +46,13 @@ func SignEdDSAMessage(priKey string, txMsg string) (string, error) {
 // The public key, message hash, and signature are expected to be in hexadecimal format,
 // and are decoded internally into byte slices.
 //
-// Returns true if the signature is valid, or false if it is not.
+// Returns true if the signature is valid, or false if it is not or the public key is not 32 bytes long.
 func VerifyEdDSASign(pubKey, msgHash, sig string) bool {
 	publicKeyByte, _ := hex.DecodeString(pubKey)
 	msgHashByte, _ := hex.DecodeString(msgHash)
 	signature, _ := hex.DecodeString(sig)
+	if len(publicKeyByte) != ed25519.PublicKeySize {
+		return false
+	}
 	return ed25519.Verify(publicKeyByte, msgHashByte, signature)
 }
@@ -0,0 +1,135 @@
+package ssm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// eip2335Keystore is a BLS keystore of EIP-2335, as written by the staking deposit tools.
+type eip2335Keystore struct {
+	Crypto struct {
+		KDF      eip2335Module `json:"kdf"`
+		Checksum eip2335Module `json:"checksum"`
+		Cipher   eip2335Module `json:"cipher"`
+	} `json:"crypto"`
+	Pubkey  string `json:"pubkey"`
+	Path    string `json:"path"`
+	Version int    `json:"version"`
+}
+
+type eip2335Module struct {
+	Function string          `json:"function"`
+	Params   json.RawMessage `json:"params"`
+	Message  string          `json:"message"`
+}
+
+// DecryptEIP2335Keystore decrypts the secret of an EIP-2335 keystore with password.
+// It returns the secret and the derivation path recorded in the keystore.
+func DecryptEIP2335Keystore(data []byte, password string) ([]byte, string, error) {
+	var ks eip2335Keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, "", fmt.Errorf("invalid keystore: %w", err)
+	}
+	if ks.Version != 4 {
+		return nil, "", fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+
+	decryptionKey, err := eip2335DecryptionKey(ks.Crypto.KDF, eip2335Password(password))
+	if err != nil {
+		return nil, "", err
+	}
+	cipherMessage, err := hex.DecodeString(ks.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cipher message: %w", err)
+	}
+	if ks.Crypto.Checksum.Function != "sha256" {
+		return nil, "", fmt.Errorf("unsupported checksum function %q", ks.Crypto.Checksum.Function)
+	}
+	checksum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherMessage...))
+	if hex.EncodeToString(checksum[:]) != strings.ToLower(ks.Crypto.Checksum.Message) {
+		return nil, "", errors.New("invalid keystore password")
+	}
+
+	if ks.Crypto.Cipher.Function != "aes-128-ctr" {
+		return nil, "", fmt.Errorf("unsupported cipher function %q", ks.Crypto.Cipher.Function)
+	}
+	var cipherParams struct {
+		IV string `json:"iv"`
+	}
+	if err := json.Unmarshal(ks.Crypto.Cipher.Params, &cipherParams); err != nil {
+		return nil, "", fmt.Errorf("invalid cipher params: %w", err)
+	}
+	iv, err := hex.DecodeString(cipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, "", errors.New("invalid cipher iv")
+	}
+	block, err := aes.NewCipher(decryptionKey[:16])
+	if err != nil {
+		return nil, "", err
+	}
+	secret := make([]byte, len(cipherMessage))
+	cipher.NewCTR(block, iv).XORKeyStream(secret, cipherMessage)
+	return secret, ks.Path, nil
+}
+
+// eip2335DecryptionKey derives the 32-byte decryption key of a keystore from the password.
+func eip2335DecryptionKey(kdf eip2335Module, password []byte) ([]byte, error) {
+	var params struct {
+		DKLen int    `json:"dklen"`
+		Salt  string `json:"salt"`
+		// scrypt
+		N int `json:"n"`
+		R int `json:"r"`
+		P int `json:"p"`
+		// pbkdf2
+		C   int    `json:"c"`
+		PRF string `json:"prf"`
+	}
+	if err := json.Unmarshal(kdf.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid kdf params: %w", err)
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kdf salt: %w", err)
+	}
+	if params.DKLen < 32 {
+		return nil, fmt.Errorf("invalid kdf dklen %d", params.DKLen)
+	}
+	switch kdf.Function {
+	case "scrypt":
+		return scrypt.Key(password, salt, params.N, params.R, params.P, params.DKLen)
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %q", params.PRF)
+		}
+		if params.C <= 0 {
+			return nil, fmt.Errorf("invalid pbkdf2 iterations %d", params.C)
+		}
+		return pbkdf2.Key(password, salt, params.C, params.DKLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf function %q", kdf.Function)
+	}
+}
+
+// eip2335Password normalizes password to NFKD and strips the C0, C1 and Delete control codes.
+func eip2335Password(password string) []byte {
+	var buf bytes.Buffer
+	for _, r := range norm.NFKD.String(password) {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
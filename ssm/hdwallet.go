@@ -0,0 +1,109 @@
+package ssm
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart = uint32(0x80000000)
+
+	// DefaultECDSADerivationPath is the BIP44 path of the first Ethereum account.
+	DefaultECDSADerivationPath = "m/44'/60'/0'/0/0"
+	// DefaultEdDSADerivationPath is the BIP44 path of the first Solana account.
+	DefaultEdDSADerivationPath = "m/44'/501'/0'/0'"
+)
+
+// ParseDerivationPath parses a BIP32 derivation path such as m/44'/60'/0'/0/0.
+//
+// Hardened indexes are marked with a trailing ' or h.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		part = strings.TrimRight(part, "'h")
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path %q", path)
+		}
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// DeriveSecp256k1Key derives the secp256k1 private key of path from a BIP32 seed.
+func DeriveSecp256k1Key(seed []byte, path []uint32) ([]byte, error) {
+	curveN := crypto.S256().Params().N
+	key, chainCode := hmacSHA512([]byte("Bitcoin seed"), seed)
+	if k := new(big.Int).SetBytes(key); k.Sign() == 0 || k.Cmp(curveN) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+	for _, index := range path {
+		var data []byte
+		if index >= HardenedKeyStart {
+			data = append([]byte{0x00}, key...)
+		} else {
+			privateKey, err := crypto.ToECDSA(key)
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&privateKey.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		il, childChainCode := hmacSHA512(chainCode, data)
+		childKey := new(big.Int).SetBytes(il)
+		if childKey.Cmp(curveN) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		childKey.Add(childKey, new(big.Int).SetBytes(key))
+		childKey.Mod(childKey, curveN)
+		if childKey.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		key = math.PaddedBigBytes(childKey, 32)
+		chainCode = childChainCode
+	}
+	return key, nil
+}
+
+// DeriveEd25519Key derives the ed25519 private key of path from a seed following SLIP-10.
+//
+// SLIP-10 only supports hardened derivation for ed25519.
+func DeriveEd25519Key(seed []byte, path []uint32) (ed25519.PrivateKey, error) {
+	key, chainCode := hmacSHA512([]byte("ed25519 seed"), seed)
+	for _, index := range path {
+		if index < HardenedKeyStart {
+			return nil, errors.New("ed25519 only supports hardened derivation")
+		}
+		data := append([]byte{0x00}, key...)
+		data = binary.BigEndian.AppendUint32(data, index)
+		key, chainCode = hmacSHA512(chainCode, data)
+	}
+	return ed25519.NewKeyFromSeed(key), nil
+}
+
+func hmacSHA512(key, data []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
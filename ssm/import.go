@@ -0,0 +1,195 @@
+package ssm
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Supported formats of imported private keys.
+const (
+	// ImportFormatHex is a hex encoded private key.
+	ImportFormatHex = "hex"
+	// ImportFormatWIF is a Bitcoin wallet import format private key.
+	ImportFormatWIF = "wif"
+	// ImportFormatKeystore is an Ethereum keystore V3 JSON file.
+	ImportFormatKeystore = "keystore"
+	// ImportFormatMnemonic is a BIP39 mnemonic.
+	ImportFormatMnemonic = "mnemonic"
+)
+
+// ImportECDSAKey parses an ECDSA private key of the given format.
+//
+// The passphrase decrypts a keystore file, or is the BIP39 passphrase of a mnemonic.
+// The derivation path is only used for mnemonics and defaults to DefaultECDSADerivationPath.
+//
+// Returns:
+// - A string representing the private key in hexadecimal format.
+// - A string representing the uncompressed public key in hexadecimal format.
+// - A string representing the compressed public key in hexadecimal format.
+// - An error if the key is malformed or not on the secp256k1 curve.
+func ImportECDSAKey(format, data, passphrase, path string) (string, string, string, error) {
+	var keyBytes []byte
+	var err error
+	switch format {
+	case ImportFormatHex:
+		keyBytes, err = hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(data), "0x"))
+	case ImportFormatWIF:
+		keyBytes, err = decodeWIF(strings.TrimSpace(data))
+	case ImportFormatKeystore:
+		var key *keystore.Key
+		key, err = keystore.DecryptKey([]byte(data), passphrase)
+		if err == nil {
+			keyBytes = crypto.FromECDSA(key.PrivateKey)
+		}
+	case ImportFormatMnemonic:
+		if path == "" {
+			path = DefaultECDSADerivationPath
+		}
+		var seed []byte
+		seed, err = mnemonicToSeed(data, passphrase)
+		if err == nil {
+			keyBytes, err = deriveFromSeed(seed, path, DeriveSecp256k1Key)
+		}
+	default:
+		return EmptyHexString, EmptyHexString, EmptyHexString, fmt.Errorf("unsupported import format %q", format)
+	}
+	if err != nil {
+		return EmptyHexString, EmptyHexString, EmptyHexString, err
+	}
+
+	privateKey, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return EmptyHexString, EmptyHexString, EmptyHexString, fmt.Errorf("invalid secp256k1 private key: %w", err)
+	}
+	priKeyStr := hex.EncodeToString(crypto.FromECDSA(privateKey))
+	pubKeyStr := hex.EncodeToString(crypto.FromECDSAPub(&privateKey.PublicKey))
+	compressPubkeyStr := hex.EncodeToString(crypto.CompressPubkey(&privateKey.PublicKey))
+	return priKeyStr, pubKeyStr, compressPubkeyStr, nil
+}
+
+// ImportEdDSAKey parses an EdDSA private key of the given format.
+//
+// Hex keys are either a 32 bytes seed or a 64 bytes private key. Mnemonics are
+// derived with SLIP-10, the derivation path defaults to DefaultEdDSADerivationPath.
+//
+// Returns:
+// - A string representing the private key in hexadecimal format.
+// - A string representing the public key in hexadecimal format.
+// - An error if the key is malformed.
+func ImportEdDSAKey(format, data, passphrase, path string) (string, string, error) {
+	var privateKey ed25519.PrivateKey
+	switch format {
+	case ImportFormatHex:
+		keyBytes, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(data), "0x"))
+		if err != nil {
+			return EmptyHexString, EmptyHexString, err
+		}
+		switch len(keyBytes) {
+		case ed25519.SeedSize:
+			privateKey = ed25519.NewKeyFromSeed(keyBytes)
+		case ed25519.PrivateKeySize:
+			privateKey = ed25519.NewKeyFromSeed(keyBytes[:ed25519.SeedSize])
+			if !privateKey.Equal(ed25519.PrivateKey(keyBytes)) {
+				return EmptyHexString, EmptyHexString, errors.New("public key does not match the ed25519 seed")
+			}
+		default:
+			return EmptyHexString, EmptyHexString, fmt.Errorf("invalid ed25519 private key length %d", len(keyBytes))
+		}
+	case ImportFormatMnemonic:
+		if path == "" {
+			path = DefaultEdDSADerivationPath
+		}
+		seed, err := mnemonicToSeed(data, passphrase)
+		if err != nil {
+			return EmptyHexString, EmptyHexString, err
+		}
+		keyBytes, err := deriveFromSeed(seed, path, func(seed []byte, indexes []uint32) ([]byte, error) {
+			return DeriveEd25519Key(seed, indexes)
+		})
+		if err != nil {
+			return EmptyHexString, EmptyHexString, err
+		}
+		privateKey = keyBytes
+	default:
+		return EmptyHexString, EmptyHexString, fmt.Errorf("unsupported import format %q for eddsa", format)
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return hex.EncodeToString(privateKey), hex.EncodeToString(publicKey), nil
+}
+
+// ImportBLSKey parses a BLS private key of the given format.
+//
+// Hex keys are the 32 bytes private key, keystores are EIP-2335 keystores decrypted with
+// the passphrase, as written by the staking deposit tools.
+//
+// Returns:
+// - A string representing the private key in hexadecimal format.
+// - A string representing the compressed public key in hexadecimal format.
+// - A string representing the derivation path recorded in the keystore, if any.
+// - An error if the key is malformed.
+func ImportBLSKey(format, data, passphrase string) (string, string, string, error) {
+	var keyBytes []byte
+	var path string
+	var err error
+	switch format {
+	case ImportFormatHex:
+		keyBytes, err = hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(data), "0x"))
+	case ImportFormatKeystore:
+		keyBytes, path, err = DecryptEIP2335Keystore([]byte(data), passphrase)
+	default:
+		return EmptyHexString, EmptyHexString, "", fmt.Errorf("unsupported import format %q for bls", format)
+	}
+	if err != nil {
+		return EmptyHexString, EmptyHexString, "", err
+	}
+	secretKey, err := ParseBLSPrivateKey(hex.EncodeToString(keyBytes))
+	if err != nil {
+		return EmptyHexString, EmptyHexString, "", err
+	}
+	defer secretKey.Zeroize()
+	return hex.EncodeToString(secretKey.Serialize()), BLSPublicKey(secretKey), path, nil
+}
+
+// decodeWIF decodes a wallet import format private key of mainnet (0x80) or testnet (0xef).
+func decodeWIF(wif string) ([]byte, error) {
+	payload, version, err := base58.CheckDecode(wif)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wif: %w", err)
+	}
+	if version != 0x80 && version != 0xef {
+		return nil, fmt.Errorf("invalid wif version 0x%x", version)
+	}
+	switch {
+	case len(payload) == 32:
+		return payload, nil
+	case len(payload) == 33 && payload[32] == 0x01:
+		return payload[:32], nil
+	default:
+		return nil, errors.New("invalid wif length")
+	}
+}
+
+func mnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	return seed, nil
+}
+
+func deriveFromSeed(seed []byte, path string, derive func([]byte, []uint32) ([]byte, error)) ([]byte, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return derive(seed, indexes)
+}
@@ -0,0 +1,123 @@
+package ssm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestDeriveSecp256k1Key(t *testing.T) {
+	// BIP32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	path, err := ParseDerivationPath("m/0'/1/2'/2/1000000000")
+	assert.NoError(t, err)
+
+	key, err := DeriveSecp256k1Key(seed, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", hex.EncodeToString(key))
+}
+
+func TestDeriveEd25519Key(t *testing.T) {
+	// SLIP-10 ed25519 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	path, err := ParseDerivationPath("m/0h/1h/2h/2h/1000000000h")
+	assert.NoError(t, err)
+
+	key, err := DeriveEd25519Key(seed, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793", hex.EncodeToString(key.Seed()))
+
+	_, err = DeriveEd25519Key(seed, []uint32{0})
+	assert.Error(t, err)
+}
+
+func TestImportECDSAKey(t *testing.T) {
+	privateKey, _, compressPubkey, err := ImportECDSAKey(ImportFormatMnemonic, testMnemonic, "", "")
+	assert.NoError(t, err)
+	pubkeyBytes, _ := hex.DecodeString(compressPubkey)
+	pubkey, _ := crypto.DecompressPubkey(pubkeyBytes)
+	assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", crypto.PubkeyToAddress(*pubkey).Hex())
+
+	hexKey, _, _, err := ImportECDSAKey(ImportFormatHex, "0x"+privateKey, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, hexKey)
+
+	wifKey, _, _, err := ImportECDSAKey(ImportFormatWIF, "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d", wifKey)
+
+	ecdsaKey, _ := crypto.HexToECDSA(privateKey)
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(ecdsaKey.PublicKey),
+		PrivateKey: ecdsaKey,
+	}, "passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	assert.NoError(t, err)
+	keystoreKey, _, _, err := ImportECDSAKey(ImportFormatKeystore, string(keyJSON), "passphrase", "")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, keystoreKey)
+
+	_, _, _, err = ImportECDSAKey(ImportFormatKeystore, string(keyJSON), "wrong", "")
+	assert.Error(t, err)
+	_, _, _, err = ImportECDSAKey(ImportFormatHex, "00", "", "")
+	assert.Error(t, err)
+}
+
+func TestImportEdDSAKey(t *testing.T) {
+	privateKey := "09fa5c99a11f3857dccfede0b9f6ead29bc2f5757b43b336796d64d2cdacf74a39f523de37c1218d28ca467a6e0ea0aa0a603064ab402983829513a0feca0039"
+
+	fullKey, pubKey, err := ImportEdDSAKey(ImportFormatHex, privateKey, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, fullKey)
+	assert.Equal(t, "39f523de37c1218d28ca467a6e0ea0aa0a603064ab402983829513a0feca0039", pubKey)
+
+	seedKey, _, err := ImportEdDSAKey(ImportFormatHex, privateKey[:64], "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, seedKey)
+
+	_, _, err = ImportEdDSAKey(ImportFormatHex, privateKey[:64]+privateKey[:64], "", "")
+	assert.Error(t, err)
+
+	_, _, err = ImportEdDSAKey(ImportFormatMnemonic, testMnemonic, "", "")
+	assert.NoError(t, err)
+	_, _, err = ImportEdDSAKey(ImportFormatWIF, "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", "", "")
+	assert.Error(t, err)
+}
+
+func TestImportBLSKey(t *testing.T) {
+	// the PBKDF2 test vector of EIP-2335
+	keystoreJSON := `{
+		"crypto": {
+			"kdf": {"function": "pbkdf2", "params": {"dklen": 32, "c": 262144, "prf": "hmac-sha256", "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"}, "message": ""},
+			"checksum": {"function": "sha256", "params": {}, "message": "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"},
+			"cipher": {"function": "aes-128-ctr", "params": {"iv": "264daa3f303d7259501c93d997d84fe6"}, "message": "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad"}
+		},
+		"pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+		"path": "m/12381/60/0/0",
+		"uuid": "64625def-3331-4eea-ab6f-782f3ed16a83",
+		"version": 4
+	}`
+	password := "\U0001d531\U0001d522\U0001d530\U0001d531\U0001d52d\U0001d51e\U0001d530\U0001d530\U0001d534\U0001d52c\U0001d52f\U0001d521\U0001f511"
+	privateKey, publicKey, path, err := ImportBLSKey(ImportFormatKeystore, keystoreJSON, password)
+	assert.NoError(t, err)
+	assert.Equal(t, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", privateKey)
+	assert.Equal(t, "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07", publicKey)
+	assert.Equal(t, "m/12381/60/0/0", path)
+
+	_, _, _, err = ImportBLSKey(ImportFormatKeystore, keystoreJSON, "testpassword")
+	assert.Error(t, err)
+
+	hexPrivateKey, hexPublicKey, _, err := ImportBLSKey(ImportFormatHex, "0x"+privateKey, "")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, hexPrivateKey)
+	assert.Equal(t, publicKey, hexPublicKey)
+
+	_, _, _, err = ImportBLSKey(ImportFormatMnemonic, testMnemonic, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+// Package tracing sets up the OpenTelemetry tracing of the signing service.
+package tracing
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// DefaultServiceName is used when Config.ServiceName is not set.
+const DefaultServiceName = "web3-wallet-sign"
+
+type Config struct {
+	// Endpoint is the host:port of the OTLP gRPC collector, empty to export no span
+	Endpoint string
+	// Insecure connects to Endpoint without TLS
+	Insecure bool
+	// SampleRatio is the ratio of the traces started by the service that are sampled,
+	// the traces started by a caller follow the sampling decision of the caller
+	SampleRatio float64
+	ServiceName string
+}
+
+// Setup installs the W3C trace context propagator so that the traces of the callers are continued,
+// and exports the spans to cfg.Endpoint when it is set, otherwise a no-op tracer provider is installed.
+// The returned function flushes the spans not exported yet and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	log.Info("export traces", "endpoint", cfg.Endpoint, "sampleRatio", cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// End records err on span, if any, and ends span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
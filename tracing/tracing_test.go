@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(t.Context(), Config{SampleRatio: 1})
+	assert.NoError(t, err)
+	assert.IsType(t, noop.TracerProvider{}, otel.GetTracerProvider())
+	_, span := otel.Tracer("test").Start(t.Context(), "span")
+	assert.False(t, span.IsRecording())
+	assert.False(t, span.SpanContext().IsValid())
+	span.End()
+	assert.NoError(t, shutdown(t.Context()))
+}
+
+func TestEnd(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	_, span := tracer.Start(t.Context(), "ok")
+	End(span, nil)
+	_, span = tracer.Start(t.Context(), "failed")
+	End(span, errors.New("key not found"))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Empty(t, spans[0].Events)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, "key not found", spans[1].Status.Description)
+	assert.Len(t, spans[1].Events, 1)
+}